@@ -0,0 +1,78 @@
+// Command replay runs a captured request through the proxy routing and transport with recorded upstream responses,
+// to reproduce production incidents offline. The fixture is a JSON file:
+//
+//	{"request": "<request body>", "responses": [{"status": 200, "body": "<response body>"}, {"error": "<transport error>"}]}
+//
+// A payload captured by /debug/payloads is a fixture too, its status and response are the single recorded response.
+// The classification of the request is printed as JSON
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/proxy/chains/solana"
+	"aura-proxy/internal/proxy/config"
+)
+
+type flags struct {
+	logLevel string
+	envFile  string
+	fixture  string
+}
+
+// Setup flags
+func getFlags() (f flags) {
+	flag.StringVar(&f.logLevel, "log", "error", "log level [debug|info|warn|error|crit]")
+	flag.StringVar(&f.envFile, "envFile", "", "path to .env file with the transport settings (optional, defaults if empty)")
+	flag.StringVar(&f.fixture, "fixture", "", "path to the fixture file")
+	flag.Parse()
+
+	return
+}
+
+func main() {
+	f := getFlags()
+	err := log.Setup(f.logLevel)
+	if err != nil {
+		log.Logger.Proxy.Fatalf("Log setup: %s", err)
+	}
+	if f.fixture == "" {
+		log.Logger.Proxy.Fatalf("-fixture is required")
+	}
+
+	// the defaults of the transport settings apply without an env file, as in production
+	proxyCfg, err := configtypes.Defaults[configtypes.ProxyConfig]()
+	if err != nil {
+		log.Logger.Proxy.Fatalf("Config defaults: %s", err)
+	}
+	if f.envFile != "" {
+		cfg, err := configtypes.LoadFile[config.Config](f.envFile)
+		if err != nil {
+			log.Logger.Proxy.Fatalf("Config: %s", err)
+		}
+		proxyCfg = cfg.Proxy
+	}
+
+	data, err := os.ReadFile(f.fixture)
+	if err != nil {
+		log.Logger.Proxy.Fatalf("Fixture: %s", err)
+	}
+	var fixture solana.ReplayFixture
+	if err = json.Unmarshal(data, &fixture); err != nil {
+		log.Logger.Proxy.Fatalf("Fixture: %s", err)
+	}
+
+	res, err := solana.Replay(&proxyCfg, fixture)
+	if err != nil {
+		log.Logger.Proxy.Fatalf("Replay: %s", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(res); err != nil {
+		log.Logger.Proxy.Fatalf("Output: %s", err)
+	}
+}
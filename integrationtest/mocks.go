@@ -1,6 +1,8 @@
 package integrationtest
 
 import (
+	"context"
+
 	echoUtil "aura-proxy/internal/pkg/util/echo"
 
 	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
@@ -12,6 +14,8 @@ type testStatCollector struct{}
 
 func (t *testStatCollector) Add(s *auraProto.Stat) {}
 
+func (t *testStatCollector) Flush(ctx context.Context) error { return nil }
+
 // IRequestCounter dummy
 type testRequestCounter struct{}
 
@@ -19,6 +23,8 @@ func (t *testRequestCounter) IncUserRequests(user *auraProto.UserWithTokens, cur
 	// no-op
 }
 
+func (t *testRequestCounter) Flush(ctx context.Context) error { return nil }
+
 // testTokenChecker implements the ITokenChecker interface.
 type testTokenChecker struct{}
 
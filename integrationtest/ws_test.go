@@ -41,8 +41,9 @@ func TestProxyWebSocketIntegration(t *testing.T) {
 	// the upstream address should be using http protocol because the reverse proxy will use HTTP to connect to the upstream and upgrade to WS.
 	cfg := config.Config{
 		Proxy: configtypes.ProxyConfig{
-			Port:      44999, // local test port for proxy
-			IsMainnet: true,
+			Port:             44999, // local test port for proxy
+			IsMainnet:        true,
+			WSMaxConnections: 5,
 
 			// For Solana:
 			Solana: configtypes.SolanaConfig{
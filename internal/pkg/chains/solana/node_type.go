@@ -22,6 +22,28 @@ const (
 	//fullWebsocketNode  = ""
 )
 
+// nodeTypeRanks orders node types by capabilities, each type supports everything of the lower ones
+var nodeTypeRanks = map[string]int{
+	basicSolanaNode:    1,
+	extendedSolanaNode: 2,
+	ArchiveSolanaNode:  3,
+}
+
+func IsKnownNodeType(name string) bool {
+	_, ok := nodeTypeRanks[name]
+	return ok
+}
+
+// IsAtLeast checks if the node type is the same as or more capable than minType
+func (n NodeType) IsAtLeast(minType string) (bool, error) {
+	minRank, ok := nodeTypeRanks[minType]
+	if !ok {
+		return false, fmt.Errorf("invalid node type: %s", minType)
+	}
+
+	return nodeTypeRanks[n.Name] >= minRank, nil
+}
+
 func (n NodeType) IsSupportMethod(method string) (bool, error) {
 	switch method {
 	case GetAccountInfo, GetBalance, GetClusterNodes, GetEpochInfo, GetEpochSchedule, GetFeeForMessage, GetGenesisHash, GetHealth, GetHighestSnapshotSlot, GetIdentity,
@@ -39,3 +61,18 @@ func (n NodeType) IsSupportMethod(method string) (bool, error) {
 		return false, fmt.Errorf("invalid requested method: %s", method)
 	}
 }
+
+// SupportedMethods precomputes IsSupportMethod decisions for all known methods.
+// Methods for which IsSupportMethod returns an error are not included.
+func (n NodeType) SupportedMethods() map[string]bool {
+	res := make(map[string]bool, len(MethodList))
+	for method := range MethodList {
+		supported, err := n.IsSupportMethod(method)
+		if err != nil {
+			continue
+		}
+		res[method] = supported
+	}
+
+	return res
+}
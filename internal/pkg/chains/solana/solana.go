@@ -195,3 +195,35 @@ func TxRelatedMethod(method string) bool {
 func BlockRelatedMethod(method string) bool {
 	return method == GetBlock || method == GetBlockTime || method == GetBlockCommitment || method == GetConfirmedBlock
 }
+
+// Commitment levels of the request config, from the freshest to the most settled
+const (
+	CommitmentProcessed = "processed"
+	CommitmentConfirmed = "confirmed"
+	CommitmentFinalized = "finalized"
+)
+
+// CommitmentFreshness ranks commitments by the required state freshness, 0 for unknown or not specified
+func CommitmentFreshness(commitment string) int {
+	switch commitment {
+	case CommitmentProcessed:
+		return 3
+	case CommitmentConfirmed:
+		return 2
+	case CommitmentFinalized:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SlotSensitiveMethod reports whether the method answer depends on the node being at the cluster tip
+func SlotSensitiveMethod(method string) bool {
+	switch method {
+	case GetHealth, GetSlot, GetBlockHeight, GetEpochInfo, GetLatestBlockhash,
+		SendTransaction, SimulateTransaction:
+		return true
+	}
+
+	return TxRelatedMethod(method) || BlockRelatedMethod(method)
+}
@@ -4,48 +4,62 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
 
 	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/metrics"
 	"aura-proxy/internal/pkg/util"
 )
 
 const (
 	flushAmount = 1000
+
+	// dropped stats metric reasons
+	dropReasonQueueFull   = "queue_full"
+	dropReasonFlushFailed = "flush_failed"
 )
 
 type (
 	collectorPossibleTypes interface {
 		*auraProto.Stat
 	}
+	// Collector caches entries and flushes them in batches by interval or when flushAmount is reached.
+	// Batches are flushed by background workers. When the backend is slow and the queue is full, batches are dropped,
+	// so memory is bounded by (1 + queueSize + workers) * flushAmount entries.
 	Collector[T collectorPossibleTypes] struct {
 		auraAPI       auraProto.AuraClient
 		cache         []T
 		mx            sync.Mutex
 		flushInterval time.Duration
+		batches       chan []T
 	}
 )
 
-func NewCollector[T collectorPossibleTypes](ctx context.Context, flushInterval time.Duration, auraAPI auraProto.AuraClient) (c *Collector[T], err error) {
+func NewCollector[T collectorPossibleTypes](ctx context.Context, flushInterval time.Duration, workers, queueSize uint64, auraAPI auraProto.AuraClient) (c *Collector[T], err error) {
 	if auraAPI == nil {
 		return nil, errors.New("empty auraAPI")
 	}
+	if workers == 0 {
+		return nil, errors.New("at least one flush worker is required")
+	}
 
 	c = &Collector[T]{
 		flushInterval: flushInterval,
 		cache:         make([]T, 0, flushAmount),
 		auraAPI:       auraAPI,
+		batches:       make(chan []T, queueSize),
 	}
 
-	err = util.AsyncRunWithInterval(ctx, nil, flushInterval, false, true, func(ctx context.Context) error {
-		err := c.flushData(ctx)
-		if err != nil {
-			log.Logger.Collector.Errorf("Collector: flushData: %s", err)
-		}
+	for i := uint64(0); i < workers; i++ {
+		go c.flushWorker(ctx)
+	}
 
+	err = util.AsyncRunWithInterval(ctx, nil, flushInterval, false, true, func(_ context.Context) error {
+		c.enqueue(c.getCachedEntries())
 		return nil
 	})
 	if err != nil {
@@ -55,12 +69,35 @@ func NewCollector[T collectorPossibleTypes](ctx context.Context, flushInterval t
 	return
 }
 
-func (c *Collector[T]) flushData(ctx context.Context) error {
-	entries := c.getCachedEntries()
+func (c *Collector[T]) flushWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entries := <-c.batches:
+			if err := c.flushData(ctx, entries); err != nil {
+				metrics.AddDroppedStats(dropReasonFlushFailed, len(entries))
+				log.Logger.Collector.Errorf("Collector: flushData: %s", err)
+			}
+		}
+	}
+}
+
+// enqueue passes entries to the flush workers without blocking, entries are dropped if the queue is full
+func (c *Collector[T]) enqueue(entries []T) {
 	if len(entries) == 0 {
-		return nil
+		return
 	}
 
+	select {
+	case c.batches <- entries:
+	default:
+		metrics.AddDroppedStats(dropReasonQueueFull, len(entries))
+		log.Logger.Collector.Warnf("Collector: flush queue is full, dropped %d entries", len(entries))
+	}
+}
+
+func (c *Collector[T]) flushData(ctx context.Context, entries []T) error {
 	var (
 		err     error
 		caller  string
@@ -82,10 +119,43 @@ func (c *Collector[T]) flushData(ctx context.Context) error {
 	return nil
 }
 
+// Flush synchronously sends cached entries and batches waiting in the queue. It's called on shutdown,
+// when the background workers may be already stopped
+func (c *Collector[T]) Flush(ctx context.Context) (err error) {
+	entries := c.getCachedEntries()
+	for pending := true; pending; {
+		select {
+		case batch := <-c.batches:
+			entries = append(entries, batch...)
+		default:
+			pending = false
+		}
+	}
+
+	for batch := range slices.Chunk(entries, flushAmount) {
+		if err = c.flushData(ctx, batch); err != nil {
+			metrics.AddDroppedStats(dropReasonFlushFailed, len(batch))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add caches the entry and flushes the cache without waiting for the interval when it reaches flushAmount.
+// The cache is swapped under the lock by both flushes, so every entry is sent once
 func (c *Collector[collectorPossibleTypes]) Add(s collectorPossibleTypes) {
+	var full []collectorPossibleTypes
+
 	c.mx.Lock()
 	c.cache = append(c.cache, s)
+	if len(c.cache) >= flushAmount {
+		full = c.cache
+		c.cache = make([]collectorPossibleTypes, 0, flushAmount)
+	}
 	c.mx.Unlock()
+
+	c.enqueue(full)
 }
 func (c *Collector[collectorPossibleTypes]) getCachedEntries() (s []collectorPossibleTypes) {
 	c.mx.Lock()
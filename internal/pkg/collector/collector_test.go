@@ -0,0 +1,238 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// slowAuraClient blocks BatchInsertStats until released. If sizes is set, the size of every batch is sent to it
+type slowAuraClient struct {
+	auraProto.AuraClient
+	release  chan struct{}
+	sizes    chan int
+	calls    atomic.Int64
+	inserted atomic.Int64
+}
+
+func (s *slowAuraClient) BatchInsertStats(ctx context.Context, in *auraProto.BatchInsertStatsReq, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	s.calls.Add(1)
+	if s.sizes != nil {
+		s.sizes <- len(in.GetStats())
+	}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.inserted.Add(int64(len(in.GetStats())))
+
+	return &emptypb.Empty{}, nil
+}
+
+func droppedStats(t *testing.T, reason string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != "dropped_stats_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if len(m.GetLabel()) == 1 && m.GetLabel()[0].GetValue() == reason {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestCollector_SlowBackend(t *testing.T) {
+	const (
+		workers   = 2
+		queueSize = 3
+		batches   = 50
+		total     = batches * flushAmount
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &slowAuraClient{release: make(chan struct{})}
+	c, err := NewCollector[*auraProto.Stat](ctx, time.Hour, workers, queueSize, client)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+
+	droppedBefore := droppedStats(t, dropReasonQueueFull)
+	for i := 0; i < batches; i++ {
+		for j := 0; j < flushAmount; j++ {
+			c.Add(&auraProto.Stat{})
+		}
+		c.enqueue(c.getCachedEntries()) // as the interval flush does
+	}
+
+	// wait for the workers to take their batches
+	deadline := time.Now().Add(time.Second)
+	for client.calls.Load() < workers && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if calls := client.calls.Load(); calls != workers {
+		t.Fatalf("Expected %d concurrent flushes, got %d", workers, calls)
+	}
+
+	// pending entries are bounded by the queue and the in-flight batches
+	if queued := len(c.batches) * flushAmount; queued != queueSize*flushAmount {
+		t.Errorf("Expected full queue, got %d entries", queued)
+	}
+
+	dropped := droppedStats(t, dropReasonQueueFull) - droppedBefore
+	if expected := float64(total - (queueSize+workers)*flushAmount); dropped != expected {
+		t.Errorf("Expected %v dropped entries, got %v", expected, dropped)
+	}
+
+	// the backend recovers: queued batches are delivered
+	close(client.release)
+	deadline = time.Now().Add(time.Second)
+	for client.inserted.Load() < (queueSize+workers)*flushAmount && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if inserted := client.inserted.Load(); inserted != (queueSize+workers)*flushAmount {
+		t.Errorf("Expected %d inserted entries, got %d", (queueSize+workers)*flushAmount, inserted)
+	}
+}
+
+func TestCollector_FlushByInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &slowAuraClient{release: make(chan struct{})}
+	close(client.release)
+	c, err := NewCollector[*auraProto.Stat](ctx, 10*time.Millisecond, 1, 1, client)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		c.Add(&auraProto.Stat{})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for client.inserted.Load() < 10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if inserted := client.inserted.Load(); inserted != 10 {
+		t.Errorf("Expected 10 inserted entries, got %d", inserted)
+	}
+
+	if _, err = NewCollector[*auraProto.Stat](ctx, time.Second, 0, 1, client); err == nil {
+		t.Error("Expected error without flush workers")
+	}
+}
+
+func TestCollector_FlushBySize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &slowAuraClient{release: make(chan struct{}), sizes: make(chan int, 1)}
+	close(client.release)
+	// the interval flush never fires, so only the size flush can send the entries
+	c, err := NewCollector[*auraProto.Stat](ctx, time.Hour, 1, 10, client)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+
+	// one entry less doesn't trigger the flush
+	for i := 0; i < flushAmount-1; i++ {
+		c.Add(&auraProto.Stat{})
+	}
+	if len(c.batches) != 0 || client.calls.Load() != 0 {
+		t.Fatal("Expected no flush before flushAmount")
+	}
+	c.mx.Lock()
+	cached := len(c.cache)
+	c.mx.Unlock()
+	if cached != flushAmount-1 {
+		t.Fatalf("Expected %d cached entries, got %d", flushAmount-1, cached)
+	}
+
+	// flushAmount entries are flushed without waiting for the interval
+	c.Add(&auraProto.Stat{})
+	select {
+	case size := <-client.sizes:
+		if size != flushAmount {
+			t.Fatalf("Expected a flush of %d entries, got %d", flushAmount, size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a flush when flushAmount is reached")
+	}
+
+	// the interval flush sends only the entries added after it
+	c.Add(&auraProto.Stat{})
+	c.enqueue(c.getCachedEntries()) // as the interval flush does
+	select {
+	case size := <-client.sizes:
+		if size != 1 {
+			t.Errorf("Expected a flush of 1 entry, got %d", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the interval flush")
+	}
+}
+
+func TestCollector_Flush(t *testing.T) {
+	client := &slowAuraClient{release: make(chan struct{})}
+	close(client.release)
+	// no workers, as they are stopped on shutdown
+	c := &Collector[*auraProto.Stat]{
+		auraAPI: client,
+		cache:   make([]*auraProto.Stat, 0, flushAmount),
+		batches: make(chan []*auraProto.Stat, 2),
+	}
+
+	const total = flushAmount + flushAmount/2 // a queued batch and cached entries
+	for i := 0; i < total; i++ {
+		c.Add(&auraProto.Stat{})
+	}
+	if len(c.batches) != 1 {
+		t.Fatalf("Expected 1 queued batch, got %d", len(c.batches))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := client.inserted.Load(); got != total {
+		t.Errorf("Expected %d flushed entries, got %d", total, got)
+	}
+	if got := client.calls.Load(); got != 2 {
+		t.Errorf("Expected entries to be sent in batches of %d, got %d calls", flushAmount, got)
+	}
+	if len(c.batches) != 0 || len(c.getCachedEntries()) != 0 {
+		t.Error("Expected the queue and the cache to be empty")
+	}
+}
+
+func TestCollector_FlushTimeout(t *testing.T) {
+	client := &slowAuraClient{release: make(chan struct{})}
+	c := &Collector[*auraProto.Stat]{auraAPI: client, cache: []*auraProto.Stat{{}}, batches: make(chan []*auraProto.Stat)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	droppedBefore := droppedStats(t, dropReasonFlushFailed)
+	if err := c.Flush(ctx); err == nil {
+		t.Fatal("Expected an error when the backend doesn't respond in time")
+	}
+	if got := droppedStats(t, dropReasonFlushFailed) - droppedBefore; got != 1 {
+		t.Errorf("Expected 1 dropped entry, got %v", got)
+	}
+}
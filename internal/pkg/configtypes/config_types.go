@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -11,38 +14,300 @@ import (
 	"aura-proxy/internal/pkg/chains/solana"
 )
 
+// ProxyConfig.NodeBehindPolicy values
+const (
+	// jail a catching up node only for methods which answers depend on the node being at the tip
+	NodeBehindPolicySlotSensitive = "slot_sensitive"
+	// jail a catching up node for all methods
+	NodeBehindPolicyFull = "full"
+)
+
+// ProxyConfig.ResponseNormalizations values
+const (
+	// trim leading and trailing spaces of string values
+	ResponseNormalizationTrim = "trim"
+	// lowercase hex string values (optionally 0x prefixed), other values (e.g. base58 addresses) are kept
+	ResponseNormalizationLowerHex = "lower_hex"
+)
+
+// SelectionStrategy is the algorithm used to select a target of a method
+type SelectionStrategy string
+
+const (
+	// random selection proportional to the target weights (default)
+	SelectionStrategyProbabilistic SelectionStrategy = "probabilistic"
+	SelectionStrategyRoundRobin    SelectionStrategy = "round_robin"
+	// the target with the lowest p95 response time of the method
+	SelectionStrategyLeastLatency SelectionStrategy = "least_latency"
+	// the faster one of two random targets
+	SelectionStrategyP2C SelectionStrategy = "p2c"
+	// the same target for the same request key (account, signature, asset id etc.)
+	SelectionStrategyConsistentHash SelectionStrategy = "consistent_hash"
+	// the lowest weighted sum of the normalized response time and the error rate, see CompositeWeights
+	SelectionStrategyComposite SelectionStrategy = "composite"
+)
+
+func (s SelectionStrategy) IsKnown() bool {
+	switch s {
+	case SelectionStrategyProbabilistic, SelectionStrategyRoundRobin, SelectionStrategyLeastLatency,
+		SelectionStrategyP2C, SelectionStrategyConsistentHash, SelectionStrategyComposite:
+		return true
+	}
+
+	return false
+}
+
 // struct field names are used for env variable names. Edit with care
 type (
 	ProxyConfig struct {
 		CertFile     string `required:"false" split_words:"true"`
 		AuraGRPCHost string `envconfig:"PROXY_AURA_GRPC_HOST" required:"true" split_words:"true"`
 
+		// Concurrent stats flushes and batches waiting for them. Stats are dropped when the queue is full
+		StatsFlushWorkers   uint64 `required:"false" default:"1" split_words:"true"`
+		StatsFlushQueueSize uint64 `required:"false" default:"10" split_words:"true"`
+		// Fraction of successful requests saved to stats, errored requests and requests which used credits are always saved
+		StatsSampleRate float64 `required:"false" default:"1" split_words:"true"`
+		// Users kept in the request counter before a forced flush, the oldest are evicted during long aura-api outages
+		RequestCounterMaxUsers uint64 `required:"false" default:"100000" split_words:"true"`
+		// Fraction of the user cache TTL and the subscriptions refresh interval added randomly, so users cached together
+		// and proxy instances started together don't refresh at once. 0 disables it
+		TokenRefreshJitter float64 `required:"false" default:"0.2" split_words:"true"`
+		// Credits charged per request when the subscription pricing is unavailable (e.g. an unknown subscription id)
+		DefaultRequestCost uint64 `required:"false" default:"10" split_words:"true"`
+
 		Solana  SolanaConfig `envconfig:"PROXY_SOLANA_CONFIG" required:"true" split_words:"true"`
 		Eclipse SolanaConfig `envconfig:"PROXY_ECLIPSE_CONFIG" required:"false" split_words:"true"`
 		Chains  Chains       `required:"false" split_words:"true"`
+		// Additional Solana-compatible chains by chain name, each served on its own host names
+		SolanaChains SolanaChains `required:"false" split_words:"true"`
 
 		Port        uint64 `required:"true" split_words:"true"`
 		MetricsPort uint64 `required:"false" split_words:"true"`
+		// Prefix of all proxy metric names (e.g. "eclipse"), for dashboards of separate deployments. Metrics are
+		// distinguished by the chain label only if empty
+		MetricsNamespace string `required:"false" split_words:"true"`
+		// Deadline of a proxied request, independent of the write timeout. The metrics server write timeout, the proxy
+		// server has none as WebSocket connections are long-lived
+		RequestTimeout time.Duration `required:"false" default:"120s" split_words:"true"`
+		WriteTimeout   time.Duration `required:"false" default:"121s" split_words:"true"`
+
+		// Bearer token of the /debug endpoints on the metrics server. They are disabled when empty
+		AdminToken string `required:"false" split_words:"true"`
+		// Hide paths and query params (usually containing provider API keys) of target URLs in /debug/targets
+		DebugMaskTargetURLs bool `required:"false" default:"true" split_words:"true"`
+		// Capture of request and response bodies served by /debug/payloads, for a sampled fraction or the listed API tokens.
+		// Requires AdminToken. Bodies over the max size are dropped, values of the redact fields are replaced at any nesting level
+		DebugCaptureSampleRate   float64  `required:"false" split_words:"true"`
+		DebugCaptureTokens       []string `required:"false" split_words:"true"`
+		DebugCaptureMaxBodySize  uint     `required:"false" default:"65536" split_words:"true"`
+		DebugCaptureMaxPerSecond uint     `required:"false" default:"10" split_words:"true"`
+		DebugCaptureCapacity     uint     `required:"false" default:"1000" split_words:"true"`
+		DebugCaptureRedactFields []string `required:"false" split_words:"true"`
+		// API tokens which successful responses (every successful element of batches) get the _aura extension field
+		// ({"_aura":{"attempts":N,"provider":"...","responseTimeMs":N}} next to the result). Disabled if empty
+		DebugResponseExtensionTokens []string `required:"false" split_words:"true"`
+
+		// Methods rejected for all chains. Can be changed at runtime via the metrics server admin endpoint
+		DeniedMethods []string `required:"false" split_words:"true"`
+		// Allow getProgramAccounts in batch requests, the whole batch is routed through the GPA pool. Rejected by default
+		AllowGPABatchRequests bool `required:"false" split_words:"true"`
+		// Batches which elements still fail after the retries return the succeeded elements with error elements for the rest,
+		// instead of the last failed response
+		PartialBatchResults bool `required:"false" split_words:"true"`
+
+		// Idempotent methods served over GET with the method and params (a JSON array) query parameters, e.g. getSlot
+		GetMethods []string `required:"false" split_words:"true"`
+
+		// Methods which upstream responses are copied to the client without buffering (e.g. getBlock). Only responses
+		// shorter than 4KB (e.g. RPC errors) are analyzed
+		StreamedMethods []string `required:"false" split_words:"true"`
+		// Concurrent single requests of these methods (e.g. getAccountInfo) to the same target are sent as one batch,
+		// collected for up to the window or until the max size. 0 window disables it
+		MicroBatchMethods []string      `required:"false" split_words:"true"`
+		MicroBatchWindow  time.Duration `required:"false" split_words:"true"`
+		MicroBatchMaxSize uint          `required:"false" default:"20" split_words:"true"`
+		// Methods which successful single responses get the slot of the serving target in an extension field
+		// ({"proxyContext":{"slot":N}} next to the result), so clients can correlate results with a slot
+		SlotAnnotatedMethods []string `required:"false" split_words:"true"`
+		// Normalizations of string fields of results by method and field name at any nesting level, e.g.
+		// "getAsset.data_hash:lower_hex,getAsset.json_uri:trim" (ResponseNormalization* values). Single responses only
+		ResponseNormalizations map[string]string `required:"false" split_words:"true"`
+		// Max staleness per method (e.g. "getTokenSupply:30s,getEpochInfo:10s") of the last successful single responses
+		// served with the X-Aura-Stale-Age header after all targets failed, instead of an error. Never served while upstreams respond
+		StaleOnErrorMethods map[string]time.Duration `required:"false" split_words:"true"`
+		// Param holding the key used for target affinity and stats per method, instead of the built-in one
+		// (e.g. "getFoo:1,searchAssets:ownerAddress"). A number is the index of array params, otherwise a field of object params
+		RoutingKeyParams map[string]string `required:"false" split_words:"true"`
+
+		// User-Agent header of upstream requests. Default: aura-proxy/<version> (<service name>-<level>)
+		UpstreamUserAgent string `required:"false" split_words:"true"`
+		// Return the lowest remaining request budget of the X-RateLimit-Remaining (and alike) headers of the upstream
+		// responses of a request in the X-Aura-Upstream-RateLimit-Remaining header
+		UpstreamRateLimitHeader bool `required:"false" split_words:"true"`
+		// Upstream response headers (e.g. provider-identifying or caching ones) removed before returning to the client
+		StripResponseHeaders []string `required:"false" split_words:"true"`
+		// Gzip responses for clients accepting it, smaller responses than the min length aren't compressed
+		ResponseCompression          bool `required:"false" split_words:"true"`
+		ResponseCompressionMinLength uint `required:"false" default:"1024" split_words:"true"`
+		// Return X-Credits-Used and X-Credits-Remaining headers, for the listed tiers (token types, e.g. "basic,pro") or all if empty
+		CreditHeaders      bool     `required:"false" split_words:"true"`
+		CreditHeadersTiers []string `required:"false" split_words:"true"`
+		// Tiers (token types, e.g. "unlimited") allowed to route a request to a provider named in the X-Aura-Provider header,
+		// bypassing the balancer (debugging and QA). Disabled if empty
+		ProviderPinTiers []string `required:"false" split_words:"true"`
+		// Secret signing provider affinity cookies. When set, responses carry a cookie with the serving provider, which targets
+		// are tried first by the next requests of the client (browser sessions) until it fails. Disabled if empty
+		ProviderAffinitySecret string        `required:"false" split_words:"true"`
+		ProviderAffinityTTL    time.Duration `required:"false" default:"30m" split_words:"true"`
+
+		// 0 disables the in-flight requests limit
+		MaxConcurrentRequests uint64        `required:"false" split_words:"true"`
+		RequestQueueSize      uint64        `required:"false" split_words:"true"`
+		RequestQueueTimeout   time.Duration `required:"false" default:"100ms" split_words:"true"`
+		// In-flight requests limits by request type (DAS, RPC, GPA, SWQOS), e.g. "GPA:20,DAS:50", so slow requests can't take
+		// all slots of cheap reads. 0 disables a limit. They are applied before MaxConcurrentRequests and share its queue settings
+		RequestTypeMaxConcurrentRequests map[string]uint64 `required:"false" split_words:"true"`
+
+		// Keep-alive connections opened to every target at startup, so first user requests skip the TLS handshake.
+		// Capped at 2, the idle connections limit per host. 0 disables it
+		UpstreamWarmupConnections uint `required:"false" split_words:"true"`
+
+		// Max request body size in bytes. Limits by tier (token type, e.g. "basic:262144,unlimited:10485760") override it
+		RequestBodyLimit      uint64            `required:"false" default:"1048576" split_words:"true"`
+		TierRequestBodyLimits map[string]uint64 `required:"false" split_words:"true"`
+		// Max in-flight requests per API token, over it requests get 429 whatever the rate. Limits by tier (token type,
+		// e.g. "basic:5,unlimited:0") override it. 0 disables a limit
+		TokenMaxConcurrentRequests     uint64            `required:"false" split_words:"true"`
+		TierTokenMaxConcurrentRequests map[string]uint64 `required:"false" split_words:"true"`
+
+		// Concurrent WebSocket connections per user (per IP without a token)
+		WSMaxConnections uint64 `required:"false" default:"5" split_words:"true"`
+		// Connection limits by subscription name (e.g. "pro:10,enterprise:30"), WSMaxConnections for other subscriptions
+		WSSubscriptionMaxConnections map[string]uint64 `required:"false" split_words:"true"`
 
 		IsMainnet bool `required:"true" default:"true" split_words:"true"`
+		// Deployment region. Targets of providers of the same region are tried first, others after they are exhausted
+		Region string `required:"false" split_words:"true"`
+
+		// Max weight multiplier of targets with a full consecutive success streak (capped at 3). 0 disables it
+		SuccessStreakBoost float64 `required:"false" split_words:"true"`
+		// Weight divisor step of targets with an error streak of a method (errors since their last success streak): the weight
+		// is divided by 1 + penalty * streak, so degrading targets get less traffic between short jails. 0 disables it
+		ErrorStreakPenalty float64 `required:"false" split_words:"true"`
+		// Period after a target is added during which its success and error streaks don't change its weight, failures still jail it. 0 disables it
+		TargetWarmUpPeriod time.Duration `required:"false" split_words:"true"`
+		// Last successful response times per method of a target averaged for speed tokens. 0 means the default.
+		// least_latency, p2c and composite rank by the p95 of the last 100 response times
+		ResponseTimeHistoryLength uint `required:"false" default:"10" split_words:"true"`
+		// Targets which p95 response time of a method is over the factor (e.g. 3) times the median of the method targets
+		// get their weight multiplied by the weight factor until they recover, evaluated every interval. Probabilistic
+		// method balancers only, with at least 3 targets with response times. Must be over 1, 0 disables it
+		SlowTargetLatencyFactor float64       `required:"false" split_words:"true"`
+		SlowTargetWeightFactor  float64       `required:"false" default:"0.25" split_words:"true"`
+		SlowTargetCheckInterval time.Duration `required:"false" default:"30s" split_words:"true"`
+		// Methods jailed on a catching up (behind) node: slot_sensitive or full
+		NodeBehindPolicy string `required:"false" default:"slot_sensitive" split_words:"true"`
+		// Min targets per method left available by jailing, the least failing jailed targets are released below it. 0 disables it
+		MinAvailableTargets uint `required:"false" split_words:"true"`
+		// Skip all targets of a provider for the rest of the request after one of them responds 429 (provider-wide rate limit)
+		ExcludeRateLimitedProviders bool `required:"false" split_words:"true"`
+		// Attempts and time spent on targets of one provider in a request. Targets of a provider out of its budget aren't
+		// tried again in the request, so a slow provider can't take the whole request deadline. 0 disables a limit
+		ProviderMaxAttempts uint          `required:"false" split_words:"true"`
+		ProviderTimeBudget  time.Duration `required:"false" split_words:"true"`
+		// Fixed jail time by upstream status code (e.g. "502:1s,504:0s"), instead of the jail escalating with errors.
+		// For gateway errors in front of healthy nodes. The jail has a second granularity, 0 only retries on another target
+		UpstreamStatusJailTimes map[int]time.Duration `required:"false" split_words:"true"`
+		// Node request timeouts per method (e.g. "getSlot:2s,getBlock:0s") over the defaults, 5s for cheap methods like getSlot
+		// and getHealth. 0 removes the limit, the global upstream timeout is always applied
+		MethodTimeouts map[string]time.Duration `required:"false" split_words:"true"`
+		// Retry DAS responses without an error or a result (or items of paged methods), e.g. truncated ones
+		DASResponseValidation bool `required:"false" split_words:"true"`
+		// Targets queried concurrently (4 at once) for getClusterNodes, which nodes are merged and deduped by pubkey (gossip
+		// views differ per node). Each query counts as an attempt. 0 or 1 disables it
+		ClusterNodesAggregationTargets uint `required:"false" split_words:"true"`
+		// Try the last target which served a method successfully first, the balancer is used after it fails (connection reuse)
+		StickyTargets bool `required:"false" split_words:"true"`
+		// Max slots a target may lag behind the freshest one to serve processed commitment requests. Slots are observed
+		// from processed commitment responses and expire after 30s, so excluded targets are probed again. 0 disables it
+		CommitmentMaxSlotLag uint64 `required:"false" split_words:"true"`
+		// Max slots a target may lag behind the freshest one to serve any request. 0 disables it
+		MaxSlotLag uint64 `required:"false" split_words:"true"`
+		// Retries of requests failed with -32016 (min context slot not reached) on targets ahead of the failed one by their
+		// tracked slots, the error is returned after them. 0 handles it as any other node error
+		MinContextSlotRetries uint `required:"false" default:"2" split_words:"true"`
+		// getHealth is answered by the proxy with a structured status, e.g. {"result":"behind","context":{"availableTargets":N,
+		// "slotLag":L}}, while fewer getHealth targets aren't jailed ("degraded") or the freshest available one lags more
+		// slots behind the freshest target ("behind"). 0 disables a condition
+		DegradedHealthMinTargets uint   `required:"false" split_words:"true"`
+		DegradedHealthMaxSlotLag uint64 `required:"false" split_words:"true"`
+		// Max slot lag per method (e.g. "getLatestBlockhash:5,getBlock:1000") over MaxSlotLag, 0 removes the limit of a method
+		MethodMaxSlotLag map[string]uint64 `required:"false" split_words:"true"`
+		// Max provider names in the log of a request which exhausted all targets, the rest is logged as "+N more"
+		FailedProvidersLogLimit uint `required:"false" default:"5" split_words:"true"`
+		// getProgramAccounts params limits rejected with invalid params, 0 disables a limit. Memcmp bytes are limited by the encoded length
+		GPAMaxFilters         uint   `required:"false" split_words:"true"`
+		GPAMaxMemcmpBytes     uint   `required:"false" split_words:"true"`
+		GPAMaxDataSliceLength uint64 `required:"false" split_words:"true"`
+
+		// Debug: seed target selection from the request id, so the target sequence of a request is reproducible
+		DebugSeededRouting bool `required:"false" split_words:"true"`
 	}
 	SolanaConfig struct {
+		// Request hosts (e.g. "aura-sonic-mainnet.metaplex.com") routed to the chain. Default: the built-in hosts of
+		// Solana and Eclipse, required for SolanaChains
+		HostNames []string `json:"hostNames,omitempty"`
+
 		// Legacy configuration (for backward compatibility)
 		DasAPINodes     SolanaNodes `json:"dasAPINodes"`
 		BasicRouteNodes SolanaNodes `json:"basicRouteNodes"`
 		WSHostNodes     SolanaNodes `json:"WSHostNodes"`
+		GPANodes        SolanaNodes `json:"gpaNodes"`
+
+		// Last resort public RPC used after all partner targets are exhausted. Disabled when empty
+		PublicFallbackURL *WrappedURL `json:"publicFallbackURL,omitempty"`
 
 		// Method groups shared across providers
 		MethodGroups []MethodGroupConfig `json:"methodGroups,omitempty"`
 
+		// Minimum node type per method (e.g. "getSignaturesForAddress": "archive_node"), less capable targets are excluded
+		MethodNodeRequirements map[string]string `json:"methodNodeRequirements,omitempty"`
+
+		// Ordered providers per method (primary first). A next provider is used only after all targets of previous ones failed
+		MethodProviderOrder map[string][]string `json:"methodProviderOrder,omitempty"`
+
+		// Providers never used for a method (e.g. "getProgramAccounts": ["provider1"]), even if their endpoints handle it
+		MethodProviderBlacklist map[string][]string `json:"methodProviderBlacklist,omitempty"`
+
+		// Target selection strategy per method, probabilistic (by weight) if not set
+		MethodSelectionStrategy map[string]SelectionStrategy `json:"methodSelectionStrategy,omitempty"`
+		// Score weights of the composite strategy. Default: equal weights
+		CompositeWeights *CompositeWeights `json:"compositeWeights,omitempty"`
+
 		// New method-based routing configuration
 		Providers []ProviderConfig `json:"providers,omitempty"`
 	}
 
+	CompositeWeights struct {
+		Latency   float64 `json:"latency"`
+		ErrorRate float64 `json:"errorRate"`
+	}
+
+	// SolanaChainConfig is a Solana-compatible chain added without code changes
+	SolanaChainConfig struct {
+		SolanaConfig
+		// Default: IsMainnet of the proxy
+		IsMainnet *bool `json:"isMainnet,omitempty"`
+	}
+	SolanaChains map[string]SolanaChainConfig
+
 	// New configuration types for method-based routing
 	ProviderConfig struct {
 		Name      string           `json:"name"`
 		Endpoints []EndpointConfig `json:"endpoints"`
+		Region    string           `json:"region,omitempty"`   // Providers of the proxy Region are preferred
+		Capacity  float64          `json:"capacity,omitempty"` // Advertised requests per second, the weight of endpoints without one
 	}
 
 	EndpointConfig struct {
@@ -54,11 +319,15 @@ type (
 		MethodGroups    []string        `json:"methodGroups,omitempty"`    // Named method groups
 		HandleOther     bool            `json:"handleOther,omitempty"`     // Handle methods not explicitly assigned elsewhere
 		HandleWebSocket bool            `json:"handleWebSocket,omitempty"` // Handle WebSocket connections
+		HandleGPA       bool            `json:"handleGPA,omitempty"`       // Serve getProgramAccounts from a dedicated pool
+		HostHeader      string          `json:"hostHeader,omitempty"`      // Host header and TLS server name sent upstream. Default: URL host
+		DeadlineHeader  bool            `json:"deadlineHeader,omitempty"`  // Send the remaining time of the client request as X-Deadline-Ms
 	}
 
 	MethodGroupConfig struct {
-		Name    string   `json:"name"`
-		Methods []string `json:"methods"`
+		Name     string   `json:"name"`
+		Methods  []string `json:"methods"`
+		Includes []string `json:"includes,omitempty"` // Names of groups which methods are included transitively
 	}
 )
 
@@ -95,6 +364,35 @@ func LoadFile[T PossibleConfig](envFile string) (c T, err error) {
 	return c, nil
 }
 
+// defaultsKey is the env variable defaults are processed with, so the environment can't override them
+const defaultsKey = "AURA_PROXY_CONFIG_DEFAULT"
+
+// Defaults returns the config struct with the default tag values, the environment isn't read. Required fields
+// without a default are kept zero, so the config isn't validated
+func Defaults[T any]() (c T, err error) {
+	v := reflect.ValueOf(&c).Elem()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !field.IsExported() {
+			continue
+		}
+
+		// the field alone, so its type is decoded by envconfig as in the whole config
+		spec := reflect.New(reflect.StructOf([]reflect.StructField{{
+			Name: "Value",
+			Type: field.Type,
+			Tag:  reflect.StructTag("envconfig:" + strconv.Quote(defaultsKey) + " default:" + strconv.Quote(def)),
+		}}))
+		if err = envconfig.Process("", spec.Interface()); err != nil {
+			return c, fmt.Errorf("%s default: %w", field.Name, err)
+		}
+		v.Field(i).Set(spec.Elem().Field(0))
+	}
+
+	return c, nil
+}
+
 type (
 	SolanaNodes []SolanaNode
 	SolanaNode  struct {
@@ -127,6 +425,14 @@ func (c *SolanaNodes) Decode(value string) error {
 	return json.Unmarshal([]byte(value), &c)
 }
 
+func (c *SolanaChains) Decode(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(value), &c)
+}
+
 func (c *Chains) Decode(value string) error {
 	if value == "" {
 		return nil
@@ -135,6 +441,31 @@ func (c *Chains) Decode(value string) error {
 	return json.Unmarshal([]byte(value), &c)
 }
 
+// UpstreamHosts returns the hosts (with ports) of all upstream URLs and Host headers
+func (s *SolanaConfig) UpstreamHosts() []string {
+	var hosts []string
+	for _, nodes := range []SolanaNodes{s.DasAPINodes, s.BasicRouteNodes, s.WSHostNodes, s.GPANodes} {
+		for _, node := range nodes {
+			hosts = append(hosts, node.URL.Host)
+		}
+	}
+	if s.PublicFallbackURL != nil {
+		hosts = append(hosts, s.PublicFallbackURL.Host)
+	}
+	for _, provider := range s.Providers {
+		for _, endpoint := range provider.Endpoints {
+			if u, err := url.Parse(endpoint.URL); err == nil {
+				hosts = append(hosts, u.Host)
+			}
+			if endpoint.HostHeader != "" {
+				hosts = append(hosts, endpoint.HostHeader)
+			}
+		}
+	}
+
+	return hosts
+}
+
 func (w *WrappedURL) UnmarshalText(text []byte) error {
 	u, err := url.ParseRequestURI(string(text))
 	if err != nil {
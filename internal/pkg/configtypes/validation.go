@@ -3,14 +3,69 @@ package configtypes
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+
+	"aura-proxy/internal/pkg/chains/solana"
 )
 
-var ErrInvalidPort = errors.New("invalid port")
+var (
+	ErrInvalidPort             = errors.New("invalid port")
+	ErrInvalidNodeBehindPolicy = errors.New("invalid node behind policy")
+	ErrInvalidStatsSampleRate  = errors.New("stats sample rate must be in [0, 1]")
+	ErrInvalidCaptureRate      = errors.New("debug capture sample rate must be in [0, 1]")
+	ErrInvalidRefreshJitter    = errors.New("token refresh jitter must be in [0, 1]")
+	ErrInvalidRequestType      = errors.New("invalid request type")
+	ErrInvalidStatusJailTime   = errors.New("upstream status jail time must be set for a bad status code (>= 300) and be non-negative")
+	ErrInvalidSlowTargetWeight = errors.New("slow target weight factor must be in (0, 1]")
+	ErrInvalidSlowTargetFactor = errors.New("slow target latency factor must be over 1, or 0 to disable it")
+	ErrInvalidNormalization    = errors.New("response normalization must be set as method.field:normalization with a known normalization")
+	ErrDuplicateHostName       = errors.New("host name is routed to more than one chain")
+)
 
 func (p ProxyConfig) Validate(possibleChains map[string]map[string]uint) error { //nolint:gocritic
 	if p.Port == 0 {
 		return ErrInvalidPort
 	}
+	switch p.NodeBehindPolicy {
+	case "", NodeBehindPolicySlotSensitive, NodeBehindPolicyFull:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidNodeBehindPolicy, p.NodeBehindPolicy)
+	}
+	if p.StatsSampleRate < 0 || p.StatsSampleRate > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidStatsSampleRate, p.StatsSampleRate)
+	}
+	if p.DebugCaptureSampleRate < 0 || p.DebugCaptureSampleRate > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidCaptureRate, p.DebugCaptureSampleRate)
+	}
+	if p.TokenRefreshJitter < 0 || p.TokenRefreshJitter > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidRefreshJitter, p.TokenRefreshJitter)
+	}
+	if p.SlowTargetLatencyFactor < 0 || (p.SlowTargetLatencyFactor > 0 && p.SlowTargetLatencyFactor <= 1) {
+		return fmt.Errorf("%w: %v", ErrInvalidSlowTargetFactor, p.SlowTargetLatencyFactor)
+	}
+	if p.SlowTargetLatencyFactor > 0 && (p.SlowTargetWeightFactor <= 0 || p.SlowTargetWeightFactor > 1) {
+		return fmt.Errorf("%w: %v", ErrInvalidSlowTargetWeight, p.SlowTargetWeightFactor)
+	}
+	for status, jailTime := range p.UpstreamStatusJailTimes {
+		if status < 300 || jailTime < 0 {
+			return fmt.Errorf("%w: %d:%s", ErrInvalidStatusJailTime, status, jailTime)
+		}
+	}
+	for key, normalization := range p.ResponseNormalizations {
+		method, field, ok := strings.Cut(key, ".")
+		if !ok || method == "" || field == "" || (normalization != ResponseNormalizationTrim && normalization != ResponseNormalizationLowerHex) {
+			return fmt.Errorf("%w: %s:%s", ErrInvalidNormalization, key, normalization)
+		}
+	}
+	for requestType := range p.RequestTypeMaxConcurrentRequests {
+		switch requestType {
+		case types.DAS.String(), types.RPC.String(), types.GPA.String(), types.SWQOS.String():
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidRequestType, requestType)
+		}
+	}
 	err := p.Solana.Validate()
 	if err != nil {
 		return fmt.Errorf("solana config: %s", err)
@@ -18,6 +73,12 @@ func (p ProxyConfig) Validate(possibleChains map[string]map[string]uint) error {
 	if err := p.Eclipse.Validate(); err != nil {
 		return fmt.Errorf("eclipse config: %s", err)
 	}
+	if err := p.SolanaChains.Validate(); err != nil {
+		return fmt.Errorf("solana chains config: %s", err)
+	}
+	if err := p.validateHostNames(); err != nil {
+		return err
+	}
 	err = p.Chains.Validate(possibleChains)
 	if err != nil {
 		return fmt.Errorf("chains config: %s", err)
@@ -48,9 +109,93 @@ func (s SolanaConfig) Validate() error { //nolint:gocritic
 		}
 	}
 
+	for i := range s.GPANodes {
+		err := s.GPANodes[i].URL.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for method, nodeType := range s.MethodNodeRequirements {
+		if !solana.IsKnownNodeType(nodeType) {
+			return fmt.Errorf("method %s: invalid node type requirement: %s", method, nodeType)
+		}
+	}
+
+	for method, strategy := range s.MethodSelectionStrategy {
+		if !strategy.IsKnown() {
+			return fmt.Errorf("method %s: invalid selection strategy: %s", method, strategy)
+		}
+	}
+	for _, provider := range s.Providers {
+		if provider.Capacity < 0 {
+			return fmt.Errorf("provider %s: negative capacity: %v", provider.Name, provider.Capacity)
+		}
+	}
+	if w := s.CompositeWeights; w != nil && (w.Latency < 0 || w.ErrorRate < 0 || w.Latency+w.ErrorRate == 0) {
+		return fmt.Errorf("composite weights must be non-negative with a positive sum: %+v", *w)
+	}
+
+	if s.PublicFallbackURL != nil {
+		if err := s.PublicFallbackURL.Validate(); err != nil {
+			return fmt.Errorf("public fallback: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// validateHostNames checks that a configured host is routed to a single chain. Built-in hosts of chains without configured
+// ones are checked when the chains are added
+func (p ProxyConfig) validateHostNames() error { //nolint:gocritic
+	chains := make(map[string]string)
+	add := func(chainName string, hostNames []string) error {
+		for _, hostName := range hostNames {
+			if existing, ok := chains[hostName]; ok {
+				return fmt.Errorf("%w: %s: %s, %s", ErrDuplicateHostName, hostName, existing, chainName)
+			}
+			chains[hostName] = chainName
+		}
+
+		return nil
+	}
+
+	if err := add(solana.ChainName, p.Solana.HostNames); err != nil {
+		return err
+	}
+	if err := add(solana.EclipseChainName, p.Eclipse.HostNames); err != nil {
+		return err
+	}
+	for chainName, chain := range p.SolanaChains {
+		if err := add(chainName, chain.HostNames); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (c SolanaChains) Validate() error {
+	for chainName, chain := range c {
+		if chainName == solana.ChainName || chainName == solana.EclipseChainName {
+			return fmt.Errorf("chain %s: built-in chain is configured by its own variable", chainName)
+		}
+		if err := chain.Validate(); err != nil {
+			return fmt.Errorf("chain %s: %s", chainName, err)
+		}
+	}
+
+	return nil
+}
+
+func (c SolanaChainConfig) Validate() error { //nolint:gocritic
+	if len(c.HostNames) == 0 {
+		return errors.New("empty host names")
+	}
+
+	return c.SolanaConfig.Validate()
+}
+
 func (c Chains) Validate(possibleChains map[string]map[string]uint) error {
 	for chainName, chain := range c {
 		if _, ok := possibleChains[chainName]; !ok {
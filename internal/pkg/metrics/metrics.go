@@ -17,6 +17,8 @@ const (
 	endpointArg     = "endpoint"
 	chainArg        = "chain"
 	hostArg         = "host"
+	reasonArg       = "reason"
+	tierArg         = "tier"
 )
 
 // See the NewMetrics func for proper descriptions and prometheus names!
@@ -32,6 +34,15 @@ var (
 		httpResponsesTotal *prometheus.CounterVec
 		partnersNodeUsage  *prometheus.CounterVec
 		rpcErrors          *prometheus.CounterVec
+		missingPricing     *prometheus.CounterVec
+		publicFallback     *prometheus.CounterVec
+		staleResponses     *prometheus.CounterVec
+		methodTimeouts     *prometheus.CounterVec
+		droppedStats       *prometheus.CounterVec
+		droppedUserReqs    *prometheus.CounterVec
+		failedProviders    *prometheus.CounterVec
+		creditsExhausted   *prometheus.CounterVec
+		tokenRejections    *prometheus.CounterVec
 
 		// Histogram
 		executionTime    *prometheus.HistogramVec
@@ -40,6 +51,9 @@ var (
 
 		externalRequests *prometheus.HistogramVec
 	}
+
+	// collectors of the metrics struct, moved under the namespace by NewGatherer
+	proxyCollectors []prometheus.Collector
 )
 
 // Creates and populates a new Metrics struct
@@ -54,7 +68,16 @@ func init() {
 	// Counter
 	initMetric(&metrics.httpResponsesTotal, newCounterVec("http_responses_total", "", []string{chainArg, targetTypeArg, methodMetricArg, successArg}))
 	initMetric(&metrics.partnersNodeUsage, newCounterVec("partners_node_usage", "", []string{partnerNameArg, successArg}))
+	initMetric(&metrics.methodTimeouts, newCounterVec("method_timeouts_total", "node requests exceeded the deadline by primary request method", []string{chainArg, methodMetricArg}))
 	initMetric(&metrics.rpcErrors, newCounterVec("rpc_errors", "", []string{rpcErrorArg, endpointArg, methodMetricArg}))
+	initMetric(&metrics.missingPricing, newCounterVec("missing_subscription_pricing", "requests served with default pricing because subscription pricing is unavailable", []string{chainArg}))
+	initMetric(&metrics.publicFallback, newCounterVec("public_fallback_usage", "requests served by the public RPC after partner nodes were exhausted", []string{chainArg, successArg}))
+	initMetric(&metrics.staleResponses, newCounterVec("stale_responses_total", "cached responses served after all targets failed", []string{chainArg, methodMetricArg}))
+	initMetric(&metrics.droppedStats, newCounterVec("dropped_stats_total", "request stats not delivered to aura-api", []string{reasonArg}))
+	initMetric(&metrics.droppedUserReqs, newCounterVec("dropped_user_requests_total", "user requests evicted from the request counter before reaching aura-api", nil))
+	initMetric(&metrics.failedProviders, newCounterVec("failed_request_providers_total", "providers failed on requests which exhausted all targets", []string{chainArg}))
+	initMetric(&metrics.creditsExhausted, newCounterVec("credits_exhausted_total", "requests rejected because the user credits are exhausted", []string{chainArg, tierArg}))
+	initMetric(&metrics.tokenRejections, newCounterVec("token_rejections_total", "requests rejected because of an empty or invalid API token", []string{chainArg, reasonArg}))
 
 	// Histogram
 	buckets := []float64{1, 5, 10, 25, 50, 100, 500, 800, 1000, 2000, 4000, 8000, 10000, 15000, 20000, 30000, 50000, 100000, 200000}
@@ -68,6 +91,7 @@ func init() {
 
 func initMetric[T prometheus.Collector](dest *T, metric T) {
 	*dest = metric
+	proxyCollectors = append(proxyCollectors, metric)
 	prometheus.MustRegister(metric)
 }
 
@@ -136,6 +160,54 @@ func IncRPCErrors(rpcErr int, endpoint, method string) {
 	metrics.rpcErrors.With(l).Inc()
 }
 
+func IncPublicFallbackUsage(chain string, success bool) {
+	l := prometheus.Labels{
+		chainArg:   chain,
+		successArg: strconv.FormatBool(success),
+	}
+	metrics.publicFallback.With(l).Inc()
+}
+
+func IncStaleResponses(chain, method string) {
+	l := prometheus.Labels{
+		chainArg:        chain,
+		methodMetricArg: method,
+	}
+	metrics.staleResponses.With(l).Inc()
+}
+
+func IncMethodTimeouts(chain, method string) {
+	l := prometheus.Labels{
+		chainArg:        chain,
+		methodMetricArg: method,
+	}
+	metrics.methodTimeouts.With(l).Inc()
+}
+
+func AddDroppedStats(reason string, n int) {
+	metrics.droppedStats.With(prometheus.Labels{reasonArg: reason}).Add(float64(n))
+}
+
+func AddDroppedUserRequests(n int64) {
+	metrics.droppedUserReqs.With(prometheus.Labels{}).Add(float64(n))
+}
+
+func AddFailedRequestProviders(chain string, n int) {
+	metrics.failedProviders.With(prometheus.Labels{chainArg: chain}).Add(float64(n))
+}
+
+func IncCreditsExhausted(chain, tier string) {
+	metrics.creditsExhausted.With(prometheus.Labels{chainArg: chain, tierArg: tier}).Inc()
+}
+
+func IncTokenRejections(chain, reason string) {
+	metrics.tokenRejections.With(prometheus.Labels{chainArg: chain, reasonArg: reason}).Inc()
+}
+
+func IncMissingPricing(chain string) {
+	metrics.missingPricing.With(prometheus.Labels{chainArg: chain}).Inc()
+}
+
 func ObserveExternalRequests(chain, host, method string, success bool, d time.Duration) {
 	l := prometheus.Labels{
 		chainArg:        chain,
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPSubsystem of the metrics of the proxy HTTP server
+const HTTPSubsystem = "aura"
+
+// NewGatherer returns the gatherer of the metrics endpoint. With a namespace, the proxy metrics are registered
+// as <namespace>_<name>, Go runtime and process metrics keep their names. The chain label is kept either way.
+// It fails if a namespaced name collides with an already registered metric. Must be called once, before serving
+func NewGatherer(namespace string) (prometheus.Gatherer, error) {
+	if namespace == "" {
+		return prometheus.DefaultGatherer, nil
+	}
+	if err := registerNamespaced(prometheus.DefaultRegisterer, proxyCollectors, namespace+"_"); err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+	}
+
+	return prometheus.DefaultGatherer, nil
+}
+
+// registerNamespaced moves the collectors registered on reg under the prefix. On a collision, the already moved
+// collectors are registered back without the prefix, so the metrics keep being exposed
+func registerNamespaced(reg prometheus.Registerer, collectors []prometheus.Collector, prefix string) error {
+	namespaced := prometheus.WrapRegistererWithPrefix(prefix, reg)
+	for i, c := range collectors {
+		reg.Unregister(c)
+		if err := namespaced.Register(c); err != nil {
+			for _, moved := range collectors[:i] {
+				namespaced.Unregister(moved)
+				reg.MustRegister(moved)
+			}
+			reg.MustRegister(c)
+
+			return err
+		}
+	}
+
+	return nil
+}
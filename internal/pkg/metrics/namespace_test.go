@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gatheredNames(t *testing.T, g prometheus.Gatherer) []string {
+	t.Helper()
+
+	families, err := g.Gather()
+	require.NoError(t, err)
+	names := make([]string, 0, len(families))
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+
+	return names
+}
+
+func TestNewGatherer(t *testing.T) {
+	IncMissingPricing("solana")
+
+	g, err := NewGatherer("")
+	require.NoError(t, err)
+	assert.Equal(t, prometheus.DefaultGatherer, g)
+	assert.Contains(t, gatheredNames(t, g), "missing_subscription_pricing")
+}
+
+func TestRegisterNamespaced(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector())
+	startTime, pricing := newGauge("start_time", ""), newCounterVec("missing_subscription_pricing", "", []string{chainArg})
+	registry.MustRegister(startTime, pricing)
+	pricing.WithLabelValues("solana").Inc()
+
+	require.NoError(t, registerNamespaced(registry, []prometheus.Collector{startTime, pricing}, "eclipse_"))
+	names := gatheredNames(t, registry)
+	assert.Contains(t, names, "eclipse_missing_subscription_pricing")
+	assert.Contains(t, names, "eclipse_start_time")
+	assert.NotContains(t, names, "missing_subscription_pricing")
+	assert.NotContains(t, names, "start_time")
+	assert.Contains(t, names, "go_goroutines") // runtime metrics aren't proxy ones
+}
+
+func TestRegisterNamespaced_Collision(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	startTime, pricing := newGauge("start_time", ""), newCounterVec("missing_subscription_pricing", "", []string{chainArg})
+	registry.MustRegister(startTime, pricing)
+	pricing.WithLabelValues("solana").Inc()
+	// a metric of another component already named as a prefixed proxy metric
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "eclipse_missing_subscription_pricing"}))
+
+	assert.Error(t, registerNamespaced(registry, []prometheus.Collector{startTime, pricing}, "eclipse_"))
+
+	// the proxy metrics are registered back without the prefix
+	names := gatheredNames(t, registry)
+	assert.Contains(t, names, "start_time")
+	assert.Contains(t, names, "missing_subscription_pricing")
+	assert.NotContains(t, names, "eclipse_start_time")
+}
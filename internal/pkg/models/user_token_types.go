@@ -1,5 +1,7 @@
 package models
 
+import "strings"
+
 type TokenType string
 
 const (
@@ -21,6 +23,16 @@ func (t TokenType) IsTokenRateLimited() bool { // currently all tokens (except D
 	return t == DefaultTokenType || t == BasicTokenType || t == ProTokenType
 }
 
+// TokenTypeFromSubscription returns the token type named as the subscription (case-insensitive), DefaultTokenType for other subscriptions
+func TokenTypeFromSubscription(name string) TokenType {
+	switch t := TokenType(strings.ToLower(name)); t {
+	case SpeedTokenType, ReliableTokenType, OnlyPublicNodesTokenType, UnlimitedTokenType, BasicTokenType, ProTokenType:
+		return t
+	}
+
+	return DefaultTokenType
+}
+
 type TokenInfo struct {
 	TokenType TokenType
 	Tracked   bool
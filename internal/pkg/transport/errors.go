@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"aura-proxy/internal/pkg/util"
+)
+
+// upstream network failures, wrapping the original error
+var (
+	ErrUpstreamTimeout     = errors.New("upstream timeout")
+	ErrUpstreamConnRefused = errors.New("upstream connection refused")
+	ErrUpstreamUnreachable = errors.New("upstream unreachable")
+	ErrUpstreamReset       = errors.New("upstream connection reset")
+)
+
+// classifyUpstreamErr wraps network failures with the matching ErrUpstream* error, others are returned as is
+func classifyUpstreamErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: %w", ErrUpstreamConnRefused, err)
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return fmt.Errorf("%w: %w", ErrUpstreamUnreachable, err)
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: %w", ErrUpstreamReset, err)
+	// network i/o timeouts only, http client timeouts are caused by the request deadline, not by the node
+	case errors.As(err, &opErr) && opErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrUpstreamTimeout, err)
+	}
+
+	return err
+}
+
+// IsMutedErr checks if the node request error is expected and shouldn't be logged.
+// isAvailable reports if the node is not guilty and can still be used
+func IsMutedErr(err, contextErr error) (mute, isAvailable bool) {
+	if errors.Is(err, util.ErrBadStatusCode) || errors.Is(err, ErrUpstreamTimeout) || errors.Is(err, ErrUpstreamReset) ||
+		errors.Is(err, ErrUpstreamConnRefused) || errors.Is(err, ErrUpstreamUnreachable) {
+		return true, false
+	}
+
+	// possible cases when the node is not guilty:
+	// - context.DeadlineExceeded - node response timeout. Slow node or multiple attempts are passed
+	// - context.Canceled - user cancelled request
+	if IsDeadlineErr(err, contextErr) || errors.Is(err, context.Canceled) || errors.Is(contextErr, context.Canceled) {
+		return true, true
+	}
+
+	return false, false
+}
+
+// IsDeadlineErr checks if the node request exceeded the deadline (request timeout or http client timeout)
+func IsDeadlineErr(err, contextErr error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(contextErr, context.DeadlineExceeded)
+}
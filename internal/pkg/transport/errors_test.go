@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"aura-proxy/internal/pkg/util"
+)
+
+func dialErr(errno syscall.Errno) error {
+	return &url.Error{Op: "Post", URL: "http://node", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}}
+}
+
+func TestClassifyUpstreamErr(t *testing.T) {
+	deadlineCtx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	<-deadlineCtx.Done()
+
+	tests := []struct {
+		name        string
+		err         error
+		expectedErr error
+	}{
+		{name: "refused", err: dialErr(syscall.ECONNREFUSED), expectedErr: ErrUpstreamConnRefused},
+		{name: "no route", err: dialErr(syscall.EHOSTUNREACH), expectedErr: ErrUpstreamUnreachable},
+		{name: "reset", err: dialErr(syscall.ECONNRESET), expectedErr: ErrUpstreamReset},
+		{name: "eof", err: &url.Error{Op: "Post", URL: "http://node", Err: io.EOF}, expectedErr: ErrUpstreamReset},
+		{name: "i/o timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, expectedErr: ErrUpstreamTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyUpstreamErr(tt.err)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.ErrorIs(t, err, tt.err, "the original error is wrapped")
+		})
+	}
+
+	// request deadline and other errors are not classified
+	for _, err := range []error{deadlineCtx.Err(), &url.Error{Op: "Post", URL: "http://node", Err: deadlineCtx.Err()}, errors.New("other")} {
+		assert.Equal(t, err, classifyUpstreamErr(err), "returned as is")
+	}
+}
+
+func TestIsMutedErr(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	deadlineCtx, cancelDeadline := context.WithDeadline(context.Background(), time.Now())
+	defer cancelDeadline()
+	<-deadlineCtx.Done()
+
+	tests := []struct {
+		name              string
+		err               error
+		contextErr        error
+		expectedMute      bool
+		expectedAvailable bool
+	}{
+		{name: "bad status code", err: fmt.Errorf("wrapped: %w", util.ErrBadStatusCode), expectedMute: true},
+		{name: "connection refused", err: fmt.Errorf("do: %w", classifyUpstreamErr(dialErr(syscall.ECONNREFUSED))), expectedMute: true},
+		{name: "no route to host", err: fmt.Errorf("do: %w", classifyUpstreamErr(dialErr(syscall.EHOSTUNREACH))), expectedMute: true},
+		{name: "connection reset", err: fmt.Errorf("copy: %w", classifyUpstreamErr(dialErr(syscall.ECONNRESET))), expectedMute: true},
+		{name: "i/o timeout", err: fmt.Errorf("do: %w", classifyUpstreamErr(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded})), expectedMute: true},
+		{
+			name:              "wrapped deadline",
+			err:               fmt.Errorf("do: %w", &url.Error{Op: "Post", URL: "http://node", Err: context.DeadlineExceeded}),
+			expectedMute:      true,
+			expectedAvailable: true,
+		},
+		{name: "request deadline", err: io.ErrClosedPipe, contextErr: deadlineCtx.Err(), expectedMute: true, expectedAvailable: true},
+		{
+			name:              "wrapped cancellation",
+			err:               fmt.Errorf("do: %w", &url.Error{Op: "Post", URL: "http://node", Err: context.Canceled}),
+			expectedMute:      true,
+			expectedAvailable: true,
+		},
+		{name: "request canceled", err: io.ErrClosedPipe, contextErr: canceledCtx.Err(), expectedMute: true, expectedAvailable: true},
+		{name: "other error", err: errors.New("unexpected")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mute, isAvailable := IsMutedErr(tt.err, tt.contextErr)
+			assert.Equal(t, tt.expectedMute, mute)
+			assert.Equal(t, tt.expectedAvailable, isAvailable)
+		})
+	}
+}
+
+func TestIsDeadlineErr(t *testing.T) {
+	assert.True(t, IsDeadlineErr(fmt.Errorf("do: %w", context.DeadlineExceeded), nil), "a wrapped deadline")
+	assert.True(t, IsDeadlineErr(errors.New("other"), context.DeadlineExceeded), "the request deadline")
+	assert.False(t, IsDeadlineErr(fmt.Errorf("do: %w", context.Canceled), context.Canceled), "a cancellation")
+}
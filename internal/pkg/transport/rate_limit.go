@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// HeaderUpstreamRateLimitRemaining is the lowest remaining request budget reported by upstreams which served or failed
+// the request, so clients can self-throttle before providers start rejecting proxy traffic
+const HeaderUpstreamRateLimitRemaining = "X-Aura-Upstream-RateLimit-Remaining"
+
+// upstreamRateLimitHeaders are remaining budget headers of known providers
+var upstreamRateLimitHeaders = []string{
+	"X-RateLimit-Remaining",
+	"RateLimit-Remaining", // IETF draft
+	"X-RateLimit-Rps-Remaining",
+	"X-RateLimit-Method-Remaining",
+}
+
+// exposeUpstreamRateLimit enables HeaderUpstreamRateLimitRemaining
+var exposeUpstreamRateLimit bool
+
+// SetExposeUpstreamRateLimit enables the aggregated upstream rate limit header. It's not synchronized, so it must be set before serving
+func SetExposeUpstreamRateLimit(expose bool) {
+	exposeUpstreamRateLimit = expose
+}
+
+// upstreamRateLimitRemaining returns the lowest remaining budget of the known headers of an upstream response
+func upstreamRateLimitRemaining(h http.Header) (remaining int64, ok bool) {
+	for _, name := range upstreamRateLimitHeaders {
+		value := h.Get(name)
+		if value == "" {
+			continue
+		}
+		// RateLimit-Remaining of the IETF draft may list several policies, e.g. "10, 100"
+		for _, part := range strings.Split(value, ",") {
+			v, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || v < 0 {
+				continue
+			}
+			if !ok || v < remaining {
+				remaining, ok = v, true
+			}
+		}
+	}
+
+	return remaining, ok
+}
+
+// observeUpstreamRateLimit lowers the response header to the remaining budget of an upstream response.
+// Budgets of all attempts of the request are aggregated, the lowest one is exposed
+func observeUpstreamRateLimit(c *echoUtil.CustomContext, upstream http.Header) {
+	if !exposeUpstreamRateLimit || upstream == nil {
+		return
+	}
+	remaining, ok := upstreamRateLimitRemaining(upstream)
+	if !ok {
+		return
+	}
+
+	h := c.Response().Header()
+	if current, err := strconv.ParseInt(h.Get(HeaderUpstreamRateLimitRemaining), 10, 64); err == nil && current <= remaining {
+		return
+	}
+	h.Set(HeaderUpstreamRateLimitRemaining, strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeHTTPRequest_UpstreamRateLimit(t *testing.T) {
+	defer SetExposeUpstreamRateLimit(exposeUpstreamRateLimit)
+
+	newUpstream := func(headers map[string]string, statusCode int) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(statusCode)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":1,"id":1}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	limited := newUpstream(map[string]string{"X-RateLimit-Remaining": "0"}, http.StatusTooManyRequests)
+	low := newUpstream(map[string]string{"X-RateLimit-Remaining": "40", "RateLimit-Remaining": "25, 900"}, http.StatusOK)
+	high := newUpstream(map[string]string{"X-RateLimit-Remaining": "500"}, http.StatusOK)
+	unlimited := newUpstream(nil, http.StatusOK)
+
+	tests := []struct {
+		name     string
+		expose   bool
+		targets  []string
+		expected string
+	}{
+		{name: "lowest header of a response", expose: true, targets: []string{low.URL}, expected: "25"},
+		{name: "lowest budget of the attempts", expose: true, targets: []string{high.URL, low.URL, high.URL}, expected: "25"},
+		{name: "rate limited attempt", expose: true, targets: []string{limited.URL, high.URL}, expected: "0"},
+		{name: "no rate limit headers", expose: true, targets: []string{unlimited.URL}, expected: ""},
+		{name: "disabled", expose: false, targets: []string{low.URL}, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetExposeUpstreamRateLimit(tt.expose)
+			c := newPostContext([]byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`), "")
+			require.NoError(t, PreparePostRequest(c, "solana"))
+
+			for _, target := range tt.targets {
+				_, _, _ = MakeHTTPRequest(c, &http.Client{}, http.MethodPost, target, false)
+			}
+			assert.Equal(t, tt.expected, c.Response().Header().Get(HeaderUpstreamRateLimitRemaining))
+		})
+	}
+}
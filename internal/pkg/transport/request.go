@@ -1,12 +1,17 @@
 package transport
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -25,7 +30,49 @@ import (
 var (
 	ErrFailToReadBody     = errors.New("fail to read body")
 	ErrInvalidContentType = errors.New("supplied content type is not allowed. Content-Type: application/json is required")
+
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding, only gzip is allowed")
+)
+
+// HeaderDeadlineMs is the remaining time of the client request in milliseconds, so upstreams honoring it can abort work
+// they can't finish. Sent by WithDeadlineHeader clients only
+const HeaderDeadlineMs = "X-Deadline-Ms"
+
+// HeaderProxyHops counts the proxies a request passed, requests with MaxProxyHops are rejected to break routing loops
+const (
+	HeaderProxyHops = "X-Proxy-Hops"
+	MaxProxyHops    = 5
 )
+
+const headerUserAgent = "User-Agent"
+
+// userAgent is sent to upstreams, so providers can identify the proxy traffic
+var userAgent = DefaultUserAgent("")
+
+// DefaultUserAgent identifies the proxy build and the service, e.g. "aura-proxy/v1.2.0-1a2b3c4 (proxy-prod)"
+func DefaultUserAgent(serviceName string) string {
+	if serviceName == "" {
+		return "aura-proxy/" + util.Version
+	}
+
+	return fmt.Sprintf("aura-proxy/%s (%s)", util.Version, serviceName)
+}
+
+// SetUserAgent sets the User-Agent header of upstream requests. It's not synchronized, so it must be set before serving
+func SetUserAgent(ua string) {
+	userAgent = ua
+}
+
+// maxDecompressedBodySize limits gzip request bodies after decompression. The body limit middleware checks the
+// decompressed body too, so larger bodies aren't decompressed in full
+var maxDecompressedBodySize int64 = echoUtil.DefaultBodyLimit
+
+// SetMaxDecompressedBodySize sets the largest configured body limit as the limit of decompressed bodies.
+// It's not synchronized, so it must be set before serving
+func SetMaxDecompressedBodySize(size uint64) {
+	maxDecompressedBodySize = int64(size) //nolint:gosec
+}
+
 var (
 	MethodNotFoundRPCError = types.NewRPCError(solana.MethodNotFoundErrCode, "Method not found", nil)
 	ParseErrorResponse     = types.NewRPCErrorResponse(types.ParseError, nil)
@@ -36,22 +83,18 @@ func MakeHTTPRequest(c *echoUtil.CustomContext, httpClient *http.Client, reqType
 		return nil, http.StatusInternalServerError, fmt.Errorf("unknown request type: %s", reqType)
 	}
 
-	body := io.Reader(http.NoBody)
-	if reqType == echo.POST {
-		body = c.GetReqBody()
-	}
-	builtReq, err := http.NewRequestWithContext(c.Request().Context(), reqType, targetURL, body)
+	builtReq, err := newProxyRequest(c, reqType, targetURL)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("NewRequest: %s", err)
+		return nil, http.StatusInternalServerError, err
 	}
 
-	// Set headers
-	setProxyHeaders(c, builtReq)
-
 	var buf bytes.Buffer
 	startTime := time.Now()
 	resp, err := httpClient.Do(builtReq)
 	metrics.ObserveExternalRequests(c.GetChainName(), builtReq.Host, c.GetReqMethod(), err == nil, time.Since(startTime))
+	if resp != nil {
+		observeUpstreamRateLimit(c, resp.Header)
+	}
 	if skipErrHandling {
 		if resp != nil {
 			_, _ = io.Copy(&buf, resp.Body) // ignore err
@@ -60,10 +103,10 @@ func MakeHTTPRequest(c *echoUtil.CustomContext, httpClient *http.Client, reqType
 			return buf.Bytes(), resp.StatusCode, nil
 		}
 
-		return buf.Bytes(), http.StatusInternalServerError, err
+		return buf.Bytes(), http.StatusInternalServerError, classifyUpstreamErr(err)
 	}
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("do: %w", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("do: %w", classifyUpstreamErr(err))
 	}
 	if resp == nil {
 		return nil, http.StatusInternalServerError, errors.New("resp == nil")
@@ -76,14 +119,144 @@ func MakeHTTPRequest(c *echoUtil.CustomContext, httpClient *http.Client, reqType
 
 	_, err = io.Copy(&buf, resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("copy: %s", err)
+		return nil, resp.StatusCode, fmt.Errorf("copy: %w", classifyUpstreamErr(err))
 	}
 
 	return buf.Bytes(), resp.StatusCode, nil
 }
 
+// StreamHeadSize is the size of the response head passed to the StreamHTTPRequest beforeWrite callback.
+// A shorter head is the whole body
+const StreamHeadSize = 4096
+
+// StreamHTTPRequest sends the request body to targetURL and copies the upstream response body directly to the client
+// without buffering it. beforeWrite gets the first StreamHeadSize body bytes (not empty) and is called right before
+// the response is committed: returning an error aborts the request without writing anything, so it can be retried
+// on another target. Once the response is committed (c.Response().Committed) the request can't be retried.
+func StreamHTTPRequest(c *echoUtil.CustomContext, httpClient *http.Client, targetURL string, beforeWrite func(head []byte) error) (written int64, statusCode int, err error) {
+	builtReq, err := newProxyRequest(c, http.MethodPost, targetURL)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	startTime := time.Now()
+	resp, err := httpClient.Do(builtReq)
+	metrics.ObserveExternalRequests(c.GetChainName(), builtReq.Host, c.GetReqMethod(), err == nil, time.Since(startTime))
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("do: %w", classifyUpstreamErr(err))
+	}
+	defer resp.Body.Close()
+	observeUpstreamRateLimit(c, resp.Header)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, resp.StatusCode, util.ErrBadStatusCode
+	}
+
+	br := bufio.NewReaderSize(resp.Body, StreamHeadSize)
+	head, err := br.Peek(StreamHeadSize)
+	if errors.Is(err, io.EOF) && len(head) > 0 { // the body is shorter than the head
+		err = nil
+	}
+	if err != nil {
+		return 0, resp.StatusCode, fmt.Errorf("peek: %w", classifyUpstreamErr(err))
+	}
+	if beforeWrite != nil {
+		if err = beforeWrite(head); err != nil {
+			return 0, resp.StatusCode, err
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c.Response().WriteHeader(resp.StatusCode)
+	written, err = io.Copy(c.Response(), br)
+	if err != nil {
+		return written, resp.StatusCode, fmt.Errorf("copy: %s", err)
+	}
+
+	return written, resp.StatusCode, nil
+}
+
+// NewHostHeaderClient returns a client sending host as the Host header and the TLS server name (SNI)
+// instead of the target URL host. base is cloned, http.DefaultTransport is used if nil
+func NewHostHeaderClient(host string, timeout time.Duration, base *http.Transport) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport) //nolint:forcetypeassert
+	}
+	rt := base.Clone()
+	if rt.TLSClientConfig == nil {
+		rt.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	rt.TLSClientConfig.ServerName = host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		rt.TLSClientConfig.ServerName = hostname
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &hostHeaderRoundTripper{host: host, next: rt},
+	}
+}
+
+// WithDeadlineHeader returns a copy of client sending the remaining time of the request context as HeaderDeadlineMs,
+// for upstreams opted in to it. http.DefaultTransport is wrapped if the client transport is nil
+func WithDeadlineHeader(client *http.Client) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res := *client
+	res.Transport = &deadlineHeaderRoundTripper{next: next}
+
+	return &res
+}
+
+type deadlineHeaderRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (d *deadlineHeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return d.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context()) // RoundTripper must not modify the request
+	req.Header.Set(HeaderDeadlineMs, strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 0), 10))
+
+	return d.next.RoundTrip(req)
+}
+
+type hostHeaderRoundTripper struct {
+	host string
+	next http.RoundTripper
+}
+
+func (h *hostHeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context()) // RoundTripper must not modify the request
+	req.Host = h.host
+
+	return h.next.RoundTrip(req)
+}
+
+func newProxyRequest(c *echoUtil.CustomContext, reqType, targetURL string) (*http.Request, error) {
+	body := io.Reader(http.NoBody)
+	if reqType == echo.POST {
+		body = c.GetReqBody()
+	}
+	builtReq, err := http.NewRequestWithContext(c.Request().Context(), reqType, targetURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest: %s", err)
+	}
+
+	// Set headers
+	setProxyHeaders(c, builtReq)
+
+	return builtReq, nil
+}
+
 func setProxyHeaders(c echo.Context, req *http.Request) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(headerUserAgent, userAgent)
+	req.Header.Set(HeaderProxyHops, strconv.Itoa(ProxyHops(c.Request().Header)+1))
 
 	// Fix header
 	// Basically it's not good practice to unconditionally pass incoming x-real-ip header to upstream.
@@ -145,8 +318,23 @@ func PreparePostRequest(c *echoUtil.CustomContext, chainName string) error {
 
 	c.SetChainName(chainName)
 
-	// get & save body
-	reqBody, err := getRequestBody(c.Request().Body)
+	// get & save body. Compressed body is kept decompressed, so it's forwarded to upstream as is
+	body := c.Request().Body
+	if contentEncoding := c.Request().Header.Get(echo.HeaderContentEncoding); contentEncoding != "" {
+		decompressed, err := decompressRequestBody(body, contentEncoding)
+		if errors.Is(err, ErrUnsupportedContentEncoding) {
+			c.SetProxyUserError(true)
+			return err
+		}
+		if err != nil {
+			log.Logger.Proxy.Debugf("PreparePostRequest: %s", err)
+			c.SetRPCErrors([]int{ParseErrorResponse.Error.Code})
+			c.SetProxyUserError(true)
+			return echo.NewHTTPError(http.StatusOK, ParseErrorResponse)
+		}
+		body = decompressed
+	}
+	reqBody, err := getRequestBody(body)
 	if err != nil {
 		c.SetRPCErrors([]int{ParseErrorResponse.Error.Code})
 		c.SetProxyUserError(true)
@@ -156,6 +344,32 @@ func PreparePostRequest(c *echoUtil.CustomContext, chainName string) error {
 
 	return nil
 }
+
+// decompressRequestBody supports gzip only. Body over maxDecompressedBodySize after decompression is rejected
+func decompressRequestBody(body io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	if !strings.EqualFold(contentEncoding, "gzip") {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, contentEncoding)
+	}
+	if body == nil {
+		return nil, ErrFailToReadBody
+	}
+	defer body.Close()
+
+	gr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %s", err)
+	}
+	res, err := io.ReadAll(io.LimitReader(gr, maxDecompressedBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %s", err)
+	}
+	if int64(len(res)) > maxDecompressedBodySize {
+		return nil, fmt.Errorf("decompressed body exceeds %d bytes", maxDecompressedBodySize)
+	}
+
+	return io.NopCloser(bytes.NewReader(res)), nil
+}
+
 func getRequestBody(body io.ReadCloser) (res []byte, err error) {
 	if body == nil {
 		return nil, ErrFailToReadBody
@@ -241,3 +455,13 @@ func checkJSONRPCBody(req types.RPCRequest, methodList map[string]uint, skipMeth
 
 	return nil
 }
+
+// ProxyHops returns the proxies the request passed, 0 if the header is missing or invalid
+func ProxyHops(h http.Header) int {
+	hops, err := strconv.Atoi(h.Get(HeaderProxyHops))
+	if err != nil || hops < 0 {
+		return 0
+	}
+
+	return hops
+}
@@ -0,0 +1,340 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func TestNewHostHeaderClient(t *testing.T) {
+	var gotHost, gotServerName string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotServerName = r.Host, r.TLS.ServerName
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the test certificate is valid for example.com
+	base := server.Client().Transport.(*http.Transport) //nolint:forcetypeassert
+	for _, host := range []string{"example.com", "example.com:8443"} {
+		client := NewHostHeaderClient(host, time.Second, base)
+		req, err := http.NewRequest(http.MethodPost, server.URL, http.NoBody)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do (%s): %v", host, err)
+		}
+		resp.Body.Close()
+
+		if gotHost != host {
+			t.Errorf("Expected Host header %s, got %s", host, gotHost)
+		}
+		if gotServerName != "example.com" {
+			t.Errorf("Expected TLS server name example.com, got %s", gotServerName)
+		}
+		if req.Host != server.Listener.Addr().String() {
+			t.Errorf("Expected the original request to be untouched, got Host %s", req.Host)
+		}
+	}
+	if base.TLSClientConfig.ServerName != "" {
+		t.Errorf("Expected the base transport to be untouched, got server name %s", base.TLSClientConfig.ServerName)
+	}
+}
+
+func gzipBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(body); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func newPostContext(body []byte, contentEncoding string) *echoUtil.CustomContext {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if contentEncoding != "" {
+		req.Header.Set(echo.HeaderContentEncoding, contentEncoding)
+	}
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(req, httptest.NewRecorder())}
+	c.InitMetrics()
+
+	return c
+}
+
+func TestPreparePostRequest_Gzip(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"sendTransaction","params":["` + strings.Repeat("A", 4096) + `"]}`)
+
+	c := newPostContext(gzipBody(t, body), "gzip")
+	if err := PreparePostRequest(c, "solana"); err != nil {
+		t.Fatalf("PreparePostRequest: %v", err)
+	}
+	if got, _ := io.ReadAll(c.GetReqBody()); !bytes.Equal(got, body) {
+		t.Fatalf("Expected decompressed body, got %d bytes", len(got))
+	}
+
+	parsed, _, rpcErr := ParseJSONRPCRequestBody(c.GetReqBody, solana.MethodList, false)
+	if rpcErr != nil {
+		t.Fatalf("ParseJSONRPCRequestBody: %+v", rpcErr.Error)
+	}
+	if len(parsed) != 1 || parsed[0].Method != solana.SendTransaction {
+		t.Fatalf("Expected a sendTransaction request, got %+v", parsed)
+	}
+
+	// upstream gets the decompressed body
+	var upstreamBody []byte
+	var upstreamEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody, _ = io.ReadAll(r.Body)
+		upstreamEncoding = r.Header.Get(echo.HeaderContentEncoding)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":"sig","id":1}`))
+	}))
+	defer server.Close()
+
+	if _, _, err := MakeHTTPRequest(c, server.Client(), http.MethodPost, server.URL, false); err != nil {
+		t.Fatalf("MakeHTTPRequest: %v", err)
+	}
+	if !bytes.Equal(upstreamBody, body) || upstreamEncoding != "" {
+		t.Errorf("Expected plain body forwarded, got %d bytes with encoding %q", len(upstreamBody), upstreamEncoding)
+	}
+}
+
+func TestPreparePostRequest_ContentEncodingErrors(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`)
+
+	// unsupported encoding
+	err := PreparePostRequest(newPostContext(body, "br"), "solana")
+	if !errors.Is(err, ErrUnsupportedContentEncoding) {
+		t.Errorf("Expected unsupported content encoding error, got %v", err)
+	}
+
+	// not a gzip body
+	var httpErr *echo.HTTPError
+	err = PreparePostRequest(newPostContext(body, "gzip"), "solana")
+	if !errors.As(err, &httpErr) || httpErr.Message != ParseErrorResponse {
+		t.Errorf("Expected parse error response, got %v", err)
+	}
+
+	// decompression bomb, the limit follows the configured body limit
+	SetMaxDecompressedBodySize(1 << 10)
+	defer SetMaxDecompressedBodySize(echoUtil.DefaultBodyLimit)
+	bomb := gzipBody(t, bytes.Repeat([]byte(" "), 1<<10+1))
+	err = PreparePostRequest(newPostContext(bomb, "gzip"), "solana")
+	if !errors.As(err, &httpErr) || httpErr.Message != ParseErrorResponse {
+		t.Errorf("Expected parse error response for oversized body, got %v", err)
+	}
+	if err = PreparePostRequest(newPostContext(gzipBody(t, body), "gzip"), "solana"); err != nil {
+		t.Errorf("Expected a body within the limit to be accepted, got %v", err)
+	}
+
+	// uncompressed body is untouched
+	c := newPostContext(body, "")
+	if err = PreparePostRequest(c, "solana"); err != nil {
+		t.Fatalf("PreparePostRequest: %v", err)
+	}
+	if got, _ := io.ReadAll(c.GetReqBody()); !bytes.Equal(got, body) {
+		t.Errorf("Expected original body, got %s", got)
+	}
+}
+
+func TestMakeHTTPRequest_UpstreamErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack() //nolint:forcetypeassert
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer closing.Close()
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refusedURL := refused.URL
+	refused.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		client      *http.Client
+		expectedErr error
+	}{
+		{
+			name:        "connection refused",
+			url:         refusedURL,
+			client:      &http.Client{},
+			expectedErr: ErrUpstreamConnRefused,
+		},
+		{
+			name:        "connection closed",
+			url:         closing.URL,
+			client:      &http.Client{},
+			expectedErr: ErrUpstreamReset,
+		},
+		{
+			name: "read timeout",
+			url:  slow.URL,
+			client: &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+				if err == nil {
+					err = conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+				}
+				return conn, err
+			}}},
+			expectedErr: ErrUpstreamTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPostContext([]byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`), "")
+			if err := PreparePostRequest(c, "solana"); err != nil {
+				t.Fatalf("PreparePostRequest: %v", err)
+			}
+
+			_, _, err := MakeHTTPRequest(c, tt.client, http.MethodPost, tt.url, false)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestWithDeadlineHeader(t *testing.T) {
+	headers := make(chan []string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Values(HeaderDeadlineMs)
+	}))
+	defer server.Close()
+	e := echo.New()
+
+	send := func(ctx context.Context, client *http.Client) []string {
+		t.Helper()
+
+		c := &echoUtil.CustomContext{Context: e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx), httptest.NewRecorder())}
+		req, err := newProxyRequest(c, http.MethodGet, server.URL)
+		if err != nil {
+			t.Fatalf("newProxyRequest: %v", err)
+		}
+		if values := req.Header.Values(HeaderDeadlineMs); len(values) != 0 {
+			t.Errorf("Expected no deadline header on the built request, got %v", values)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		resp.Body.Close()
+
+		return <-headers
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// not opted in
+	if values := send(ctx, &http.Client{}); len(values) != 0 {
+		t.Errorf("Expected no deadline header, got %v", values)
+	}
+
+	client := WithDeadlineHeader(&http.Client{})
+	// no deadline
+	if values := send(context.Background(), client); len(values) != 0 {
+		t.Errorf("Expected no deadline header without a deadline, got %v", values)
+	}
+
+	// the remaining time of the client request
+	values := send(ctx, client)
+	if len(values) != 1 {
+		t.Fatalf("Expected a deadline header, got %v", values)
+	}
+	deadlineMs, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || deadlineMs <= 1000 || deadlineMs > 2000 {
+		t.Errorf("Expected a deadline in (1000, 2000] ms, got %q", values[0])
+	}
+}
+
+func TestNewProxyRequest_ProxyHopsHeader(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name     string
+		incoming string
+		expected string
+	}{
+		{name: "first hop", expected: "1"},
+		{name: "behind another proxy", incoming: "2", expected: "3"},
+		{name: "invalid header", incoming: "abc", expected: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.incoming != "" {
+				incoming.Header.Set(HeaderProxyHops, tt.incoming)
+			}
+			c := &echoUtil.CustomContext{Context: e.NewContext(incoming, httptest.NewRecorder())}
+			req, err := newProxyRequest(c, http.MethodGet, "http://node")
+			if err != nil {
+				t.Fatalf("newProxyRequest: %v", err)
+			}
+			if got := req.Header.Get(HeaderProxyHops); got != tt.expected {
+				t.Errorf("Expected %s hops, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewProxyRequest_UserAgent(t *testing.T) {
+	defer SetUserAgent(userAgent)
+	e := echo.New()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{name: "default", userAgent: DefaultUserAgent("proxy-prod"), expected: "aura-proxy/dev (proxy-prod)"},
+		{name: "no service name", userAgent: DefaultUserAgent(""), expected: "aura-proxy/dev"},
+		{name: "configured", userAgent: "custom-agent/1.0", expected: "custom-agent/1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetUserAgent(tt.userAgent)
+			incoming := httptest.NewRequest(http.MethodPost, "/", nil)
+			incoming.Header.Set("User-Agent", "client-agent")
+			c := &echoUtil.CustomContext{Context: e.NewContext(incoming, httptest.NewRecorder())}
+			req, err := newProxyRequest(c, http.MethodGet, "http://node")
+			if err != nil {
+				t.Fatalf("newProxyRequest: %v", err)
+			}
+			if got := req.Header.Get("User-Agent"); got != tt.expected {
+				t.Errorf("Expected User-Agent %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -2,19 +2,119 @@ package balancer
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math"
 	"math/rand"
-	"sort"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TargetSelector interface abstracts the target selection logic.
 type TargetSelector[T any] interface {
 	GetNext(exclude []int) (T, int, error) // Returns target, index, and error
+	// GetNextBatch returns up to n distinct targets and their indices, for callers fanning a request out.
+	// An error is returned only when no target can be selected
+	GetNextBatch(n int, exclude []int) ([]T, []int, error)
 	IsAvailable() bool
 	GetTargetsCount() int
 }
 
+// SeededTargetSelector is implemented by selectors able to draw from a caller-provided RNG.
+// It makes the selection sequence reproducible for a given seed.
+type SeededTargetSelector[T any] interface {
+	GetNextWithRand(r *rand.Rand, exclude []int) (T, int, error)
+}
+
+// KeyedTargetSelector is implemented by selectors mapping a request key (e.g. account address) to a stable target.
+type KeyedTargetSelector[T any] interface {
+	GetNextForKey(key string, exclude []int) (T, int, error)
+}
+
+// ExclusionsTargetSelector is implemented by selectors checking Exclusions directly, without the index slice.
+type ExclusionsTargetSelector[T any] interface {
+	GetNextExcluding(exclude *Exclusions) (T, int, error)
+}
+
+// Exclusions is a set of target indices excluded from the selection (e.g. failed on previous attempts).
+// It's reused across the attempts of a request and has O(1) lookups. A nil set is empty.
+type Exclusions struct {
+	excluded []bool
+	indices  []int // in the order of addition, for selectors taking an index slice
+}
+
+// exclusionsIndicesCap is the initial capacity of Exclusions indices, requests usually have a few attempts
+const exclusionsIndicesCap = 8
+
+// NewExclusions creates an empty set for size targets
+func NewExclusions(size int) *Exclusions {
+	return &Exclusions{excluded: make([]bool, size), indices: make([]int, 0, min(size, exclusionsIndicesCap))}
+}
+
+func (e *Exclusions) Add(index int) {
+	if index < 0 || e.Contains(index) {
+		return
+	}
+	if index >= len(e.excluded) {
+		e.excluded = append(e.excluded, make([]bool, index+1-len(e.excluded))...)
+	}
+	e.excluded[index] = true
+	e.indices = append(e.indices, index)
+}
+
+func (e *Exclusions) Contains(index int) bool {
+	return e != nil && index >= 0 && index < len(e.excluded) && e.excluded[index]
+}
+
+func (e *Exclusions) Len() int {
+	if e == nil {
+		return 0
+	}
+
+	return len(e.indices)
+}
+
+// Indices returns excluded indices in the order of addition. The slice must not be modified
+func (e *Exclusions) Indices() []int {
+	if e == nil {
+		return nil
+	}
+
+	return e.indices
+}
+
+// nextBatch selects up to n distinct targets with next, each selected target is excluded from the following selections
+func nextBatch[T any](n int, exclude []int, next func(exclude []int) (T, int, error)) (targets []T, indices []int, err error) {
+	if n <= 0 {
+		return nil, nil, nil
+	}
+
+	exclude = slices.Clip(exclude) // the caller slice isn't modified by appends
+	for len(indices) < n {
+		target, index, nextErr := next(exclude)
+		if nextErr != nil {
+			if len(indices) == 0 {
+				return nil, nil, nextErr
+			}
+			break
+		}
+		targets = append(targets, target)
+		indices = append(indices, index)
+		exclude = append(exclude, index)
+	}
+
+	return targets, indices, nil
+}
+
+// SeedFromString derives a deterministic RNG seed from s (e.g. request id)
+func SeedFromString(s string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	return int64(h.Sum64())
+}
+
 // RoundRobin (existing implementation, modified to implement TargetSelector)
 type RoundRobin[T comparable] struct {
 	mx      *sync.Mutex
@@ -38,11 +138,48 @@ func (r *RoundRobin[T]) GetNext(exclude []int) (t T, index int, err error) {
 		return t, -1, fmt.Errorf("no targets available")
 	}
 
-	// Simple round-robin, ignoring excludes (for now, in this basic implementation)
-	index = r.counter
-	r.counter = (r.counter + 1) % len(r.targets)
-	t = r.targets[index]
-	return t, index, nil
+	// Take the next not excluded target starting from the counter
+	for i := range r.targets {
+		index = (r.counter + i) % len(r.targets)
+		if slices.Contains(exclude, index) {
+			continue
+		}
+		r.counter = (index + 1) % len(r.targets)
+
+		return r.targets[index], index, nil
+	}
+
+	return t, -1, fmt.Errorf("all targets excluded")
+}
+
+// GetNextBatch implements the TargetSelector interface for RoundRobin, taking the next not excluded targets in order
+func (r *RoundRobin[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	if n <= 0 {
+		return nil, nil, nil
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if len(r.targets) == 0 {
+		return nil, nil, fmt.Errorf("no targets available")
+	}
+
+	start := r.counter
+	for i := 0; i < len(r.targets) && len(indices) < n; i++ {
+		index := (start + i) % len(r.targets)
+		if slices.Contains(exclude, index) {
+			continue
+		}
+		targets = append(targets, r.targets[index])
+		indices = append(indices, index)
+		r.counter = (index + 1) % len(r.targets)
+	}
+	if len(indices) == 0 {
+		return nil, nil, fmt.Errorf("all targets excluded")
+	}
+
+	return targets, indices, nil
 }
 
 func (r *RoundRobin[T]) GetByCounter(counter int) (t T) {
@@ -77,21 +214,57 @@ func (r *RoundRobin[T]) GetTargetsCount() int {
 
 // ProbabilisticBalancer
 type ProbabilisticBalancer[T any] struct {
-	targets           []T
+	targets []T
+	table   atomic.Pointer[weightTable] // replaced as a whole by SetWeights, so selections see consistent weights
+	r       *rand.Rand                  // Use a dedicated random number generator
+
+	// Optional dynamic multiplier applied to the static weights on every selection
+	weightMultiplier func(target T) float64
+}
+
+// weightTable holds normalized weights and their cumulative sums for efficient selection
+type weightTable struct {
 	weights           []float64
 	cumulativeWeights []float64
-	r                 *rand.Rand // Use a dedicated random number generator
 }
 
 func NewProbabilisticBalancer[T any](targets []T, weights []float64) (*ProbabilisticBalancer[T], error) {
-	if len(targets) != len(weights) {
-		return nil, fmt.Errorf("number of targets (%d) must match number of weights (%d)", len(targets), len(weights))
-	}
-
 	if len(targets) == 0 {
 		return nil, fmt.Errorf("must provide at least one target")
 	}
 
+	p := &ProbabilisticBalancer[T]{
+		targets: targets,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())), // Initialize the random number generator
+	}
+	if err := p.SetWeights(weights); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetWeights replaces the static weights of the targets, e.g. to down-weight a slow target. Safe for concurrent use
+func (p *ProbabilisticBalancer[T]) SetWeights(weights []float64) error {
+	table, err := newWeightTable(weights, len(p.targets))
+	if err != nil {
+		return err
+	}
+	p.table.Store(table)
+
+	return nil
+}
+
+// Weights returns the normalized static weights of the targets. The slice must not be modified
+func (p *ProbabilisticBalancer[T]) Weights() []float64 {
+	return p.table.Load().weights
+}
+
+func newWeightTable(weights []float64, targetsCount int) (*weightTable, error) {
+	if targetsCount != len(weights) {
+		return nil, fmt.Errorf("number of targets (%d) must match number of weights (%d)", targetsCount, len(weights))
+	}
+
 	for _, w := range weights {
 		if w < 0 {
 			return nil, fmt.Errorf("weights must be non-negative")
@@ -120,23 +293,75 @@ func NewProbabilisticBalancer[T any](targets []T, weights []float64) (*Probabili
 		cumulativeWeights[i] = cumulativeSum
 	}
 
-	return &ProbabilisticBalancer[T]{
-		targets:           targets,
-		weights:           normalizedWeights, // Store normalized weights
-		cumulativeWeights: cumulativeWeights,
-		r:                 rand.New(rand.NewSource(time.Now().UnixNano())), // Initialize the random number generator
-	}, nil
+	return &weightTable{weights: normalizedWeights, cumulativeWeights: cumulativeWeights}, nil
+}
+
+// SetWeightMultiplier sets a dynamic multiplier of target weights. Must be called before the balancer is used
+func (p *ProbabilisticBalancer[T]) SetWeightMultiplier(fn func(target T) float64) {
+	p.weightMultiplier = fn
+}
+
+// exclusionCheck looks up excluded indices in the set when provided, otherwise in the slice
+type exclusionCheck struct {
+	set   []bool
+	list  []int
+	count int
+}
+
+// exclusionListMaxLen is the longest exclude slice scanned linearly, longer ones are converted to a set
+const exclusionListMaxLen = 4
+
+func newExclusionCheck(exclude []int, size int) exclusionCheck {
+	if len(exclude) <= exclusionListMaxLen {
+		return exclusionCheck{list: exclude, count: len(exclude)}
+	}
+
+	set := make([]bool, size)
+	for _, i := range exclude {
+		if i >= 0 && i < size {
+			set[i] = true
+		}
+	}
+
+	return exclusionCheck{set: set, count: len(exclude)}
+}
+
+func (c exclusionCheck) has(i int) bool {
+	if c.set != nil {
+		return i < len(c.set) && c.set[i]
+	}
+
+	return slices.Contains(c.list, i)
 }
 
 func (p *ProbabilisticBalancer[T]) GetNext(exclude []int) (t T, index int, err error) {
+	return p.getNext(p.r.Float64, newExclusionCheck(exclude, len(p.targets)))
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for ProbabilisticBalancer.
+func (p *ProbabilisticBalancer[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return p.getNext(r.Float64, newExclusionCheck(exclude, len(p.targets)))
+}
+
+// GetNextExcluding implements the ExclusionsTargetSelector interface for ProbabilisticBalancer.
+func (p *ProbabilisticBalancer[T]) GetNextExcluding(exclude *Exclusions) (t T, index int, err error) {
+	if exclude == nil {
+		return p.getNext(p.r.Float64, exclusionCheck{})
+	}
+
+	return p.getNext(p.r.Float64, exclusionCheck{set: exclude.excluded, count: exclude.Len()})
+}
+
+func (p *ProbabilisticBalancer[T]) getNext(randFloat func() float64, exclude exclusionCheck) (t T, index int, err error) {
 	if len(p.targets) == 0 {
 		return t, -1, fmt.Errorf("no targets available")
 	}
 
-	// Fast path for no exclusions.
-	if len(exclude) == 0 {
-		randomValue := p.r.Float64()
-		for i, cw := range p.cumulativeWeights {
+	table := p.table.Load()
+	// Fast path for no exclusions and static weights.
+	if exclude.count == 0 && p.weightMultiplier == nil {
+		randomValue := randFloat()
+		for i, cw := range table.cumulativeWeights {
 			if randomValue <= cw {
 				return p.targets[i], i, nil
 			}
@@ -145,51 +370,54 @@ func (p *ProbabilisticBalancer[T]) GetNext(exclude []int) (t T, index int, err e
 		return t, -1, fmt.Errorf("internal error: no target selected")
 	}
 
-	// Sort the exclude slice for efficient lookup.
-	sort.Ints(exclude)
+	weights := table.weights
+	if p.weightMultiplier != nil {
+		weights = make([]float64, len(table.weights))
+		for i, w := range table.weights {
+			weights[i] = w * p.weightMultiplier(p.targets[i])
+		}
+	}
 
-	filteredIndices := make([]int, 0, len(p.targets)) // Pre-allocate with maximum possible size
+	// Sum weights of not excluded targets, then walk them again to find the selected one
+	firstIndex, lastIndex := -1, -1
 	cumulativeSum := 0.0
-	excludeIndex := 0 // Index for iterating through the sorted exclude slice
-	cumulativeWeights := make([]float64, 0, len(p.targets))
-
-	// Iterate through targets and build cumulative weights, skipping excluded targets.
 	for i := range p.targets {
-		// Check if the current target is excluded using the sorted exclude slice.
-		if excludeIndex < len(exclude) && i == exclude[excludeIndex] {
-			excludeIndex++ // Move to the next excluded index
-			continue       // Skip this target
+		if exclude.count != 0 && exclude.has(i) {
+			continue
 		}
-
-		cumulativeSum += p.weights[i]
-		filteredIndices = append(filteredIndices, i)
-		cumulativeWeights = append(cumulativeWeights, cumulativeSum)
+		if firstIndex == -1 {
+			firstIndex = i
+		}
+		lastIndex = i
+		cumulativeSum += weights[i]
 	}
 
-	if len(filteredIndices) == 0 {
+	if firstIndex == -1 {
 		return t, -1, fmt.Errorf("all targets excluded")
 	}
 	if cumulativeSum == 0 {
-		return p.targets[filteredIndices[0]], filteredIndices[0], nil
+		return p.targets[firstIndex], firstIndex, nil
 	}
 
-	randomValue := p.r.Float64() * cumulativeSum
-	selectedOriginalIndex := -1
-	for i, cumWeight := range cumulativeWeights {
-		if i >= len(filteredIndices) {
-			break
+	randomValue := randFloat() * cumulativeSum
+	cumWeight := 0.0
+	for i := firstIndex; i <= lastIndex; i++ {
+		if exclude.count != 0 && exclude.has(i) {
+			continue
 		}
+		cumWeight += weights[i]
 		if randomValue <= cumWeight {
-			selectedOriginalIndex = filteredIndices[i]
-			break
+			return p.targets[i], i, nil
 		}
 	}
 
-	if selectedOriginalIndex == -1 {
-		selectedOriginalIndex = filteredIndices[len(filteredIndices)-1]
-	}
+	return p.targets[lastIndex], lastIndex, nil
+}
 
-	return p.targets[selectedOriginalIndex], selectedOriginalIndex, nil
+// GetNextBatch implements the TargetSelector interface for ProbabilisticBalancer.
+// Targets are drawn by weight without replacement
+func (p *ProbabilisticBalancer[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, p.GetNext)
 }
 
 func (p *ProbabilisticBalancer[T]) IsAvailable() bool {
@@ -199,3 +427,437 @@ func (p *ProbabilisticBalancer[T]) IsAvailable() bool {
 func (p *ProbabilisticBalancer[T]) GetTargetsCount() int {
 	return len(p.targets)
 }
+
+// OrderedSelector is a composite selector trying its selectors in order: a selector is used
+// only when all targets of the previous ones are excluded (e.g. failed on previous attempts).
+// Indices are global: targets of each selector follow the targets of the previous one.
+type OrderedSelector[T any] struct {
+	selectors []TargetSelector[T]
+	offsets   []int
+	count     int
+}
+
+func NewOrderedSelector[T any](selectors ...TargetSelector[T]) (*OrderedSelector[T], error) {
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("must provide at least one selector")
+	}
+
+	o := &OrderedSelector[T]{
+		selectors: selectors,
+		offsets:   make([]int, len(selectors)),
+	}
+	for i, s := range selectors {
+		o.offsets[i] = o.count
+		o.count += s.GetTargetsCount()
+	}
+
+	return o, nil
+}
+
+// GetNext implements the TargetSelector interface for OrderedSelector.
+func (o *OrderedSelector[T]) GetNext(exclude []int) (t T, index int, err error) {
+	return o.getNext(exclude, func(s TargetSelector[T], localExclude []int) (T, int, error) {
+		return s.GetNext(localExclude)
+	})
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for OrderedSelector.
+func (o *OrderedSelector[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return o.getNext(exclude, func(s TargetSelector[T], localExclude []int) (T, int, error) {
+		if seeded, ok := s.(SeededTargetSelector[T]); ok {
+			return seeded.GetNextWithRand(r, localExclude)
+		}
+		return s.GetNext(localExclude)
+	})
+}
+
+func (o *OrderedSelector[T]) getNext(exclude []int, next func(s TargetSelector[T], localExclude []int) (T, int, error)) (t T, index int, err error) {
+	for i, s := range o.selectors {
+		offset, size := o.offsets[i], s.GetTargetsCount()
+
+		// Translate global indices to the selector ones
+		localExclude := make([]int, 0, len(exclude))
+		for _, idx := range exclude {
+			if idx >= offset && idx < offset+size {
+				localExclude = append(localExclude, idx-offset)
+			}
+		}
+
+		t, index, err = next(s, localExclude)
+		if err != nil {
+			continue // all targets of the selector are excluded
+		}
+
+		return t, index + offset, nil
+	}
+
+	return t, -1, fmt.Errorf("all targets excluded")
+}
+
+// GetNextBatch implements the TargetSelector interface for OrderedSelector.
+func (o *OrderedSelector[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, o.GetNext)
+}
+
+func (o *OrderedSelector[T]) IsAvailable() bool {
+	for _, s := range o.selectors {
+		if s.IsAvailable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *OrderedSelector[T]) GetTargetsCount() int {
+	return o.count
+}
+
+// LeastLatency selects the target with the lowest latency, ties are broken randomly.
+// Targets without latency stats (0) are preferred, so they get probed.
+type LeastLatency[T any] struct {
+	mx      *sync.Mutex
+	targets []T
+	latency func(target T) float64
+	r       *rand.Rand
+}
+
+func NewLeastLatency[T any](targets []T, latency func(target T) float64) (*LeastLatency[T], error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("must provide at least one target")
+	}
+
+	return &LeastLatency[T]{
+		mx:      &sync.Mutex{},
+		targets: targets,
+		latency: latency,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// GetNext implements the TargetSelector interface for LeastLatency.
+func (l *LeastLatency[T]) GetNext(exclude []int) (t T, index int, err error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.getNext(l.r, exclude)
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for LeastLatency.
+func (l *LeastLatency[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return l.getNext(r, exclude)
+}
+
+func (l *LeastLatency[T]) getNext(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	best := math.Inf(1)
+	var candidates []int
+	for _, i := range availableIndices(len(l.targets), exclude) {
+		switch latency := l.latency(l.targets[i]); {
+		case latency < best:
+			best = latency
+			candidates = append(candidates[:0], i)
+		case latency == best:
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return t, -1, fmt.Errorf("all targets excluded")
+	}
+
+	index = candidates[r.Intn(len(candidates))]
+
+	return l.targets[index], index, nil
+}
+
+// GetNextBatch implements the TargetSelector interface for LeastLatency.
+func (l *LeastLatency[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, l.GetNext)
+}
+
+func (l *LeastLatency[T]) IsAvailable() bool {
+	return len(l.targets) > 0
+}
+
+func (l *LeastLatency[T]) GetTargetsCount() int {
+	return len(l.targets)
+}
+
+// PowerOfTwoChoices picks two random targets and selects the one with the lower load.
+// It avoids herding on a single best target, unlike LeastLatency.
+type PowerOfTwoChoices[T any] struct {
+	mx      *sync.Mutex
+	targets []T
+	load    func(target T) float64
+	r       *rand.Rand
+}
+
+func NewPowerOfTwoChoices[T any](targets []T, load func(target T) float64) (*PowerOfTwoChoices[T], error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("must provide at least one target")
+	}
+
+	return &PowerOfTwoChoices[T]{
+		mx:      &sync.Mutex{},
+		targets: targets,
+		load:    load,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// GetNext implements the TargetSelector interface for PowerOfTwoChoices.
+func (p *PowerOfTwoChoices[T]) GetNext(exclude []int) (t T, index int, err error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.getNext(p.r, exclude)
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for PowerOfTwoChoices.
+func (p *PowerOfTwoChoices[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return p.getNext(r, exclude)
+}
+
+func (p *PowerOfTwoChoices[T]) getNext(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	available := availableIndices(len(p.targets), exclude)
+	switch len(available) {
+	case 0:
+		return t, -1, fmt.Errorf("all targets excluded")
+	case 1:
+		index = available[0]
+		return p.targets[index], index, nil
+	}
+
+	first := r.Intn(len(available))
+	second := r.Intn(len(available) - 1)
+	if second >= first { // distinct from the first one
+		second++
+	}
+
+	index = available[first]
+	if other := available[second]; p.load(p.targets[other]) < p.load(p.targets[index]) {
+		index = other
+	}
+
+	return p.targets[index], index, nil
+}
+
+// GetNextBatch implements the TargetSelector interface for PowerOfTwoChoices.
+func (p *PowerOfTwoChoices[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, p.GetNext)
+}
+
+func (p *PowerOfTwoChoices[T]) IsAvailable() bool {
+	return len(p.targets) > 0
+}
+
+func (p *PowerOfTwoChoices[T]) GetTargetsCount() int {
+	return len(p.targets)
+}
+
+// compositeScoreTolerance is the score distance from the best target within which targets are selected randomly,
+// so similar targets share the load instead of herding on the best one
+const compositeScoreTolerance = 0.05
+
+// Composite selects the target with the lowest weighted sum of its latency, normalized by the max latency among
+// available targets, and its error rate. The error rate is a fraction already, normalizing it would turn
+// negligible differences into the full score range. Targets close to the best score are selected randomly.
+type Composite[T any] struct {
+	mx              *sync.Mutex
+	targets         []T
+	latency         func(target T) float64
+	errorRate       func(target T) float64
+	latencyWeight   float64
+	errorRateWeight float64
+	r               *rand.Rand
+}
+
+func NewComposite[T any](targets []T, latency, errorRate func(target T) float64, latencyWeight, errorRateWeight float64) (*Composite[T], error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("must provide at least one target")
+	}
+	if latencyWeight < 0 || errorRateWeight < 0 || latencyWeight+errorRateWeight == 0 {
+		return nil, fmt.Errorf("weights must be non-negative with a positive sum")
+	}
+
+	return &Composite[T]{
+		mx:              &sync.Mutex{},
+		targets:         targets,
+		latency:         latency,
+		errorRate:       errorRate,
+		latencyWeight:   latencyWeight,
+		errorRateWeight: errorRateWeight,
+		r:               rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// GetNext implements the TargetSelector interface for Composite.
+func (c *Composite[T]) GetNext(exclude []int) (t T, index int, err error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.getNext(c.r, exclude)
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for Composite.
+func (c *Composite[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return c.getNext(r, exclude)
+}
+
+func (c *Composite[T]) getNext(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	available := availableIndices(len(c.targets), exclude)
+	if len(available) == 0 {
+		return t, -1, fmt.Errorf("all targets excluded")
+	}
+
+	latencies := make([]float64, len(available))
+	errorRates := make([]float64, len(available))
+	var maxLatency float64
+	for i, idx := range available {
+		latencies[i], errorRates[i] = c.latency(c.targets[idx]), c.errorRate(c.targets[idx])
+		maxLatency = max(maxLatency, latencies[i])
+	}
+
+	scores := make([]float64, len(available))
+	best := math.Inf(1)
+	for i := range available {
+		scores[i] = c.latencyWeight*normalize(latencies[i], maxLatency) + c.errorRateWeight*errorRates[i]
+		best = min(best, scores[i])
+	}
+
+	candidates := make([]int, 0, len(available))
+	for i, idx := range available {
+		if scores[i] <= best+compositeScoreTolerance*(c.latencyWeight+c.errorRateWeight) {
+			candidates = append(candidates, idx)
+		}
+	}
+	index = candidates[r.Intn(len(candidates))]
+
+	return c.targets[index], index, nil
+}
+
+// GetNextBatch implements the TargetSelector interface for Composite.
+func (c *Composite[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, c.GetNext)
+}
+
+func (c *Composite[T]) IsAvailable() bool {
+	return len(c.targets) > 0
+}
+
+func (c *Composite[T]) GetTargetsCount() int {
+	return len(c.targets)
+}
+
+// normalize scales v to [0, 1] by the max value, 0 if the max is not positive
+func normalize(v, maxValue float64) float64 {
+	if maxValue <= 0 {
+		return 0
+	}
+
+	return v / maxValue
+}
+
+// ConsistentHash maps a request key to a stable target with rendezvous hashing:
+// only keys of a removed or excluded target move to other targets.
+// Requests without a key get a random target.
+type ConsistentHash[T any] struct {
+	mx      *sync.Mutex
+	targets []T
+	ids     []string
+	r       *rand.Rand
+}
+
+// NewConsistentHash creates the selector, ids are stable target identifiers (e.g. URLs)
+func NewConsistentHash[T any](targets []T, ids []string) (*ConsistentHash[T], error) {
+	if len(targets) != len(ids) {
+		return nil, fmt.Errorf("number of targets (%d) must match number of ids (%d)", len(targets), len(ids))
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("must provide at least one target")
+	}
+
+	return &ConsistentHash[T]{
+		mx:      &sync.Mutex{},
+		targets: targets,
+		ids:     ids,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// GetNext implements the TargetSelector interface for ConsistentHash.
+func (c *ConsistentHash[T]) GetNext(exclude []int) (t T, index int, err error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.getRandom(c.r, exclude)
+}
+
+// GetNextWithRand implements the SeededTargetSelector interface for ConsistentHash.
+func (c *ConsistentHash[T]) GetNextWithRand(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	return c.getRandom(r, exclude)
+}
+
+// GetNextForKey implements the KeyedTargetSelector interface for ConsistentHash.
+func (c *ConsistentHash[T]) GetNextForKey(key string, exclude []int) (t T, index int, err error) {
+	if key == "" {
+		return c.GetNext(exclude)
+	}
+
+	index = -1
+	var best uint64
+	for _, i := range availableIndices(len(c.targets), exclude) {
+		if score := rendezvousScore(key, c.ids[i]); index == -1 || score > best {
+			best, index = score, i
+		}
+	}
+	if index == -1 {
+		return t, -1, fmt.Errorf("all targets excluded")
+	}
+
+	return c.targets[index], index, nil
+}
+
+func (c *ConsistentHash[T]) getRandom(r *rand.Rand, exclude []int) (t T, index int, err error) {
+	available := availableIndices(len(c.targets), exclude)
+	if len(available) == 0 {
+		return t, -1, fmt.Errorf("all targets excluded")
+	}
+
+	index = available[r.Intn(len(available))]
+
+	return c.targets[index], index, nil
+}
+
+// GetNextBatch implements the TargetSelector interface for ConsistentHash.
+func (c *ConsistentHash[T]) GetNextBatch(n int, exclude []int) (targets []T, indices []int, err error) {
+	return nextBatch(n, exclude, c.GetNext)
+}
+
+func (c *ConsistentHash[T]) IsAvailable() bool {
+	return len(c.targets) > 0
+}
+
+func (c *ConsistentHash[T]) GetTargetsCount() int {
+	return len(c.targets)
+}
+
+func rendezvousScore(key, id string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(id))
+
+	return h.Sum64()
+}
+
+// availableIndices returns indices in [0, n) not present in exclude
+func availableIndices(n int, exclude []int) []int {
+	res := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if !slices.Contains(exclude, i) {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
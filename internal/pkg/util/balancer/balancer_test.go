@@ -1,7 +1,11 @@
 package balancer
 
 import (
+	"fmt"
 	"math"
+	"math/rand"
+	"reflect"
+	"slices"
 	"sync"
 	"testing"
 )
@@ -266,6 +270,61 @@ func TestProbabilisticBalancer_GetTargetsCount(t *testing.T) {
 	}
 }
 
+func TestProbabilisticBalancer_GetNextWithRand(t *testing.T) {
+	targets := []string{"target1", "target2", "target3", "target4"}
+	weights := []float64{0.4, 0.3, 0.2, 0.1}
+	balancer, err := NewProbabilisticBalancer(targets, weights)
+	if err != nil {
+		t.Fatalf("Error creating balancer: %v", err)
+	}
+
+	selectSequence := func(reqID string) []int {
+		r := rand.New(rand.NewSource(SeedFromString(reqID)))
+		var (
+			exclude  []int
+			sequence []int
+		)
+		for range targets {
+			_, index, err := balancer.GetNextWithRand(r, exclude)
+			if err != nil {
+				t.Fatalf("Error getting next target: %v", err)
+			}
+			sequence = append(sequence, index)
+			exclude = append(exclude, index)
+		}
+		return sequence
+	}
+
+	// Same request id yields the same selection sequence
+	for _, reqID := range []string{"req-1", "req-2", "0b7f3c4e-1d2a-4f5b-9c8d-7e6f5a4b3c2d"} {
+		first := selectSequence(reqID)
+		for i := 0; i < 10; i++ {
+			if got := selectSequence(reqID); !reflect.DeepEqual(first, got) {
+				t.Fatalf("Request %s: expected sequence %v, got %v", reqID, first, got)
+			}
+		}
+	}
+
+	// Selection stays weighted across different request ids
+	numIterations := 100000
+	counts := make(map[string]int)
+	for i := 0; i < numIterations; i++ {
+		r := rand.New(rand.NewSource(SeedFromString(fmt.Sprintf("req-%d", i))))
+		target, _, err := balancer.GetNextWithRand(r, nil)
+		if err != nil {
+			t.Fatalf("Error getting next target: %v", err)
+		}
+		counts[target]++
+	}
+	tolerance := 0.02
+	for i, target := range targets {
+		actualRatio := float64(counts[target]) / float64(numIterations)
+		if deviation := math.Abs(actualRatio - weights[i]); deviation > tolerance {
+			t.Errorf("Target %s: expected ratio ≈ %f, got %f (deviation %f)", target, weights[i], actualRatio, deviation)
+		}
+	}
+}
+
 func BenchmarkProbabilisticBalancer_GetNext_2Targets_NoExclusions(b *testing.B) {
 	targets := []string{"target1", "target2"}
 	weights := []float64{0.5, 0.5}
@@ -316,6 +375,52 @@ func BenchmarkRoundRobinBalancer_GetNext_3Targets_NoExclusions(b *testing.B) {
 	}
 }
 
+// benchmarkRetries selects targets of a request failing on every attempt
+func benchmarkRetries(b *testing.B, next func(pb *ProbabilisticBalancer[int], attempt int) int) {
+	const (
+		targetsCount = 50
+		attempts     = 10
+	)
+	targets := make([]int, targetsCount)
+	weights := make([]float64, targetsCount)
+	for i := range targets {
+		targets[i], weights[i] = i, 1
+	}
+	pb, _ := NewProbabilisticBalancer(targets, weights)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for attempt := 0; attempt < attempts; attempt++ {
+			next(pb, attempt)
+		}
+	}
+}
+
+func BenchmarkProbabilisticBalancer_GetNext_50Targets_Retries(b *testing.B) {
+	var exclude []int
+	benchmarkRetries(b, func(pb *ProbabilisticBalancer[int], attempt int) int {
+		if attempt == 0 {
+			exclude = make([]int, 0)
+		}
+		_, index, _ := pb.GetNext(exclude)
+		exclude = append(exclude, index)
+		return index
+	})
+}
+
+func BenchmarkProbabilisticBalancer_GetNextExcluding_50Targets_Retries(b *testing.B) {
+	var exclude *Exclusions
+	benchmarkRetries(b, func(pb *ProbabilisticBalancer[int], attempt int) int {
+		if attempt == 0 {
+			exclude = NewExclusions(pb.GetTargetsCount())
+		}
+		_, index, _ := pb.GetNextExcluding(exclude)
+		exclude.Add(index)
+		return index
+	})
+}
+
 func BenchmarkProbabilisticBalancer_GetNext_Concurrent(b *testing.B) {
 	targets := []string{"target1", "target2", "target3"}
 	weights := []float64{0.3, 0.3, 0.4}
@@ -354,3 +459,492 @@ func BenchmarkRoundRobinBalancer_GetNext_Concurrent(b *testing.B) {
 		wg.Wait()
 	}
 }
+
+func TestProbabilisticBalancer_WeightMultiplier(t *testing.T) {
+	targets := []string{"A", "B", "C"}
+	pb, err := NewProbabilisticBalancer(targets, []float64{1, 1, 1})
+	if err != nil {
+		t.Fatalf("NewProbabilisticBalancer failed: %v", err)
+	}
+	multipliers := map[string]float64{"A": 3, "B": 1, "C": 0}
+	pb.SetWeightMultiplier(func(target string) float64 { return multipliers[target] })
+
+	numRequests := 100000
+	counts := make(map[string]int)
+	for i := 0; i < numRequests; i++ {
+		target, _, err := pb.GetNext(nil)
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		counts[target]++
+	}
+
+	expected := map[string]float64{"A": 0.75, "B": 0.25, "C": 0}
+	for target, prob := range expected {
+		actual := float64(counts[target]) / float64(numRequests)
+		if math.Abs(actual-prob) > 0.01 {
+			t.Errorf("Target %s: expected probability ≈ %f, got %f", target, prob, actual)
+		}
+	}
+
+	// exclusions still apply
+	for i := 0; i < 100; i++ {
+		target, _, err := pb.GetNext([]int{0})
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		if target != "B" {
+			t.Errorf("Expected B, got %s", target)
+		}
+	}
+}
+
+func TestProbabilisticBalancer_SetWeights(t *testing.T) {
+	pb, err := NewProbabilisticBalancer([]string{"A", "B"}, []float64{1, 1})
+	if err != nil {
+		t.Fatalf("NewProbabilisticBalancer failed: %v", err)
+	}
+	if err = pb.SetWeights([]float64{1}); err == nil {
+		t.Errorf("Expected error for mismatched targets and weights, but got nil")
+	}
+	if err = pb.SetWeights([]float64{0, 0}); err == nil {
+		t.Errorf("Expected error for zero total weight, but got nil")
+	}
+
+	if err = pb.SetWeights([]float64{3, 1}); err != nil {
+		t.Fatalf("SetWeights failed: %v", err)
+	}
+	if weights := pb.Weights(); weights[0] != 0.75 || weights[1] != 0.25 {
+		t.Errorf("Expected normalized weights [0.75 0.25], got %v", weights)
+	}
+
+	numRequests := 100000
+	counts := make(map[string]int)
+	for i := 0; i < numRequests; i++ {
+		target, _, err := pb.GetNext(nil)
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		counts[target]++
+	}
+	if actual := float64(counts["A"]) / float64(numRequests); math.Abs(actual-0.75) > 0.01 {
+		t.Errorf("Target A: expected probability ≈ 0.75, got %f", actual)
+	}
+}
+
+func TestOrderedSelector_GetNext(t *testing.T) {
+	primary, err := NewProbabilisticBalancer([]string{"P1", "P2"}, []float64{1, 1})
+	if err != nil {
+		t.Fatalf("NewProbabilisticBalancer failed: %v", err)
+	}
+	secondary, err := NewProbabilisticBalancer([]string{"S1"}, []float64{1})
+	if err != nil {
+		t.Fatalf("NewProbabilisticBalancer failed: %v", err)
+	}
+	o, err := NewOrderedSelector[string](primary, secondary)
+	if err != nil {
+		t.Fatalf("NewOrderedSelector failed: %v", err)
+	}
+	if o.GetTargetsCount() != 3 || !o.IsAvailable() {
+		t.Fatalf("Expected 3 available targets, got %d", o.GetTargetsCount())
+	}
+
+	// secondary is never selected while primary targets are available
+	for i := 0; i < 1000; i++ {
+		target, index, err := o.GetNext(nil)
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		if target == "S1" || index > 1 {
+			t.Fatalf("Expected primary target, got %s (%d)", target, index)
+		}
+		target, index, err = o.GetNext([]int{0})
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		if target != "P2" || index != 1 {
+			t.Fatalf("Expected P2 (1), got %s (%d)", target, index)
+		}
+	}
+
+	// secondary is selected after all primary targets are excluded
+	target, index, err := o.GetNext([]int{1, 0})
+	if err != nil {
+		t.Fatalf("GetNext failed: %v", err)
+	}
+	if target != "S1" || index != 2 {
+		t.Errorf("Expected S1 (2), got %s (%d)", target, index)
+	}
+
+	if _, _, err = o.GetNext([]int{0, 1, 2}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+
+	if _, err = NewOrderedSelector[string](); err == nil {
+		t.Error("Expected error without selectors")
+	}
+}
+
+func TestRoundRobin_GetNext_Exclusion(t *testing.T) {
+	rr := NewRoundRobin([]string{"A", "B", "C"})
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		target, _, err := rr.GetNext([]int{1})
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		got = append(got, target)
+	}
+	if !reflect.DeepEqual(got, []string{"A", "C", "A", "C"}) {
+		t.Errorf("Expected excluded target to be skipped, got %v", got)
+	}
+
+	if _, _, err := rr.GetNext([]int{0, 1, 2}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+}
+
+func TestLeastLatency_GetNext(t *testing.T) {
+	latency := map[string]float64{"A": 30, "B": 10, "C": 20}
+	l, err := NewLeastLatency([]string{"A", "B", "C"}, func(target string) float64 { return latency[target] })
+	if err != nil {
+		t.Fatalf("NewLeastLatency failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		exclude []int
+		want    string
+	}{
+		{nil, "B"},
+		{[]int{1}, "C"},
+		{[]int{1, 2}, "A"},
+	} {
+		target, _, err := l.GetNext(tc.exclude)
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		if target != tc.want {
+			t.Errorf("Exclude %v: expected %s, got %s", tc.exclude, tc.want, target)
+		}
+	}
+	if _, _, err = l.GetNext([]int{0, 1, 2}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+
+	// ties are spread across targets
+	latency["A"], latency["C"] = 10, 10
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		target, _, _ := l.GetNext(nil)
+		counts[target]++
+	}
+	if len(counts) != 3 {
+		t.Errorf("Expected all tied targets to be selected, got %v", counts)
+	}
+
+	if _, err = NewLeastLatency([]string{}, nil); err == nil {
+		t.Error("Expected error without targets")
+	}
+}
+
+func TestPowerOfTwoChoices_GetNext(t *testing.T) {
+	load := map[string]float64{"A": 1, "B": 2, "C": 3}
+	p, err := NewPowerOfTwoChoices([]string{"A", "B", "C"}, func(target string) float64 { return load[target] })
+	if err != nil {
+		t.Fatalf("NewPowerOfTwoChoices failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		target, _, err := p.GetNext(nil)
+		if err != nil {
+			t.Fatalf("GetNext failed: %v", err)
+		}
+		counts[target]++
+	}
+	// the most loaded target always loses its pair, the least loaded one always wins
+	if counts["C"] != 0 || counts["A"] <= counts["B"] {
+		t.Errorf("Unexpected distribution: %v", counts)
+	}
+
+	target, index, err := p.GetNext([]int{0, 1})
+	if err != nil || target != "C" || index != 2 {
+		t.Errorf("Expected the only available target C (2), got %s (%d), err %v", target, index, err)
+	}
+	if _, _, err = p.GetNext([]int{0, 1, 2}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+}
+
+func TestComposite_GetNext(t *testing.T) {
+	// A is the fastest but fails often, B never fails but is the slowest, C is good at both
+	latency := map[string]float64{"A": 20, "B": 100, "C": 30}
+	errorRate := map[string]float64{"A": 0.5, "B": 0, "C": 0.1}
+	newComposite := func(latencyWeight, errorRateWeight float64) *Composite[string] {
+		c, err := NewComposite([]string{"A", "B", "C"},
+			func(target string) float64 { return latency[target] },
+			func(target string) float64 { return errorRate[target] },
+			latencyWeight, errorRateWeight)
+		if err != nil {
+			t.Fatalf("NewComposite failed: %v", err)
+		}
+		return c
+	}
+
+	for _, tc := range []struct {
+		name                           string
+		latencyWeight, errorRateWeight float64
+		exclude                        []int
+		want                           string
+	}{
+		{"best overall", 1, 1, nil, "C"},
+		{"best overall excluded", 1, 1, []int{2}, "A"},
+		{"latency only", 1, 0, nil, "A"},
+		{"error rate only", 0, 1, nil, "B"},
+	} {
+		target, _, err := newComposite(tc.latencyWeight, tc.errorRateWeight).GetNext(tc.exclude)
+		if err != nil {
+			t.Fatalf("%s: GetNext failed: %v", tc.name, err)
+		}
+		if target != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, target)
+		}
+	}
+	if _, _, err := newComposite(1, 1).GetNext([]int{0, 1, 2}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+
+	// close scores are spread across targets
+	latency["A"], errorRate["A"], errorRate["B"] = 31, 0.1, 0.1
+	c := newComposite(1, 1)
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		target, _, _ := c.GetNext(nil)
+		counts[target]++
+	}
+	if counts["A"] == 0 || counts["C"] == 0 || counts["B"] != 0 {
+		t.Errorf("Unexpected distribution: %v", counts)
+	}
+
+	// negligible error rates don't outweigh latency
+	latency["A"], latency["C"] = 20, 40
+	errorRate["A"], errorRate["B"], errorRate["C"] = 0.02, 0, 0.01
+	if target, _, _ := newComposite(1, 1).GetNext(nil); target != "A" {
+		t.Errorf("Expected the fastest target with a negligible error rate, got %s", target)
+	}
+
+	if _, err := NewComposite([]string{"A"}, nil, nil, 0, 0); err == nil {
+		t.Error("Expected error with zero weights")
+	}
+	if _, err := NewComposite([]string{}, nil, nil, 1, 1); err == nil {
+		t.Error("Expected error without targets")
+	}
+}
+
+func TestConsistentHash_GetNextForKey(t *testing.T) {
+	targets := []string{"A", "B", "C", "D"}
+	c, err := NewConsistentHash(targets, targets)
+	if err != nil {
+		t.Fatalf("NewConsistentHash failed: %v", err)
+	}
+
+	keys := make([]string, 200)
+	assigned := make(map[string]int, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		_, index, err := c.GetNextForKey(keys[i], nil)
+		if err != nil {
+			t.Fatalf("GetNextForKey failed: %v", err)
+		}
+		assigned[keys[i]] = index
+	}
+
+	used := make(map[int]bool)
+	for _, key := range keys {
+		_, index, _ := c.GetNextForKey(key, nil)
+		if index != assigned[key] {
+			t.Fatalf("Expected key %s to be mapped to the same target %d, got %d", key, assigned[key], index)
+		}
+		used[index] = true
+
+		// only keys of the excluded target move
+		_, moved, _ := c.GetNextForKey(key, []int{0})
+		if assigned[key] != 0 && moved != assigned[key] {
+			t.Errorf("Expected key %s to stay on %d after excluding 0, got %d", key, assigned[key], moved)
+		}
+		if moved == 0 {
+			t.Errorf("Expected key %s to move from the excluded target", key)
+		}
+	}
+	if len(used) != len(targets) {
+		t.Errorf("Expected keys to be spread across all targets, got %v", used)
+	}
+
+	if _, _, err = c.GetNextForKey("key", []int{0, 1, 2, 3}); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+	if _, _, err = c.GetNextForKey("", nil); err != nil {
+		t.Errorf("Expected random target without a key, got error %v", err)
+	}
+	if _, err = NewConsistentHash([]string{"A"}, []string{}); err == nil {
+		t.Error("Expected error on ids count mismatch")
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	var empty *Exclusions
+	if empty.Contains(0) || empty.Len() != 0 || empty.Indices() != nil {
+		t.Error("Expected a nil set to be empty")
+	}
+
+	e := NewExclusions(3)
+	e.Add(2)
+	e.Add(0)
+	e.Add(2)  // duplicate
+	e.Add(-1) // invalid
+	e.Add(5)  // over the initial size
+	if e.Len() != 3 {
+		t.Errorf("Expected 3 exclusions, got %d", e.Len())
+	}
+	if !reflect.DeepEqual(e.Indices(), []int{2, 0, 5}) {
+		t.Errorf("Expected indices in the order of addition, got %v", e.Indices())
+	}
+	for i, expected := range []bool{true, false, true, false, false, true, false} {
+		if e.Contains(i) != expected {
+			t.Errorf("Contains(%d): expected %v", i, expected)
+		}
+	}
+}
+
+func TestProbabilisticBalancer_GetNextExcluding(t *testing.T) {
+	targets := []string{"A", "B", "C", "D"}
+	pb, err := NewProbabilisticBalancer(targets, []float64{1, 0, 2, 1})
+	if err != nil {
+		t.Fatalf("NewProbabilisticBalancer failed: %v", err)
+	}
+
+	// the same sequence as GetNext with the index slice
+	exclude := NewExclusions(len(targets))
+	exclude.Add(3)
+	seeded, _ := NewProbabilisticBalancer(targets, []float64{1, 0, 2, 1})
+	for i := 0; i < 100; i++ {
+		pb.r, seeded.r = rand.New(rand.NewSource(int64(i))), rand.New(rand.NewSource(int64(i))) //nolint:gosec
+		target, index, err := pb.GetNextExcluding(exclude)
+		expectedTarget, expectedIndex, _ := seeded.GetNext([]int{3})
+		if err != nil || target != expectedTarget || index != expectedIndex {
+			t.Fatalf("Expected %s (%d), got %s (%d), err %v", expectedTarget, expectedIndex, target, index, err)
+		}
+		if target == "B" || target == "D" {
+			t.Fatalf("Unexpected target %s", target)
+		}
+	}
+
+	exclude.Add(0)
+	exclude.Add(2)
+	if target, _, err := pb.GetNextExcluding(exclude); err != nil || target != "B" {
+		t.Errorf("Expected the only zero weight target B, got %s, err %v", target, err)
+	}
+	exclude.Add(1)
+	if _, _, err := pb.GetNextExcluding(exclude); err == nil {
+		t.Error("Expected error when all targets are excluded")
+	}
+}
+
+func TestGetNextBatch(t *testing.T) {
+	targets := []string{"a", "b", "c", "d"}
+	rr := NewRoundRobin(targets)
+	probabilistic, err := NewProbabilisticBalancer(targets, []float64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectors := map[string]TargetSelector[string]{"round robin": rr, "probabilistic": probabilistic}
+
+	tests := []struct {
+		name     string
+		n        int
+		exclude  []int
+		expected int
+	}{
+		{name: "part", n: 2, expected: 2},
+		{name: "all", n: 4, expected: 4},
+		{name: "more than available", n: 10, expected: 4},
+		{name: "with exclusions", n: 3, exclude: []int{0, 2}, expected: 2},
+		{name: "none", n: 0, expected: 0},
+	}
+	for selectorName, selector := range selectors {
+		for _, tt := range tests {
+			t.Run(selectorName+"/"+tt.name, func(t *testing.T) {
+				exclude := slices.Clone(tt.exclude)
+				for i := 0; i < 100; i++ {
+					batch, indices, err := selector.GetNextBatch(tt.n, exclude)
+					if err != nil {
+						t.Fatalf("GetNextBatch: %v", err)
+					}
+					if len(batch) != tt.expected || len(indices) != tt.expected {
+						t.Fatalf("expected %d targets, got %v %v", tt.expected, batch, indices)
+					}
+					seen := make(map[int]bool)
+					for j, index := range indices {
+						if seen[index] {
+							t.Fatalf("duplicated index %d in %v", index, indices)
+						}
+						seen[index] = true
+						if slices.Contains(tt.exclude, index) {
+							t.Fatalf("excluded index %d selected", index)
+						}
+						if batch[j] != targets[index] {
+							t.Fatalf("target %s doesn't match index %d", batch[j], index)
+						}
+					}
+				}
+				if !reflect.DeepEqual(exclude, tt.exclude) {
+					t.Errorf("exclude modified: %v", exclude)
+				}
+			})
+		}
+	}
+
+	// all excluded
+	for selectorName, selector := range selectors {
+		if _, _, err := selector.GetNextBatch(2, []int{0, 1, 2, 3}); err == nil {
+			t.Errorf("%s: expected an error when all targets are excluded", selectorName)
+		}
+	}
+}
+
+func TestRoundRobin_GetNextBatch_Order(t *testing.T) {
+	rr := NewRoundRobin([]string{"a", "b", "c", "d"})
+
+	batch, _, _ := rr.GetNextBatch(3, []int{1})
+	if !reflect.DeepEqual(batch, []string{"a", "c", "d"}) {
+		t.Errorf("expected [a c d], got %v", batch)
+	}
+	// the counter continues after the last selected target
+	if target, _, _ := rr.GetNext(nil); target != "a" {
+		t.Errorf("expected a, got %s", target)
+	}
+	batch, _, _ = rr.GetNextBatch(2, nil)
+	if !reflect.DeepEqual(batch, []string{"b", "c"}) {
+		t.Errorf("expected [b c], got %v", batch)
+	}
+}
+
+func TestProbabilisticBalancer_GetNextBatch_Weights(t *testing.T) {
+	p, err := NewProbabilisticBalancer([]string{"heavy", "light1", "light2"}, []float64{98, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the heavy target is almost always in a batch of 2
+	withHeavy := 0
+	for i := 0; i < 1000; i++ {
+		batch, _, _ := p.GetNextBatch(2, nil)
+		if slices.Contains(batch, "heavy") {
+			withHeavy++
+		}
+	}
+	if withHeavy < 950 {
+		t.Errorf("expected the heavy target in most batches, got %d of 1000", withHeavy)
+	}
+}
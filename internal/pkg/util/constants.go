@@ -1,3 +1,6 @@
 package util
 
 const ProxyBasePath = "-mainnet.rpc.aura.com"
+
+// Version of the build, set with -ldflags "-X aura-proxy/internal/pkg/util.Version=..."
+var Version = "dev"
@@ -13,16 +13,30 @@ import (
 
 	"aura-proxy/internal/pkg/chains/solana"
 	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/metrics"
 	"aura-proxy/internal/pkg/models"
 	"aura-proxy/internal/pkg/util"
 )
 
 const (
-	timeout                 = APIWriteTimeout - time.Second
+	// DefaultRequestTimeout is the request deadline of RequestTimeoutMiddleware, it leaves time to write the response
+	// within the default write timeout
+	DefaultRequestTimeout   = APIWriteTimeout - time.Second
 	bodyLimit               = 1000
 	MultipleValuesRequested = "multiple_values"
+
+	// safe default used when subscription pricing is unavailable
+	defaultReqPerSecond int64 = 10
 )
 
+// defaultReqCost is the request price used when subscription pricing is unavailable
+var defaultReqCost int64 = 10
+
+// SetDefaultReqCost sets the price of requests without subscription pricing. It's not synchronized, so it must be set before serving
+func SetDefaultReqCost(cost int64) {
+	defaultReqCost = cost
+}
+
 type CustomContext struct {
 	chainName           string
 	proxyEndpoint       string
@@ -31,6 +45,10 @@ type CustomContext struct {
 	statsAdditionalData string
 	apiToken            string
 	provider            string
+	pinnedProvider      string
+	preferredProvider   string
+	reqCommitment       string
+	tokenType           models.TokenType
 	echo.Context
 
 	userInfo          *auraProto.UserWithTokens
@@ -47,10 +65,11 @@ type CustomContext struct {
 	reqBlock          int64
 	creditsUsed       int64
 
-	proxyUserError bool
-	proxyHasError  bool
-	arrayRequested bool
-	isPartnerNode  bool
+	proxyUserError         bool
+	proxyHasError          bool
+	arrayRequested         bool
+	isPartnerNode          bool
+	missingPricingRecorded bool
 
 	requestType  types.RequestType
 	isDASRequest bool
@@ -69,10 +88,14 @@ func CustomContextMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func RequestTimeoutMiddleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+// RequestTimeoutMiddleware sets the request context deadline, DefaultRequestTimeout if timeout is 0
+func RequestTimeoutMiddleware(timeout time.Duration, skipper middleware.Skipper) echo.MiddlewareFunc {
 	if skipper == nil {
 		skipper = middleware.DefaultSkipper
 	}
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -245,7 +268,14 @@ func (c *CustomContext) SetAPIToken(apiToken string) {
 }
 
 func (c *CustomContext) GetTokenType() models.TokenType {
-	return models.DefaultTokenType
+	if c.tokenType == "" {
+		return models.DefaultTokenType
+	}
+
+	return c.tokenType
+}
+func (c *CustomContext) SetTokenType(tokenType models.TokenType) {
+	c.tokenType = tokenType
 }
 
 func (c *CustomContext) SetRPCRequestsParsed(u types.RPCRequests) {
@@ -289,6 +319,12 @@ func (c *CustomContext) SetReqBlock(reqBlock int64) {
 func (c *CustomContext) GetReqBlock() int64 {
 	return c.reqBlock
 }
+func (c *CustomContext) SetReqCommitment(commitment string) {
+	c.reqCommitment = commitment
+}
+func (c *CustomContext) GetReqCommitment() string {
+	return c.reqCommitment
+}
 func (c *CustomContext) ReachPartnerNode() {
 	c.isPartnerNode = true
 }
@@ -296,76 +332,82 @@ func (c *CustomContext) GetIsPartnerNode() bool {
 	return c.isPartnerNode
 }
 
-func (c *CustomContext) GetReqPerSecond() int32 {
-	if c.chainName == solana.ChainName {
-		switch c.requestType {
-		case types.RPC:
-			return c.subscription.GetPricing().GetSolanaRpc().GetRequestsPerSecond()
-		case types.DAS:
-			return c.subscription.GetPricing().GetSolanaDas().GetRequestsPerSecond()
-		case types.GPA:
-			return c.subscription.GetPricing().GetSolanaGetProgramAccounts().GetRequestsPerSecond()
-		case types.Websocket:
-			return c.subscription.GetPricing().GetSolanaWebsocket().GetRequestsPerSecond()
-		case types.SWQOS:
-			return c.subscription.GetPricing().GetSolanaSwqos().GetRequestsPerSecond()
-		default:
-			return 10
-		}
-	} else if c.chainName == solana.EclipseChainName {
-		switch c.requestType {
-		case types.RPC:
-			return c.subscription.GetPricing().GetEclipseRpc().GetRequestsPerSecond()
-		case types.DAS:
-			return c.subscription.GetPricing().GetEclipseDas().GetRequestsPerSecond()
-		case types.GPA:
-			return c.subscription.GetPricing().GetEclipseGetProgramAccounts().GetRequestsPerSecond()
-		case types.Websocket:
-			return c.subscription.GetPricing().GetEclipseWebsocket().GetRequestsPerSecond()
-		case types.SWQOS:
-			return c.subscription.GetPricing().GetEclipseSwqos().GetRequestsPerSecond()
-		default:
-			return 10
-		}
-	} else {
-		return 10
+// GetLimitForRequest returns the requests per second limit of the current subscription for the chain and request type.
+// It's the single source of the limit for the rate limiter, including WebSocket upgrade requests.
+// defaultReqPerSecond is used when pricing is unavailable or the chain/request type is unknown,
+// 0 when the subscription pricing doesn't include the request type.
+func (c *CustomContext) GetLimitForRequest() int64 {
+	pricing := c.getPricing()
+	if pricing == nil {
+		return defaultReqPerSecond
 	}
+	model, ok := c.pricingModel(pricing)
+	if !ok {
+		return defaultReqPerSecond
+	}
+
+	return int64(model.GetRequestsPerSecond())
 }
 
+// GetReqCost returns the price of a single request of the current subscription for the request type.
+// defaultReqCost is used when pricing is unavailable or the chain/request type is unknown.
 func (c *CustomContext) GetReqCost() int64 {
-	if c.chainName == solana.ChainName {
+	pricing := c.getPricing()
+	if pricing == nil {
+		return defaultReqCost
+	}
+	model, ok := c.pricingModel(pricing)
+	if !ok {
+		return defaultReqCost
+	}
+
+	return model.GetPriceMplx()
+}
+
+// pricingModel selects the pricing of the chain and request type. ok is false for unknown chains and request types
+func (c *CustomContext) pricingModel(pricing *auraProto.Pricing) (model *auraProto.PricingModel, ok bool) {
+	switch c.chainName {
+	case solana.ChainName:
 		switch c.requestType {
 		case types.RPC:
-			return c.subscription.GetPricing().GetSolanaRpc().GetPriceMplx()
+			return pricing.GetSolanaRpc(), true
 		case types.DAS:
-			return c.subscription.GetPricing().GetSolanaDas().GetPriceMplx()
+			return pricing.GetSolanaDas(), true
 		case types.GPA:
-			return c.subscription.GetPricing().GetSolanaGetProgramAccounts().GetPriceMplx()
+			return pricing.GetSolanaGetProgramAccounts(), true
 		case types.Websocket:
-			return c.subscription.GetPricing().GetSolanaWebsocket().GetPriceMplx()
+			return pricing.GetSolanaWebsocket(), true
 		case types.SWQOS:
-			return c.subscription.GetPricing().GetSolanaSwqos().GetPriceMplx()
-		default:
-			return 10
+			return pricing.GetSolanaSwqos(), true
 		}
-	} else if c.chainName == solana.EclipseChainName {
+	case solana.EclipseChainName:
 		switch c.requestType {
 		case types.RPC:
-			return c.subscription.GetPricing().GetEclipseRpc().GetPriceMplx()
+			return pricing.GetEclipseRpc(), true
 		case types.DAS:
-			return c.subscription.GetPricing().GetEclipseDas().GetPriceMplx()
+			return pricing.GetEclipseDas(), true
 		case types.GPA:
-			return c.subscription.GetPricing().GetEclipseGetProgramAccounts().GetPriceMplx()
+			return pricing.GetEclipseGetProgramAccounts(), true
 		case types.Websocket:
-			return c.subscription.GetPricing().GetEclipseWebsocket().GetPriceMplx()
+			return pricing.GetEclipseWebsocket(), true
 		case types.SWQOS:
-			return c.subscription.GetPricing().GetEclipseSwqos().GetPriceMplx()
-		default:
-			return 10
+			return pricing.GetEclipseSwqos(), true
 		}
-	} else {
-		return 10
 	}
+
+	return nil, false
+}
+
+// getPricing returns nil when the subscription (e.g. unknown subscription id) has no pricing.
+// The metric is recorded once per request, the pricing is read for the limit and the cost
+func (c *CustomContext) getPricing() *auraProto.Pricing {
+	pricing := c.subscription.GetPricing()
+	if pricing == nil && !c.missingPricingRecorded {
+		c.missingPricingRecorded = true
+		metrics.IncMissingPricing(c.chainName)
+	}
+
+	return pricing
 }
 
 func (c *CustomContext) SetIsDASRequest(isDASRequest bool) {
@@ -402,6 +444,22 @@ func (c *CustomContext) GetProvider() string {
 	return c.provider
 }
 
+// SetPinnedProvider routes the request to the targets of the provider only
+func (c *CustomContext) SetPinnedProvider(provider string) {
+	c.pinnedProvider = provider
+}
+func (c *CustomContext) GetPinnedProvider() string {
+	return c.pinnedProvider
+}
+
+// SetPreferredProvider tries the targets of the provider first, other providers serve the request after it fails
+func (c *CustomContext) SetPreferredProvider(provider string) {
+	c.preferredProvider = provider
+}
+func (c *CustomContext) GetPreferredProvider() string {
+	return c.preferredProvider
+}
+
 func (c *CustomContext) SetRequestType(requestType types.RequestType) {
 	c.requestType = requestType
 }
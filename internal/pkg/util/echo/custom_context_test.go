@@ -0,0 +1,161 @@
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+)
+
+func missingPricingCount(t *testing.T, chain string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() != "missing_subscription_pricing" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "chain" && l.GetValue() == chain {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestCustomContext_NilSubscriptionPricing(t *testing.T) {
+	c := &CustomContext{}
+	c.SetChainName(solana.ChainName)
+	c.SetRequestType(types.RPC)
+
+	before := missingPricingCount(t, solana.ChainName)
+	assert.Equal(t, defaultReqPerSecond, c.GetLimitForRequest())
+	assert.Equal(t, defaultReqCost, c.GetReqCost())
+	assert.Equal(t, before+1, missingPricingCount(t, solana.ChainName), "counted once per request")
+
+	// subscription without pricing
+	c = &CustomContext{}
+	c.SetChainName(solana.ChainName)
+	c.SetSubscription(&auraProto.SubscriptionWithPricing{})
+	assert.Equal(t, defaultReqPerSecond, c.GetLimitForRequest())
+	assert.Equal(t, before+2, missingPricingCount(t, solana.ChainName))
+
+	// configured default price
+	defer SetDefaultReqCost(defaultReqCost)
+	SetDefaultReqCost(3)
+	assert.Equal(t, int64(3), c.GetReqCost())
+}
+
+func TestCustomContext_SubscriptionPricing(t *testing.T) {
+	c := &CustomContext{}
+	c.SetChainName(solana.ChainName)
+	c.SetRequestType(types.DAS)
+	c.SetSubscription(&auraProto.SubscriptionWithPricing{
+		Pricing: &auraProto.Pricing{
+			SolanaDas: &auraProto.PricingModel{RequestsPerSecond: 50, PriceMplx: 3},
+		},
+	})
+
+	before := missingPricingCount(t, solana.ChainName)
+	assert.Equal(t, int64(50), c.GetLimitForRequest())
+	assert.Equal(t, int64(3), c.GetReqCost())
+	assert.Equal(t, before, missingPricingCount(t, solana.ChainName))
+}
+
+func TestCustomContext_GetLimitForRequest(t *testing.T) {
+	pricing := &auraProto.Pricing{
+		SolanaRpc:                 &auraProto.PricingModel{RequestsPerSecond: 1, PriceMplx: 11},
+		SolanaDas:                 &auraProto.PricingModel{RequestsPerSecond: 2, PriceMplx: 12},
+		SolanaGetProgramAccounts:  &auraProto.PricingModel{RequestsPerSecond: 3, PriceMplx: 13},
+		SolanaWebsocket:           &auraProto.PricingModel{RequestsPerSecond: 4, PriceMplx: 14},
+		SolanaSwqos:               &auraProto.PricingModel{RequestsPerSecond: 5, PriceMplx: 15},
+		EclipseRpc:                &auraProto.PricingModel{RequestsPerSecond: 6, PriceMplx: 16},
+		EclipseDas:                &auraProto.PricingModel{RequestsPerSecond: 7, PriceMplx: 17},
+		EclipseGetProgramAccounts: &auraProto.PricingModel{RequestsPerSecond: 8, PriceMplx: 18},
+		EclipseWebsocket:          &auraProto.PricingModel{RequestsPerSecond: 9, PriceMplx: 19},
+		EclipseSwqos:              &auraProto.PricingModel{RequestsPerSecond: 10, PriceMplx: 20},
+	}
+
+	tests := []struct {
+		chain       string
+		requestType types.RequestType
+		limit       int64
+		cost        int64
+	}{
+		{chain: solana.ChainName, requestType: types.RPC, limit: 1, cost: 11},
+		{chain: solana.ChainName, requestType: types.DAS, limit: 2, cost: 12},
+		{chain: solana.ChainName, requestType: types.GPA, limit: 3, cost: 13},
+		{chain: solana.ChainName, requestType: types.Websocket, limit: 4, cost: 14},
+		{chain: solana.ChainName, requestType: types.SWQOS, limit: 5, cost: 15},
+		{chain: solana.EclipseChainName, requestType: types.RPC, limit: 6, cost: 16},
+		{chain: solana.EclipseChainName, requestType: types.DAS, limit: 7, cost: 17},
+		{chain: solana.EclipseChainName, requestType: types.GPA, limit: 8, cost: 18},
+		{chain: solana.EclipseChainName, requestType: types.Websocket, limit: 9, cost: 19},
+		{chain: solana.EclipseChainName, requestType: types.SWQOS, limit: 10, cost: 20},
+		{chain: solana.ChainName, requestType: types.RequestType(100), limit: defaultReqPerSecond, cost: defaultReqCost},
+		{chain: "unknown", requestType: types.RPC, limit: defaultReqPerSecond, cost: defaultReqCost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chain+"/"+tt.requestType.String(), func(t *testing.T) {
+			c := &CustomContext{}
+			c.SetChainName(tt.chain)
+			c.SetRequestType(tt.requestType)
+			c.SetSubscription(&auraProto.SubscriptionWithPricing{Pricing: pricing})
+
+			assert.Equal(t, tt.limit, c.GetLimitForRequest())
+			assert.Equal(t, tt.cost, c.GetReqCost())
+		})
+	}
+
+	// the request type isn't included in the subscription
+	c := &CustomContext{}
+	c.SetChainName(solana.ChainName)
+	c.SetRequestType(types.DAS)
+	c.SetSubscription(&auraProto.SubscriptionWithPricing{Pricing: &auraProto.Pricing{}})
+	assert.Zero(t, c.GetLimitForRequest())
+}
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	router := echo.New()
+	SetupServerWithWriteTimeout(router, time.Minute)
+	assert.Equal(t, time.Minute, router.Server.WriteTimeout)
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		expected time.Duration
+	}{
+		{name: "configured", timeout: 5 * time.Second, expected: 5 * time.Second},
+		{name: "default", timeout: 0, expected: DefaultRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deadline time.Time
+			handler := RequestTimeoutMiddleware(tt.timeout, nil)(func(c echo.Context) error {
+				var ok bool
+				deadline, ok = c.Request().Context().Deadline()
+				require.True(t, ok)
+				return nil
+			})
+
+			startTime := time.Now()
+			require.NoError(t, handler(router.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())))
+			// independent of the write timeout of the server
+			assert.WithinDuration(t, startTime.Add(tt.expected), deadline, time.Second)
+		})
+	}
+}
@@ -24,9 +24,17 @@ const (
 	TokenParamName     = "token"     // located in path
 	RestPathParamName  = "rest_path" // located in path
 	ProxyPathWithToken = "/:token"
+
+	// DefaultBodyLimit is the max request body size in bytes
+	DefaultBodyLimit = 1 << 20
 )
 
 func InitBaseMiddlewares(router *echo.Echo, corsMiddleware echo.MiddlewareFunc) {
+	InitBaseMiddlewaresWithBodyLimit(router, corsMiddleware, DefaultBodyLimit)
+}
+
+// InitBaseMiddlewaresWithBodyLimit is InitBaseMiddlewares with the max request body size in bytes
+func InitBaseMiddlewaresWithBodyLimit(router *echo.Echo, corsMiddleware echo.MiddlewareFunc, bodyLimit uint64) {
 	router.HTTPErrorHandler = defaultHTTPErrorHandler
 	router.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		DisableStackAll: true,
@@ -35,7 +43,7 @@ func InitBaseMiddlewares(router *echo.Echo, corsMiddleware echo.MiddlewareFunc)
 	if corsMiddleware != nil {
 		router.Use(corsMiddleware)
 	}
-	router.Use(middleware.BodyLimit("1M"))
+	router.Use(middleware.BodyLimit(strconv.FormatUint(bodyLimit, 10)))
 	router.Use(CustomContextMiddleware)
 }
 
@@ -56,14 +64,23 @@ func NewRateLimiter(skipper middleware.Skipper) echo.MiddlewareFunc {
 }
 
 func SetupServer(router *echo.Echo, skipWriteTimeout bool) {
+	writeTimeout := APIWriteTimeout
+	if skipWriteTimeout {
+		writeTimeout = 0
+	}
+	SetupServerWithWriteTimeout(router, writeTimeout)
+}
+
+// SetupServerWithWriteTimeout is SetupServer with the write timeout of the server, 0 disables it
+func SetupServerWithWriteTimeout(router *echo.Echo, writeTimeout time.Duration) {
 	router.DisableHTTP2 = true
 	router.Logger.SetLevel(gommonLog.OFF)
 
 	for _, s := range []*http.Server{router.Server, router.TLSServer} {
 		s.ReadTimeout = apiReadTimeout
 		s.IdleTimeout = apiIdleTimeout
-		if !skipWriteTimeout {
-			s.WriteTimeout = APIWriteTimeout
+		if writeTimeout > 0 {
+			s.WriteTimeout = writeTimeout
 		}
 	}
 }
@@ -30,8 +30,18 @@ var (
 	ExtraNodeAttemptsExceededErrorResponse   = types.NewRPCErrorResponse(types.NewRPCError(2001, "Attempts exceeded", nil), nil)
 	ErrChainNotSupported                     = types.NewRPCErrorResponse(types.NewRPCError(2002, "Chain not supported", nil), nil)
 	ErrGPAArrayRequest                       = types.NewRPCErrorResponse(types.NewRPCError(2003, "Forbidden to use getProgramAccounts with batch request", nil), nil)
+	ErrServerOverloaded                      = types.NewRPCErrorResponse(types.NewRPCError(2004, "Server overloaded, retry later", nil), nil)
+	ExtraNodeTargetsJailedErrorResponse      = types.NewRPCErrorResponse(types.NewRPCError(2006, "All targets of the method are temporarily unavailable", nil), nil)
+	ErrProxyLoop                             = types.NewRPCErrorResponse(types.NewRPCError(2007, "Routing loop detected", nil), nil)
+	ErrPinnedProviderUnavailable             = types.NewRPCErrorResponse(types.NewRPCError(2008, "Pinned provider can't serve the method", nil), nil)
+	ErrRequestBodyTooLarge                   = types.NewRPCErrorResponse(types.NewRPCError(2009, "Request body is too large for the subscription", nil), nil)
+	ErrTooManyConcurrentRequests             = types.NewRPCErrorResponse(types.NewRPCError(2010, "Too many concurrent requests for the subscription", nil), nil)
+	ErrMissingBatchResponse                  = types.NewRPCErrorResponse(types.NewRPCError(2011, "No response for the request in the node batch response", nil), nil)
+	ErrNoMethodsRequested                    = types.NewRPCErrorResponse(types.NewRPCError(types.InvalidRequestErrCode, "No methods requested", nil), nil)
 )
 
+var MethodDeniedRPCError = types.NewRPCError(2005, "Method is temporarily unavailable", nil)
+
 var ErrBadStatusCode = errors.New("bad status code")
 
 var ErrTokenInvalid = echo.NewHTTPError(http.StatusUnauthorized, "invalid api token")
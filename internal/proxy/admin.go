@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"aura-proxy/internal/proxy/chains/solana"
+)
+
+const (
+	deniedMethodsKey = "methods"
+
+	// in-flight requests of removed targets are waited for up to the timeout query param or this default
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// targetStatesProvider is implemented by adapters able to dump their targets state
+type targetStatesProvider interface {
+	GetTargetStates(maskURL bool) []solana.TargetState
+}
+
+// targetRemover is implemented by adapters able to drain and remove their targets
+type targetRemover interface {
+	RemoveTarget(url string, timeout time.Duration) error
+	RemoveProvider(provider string, timeout time.Duration) error
+}
+
+// initAdminHandlers registers operator endpoints on the internal metrics server
+func (p *proxy) initAdminHandlers() {
+	p.metricsServer.GET("/admin/denied-methods", p.getDeniedMethodsHandler)
+	p.metricsServer.GET("/config/version", p.configVersionHandler)
+
+	// endpoints changing the proxy state or exposing provider URLs are available only with the admin token
+	if p.adminToken != "" {
+		admin := p.metricsServer.Group("/admin", middleware.KeyAuth(p.validateAdminToken))
+		admin.PUT("/denied-methods", p.setDeniedMethodsHandler)
+		admin.DELETE("/targets", p.removeTargetHandler)
+		admin.DELETE("/providers/:provider", p.removeProviderHandler)
+
+		debug := p.metricsServer.Group("/debug", middleware.KeyAuth(p.validateAdminToken))
+		debug.GET("/targets", p.debugTargetsHandler)
+		if p.payloadStore != nil {
+			debug.GET("/payloads", p.debugPayloadsHandler)
+		}
+	}
+}
+
+func (p *proxy) validateAdminToken(token string, _ echo.Context) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.adminToken)) == 1, nil
+}
+
+func (p *proxy) configVersionHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, p.configVersion.get())
+}
+
+// debugTargetsHandler returns the live state of all targets grouped by chain
+func (p *proxy) debugTargetsHandler(c echo.Context) error {
+	res := make(map[string][]solana.TargetState)
+	for _, adapter := range p.adapters { // adapters are registered per host, so the same adapter may be met several times
+		provider, ok := adapter.(targetStatesProvider)
+		if !ok {
+			continue
+		}
+		if _, ok := res[adapter.GetName()]; ok {
+			continue
+		}
+		res[adapter.GetName()] = provider.GetTargetStates(p.maskTargetURLs)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// debugPayloadsHandler returns the captured request and response bodies from the oldest one
+func (p *proxy) debugPayloadsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, p.payloadStore.List())
+}
+
+// removeTargetHandler drains and removes the target with the url query param from all chains
+func (p *proxy) removeTargetHandler(c echo.Context) error {
+	url := c.QueryParam("url")
+	if url == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "url is required")
+	}
+
+	return p.removeTargets(c, func(remover targetRemover, timeout time.Duration) error {
+		return remover.RemoveTarget(url, timeout)
+	})
+}
+
+// removeProviderHandler drains and removes all targets of the provider from all chains
+func (p *proxy) removeProviderHandler(c echo.Context) error {
+	return p.removeTargets(c, func(remover targetRemover, timeout time.Duration) error {
+		return remover.RemoveProvider(c.Param("provider"), timeout)
+	})
+}
+
+// removeTargets applies remove to every chain and responds after the in-flight requests of the removed targets finish
+func (p *proxy) removeTargets(c echo.Context, remove func(remover targetRemover, timeout time.Duration) error) error {
+	timeout := defaultDrainTimeout
+	if rawTimeout := c.QueryParam("timeout"); rawTimeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(rawTimeout); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	var removed bool
+	done := make(map[string]struct{})
+	for _, adapter := range p.adapters { // adapters are registered per host, so the same adapter may be met several times
+		remover, ok := adapter.(targetRemover)
+		if !ok {
+			continue
+		}
+		if _, ok := done[adapter.GetName()]; ok {
+			continue
+		}
+		done[adapter.GetName()] = struct{}{}
+
+		err := remove(remover, timeout)
+		switch {
+		case errors.Is(err, solana.ErrTargetNotFound) || errors.Is(err, solana.ErrProviderNotFound):
+		case err != nil:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		default:
+			removed = true
+		}
+	}
+	if !removed {
+		return echo.NewHTTPError(http.StatusNotFound, "no matching targets")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (p *proxy) getDeniedMethodsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string][]string{
+		deniedMethodsKey: p.deniedMethods.List(),
+	})
+}
+
+func (p *proxy) setDeniedMethodsHandler(c echo.Context) error {
+	var req map[string][]string
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	p.deniedMethods.Set(req[deniedMethodsKey])
+
+	return p.getDeniedMethodsHandler(c)
+}
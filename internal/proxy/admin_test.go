@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	chainSolana "aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/proxy/chains/solana"
+)
+
+const testAdminToken = "secret-admin-token"
+
+func newDebugTestProxy(t *testing.T, adminToken string, maskURLs bool) *proxy {
+	t.Helper()
+
+	router, err := solana.NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{
+				Name: "provider",
+				Endpoints: []configtypes.EndpointConfig{
+					{
+						URL:         "https://node.example.com/api-key-123?token=abc",
+						NodeType:    chainSolana.NodeType{Name: chainSolana.ArchiveSolanaNode},
+						HandleOther: true,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	adapter, err := solana.NewSolanaAdapter(context.Background(), router, &configtypes.ProxyConfig{})
+	require.NoError(t, err)
+
+	p := &proxy{
+		metricsServer:  echo.New(),
+		deniedMethods:  newMethodDenyList(nil),
+		adapters:       make(map[string]Adapter),
+		adminToken:     adminToken,
+		maskTargetURLs: maskURLs,
+	}
+	for _, host := range adapter.GetHostNames() {
+		p.adapters[host] = adapter
+	}
+	p.initAdminHandlers()
+
+	return p
+}
+
+func getDebugTargets(p *proxy, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/debug/targets", nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	p.metricsServer.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestDebugTargets_Auth(t *testing.T) {
+	p := newDebugTestProxy(t, testAdminToken, true)
+	assert.Equal(t, http.StatusBadRequest, getDebugTargets(p, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, getDebugTargets(p, "wrong").Code)
+	assert.Equal(t, http.StatusOK, getDebugTargets(p, testAdminToken).Code)
+
+	// disabled without admin token
+	p = newDebugTestProxy(t, "", true)
+	assert.Equal(t, http.StatusNotFound, getDebugTargets(p, "").Code)
+}
+
+func TestDebugTargets_Response(t *testing.T) {
+	for _, maskURLs := range []bool{true, false} {
+		p := newDebugTestProxy(t, testAdminToken, maskURLs)
+		rec := getDebugTargets(p, testAdminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var raw map[string][]map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+		require.Len(t, raw[chainSolana.ChainName], 1)
+		for _, key := range []string{"url", "provider", "nodeType", "slotAmount", "methods"} {
+			assert.Contains(t, raw[chainSolana.ChainName][0], key)
+		}
+
+		var res map[string][]solana.TargetState
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		state := res[chainSolana.ChainName][0]
+		assert.Equal(t, "provider", state.Provider)
+		assert.Equal(t, chainSolana.ArchiveSolanaNode, state.NodeType)
+		if maskURLs {
+			assert.Equal(t, "https://node.example.com/***", state.URL)
+			assert.NotContains(t, rec.Body.String(), "api-key-123")
+			assert.NotContains(t, rec.Body.String(), "token=abc")
+		} else {
+			assert.Equal(t, "https://node.example.com/api-key-123?token=abc", state.URL)
+		}
+	}
+}
+
+func TestAdminRemoveTargets(t *testing.T) {
+	p := newDebugTestProxy(t, testAdminToken, false)
+	remove := func(path string) int {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+testAdminToken)
+		rec := httptest.NewRecorder()
+		p.metricsServer.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, remove("/admin/targets"))
+	assert.Equal(t, http.StatusBadRequest, remove("/admin/targets?url=https://node.example.com&timeout=soon"))
+	assert.Equal(t, http.StatusNotFound, remove("/admin/targets?url=https://other.example.com"))
+	assert.Equal(t, http.StatusNotFound, remove("/admin/providers/other"))
+
+	assert.Equal(t, http.StatusNoContent, remove("/admin/providers/provider?timeout=1s"))
+	var targets map[string][]solana.TargetState
+	require.NoError(t, json.Unmarshal(getDebugTargets(p, testAdminToken).Body.Bytes(), &targets))
+	for _, states := range targets {
+		assert.Empty(t, states)
+	}
+	assert.Equal(t, http.StatusNotFound, remove("/admin/providers/provider"))
+}
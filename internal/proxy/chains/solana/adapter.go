@@ -1,15 +1,25 @@
 package solana
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
 	"aura-proxy/internal/pkg/util"
 	echoUtil "aura-proxy/internal/pkg/util/echo"
 )
 
+// defaultRetryAfterSeconds is returned with 503 when no target is jailed
+const defaultRetryAfterSeconds = 1
+
+// Default hosts of the built-in chains, replaced by the hostNames of their configs
 var (
 	solanaChainHosts = []string{
 		"aura-mainnet.metaplex.com",
@@ -32,40 +42,129 @@ var (
 type Adapter struct {
 	rpcTransport *UnifiedTransport
 	wsTransport  *wsTransport
+	router       *MethodBasedRouter
 
 	chainName        string
 	availableMethods map[string]uint
 	hostNames        []string
 	isMainnet        bool
+	gpaLimits        gpaLimits
+	routingKeyParams map[string]string // method: param index or field of the routing and stats key
+	degradedHealth   degradedHealth
+}
+
+func NewSolanaAdapter(ctx context.Context, router *MethodBasedRouter, cfg *configtypes.ProxyConfig) (*Adapter, error) { //nolint:gocritic
+	return newAdapter(ctx, router, cfg, solana.ChainName, solana.MethodList, hostNamesOrDefault(cfg.Solana.HostNames, solanaChainHosts))
 }
 
-func NewSolanaAdapter(router *MethodBasedRouter, isMainnet bool) (*Adapter, error) { //nolint:gocritic
-	return newAdapter(router, isMainnet, solana.ChainName, solana.MethodList, solanaChainHosts)
+func NewEclipseAdapter(ctx context.Context, router *MethodBasedRouter, cfg *configtypes.ProxyConfig) (*Adapter, error) { //nolint:gocritic
+	return newAdapter(ctx, router, cfg, solana.EclipseChainName, solana.MethodList, hostNamesOrDefault(cfg.Eclipse.HostNames, eclipseChainHosts))
 }
 
-func NewEclipseAdapter(router *MethodBasedRouter, isMainnet bool) (*Adapter, error) { //nolint:gocritic
-	return newAdapter(router, isMainnet, solana.EclipseChainName, solana.MethodList, eclipseChainHosts)
+func hostNamesOrDefault(hostNames, defaultHostNames []string) []string {
+	if len(hostNames) == 0 {
+		return defaultHostNames
+	}
+
+	return hostNames
 }
 
-func newAdapter(router *MethodBasedRouter, isMainnet bool, chainName string, availableMethods map[string]uint, hostNames []string) (*Adapter, error) {
+// NewChainAdapter creates an adapter of a configured Solana-compatible chain, overriding the mainnet flag of the proxy
+func NewChainAdapter(ctx context.Context, router *MethodBasedRouter, cfg *configtypes.ProxyConfig, chainName string, hostNames []string, isMainnet bool) (*Adapter, error) { //nolint:gocritic
+	chainCfg := *cfg
+	chainCfg.IsMainnet = isMainnet
+
+	return newAdapter(ctx, router, &chainCfg, chainName, solana.MethodList, hostNames)
+}
+
+func newAdapter(ctx context.Context, router *MethodBasedRouter, cfg *configtypes.ProxyConfig, chainName string, availableMethods map[string]uint, hostNames []string) (*Adapter, error) {
+	return newAdapterWithRequester(ctx, router, cfg, chainName, availableMethods, hostNames, NewRealHTTPRequester(router.getClientOptions()))
+}
+
+// newAdapterWithRequester creates an adapter sending node requests with the given requester. Background tasks of the
+// router stop when ctx is done
+func newAdapterWithRequester(ctx context.Context, router *MethodBasedRouter, cfg *configtypes.ProxyConfig, chainName string, availableMethods map[string]uint, hostNames []string,
+	requester HTTPRequester) (*Adapter, error) {
 	a := &Adapter{
 		chainName:        chainName,
 		availableMethods: availableMethods,
 		hostNames:        hostNames,
-		isMainnet:        isMainnet, // Store isMainnet
+		isMainnet:        cfg.IsMainnet, // Store isMainnet
+		router:           router,
+		gpaLimits: gpaLimits{
+			maxFilters:         int(cfg.GPAMaxFilters),           //nolint:gosec
+			maxMemcmpBytes:     int(cfg.GPAMaxMemcmpBytes),       //nolint:gosec
+			maxDataSliceLength: int64(cfg.GPAMaxDataSliceLength), //nolint:gosec
+		},
+		routingKeyParams: cfg.RoutingKeyParams,
+		degradedHealth: degradedHealth{
+			minTargets: int(cfg.DegradedHealthMinTargets),   //nolint:gosec
+			maxSlotLag: int64(cfg.DegradedHealthMaxSlotLag), //nolint:gosec
+		},
+	}
+
+	// before the success streak boost, which doesn't apply to the split balancers
+	if err := router.preferRegion(cfg.Region); err != nil {
+		return nil, fmt.Errorf("preferring region %s: %w", cfg.Region, err)
 	}
+	router.setSuccessStreakBoost(cfg.SuccessStreakBoost)
+	router.setErrorStreakPenalty(cfg.ErrorStreakPenalty)
+	router.setTargetWarmUp(cfg.TargetWarmUpPeriod)
+	router.setResponseTimesLen(int(cfg.ResponseTimeHistoryLength)) //nolint:gosec
+	router.setMinAvailableTargets(int(cfg.MinAvailableTargets))    //nolint:gosec
+	router.startSlowTargetDetection(ctx, slowTargetDetection{
+		factor:       cfg.SlowTargetLatencyFactor,
+		weightFactor: cfg.SlowTargetWeightFactor,
+		interval:     cfg.SlowTargetCheckInterval,
+	})
 
 	// Create unified transport with the method router
 	a.rpcTransport = NewUnifiedTransport(
 		UnifiedTransportType,
 		router,
-		&RealHTTPRequester{},
+		requester,
 		DefaultMaxAttempts,
-		isMainnet,
+		cfg.IsMainnet,
 	)
+	a.rpcTransport.seededSelection = cfg.DebugSeededRouting
+	a.rpcTransport.publicFallbackURL = router.publicFallbackURL
+	a.rpcTransport.nodeBehindPolicy = cfg.NodeBehindPolicy
+	a.rpcTransport.minContextSlotRetries = int(cfg.MinContextSlotRetries) //nolint:gosec
+	a.rpcTransport.excludeRateLimitedProviders = cfg.ExcludeRateLimitedProviders
+	a.rpcTransport.providerBudget = providerBudget{maxAttempts: int(cfg.ProviderMaxAttempts), maxTime: cfg.ProviderTimeBudget} //nolint:gosec
+	a.rpcTransport.statusJailTimes = cfg.UpstreamStatusJailTimes
+	a.rpcTransport.stickyTargets = cfg.StickyTargets
+	a.rpcTransport.dasResponseValidation = cfg.DASResponseValidation
+	a.rpcTransport.clusterNodesTargets = int(cfg.ClusterNodesAggregationTargets) //nolint:gosec
+	a.rpcTransport.commitmentMaxSlotLag = int64(cfg.CommitmentMaxSlotLag)        //nolint:gosec
+	a.rpcTransport.globalMaxSlotLag = int64(cfg.MaxSlotLag)                      //nolint:gosec
+	a.rpcTransport.failedProvidersLogLimit = int(cfg.FailedProvidersLogLimit)    //nolint:gosec
+	a.rpcTransport.methodMaxSlotLag = newMethodMaxSlotLag(cfg.MethodMaxSlotLag)
+	a.rpcTransport.methodTimeouts = newMethodTimeouts(cfg.MethodTimeouts)
+	a.rpcTransport.partialBatchResults = cfg.PartialBatchResults
+	a.rpcTransport.staleCache = newStaleCache(cfg.StaleOnErrorMethods)
+	a.rpcTransport.responseNormalizer = newResponseNormalizer(cfg.ResponseNormalizations)
+	if len(cfg.StreamedMethods) > 0 {
+		a.rpcTransport.streamedMethods = make(map[string]struct{}, len(cfg.StreamedMethods))
+		for _, method := range cfg.StreamedMethods {
+			a.rpcTransport.streamedMethods[method] = struct{}{}
+		}
+	}
+	if cfg.MicroBatchWindow > 0 && len(cfg.MicroBatchMethods) > 0 {
+		a.rpcTransport.microBatcher = newMicroBatcher(requester, cfg.MicroBatchWindow, int(cfg.MicroBatchMaxSize), cfg.MicroBatchMethods) //nolint:gosec
+	}
+	if len(cfg.SlotAnnotatedMethods) > 0 {
+		a.rpcTransport.slotAnnotatedMethods = make(map[string]struct{}, len(cfg.SlotAnnotatedMethods))
+		for _, method := range cfg.SlotAnnotatedMethods {
+			a.rpcTransport.slotAnnotatedMethods[method] = struct{}{}
+		}
+	}
+	if realRequester, ok := requester.(*RealHTTPRequester); ok && cfg.UpstreamWarmupConnections > 0 {
+		go realRequester.warmup(router.getTargetURLs(), int(cfg.UpstreamWarmupConnections)) //nolint:gosec
+	}
 	if router.wsTargetInfo != nil && router.wsTargetInfo.balancer != nil {
 		a.wsTransport = &wsTransport{
-			t: NewDefaultProxyTransport(router.wsTargetInfo.balancer),
+			t: NewDefaultProxyTransport(router.wsTargetInfo.balancer, cfg.StripResponseHeaders),
 		}
 	}
 
@@ -81,6 +180,18 @@ func (s *Adapter) GetAvailableMethods() map[string]uint {
 func (s *Adapter) GetHostNames() []string {
 	return s.hostNames
 }
+func (s *Adapter) GetTargetStates(maskURL bool) []TargetState {
+	return s.router.GetTargetStates(maskURL)
+}
+func (s *Adapter) RemoveTarget(url string, timeout time.Duration) error {
+	return s.router.RemoveTarget(url, timeout)
+}
+func (s *Adapter) RemoveProvider(provider string, timeout time.Duration) error {
+	return s.router.RemoveProvider(provider, timeout)
+}
+func (s *Adapter) IsStreamed(c *echoUtil.CustomContext) bool {
+	return s.rpcTransport != nil && s.rpcTransport.IsStreamed(c)
+}
 
 // ProxyWSRequest handles WebSocket proxy requests
 func (s *Adapter) ProxyWSRequest(c echo.Context) error {
@@ -89,9 +200,46 @@ func (s *Adapter) ProxyWSRequest(c echo.Context) error {
 
 func (s *Adapter) ProxyPostRequest(c *echoUtil.CustomContext) (resBody []byte, resCode int, err error) {
 	reqMethods := c.GetReqMethods()
+	if len(reqMethods) == 0 {
+		resCode, err = noMethodsError(c)
+		return nil, resCode, err
+	}
 
+	// checked first, the structured status is more useful than a 503 when no target is available
+	if body, ok := s.degradedHealth.response(c, s.router, reqMethods); ok {
+		return body, http.StatusOK, nil
+	}
 	if s.rpcTransport == nil || !s.rpcTransport.canHandle(reqMethods) || !s.rpcTransport.isAvailable() {
+		s.setRetryAfter(c)
 		return nil, http.StatusServiceUnavailable, echo.NewHTTPError(http.StatusServiceUnavailable, util.ExtraNodeNoAvailableTargetsErrorResponse)
 	}
-	return s.rpcTransport.SendRequest(c)
+	// with all targets jailed, requests which may use the public fallback still go through the transport
+	if !s.rpcTransport.canServe(reqMethods) && !s.rpcTransport.canUsePublicFallback(c, reqMethods) {
+		if stale, ok := s.rpcTransport.staleCache.serve(c, reqMethods); ok {
+			return stale, http.StatusOK, nil
+		}
+		s.setRetryAfter(c)
+		return nil, http.StatusServiceUnavailable, echo.NewHTTPError(http.StatusServiceUnavailable, util.ExtraNodeTargetsJailedErrorResponse)
+	}
+
+	resBody, resCode, err = s.rpcTransport.SendRequest(c)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusServiceUnavailable {
+		s.setRetryAfter(c)
+	}
+
+	return resBody, resCode, err
+}
+
+// setRetryAfter hints clients to back off until the soonest jailed target is released
+func (s *Adapter) setRetryAfter(c *echoUtil.CustomContext) {
+	retryAfter := int64(defaultRetryAfterSeconds)
+	if s.router != nil {
+		timeNow := time.Now().Unix()
+		if expireTime := s.router.getSoonestJailExpireTime(timeNow); expireTime != 0 {
+			retryAfter = max(expireTime-timeNow, defaultRetryAfterSeconds)
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
 }
@@ -0,0 +1,248 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// TestAdapter_RetryAfter tests that 503 responses hint the soonest target release time
+func TestAdapter_RetryAfter(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	// no available targets
+	isAvailable := false
+	mockSelector := &MockTargetSelector{
+		TargetsCount:  2,
+		IsAvailableFn: func() bool { return isAvailable },
+	}
+	adapter := &Adapter{
+		router:       router,
+		rpcTransport: NewUnifiedTransport(UnifiedTransportType, mockSelector, &MockHTTPRequesterWrapper{}, 3, false),
+	}
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": solana.GetSlot, "id": 1})
+	send := func() (*httptest.ResponseRecorder, error) {
+		mockSelector.CallCount = 0
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := createTestCustomContext(req, rec, []string{solana.GetSlot}, requestBytes)
+		_, _, err := adapter.ProxyPostRequest(c)
+		return rec, err
+	}
+
+	// nothing is jailed
+	rec, err := send()
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.Equal(t, strconv.Itoa(defaultRetryAfterSeconds), rec.Header().Get(echo.HeaderRetryAfter))
+
+	// the soonest jail expiry is used
+	timeNow := time.Now().Unix()
+	targets := router.providers["provider"]
+	targets[0].availableMethods[solana.GetSlot] = targetRestriction{jailExpireTime: timeNow + 30}
+	targets[0].availableMethods[solana.GetBlock] = targetRestriction{jailExpireTime: timeNow + 5}
+	targets[1].availableMethods[solana.GetSlot] = targetRestriction{jailExpireTime: timeNow + 10}
+	targets[1].availableMethods[solana.GetBalance] = targetRestriction{jailExpireTime: timeNow - 10} // already released
+
+	rec, err = send()
+	require.Error(t, err)
+	retryAfter, err := strconv.Atoi(rec.Header().Get(echo.HeaderRetryAfter))
+	require.NoError(t, err)
+	assert.InDelta(t, 5, retryAfter, 1)
+
+	// not a 503
+	isAvailable = true
+	mockSelector.NextResponses = []NextResponse{{Target: &ProxyTarget{url: "node"}}}
+	adapter.rpcTransport.httpRequester = &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK},
+	}}
+	rec, err = send()
+	require.NoError(t, err)
+	assert.Empty(t, rec.Header().Get(echo.HeaderRetryAfter))
+}
+
+// TestAdapter_AllTargetsJailed tests that requests for a method with all targets jailed are rejected before the transport
+func TestAdapter_AllTargetsJailed(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	requester := &MockHTTPRequesterWrapper{}
+	adapter := &Adapter{
+		router:       router,
+		rpcTransport: NewUnifiedTransport(UnifiedTransportType, router, requester, 3, false),
+	}
+
+	for _, target := range router.providers["provider"] {
+		router.UpdateTargetStats(target, false, []string{solana.GetSlot}, 0, 0)
+	}
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": solana.GetSlot, "id": 1})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := createTestCustomContext(req, rec, []string{solana.GetSlot}, requestBytes)
+
+	_, code, err := adapter.ProxyPostRequest(c)
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, util.ExtraNodeTargetsJailedErrorResponse, httpErr.Message)
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Zero(t, requester.CallCount, "no upstream requests expected")
+
+	// the public fallback still serves the request
+	const fallbackURL = "https://public.example.com"
+	okResponse := []byte(`{"jsonrpc":"2.0","result":42,"id":1}`)
+	requester.Responses = []HTTPResponseWrapper{{RespBody: okResponse, StatusCode: http.StatusOK}}
+	adapter.rpcTransport.publicFallbackURL = fallbackURL
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c = createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetSlot}, requestBytes)
+
+	body, code, err := adapter.ProxyPostRequest(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, string(okResponse), string(body))
+	assert.Equal(t, []string{fallbackURL}, requester.URLs)
+}
+
+// TestAdapter_ProxyPostRequest tests the production adapter flow with a mock requester
+func TestAdapter_ProxyPostRequest(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	okResponse := []byte(`{"jsonrpc":"2.0","result":42,"id":1}`)
+	requester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{Error: errConnRefused},
+		{RespBody: okResponse, StatusCode: http.StatusOK},
+	}}
+	adapter, err := newAdapterWithRequester(context.Background(), router, &configtypes.ProxyConfig{Solana: *config}, solana.ChainName, solana.MethodList, solanaChainHosts, requester)
+	require.NoError(t, err)
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": solana.GetSlot, "id": 1})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetSlot}, requestBytes)
+
+	body, code, err := adapter.ProxyPostRequest(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, string(okResponse), string(body))
+
+	// the failed target is retried on the other one
+	require.Len(t, requester.URLs, 2)
+	assert.NotEqual(t, requester.URLs[0], requester.URLs[1])
+	for _, u := range requester.URLs {
+		assert.Contains(t, []string{"https://node1.example.com", "https://node2.example.com"}, u)
+	}
+	assert.Equal(t, 2, c.GetProxyAttempts())
+}
+
+// TestAdapter_NoMethods tests that the adapter and the transport reject requests without methods the same way
+func TestAdapter_NoMethods(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:      "provider",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	requester := &MockHTTPRequesterWrapper{}
+	adapter, err := newAdapterWithRequester(context.Background(), router, &configtypes.ProxyConfig{Solana: *config}, solana.ChainName, solana.MethodList, solanaChainHosts, requester)
+	require.NoError(t, err)
+
+	send := map[string]func(c *echoUtil.CustomContext) ([]byte, int, error){
+		"adapter":   adapter.ProxyPostRequest,
+		"transport": adapter.rpcTransport.SendRequest,
+	}
+	for name, sendRequest := range send {
+		t.Run(name, func(t *testing.T) {
+			for _, methods := range [][]string{nil, {}} {
+				c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), methods, nil)
+
+				body, code, err := sendRequest(c)
+				assert.Nil(t, body)
+				assert.Equal(t, http.StatusBadRequest, code)
+				var httpErr *echo.HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+				assert.Equal(t, util.ErrNoMethodsRequested, httpErr.Message)
+				assert.Equal(t, []int{util.ErrNoMethodsRequested.Error.Code}, c.GetRPCErrors())
+				assert.True(t, c.GetProxyUserError())
+			}
+		})
+	}
+	assert.Zero(t, requester.CallCount, "no upstream requests expected")
+}
+
+func TestNewChainAdapter(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:      "provider",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	proxyCfg := &configtypes.ProxyConfig{IsMainnet: true}
+	adapter, err := NewChainAdapter(context.Background(), router, proxyCfg, "sonic", []string{"sonic.example.com"}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "sonic", adapter.GetName())
+	assert.Equal(t, []string{"sonic.example.com"}, adapter.GetHostNames())
+	assert.Equal(t, solana.MethodList, adapter.GetAvailableMethods())
+	assert.False(t, adapter.isMainnet)
+	assert.False(t, adapter.rpcTransport.isMainnet)
+	assert.True(t, proxyCfg.IsMainnet, "the proxy config isn't changed")
+}
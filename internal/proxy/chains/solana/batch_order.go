@@ -0,0 +1,56 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/buger/jsonparser"
+
+	"aura-proxy/internal/pkg/util"
+)
+
+// orderBatchResponse reorders the elements of a batch response to the order of the requests by id, as some upstreams
+// return them in another order while the responses are analyzed and returned to clients by position.
+// The response is returned as is if it's already ordered or the request ids are ambiguous (duplicated or notifications).
+// Requests without a response element get an error element with their id, elements not matching a request are dropped
+func orderBatchResponse(reqBody, respBody []byte) []byte {
+	if len(respBody) == 0 || respBody[0] != '[' {
+		return respBody
+	}
+
+	var ids [][]byte
+	requests := make(map[string]int) // request index by id
+	_, _ = jsonparser.ArrayEach(reqBody, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		id := rawID(value)
+		requests[string(id)] = len(ids)
+		ids = append(ids, id)
+	})
+	if _, ok := requests["null"]; ok || len(ids) == 0 || len(requests) != len(ids) {
+		return respBody
+	}
+
+	ordered := make([][]byte, len(ids))
+	inOrder, index := true, 0
+	_, err := jsonparser.ArrayEach(respBody, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		defer func() { index++ }()
+		reqIndex, ok := requests[string(rawID(value))]
+		if !ok || ordered[reqIndex] != nil {
+			inOrder = false
+			return
+		}
+		ordered[reqIndex] = value
+		inOrder = inOrder && reqIndex == index
+	})
+	if err != nil || (inOrder && index == len(ids)) {
+		return respBody
+	}
+
+	for i, element := range ordered {
+		if element == nil {
+			ordered[i], _ = json.Marshal(types.NewRPCErrorResponse(util.ErrMissingBatchResponse.Error, json.RawMessage(ids[i])))
+		}
+	}
+
+	return append(append([]byte{'['}, bytes.Join(ordered, []byte{','})...), ']')
+}
@@ -0,0 +1,94 @@
+package solana
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+)
+
+func TestOrderBatchResponse(t *testing.T) {
+	const reqBody = `[{"jsonrpc":"2.0","method":"getSlot","id":1},{"jsonrpc":"2.0","method":"getSlot","id":"b"},{"jsonrpc":"2.0","method":"getSlot","id":3}]`
+	tests := []struct {
+		name     string
+		reqBody  string
+		respBody string
+		expected string
+	}{
+		{
+			name:     "reordered",
+			reqBody:  reqBody,
+			respBody: `[{"jsonrpc":"2.0","result":3,"id":3},{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"}]`,
+			expected: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","result":3,"id":3}]`,
+		},
+		{
+			name:     "in order",
+			reqBody:  reqBody,
+			respBody: `[{"jsonrpc":"2.0","result":1,"id":1}, {"jsonrpc":"2.0","result":2,"id":"b"}, {"jsonrpc":"2.0","result":3,"id":3}]`,
+			expected: `[{"jsonrpc":"2.0","result":1,"id":1}, {"jsonrpc":"2.0","result":2,"id":"b"}, {"jsonrpc":"2.0","result":3,"id":3}]`,
+		},
+		{
+			name:     "missing and unknown elements",
+			reqBody:  reqBody,
+			respBody: `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid"},"id":null},{"jsonrpc":"2.0","result":3,"id":3},{"jsonrpc":"2.0","result":1,"id":1}]`,
+			expected: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":{"code":2011,"message":"No response for the request in the node batch response"},"id":"b"},{"jsonrpc":"2.0","result":3,"id":3}]`,
+		},
+		{
+			name:     "extra elements",
+			reqBody:  reqBody,
+			respBody: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","result":3,"id":3},{"jsonrpc":"2.0","result":4,"id":4}]`,
+			expected: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","result":3,"id":3}]`,
+		},
+		{
+			name:     "duplicated request ids",
+			reqBody:  `[{"jsonrpc":"2.0","method":"getSlot","id":1},{"jsonrpc":"2.0","method":"getSlot","id":1}]`,
+			respBody: `[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","result":1,"id":1}]`,
+			expected: `[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","result":1,"id":1}]`,
+		},
+		{
+			name:     "notification",
+			reqBody:  `[{"jsonrpc":"2.0","method":"getSlot"},{"jsonrpc":"2.0","method":"getSlot","id":1}]`,
+			respBody: `[{"jsonrpc":"2.0","result":1,"id":1}]`,
+			expected: `[{"jsonrpc":"2.0","result":1,"id":1}]`,
+		},
+		{
+			name:     "single response",
+			reqBody:  reqBody,
+			respBody: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid"},"id":null}`,
+			expected: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid"},"id":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.JSONEq(t, tt.expected, string(orderBatchResponse([]byte(tt.reqBody), []byte(tt.respBody))))
+		})
+	}
+}
+
+func TestUnifiedTransport_BatchResponseOrder(t *testing.T) {
+	mockSelector := &MockTargetSelector{
+		NextResponses: []NextResponse{{Target: &ProxyTarget{url: "target1"}, Index: 0}},
+		TargetsCount:  1,
+		IsAvailableFn: func() bool { return true },
+	}
+	mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{{
+		StatusCode: http.StatusOK,
+		RespBody:   []byte(`[{"jsonrpc":"2.0","result":3,"id":3},{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"}]`),
+	}}}
+	transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 1, false)
+
+	requestBytes := []byte(`[{"jsonrpc":"2.0","method":"getSlot","id":1},{"jsonrpc":"2.0","method":"getSlot","id":"b"},{"jsonrpc":"2.0","method":"getSlot","id":3}]`)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+	c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetSlot, solana.GetSlot, solana.GetSlot}, requestBytes)
+	c.SetArrayRequested(true)
+
+	respBody, statusCode, err := transport.SendRequest(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","result":3,"id":3}]`, string(respBody))
+}
@@ -0,0 +1,146 @@
+package solana
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/util/balancer"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+var errNoClusterNodes = errors.New("getClusterNodes response has no result array")
+
+// canAggregateClusterNodes checks if a single getClusterNodes request is answered with the merged gossip view of several targets
+func (t *UnifiedTransport) canAggregateClusterNodes(c *echoUtil.CustomContext, methods []string) bool {
+	return t.clusterNodesTargets > 1 && len(methods) == 1 && methods[0] == solana.GetClusterNodes && !c.GetArrayRequested()
+}
+
+// clusterNodesFanOut bounds the concurrent upstream requests of a getClusterNodes aggregation
+const clusterNodesFanOut = 4
+
+// clusterNodesResponse is the response of a target to an aggregated getClusterNodes request
+type clusterNodesResponse struct {
+	body         []byte
+	statusCode   int
+	err          error
+	header       http.Header
+	responseTime int64
+}
+
+// aggregateClusterNodes queries up to clusterNodesTargets targets concurrently and returns the union of their nodes
+// deduped by pubkey. Every query is an attempt, failed targets stay excluded for the regular retries.
+// ok is false when no target returned nodes
+func (t *UnifiedTransport) aggregateClusterNodes(c *echoUtil.CustomContext, selector balancer.TargetSelector[*ProxyTarget],
+	exclude *balancer.Exclusions) (respBody []byte, statusCode, attempts int, ok bool) {
+	reqCtx := c.Request().Context()
+	methods := c.GetReqMethods()
+
+	selected, indices, _ := selector.GetNextBatch(t.clusterNodesTargets, exclude.Indices())
+	targets := make([]*ProxyTarget, 0, len(selected))
+	for i, target := range selected {
+		exclude.Add(indices[i])
+		if target.startRequest() { // removed after its selection otherwise
+			targets = append(targets, target)
+		}
+	}
+	attempts = len(targets)
+	responses := t.requestClusterNodes(c, targets)
+
+	// the responses are analyzed in the target order, the request context isn't safe for concurrent use
+	seen := make(map[string]struct{})
+	var nodes [][]byte
+	for i, target := range targets {
+		resp := responses[i]
+		shouldRetry, isHealthy, firstSlotOnNode := t.processResponse(c, target, reqCtx, resp.body, resp.err)
+		t.updateMetricsAndStats(c, target, methods, resp.statusCode, shouldRetry, isHealthy, resp.responseTime, firstSlotOnNode)
+		if shouldRetry || resp.err != nil {
+			continue
+		}
+
+		targetNodes, err := clusterNodes(resp.body)
+		if err != nil {
+			continue
+		}
+		for _, node := range targetNodes {
+			pubkey, _ := jsonparser.GetString(node, "pubkey")
+			if _, dup := seen[pubkey]; dup && pubkey != "" {
+				continue
+			}
+			seen[pubkey] = struct{}{}
+			nodes = append(nodes, node)
+		}
+		if respBody == nil {
+			respBody, statusCode = resp.body, resp.statusCode
+			c.SetProvider(target.provider)
+			for key, values := range resp.header {
+				if c.Response().Header().Get(key) == "" {
+					c.Response().Header()[key] = values
+				}
+			}
+		}
+	}
+	if respBody == nil {
+		return nil, 0, attempts, false
+	}
+
+	merged, err := jsonparser.Set(respBody, append(append([]byte{'['}, bytes.Join(nodes, []byte{','})...), ']'), "result")
+	if err != nil {
+		return nil, 0, attempts, false
+	}
+
+	return merged, statusCode, attempts, true
+}
+
+// requestClusterNodes sends the request to the started targets, at most clusterNodesFanOut at once, and finishes
+// their requests. Each request gets its own context sharing the client request, as the request body is read by each
+func (t *UnifiedTransport) requestClusterNodes(c *echoUtil.CustomContext, targets []*ProxyTarget) []clusterNodesResponse {
+	body := []byte(c.GetReqBodyString())
+	responses := make([]clusterNodesResponse, len(targets))
+	sem := make(chan struct{}, clusterNodesFanOut)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			defer target.finishRequest()
+
+			response := &upstreamResponse{header: make(http.Header)}
+			upstreamCtx := &echoUtil.CustomContext{Context: c.Echo().NewContext(c.Request(), response)}
+			upstreamCtx.SetChainName(c.GetChainName())
+			upstreamCtx.SetReqMethods(c.GetReqMethods())
+			upstreamCtx.SetReqBody(body)
+
+			startTime := time.Now()
+			resp := &responses[i]
+			resp.body, resp.statusCode, resp.err = t.httpRequester.DoRequest(upstreamCtx, target.url)
+			resp.responseTime = time.Since(startTime).Milliseconds()
+			resp.header = response.header
+		}()
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// clusterNodes returns the nodes of the getClusterNodes response
+func clusterNodes(body []byte) (nodes [][]byte, err error) {
+	result, dataType, _, err := jsonparser.Get(body, "result")
+	if err != nil || dataType != jsonparser.Array {
+		return nil, errNoClusterNodes
+	}
+	_, err = jsonparser.ArrayEach(result, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		nodes = append(nodes, value)
+	})
+
+	return nodes, err
+}
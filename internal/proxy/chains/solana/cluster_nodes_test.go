@@ -0,0 +1,165 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// clusterNodesRequester answers by target URL, as the aggregation requests targets concurrently. With a barrier, the
+// requests wait until that many are in flight
+type clusterNodesRequester struct {
+	mx        sync.Mutex
+	responses map[string]HTTPResponseWrapper
+	urls      []string
+	inFlight  int
+	barrier   int
+	arrived   chan struct{}
+}
+
+func (r *clusterNodesRequester) DoRequest(_ *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.mx.Lock()
+	r.urls = append(r.urls, targetURL)
+	r.inFlight++
+	if r.inFlight == r.barrier {
+		close(r.arrived)
+	}
+	resp, ok := r.responses[targetURL]
+	r.mx.Unlock()
+
+	if r.barrier > 0 {
+		select {
+		case <-r.arrived:
+		case <-time.After(5 * time.Second):
+			return nil, http.StatusInternalServerError, errors.New("requests weren't sent concurrently")
+		}
+	}
+	if !ok {
+		return nil, 0, errors.New("no mock response")
+	}
+	return resp.RespBody, resp.StatusCode, resp.Error
+}
+
+func TestUnifiedTransport_AggregateClusterNodes(t *testing.T) {
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": solana.GetClusterNodes, "id": 1})
+	node1 := HTTPResponseWrapper{StatusCode: http.StatusOK,
+		RespBody: []byte(`{"jsonrpc":"2.0","result":[{"pubkey":"A","gossip":"1.1.1.1:8001"},{"pubkey":"B","gossip":"2.2.2.2:8001"}],"id":1}`)}
+	node2 := HTTPResponseWrapper{StatusCode: http.StatusOK,
+		RespBody: []byte(`{"jsonrpc":"2.0","result":[{"pubkey":"B","gossip":"2.2.2.2:8001"},{"pubkey":"C","gossip":"3.3.3.3:8001"}],"id":1}`)}
+
+	tests := []struct {
+		name         string
+		targets      int
+		responses    map[string]HTTPResponseWrapper
+		barrier      int
+		wantURLs     []string
+		wantAttempts int
+		wantResponse string
+	}{
+		{
+			name:         "merged",
+			targets:      2,
+			responses:    map[string]HTTPResponseWrapper{"target1": node1, "target2": node2, "target3": node1}, // the third target isn't queried
+			barrier:      2,
+			wantURLs:     []string{"target1", "target2"},
+			wantAttempts: 2,
+			wantResponse: `{"jsonrpc":"2.0","result":[{"pubkey":"A","gossip":"1.1.1.1:8001"},{"pubkey":"B","gossip":"2.2.2.2:8001"},{"pubkey":"C","gossip":"3.3.3.3:8001"}],"id":1}`,
+		},
+		{
+			name:         "failed target",
+			targets:      2,
+			responses:    map[string]HTTPResponseWrapper{"target1": {Error: errConnRefused}, "target2": node2},
+			wantURLs:     []string{"target1", "target2"},
+			wantAttempts: 2,
+			wantResponse: string(node2.RespBody),
+		},
+		{
+			name:         "failed aggregation counts attempts",
+			targets:      2,
+			responses:    map[string]HTTPResponseWrapper{"target1": {Error: errConnRefused}, "target2": {Error: errConnRefused}, "target3": node1},
+			wantURLs:     []string{"target1", "target2", "target3"},
+			wantAttempts: 3,
+			wantResponse: string(node1.RespBody),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{
+					{Target: &ProxyTarget{url: "target1"}, Index: 0},
+					{Target: &ProxyTarget{url: "target2"}, Index: 1},
+					{Target: &ProxyTarget{url: "target3"}, Index: 2},
+				},
+				TargetsCount:  3,
+				IsAvailableFn: func() bool { return true },
+			}
+			requester := &clusterNodesRequester{responses: tt.responses, barrier: tt.barrier, arrived: make(chan struct{})}
+			transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, requester, 3, false)
+			transport.clusterNodesTargets = tt.targets
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetClusterNodes}, requestBytes)
+			respBody, statusCode, err := transport.SendRequest(c)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, statusCode)
+			assert.ElementsMatch(t, tt.wantURLs, requester.urls)
+			assert.Equal(t, tt.wantAttempts, c.GetProxyAttempts())
+			assert.JSONEq(t, tt.wantResponse, string(respBody))
+		})
+	}
+
+	t.Run("bounded fan-out", func(t *testing.T) {
+		var nextResponses []NextResponse
+		responses := make(map[string]HTTPResponseWrapper)
+		for i := range clusterNodesFanOut + 2 {
+			url := fmt.Sprintf("target%d", i)
+			nextResponses = append(nextResponses, NextResponse{Target: &ProxyTarget{url: url}, Index: i})
+			responses[url] = node1
+		}
+		mockSelector := &MockTargetSelector{NextResponses: nextResponses, TargetsCount: len(nextResponses), IsAvailableFn: func() bool { return true }}
+		requester := &boundedRequester{clusterNodesRequester: clusterNodesRequester{responses: responses}}
+		transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, requester, len(nextResponses), false)
+		transport.clusterNodesTargets = len(nextResponses)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetClusterNodes}, requestBytes)
+		_, _, err := transport.SendRequest(c)
+		require.NoError(t, err)
+		assert.Len(t, requester.urls, len(nextResponses))
+		assert.LessOrEqual(t, requester.maxInFlight, clusterNodesFanOut)
+	})
+}
+
+// boundedRequester tracks the most concurrent requests
+type boundedRequester struct {
+	clusterNodesRequester
+	current     int
+	maxInFlight int
+}
+
+func (r *boundedRequester) DoRequest(c *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.mx.Lock()
+	r.current++
+	r.maxInFlight = max(r.maxInFlight, r.current)
+	r.mx.Unlock()
+	time.Sleep(10 * time.Millisecond) // lets the other requests start
+
+	defer func() {
+		r.mx.Lock()
+		r.current--
+		r.mx.Unlock()
+	}()
+	return r.clusterNodesRequester.DoRequest(c, targetURL)
+}
@@ -0,0 +1,85 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"aura-proxy/internal/pkg/log"
+)
+
+const (
+	// maxWarmupConnsPerTarget bounds the connections opened per target, more than the idle connections limit per host
+	// of the transport would be closed right after the warmup
+	maxWarmupConnsPerTarget = http.DefaultMaxIdleConnsPerHost
+	// warmupConcurrency bounds the connections being opened at once over all targets
+	warmupConcurrency = 16
+	warmupTimeout     = 5 * time.Second
+)
+
+// warmupRequestBody is a cheap request every node serves. The response doesn't matter, only the connection
+var warmupRequestBody = []byte(`{"jsonrpc":"2.0","id":1,"method":"getHealth"}`)
+
+// warmup opens connsPerTarget (capped at maxWarmupConnsPerTarget) keep-alive connections to every target and parks them
+// in the idle pool, so first user requests skip the TCP and TLS handshakes. It blocks until all requests are done
+func (r *RealHTTPRequester) warmup(targetURLs []string, connsPerTarget int) {
+	connsPerTarget = min(connsPerTarget, maxWarmupConnsPerTarget)
+	if connsPerTarget <= 0 {
+		return
+	}
+
+	sem := make(chan struct{}, warmupConcurrency)
+	wg := sync.WaitGroup{}
+	for _, targetURL := range targetURLs {
+		// requests to a target are concurrent, otherwise they would reuse the same connection
+		for range connsPerTarget {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := r.warmupConn(targetURL); err != nil {
+					log.Logger.Proxy.Warnf("warmup: %s", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (r *RealHTTPRequester) warmupConn(targetURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(warmupRequestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.getClient(targetURL).Do(req)
+	if err != nil {
+		return err
+	}
+	// the body is read to the end, so the connection goes back to the idle pool
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.Body.Close()
+}
+
+// getTargetURLs returns the unique URLs of all targets
+func (r *MethodBasedRouter) getTargetURLs() []string {
+	seen := make(map[string]struct{})
+	var res []string
+	for _, target := range r.findTargets(func(*ProxyTarget) bool { return true }) {
+		if _, ok := seen[target.url]; ok {
+			continue
+		}
+		seen[target.url] = struct{}{}
+		res = append(res, target.url)
+	}
+
+	return res
+}
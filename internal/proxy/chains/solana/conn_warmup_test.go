@@ -0,0 +1,85 @@
+package solana
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+// newWarmupTestServer counts the opened connections. Requests wait for each other (up to a timeout), so concurrent ones
+// can't share a connection
+func newWarmupTestServer(t *testing.T, concurrentRequests int) (*httptest.Server, *atomic.Int32) {
+	var (
+		conns   atomic.Int32
+		barrier sync.WaitGroup
+	)
+	barrier.Add(concurrentRequests)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		barrier.Done()
+		done := make(chan struct{})
+		go func() { barrier.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return srv, &conns
+}
+
+func TestAdapter_UpstreamWarmup(t *testing.T) {
+	tests := []struct {
+		name              string
+		warmupConnections uint
+		expectedConns     int32
+	}{
+		{name: "disabled", warmupConnections: 0, expectedConns: 0},
+		{name: "enabled", warmupConnections: 1, expectedConns: 1},
+		{name: "capped", warmupConnections: 10, expectedConns: maxWarmupConnsPerTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, conns := newWarmupTestServer(t, max(int(tt.expectedConns), 1))
+			config := createTestConfig()
+			config.Providers = []configtypes.ProviderConfig{
+				{
+					Name:      "provider",
+					Endpoints: []configtypes.EndpointConfig{{URL: srv.URL, NodeType: archiveNodeType(), HandleOther: true}},
+				},
+			}
+			router, err := NewMethodBasedRouter(config)
+			require.NoError(t, err)
+
+			_, err = NewChainAdapter(context.Background(), router, &configtypes.ProxyConfig{UpstreamWarmupConnections: tt.warmupConnections}, "solana", nil, false)
+			require.NoError(t, err)
+
+			if tt.expectedConns == 0 {
+				time.Sleep(50 * time.Millisecond)
+				assert.Zero(t, conns.Load())
+				return
+			}
+			assert.Eventually(t, func() bool { return conns.Load() == tt.expectedConns }, time.Second, 10*time.Millisecond)
+			// no more connections are opened
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, tt.expectedConns, conns.Load())
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package solana
+
+import (
+	"encoding/json"
+	"time"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// getHealth results of a degraded proxy
+const (
+	healthDegraded = "degraded"
+	healthBehind   = "behind"
+)
+
+// degradedHealth answers getHealth by the proxy while fewer than minTargets getHealth targets aren't jailed, or the
+// freshest available target lags more than maxSlotLag slots behind the freshest one. 0 disables a condition
+type degradedHealth struct {
+	minTargets int
+	maxSlotLag int64
+}
+
+type healthContext struct {
+	AvailableTargets int   `json:"availableTargets"`
+	SlotLag          int64 `json:"slotLag"`
+}
+
+type degradedHealthResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  string          `json:"result"`
+	Context healthContext   `json:"context"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// response returns the structured status of a single getHealth request while the proxy is degraded.
+// Other requests and getHealth of a healthy proxy are sent to the targets
+func (h degradedHealth) response(c *echoUtil.CustomContext, router *MethodBasedRouter, methods []string) ([]byte, bool) {
+	if (h.minTargets <= 0 && h.maxSlotLag <= 0) || router == nil || c.GetArrayRequested() || len(methods) != 1 || methods[0] != solana.GetHealth {
+		return nil, false
+	}
+
+	availableTargets, slotLag := router.healthState(time.Now())
+	var result string
+	switch {
+	case h.minTargets > 0 && availableTargets < h.minTargets:
+		result = healthDegraded
+	case h.maxSlotLag > 0 && slotLag > h.maxSlotLag:
+		result = healthBehind
+	default:
+		return nil, false
+	}
+
+	body, err := json.Marshal(degradedHealthResponse{
+		JSONRPC: "2.0",
+		Result:  result,
+		Context: healthContext{AvailableTargets: availableTargets, SlotLag: slotLag},
+		ID:      rawID([]byte(c.GetReqBodyString())),
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+// healthState returns the getHealth targets not jailed for it and the slots the freshest of them lags behind the
+// freshest target. Targets without an observed slot aren't compared
+func (r *MethodBasedRouter) healthState(timeNow time.Time) (availableTargets int, slotLag int64) {
+	r.mutex.RLock()
+	info, ok := r.methodMap[solana.GetHealth]
+	if !ok {
+		info = r.defaultTargetInfo
+	}
+	r.mutex.RUnlock()
+	if info == nil {
+		return 0, 0
+	}
+
+	var freshest, freshestAvailable int64
+	for _, target := range info.targets {
+		slot := target.estimatedSlot(timeNow)
+		freshest = max(freshest, slot)
+		if target.isJailed(solana.GetHealth, timeNow.Unix()) {
+			continue
+		}
+		availableTargets++
+		freshestAvailable = max(freshestAvailable, slot)
+	}
+	if availableTargets > 0 && freshestAvailable > 0 {
+		slotLag = freshest - freshestAvailable
+	}
+
+	return availableTargets, slotLag
+}
@@ -0,0 +1,76 @@
+package solana
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+func TestAdapter_DegradedHealth(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node3.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	requester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{RespBody: []byte(`{"jsonrpc":"2.0","result":"ok","id":1}`), StatusCode: http.StatusOK},
+		{RespBody: []byte(`{"jsonrpc":"2.0","result":1000,"id":1}`), StatusCode: http.StatusOK},
+	}}
+	proxyCfg := &configtypes.ProxyConfig{Solana: *config, DegradedHealthMinTargets: 2, DegradedHealthMaxSlotLag: 100}
+	adapter, err := newAdapterWithRequester(context.Background(), router, proxyCfg, solana.ChainName, solana.MethodList, solanaChainHosts, requester)
+	require.NoError(t, err)
+
+	send := func(method, body string) []byte {
+		c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), []string{method}, []byte(body))
+		respBody, code, err := adapter.ProxyPostRequest(c)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		return respBody
+	}
+	health := `{"jsonrpc":"2.0","method":"getHealth","id":"health"}`
+	targets := router.defaultTargetInfo.targets
+	now := time.Now()
+
+	// healthy, sent to a target
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"ok","id":1}`, string(send(solana.GetHealth, health)))
+	assert.Equal(t, 1, requester.CallCount)
+
+	// the freshest target is jailed and the available ones are behind it
+	targets[0].observeSlot(1000, now)
+	targets[1].observeSlot(800, now)
+	targets[2].observeSlot(850, now)
+	targets[0].jailFor([]string{solana.GetHealth}, time.Minute)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"behind","context":{"availableTargets":2,"slotLag":150},"id":"health"}`,
+		string(send(solana.GetHealth, health)))
+
+	// too few available targets
+	targets[1].jailFor([]string{solana.GetHealth}, time.Minute)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"degraded","context":{"availableTargets":1,"slotLag":150},"id":"health"}`,
+		string(send(solana.GetHealth, health)))
+
+	// no available target
+	targets[2].jailFor([]string{solana.GetHealth}, time.Minute)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"degraded","context":{"availableTargets":0,"slotLag":0},"id":"health"}`,
+		string(send(solana.GetHealth, health)))
+	assert.Equal(t, 1, requester.CallCount, "degraded statuses are answered by the proxy")
+
+	// other methods are sent to the targets
+	send(solana.GetSlot, `{"jsonrpc":"2.0","method":"getSlot","id":1}`)
+	assert.Equal(t, 2, requester.CallCount)
+}
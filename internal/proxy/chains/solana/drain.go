@@ -0,0 +1,217 @@
+package solana
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/util/balancer"
+)
+
+// drainPollInterval is the interval of checking in-flight requests of draining targets
+const drainPollInterval = 10 * time.Millisecond
+
+var (
+	ErrTargetNotFound   = errors.New("target not found")
+	ErrProviderNotFound = errors.New("provider not found")
+)
+
+// drainExcluder is implemented by method routers able to remove targets
+type drainExcluder interface {
+	ExcludeDrainingTargets(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions)
+}
+
+// startRequest counts a request sent to the target until finishRequest. It fails for a target drained after
+// its selection. The request is counted before the check, so drain either waits for it or the request sees the drain
+func (t *ProxyTarget) startRequest() bool {
+	t.inFlight.Add(1)
+	if t.draining.Load() {
+		t.inFlight.Add(-1)
+		return false
+	}
+
+	return true
+}
+
+func (t *ProxyTarget) finishRequest() {
+	t.inFlight.Add(-1)
+}
+
+// isDraining checks if the target is being removed or removed, such targets aren't selected
+func (t *ProxyTarget) isDraining() bool {
+	return t.draining.Load()
+}
+
+// RemoveTarget stops selecting the target with the URL and removes it after its in-flight requests finish
+// or the timeout expires. Balancers are rebuilt without the target, the ones selected by in-flight requests
+// exclude it as draining
+func (r *MethodBasedRouter) RemoveTarget(url string, timeout time.Duration) error {
+	targets := r.findTargets(func(target *ProxyTarget) bool { return target.url == url })
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: %s", ErrTargetNotFound, maskTargetURL(url))
+	}
+
+	return r.drain(targets, timeout)
+}
+
+// RemoveProvider removes all targets of the provider like RemoveTarget
+func (r *MethodBasedRouter) RemoveProvider(provider string, timeout time.Duration) error {
+	targets := r.findTargets(func(target *ProxyTarget) bool { return target.provider == provider })
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: %s", ErrProviderNotFound, provider)
+	}
+
+	return r.drain(targets, timeout)
+}
+
+// ExcludeDrainingTargets adds the balancer indices of draining and removed targets to exclude
+func (r *MethodBasedRouter) ExcludeDrainingTargets(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions) {
+	for i, target := range r.selectorTargets(method, selector) {
+		if target.isDraining() {
+			exclude.Add(i)
+		}
+	}
+}
+
+func (r *MethodBasedRouter) findTargets(match func(target *ProxyTarget) bool) (res []*ProxyTarget) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, targets := range r.providers {
+		for _, target := range targets {
+			if match(target) {
+				res = append(res, target)
+			}
+		}
+	}
+
+	return res
+}
+
+// drain removes the targets from the balancers, waits for their in-flight requests to finish,
+// then removes the targets from the providers
+func (r *MethodBasedRouter) drain(targets []*ProxyTarget, timeout time.Duration) error {
+	if err := r.removeFromBalancers(targets); err != nil {
+		return fmt.Errorf("removing targets from balancers: %w", err)
+	}
+	for _, target := range targets {
+		target.draining.Store(true)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var inFlight int64
+		for _, target := range targets {
+			inFlight += target.inFlight.Load()
+		}
+		if inFlight == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Logger.Proxy.Warnf("drain timeout of %d targets, %d requests are still in flight", len(targets), inFlight)
+			break
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for provider, providerTargets := range r.providers {
+		providerTargets = slices.DeleteFunc(slices.Clone(providerTargets), func(target *ProxyTarget) bool { return slices.Contains(targets, target) })
+		if len(providerTargets) == 0 {
+			delete(r.providers, provider)
+			continue
+		}
+		r.providers[provider] = providerTargets
+	}
+
+	return nil
+}
+
+// removeFromBalancers rebuilds the method, default and GPA balancers containing the targets without them.
+// Methods left without targets are routed as not configured ones. The WebSocket balancer is owned by its transport
+// and isn't changed
+func (r *MethodBasedRouter) removeFromBalancers(targets []*ProxyTarget) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for method, info := range r.methodMap {
+		filtered, err := r.withoutTargets(method, info, targets)
+		if err != nil {
+			return fmt.Errorf("method %s: %w", method, err)
+		}
+		if filtered == nil {
+			delete(r.methodMap, method)
+			delete(r.supportedMethods, method)
+			continue
+		}
+		r.methodMap[method] = filtered
+	}
+
+	var err error
+	if r.defaultTargetInfo, err = r.withoutTargets("", r.defaultTargetInfo, targets); err != nil {
+		return fmt.Errorf("default routing: %w", err)
+	}
+	if r.gpaTargetInfo, err = r.withoutTargets("", r.gpaTargetInfo, targets); err != nil {
+		return fmt.Errorf("GPA routing: %w", err)
+	}
+	r.setWeightMultipliers()
+
+	return nil
+}
+
+// withoutTargets returns the info with a new balancer of the remaining targets, ordered balancers keep their tiers.
+// The info is returned as is if it has none of the targets, nil if no targets are left
+func (r *MethodBasedRouter) withoutTargets(method string, info *methodTargetInfo, removed []*ProxyTarget) (*methodTargetInfo, error) {
+	if info == nil || !slices.ContainsFunc(info.targets, func(target *ProxyTarget) bool { return slices.Contains(removed, target) }) {
+		return info, nil
+	}
+
+	tiers := info.tiers
+	if len(tiers) == 0 {
+		tiers = []int{len(info.targets)}
+	}
+	filtered := &methodTargetInfo{}
+	var selectors []balancer.TargetSelector[*ProxyTarget]
+	offset := 0
+	for _, size := range tiers {
+		var tier methodTargetInfo
+		for i := offset; i < offset+size; i++ {
+			if !slices.Contains(removed, info.targets[i]) {
+				tier.targets = append(tier.targets, info.targets[i])
+				tier.weights = append(tier.weights, info.weights[i])
+			}
+		}
+		offset += size
+		if len(tier.targets) == 0 {
+			continue
+		}
+
+		tierBalancer, err := r.newMethodBalancer(method, tier.targets, tier.weights)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, tierBalancer)
+		filtered.targets = append(filtered.targets, tier.targets...)
+		filtered.weights = append(filtered.weights, tier.weights...)
+		filtered.tiers = append(filtered.tiers, len(tier.targets))
+	}
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	if len(info.tiers) == 0 {
+		filtered.balancer, filtered.tiers = selectors[0], nil
+		return filtered, nil
+	}
+	ordered, err := balancer.NewOrderedSelector(selectors...)
+	if err != nil {
+		return nil, err
+	}
+	filtered.balancer = ordered
+
+	return filtered, nil
+}
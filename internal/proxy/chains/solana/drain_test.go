@@ -0,0 +1,186 @@
+package solana
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// blockingRequester holds the first request until released
+type blockingRequester struct {
+	started chan string // URL of the held request
+	release chan struct{}
+
+	mx   sync.Mutex
+	urls []string
+}
+
+func (r *blockingRequester) DoRequest(_ *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.mx.Lock()
+	r.urls = append(r.urls, targetURL)
+	first := len(r.urls) == 1
+	r.mx.Unlock()
+
+	if first {
+		r.started <- targetURL
+		<-r.release
+	}
+
+	return []byte(`{"jsonrpc":"2.0","id":1,"result":1}`), http.StatusOK, nil
+}
+
+func newDrainTestRouter(t *testing.T) *MethodBasedRouter {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:      "provider1",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+		{
+			Name:      "provider2",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	return router
+}
+
+func sendDrainTestRequest(transport *UnifiedTransport) error {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`)
+	c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{solana.GetSlot}, body)
+	_, _, err := transport.SendRequest(c)
+
+	return err
+}
+
+func TestMethodBasedRouter_RemoveTarget(t *testing.T) {
+	router := newDrainTestRouter(t)
+	requester := &blockingRequester{started: make(chan string), release: make(chan struct{})}
+	transport := NewUnifiedTransport("test_transport", router, requester, 1, false)
+
+	requestDone := make(chan error, 1)
+	go func() { requestDone <- sendDrainTestRequest(transport) }()
+	var drainedURL string
+	select {
+	case drainedURL = <-requester.started:
+	case <-time.After(time.Second):
+		t.Fatal("request wasn't started")
+	}
+	drained := router.findTargets(func(target *ProxyTarget) bool { return target.url == drainedURL })[0]
+
+	removed := make(chan error, 1)
+	go func() { removed <- router.RemoveTarget(drainedURL, time.Minute) }()
+
+	// the draining target isn't selected anymore, but it's kept until its request finishes
+	require.Eventually(t, drained.isDraining, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sendDrainTestRequest(transport))
+	}
+	requester.mx.Lock()
+	assert.NotContains(t, requester.urls[1:], drainedURL)
+	requester.mx.Unlock()
+	select {
+	case <-removed:
+		t.Fatal("target removed with a request in flight")
+	case <-time.After(5 * drainPollInterval):
+	}
+	router.mutex.RLock()
+	assert.Contains(t, router.providers, drained.provider)
+	router.mutex.RUnlock()
+
+	close(requester.release)
+	select {
+	case err := <-requestDone:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request wasn't completed")
+	}
+	select {
+	case err := <-removed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("target wasn't removed after its request finished")
+	}
+	router.mutex.RLock()
+	assert.NotContains(t, router.providers, drained.provider)
+	router.mutex.RUnlock()
+	assert.True(t, router.CanServeMethod(solana.GetSlot))
+
+	assert.ErrorIs(t, router.RemoveTarget(drainedURL, time.Second), ErrTargetNotFound)
+}
+
+func TestMethodBasedRouter_RemoveProviderTimeout(t *testing.T) {
+	router := newDrainTestRouter(t)
+	target := router.providers["provider2"][0]
+	require.True(t, target.startRequest()) // never finished
+
+	start := time.Now()
+	require.NoError(t, router.RemoveProvider("provider2", 50*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.NotContains(t, router.providers, "provider2")
+	assert.True(t, target.GetState(false).Draining)
+	assert.False(t, target.startRequest(), "a request to a target drained after its selection isn't started")
+
+	assert.ErrorIs(t, router.RemoveProvider("provider2", time.Second), ErrProviderNotFound)
+}
+
+func TestMethodBasedRouter_RemoveFromBalancers(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true, HandleGPA: true, Methods: []string{solana.GetBalance}},
+			},
+		},
+		{
+			Name: "provider2",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true, Methods: []string{solana.GetBalance, solana.GetBlock}},
+				{URL: "https://node3.example.com", NodeType: archiveNodeType(), HandleOther: true, Methods: []string{solana.GetBalance}},
+			},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{solana.GetBalance: {"provider2", "provider1"}}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	require.NoError(t, router.RemoveTarget("https://node2.example.com", time.Second))
+
+	urls := func(info *methodTargetInfo) (res []string) {
+		for _, target := range info.targets {
+			res = append(res, target.url)
+		}
+		return res
+	}
+	router.mutex.RLock()
+	defer router.mutex.RUnlock()
+	// ordered balancers keep their tiers
+	getBalance := router.methodMap[solana.GetBalance]
+	assert.Equal(t, []string{"https://node3.example.com", "https://node1.example.com"}, urls(getBalance))
+	assert.Equal(t, []int{1, 1}, getBalance.tiers)
+	assert.Equal(t, 2, getBalance.balancer.GetTargetsCount())
+	for range 10 {
+		target, _, err := getBalance.balancer.GetNext(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "https://node3.example.com", target.url)
+	}
+	// a method left without targets is routed to the default handler
+	assert.NotContains(t, router.methodMap, solana.GetBlock)
+	assert.NotContains(t, router.supportedMethods, solana.GetBlock)
+	assert.Equal(t, []string{"https://node1.example.com", "https://node3.example.com"}, urls(router.defaultTargetInfo))
+	assert.Equal(t, 2, router.defaultTargetInfo.balancer.GetTargetsCount())
+	assert.Equal(t, []string{"https://node1.example.com"}, urls(router.gpaTargetInfo))
+}
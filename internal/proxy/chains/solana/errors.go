@@ -6,10 +6,14 @@ import (
 )
 
 var (
-	ErrMethodNotAvailable = errors.New("method not available")
-	ErrEmptyResponseBody  = errors.New("empty response body")
-	ErrEmptyResponseField = errors.New("empty response field")
-	ErrEmptyRequestArr    = errors.New("empty requests arr")
+	ErrMethodNotAvailable   = errors.New("method not available")
+	ErrEmptyResponseBody    = errors.New("empty response body")
+	ErrEmptyResponseField   = errors.New("empty response field")
+	ErrEmptyRequestArr      = errors.New("empty requests arr")
+	ErrNonJSONResponse      = errors.New("non-JSON response body")
+	ErrMalformedDASResponse = errors.New("malformed DAS response")
+	ErrNodeBehind           = errors.New("node is behind")
+	ErrMethodGroupCycle     = errors.New("method group includes itself")
 )
 
 type AnalyzeError struct {
@@ -0,0 +1,77 @@
+package solana
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"aura-proxy/internal/pkg/log"
+)
+
+// setMinAvailableTargets sets the number of targets per method which jailing doesn't go below, 0 disables it
+func (r *MethodBasedRouter) setMinAvailableTargets(n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.minAvailableTargets = n
+}
+
+// keepMinAvailable releases the least failing jailed targets of the methods when fewer than minAvailableTargets
+// of their targets are available, so aggressive jailing doesn't reject requests a degraded target could still serve
+func (r *MethodBasedRouter) keepMinAvailable(methods []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.minAvailableTargets <= 0 {
+		return
+	}
+
+	timeNow := time.Now().Unix()
+	for _, method := range methods {
+		var available int
+		var jailed []*ProxyTarget
+		for _, target := range r.methodTargets(method) {
+			switch {
+			case target.isDraining():
+			case target.isJailed(method, timeNow):
+				jailed = append(jailed, target)
+			default:
+				available++
+			}
+		}
+		if available >= r.minAvailableTargets || len(jailed) == 0 {
+			continue
+		}
+
+		slices.SortFunc(jailed, func(a, b *ProxyTarget) int {
+			aErrors, aExpireTime := a.jailState(method)
+			bErrors, bExpireTime := b.jailState(method)
+			return cmp.Or(cmp.Compare(aErrors, bErrors), cmp.Compare(aExpireTime, bExpireTime))
+		})
+		for _, target := range jailed[:min(r.minAvailableTargets-available, len(jailed))] {
+			target.release(method)
+			log.Logger.Proxy.Warnf("fewer than %d targets available for %s, released jailed target %s", r.minAvailableTargets, method, maskTargetURL(target.url))
+		}
+	}
+}
+
+// jailState returns the error counter and the jail expire time of the method
+func (t *ProxyTarget) jailState(method string) (errCounter uint64, jailExpireTime int64) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	restriction := t.availableMethods[method]
+
+	return restriction.errCounter, restriction.jailExpireTime
+}
+
+// release ends the jail of the method. The error counter is kept, so the next failure jails the target for longer
+func (t *ProxyTarget) release(method string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if restriction, ok := t.availableMethods[method]; ok {
+		restriction.jailExpireTime = 0
+		t.availableMethods[method] = restriction
+	}
+}
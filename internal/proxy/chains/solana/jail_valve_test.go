@@ -0,0 +1,88 @@
+package solana
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+func newJailValveTestRouter(t *testing.T, minAvailable int) (*MethodBasedRouter, []*ProxyTarget) {
+	config := createTestConfig()
+	config.Providers = nil
+	for i := 1; i <= 3; i++ {
+		config.Providers = append(config.Providers, configtypes.ProviderConfig{
+			Name:      fmt.Sprintf("provider%d", i),
+			Endpoints: []configtypes.EndpointConfig{{URL: fmt.Sprintf("https://node%d.example.com", i), NodeType: archiveNodeType(), HandleOther: true}},
+		})
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	router.setMinAvailableTargets(minAvailable)
+
+	return router, router.defaultTargetInfo.targets
+}
+
+func jailedTargets(targets []*ProxyTarget, method string) (jailed []string) {
+	timeNow := time.Now().Unix()
+	for _, target := range targets {
+		if target.isJailed(method, timeNow) {
+			jailed = append(jailed, target.url)
+		}
+	}
+
+	return jailed
+}
+
+func TestMethodBasedRouter_MinAvailableTargets(t *testing.T) {
+	router, targets := newJailValveTestRouter(t, 1)
+	methods := []string{solana.GetSlot}
+
+	// all but one
+	router.UpdateTargetStats(targets[0], false, methods, 0, 0)
+	router.UpdateTargetStats(targets[1], false, methods, 0, 0)
+	router.UpdateTargetStats(targets[1], false, methods, 0, 0)
+	assert.ElementsMatch(t, []string{targets[0].url, targets[1].url}, jailedTargets(targets, solana.GetSlot))
+
+	// the least failing jailed target is released for the last one
+	router.UpdateTargetStats(targets[2], false, methods, 0, 0)
+	router.UpdateTargetStats(targets[2], false, methods, 0, 0)
+	router.UpdateTargetStats(targets[2], false, methods, 0, 0)
+	assert.ElementsMatch(t, []string{targets[1].url, targets[2].url}, jailedTargets(targets, solana.GetSlot))
+	assert.True(t, router.CanServeMethod(solana.GetSlot))
+	errCounter, _ := targets[0].jailState(solana.GetSlot)
+	assert.Equal(t, uint64(1), errCounter, "the error counter is kept")
+
+	// fixed jail time
+	router.JailTargetFor(targets[0], methods, time.Minute)
+	assert.Len(t, jailedTargets(targets, solana.GetSlot), 2)
+	assert.True(t, router.CanServeMethod(solana.GetSlot))
+
+	// other methods aren't affected
+	assert.Empty(t, jailedTargets(targets, solana.GetBalance))
+}
+
+func TestMethodBasedRouter_MinAvailableTargetsDisabled(t *testing.T) {
+	router, targets := newJailValveTestRouter(t, 0)
+	for _, target := range targets {
+		router.UpdateTargetStats(target, false, []string{solana.GetSlot}, 0, 0)
+	}
+
+	assert.Len(t, jailedTargets(targets, solana.GetSlot), 3)
+	assert.False(t, router.CanServeMethod(solana.GetSlot))
+}
+
+func TestMethodBasedRouter_MinAvailableTargetsAboveCount(t *testing.T) {
+	router, targets := newJailValveTestRouter(t, 5)
+	for _, target := range targets {
+		router.UpdateTargetStats(target, false, []string{solana.GetSlot}, 0, 0)
+	}
+
+	// all targets are kept available
+	assert.Empty(t, jailedTargets(targets, solana.GetSlot))
+}
@@ -2,7 +2,11 @@ package solana
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"aura-proxy/internal/pkg/chains/solana"
 	"aura-proxy/internal/pkg/configtypes"
@@ -23,6 +27,9 @@ type methodTargetInfo struct {
 
 	// The balancer for this method
 	balancer balancer.TargetSelector[*ProxyTarget]
+
+	// Target counts of the tiers of an ordered balancer, in the targets order. Empty if the balancer isn't ordered
+	tiers []int
 }
 
 // MethodBasedRouter implements the MethodRouter interface
@@ -36,6 +43,12 @@ type MethodBasedRouter struct {
 	// WebSocket selector for handling WebSocket connections
 	wsTargetInfo *methodTargetInfo
 
+	// Dedicated selector for getProgramAccounts, isolating heavy scans from latency-sensitive traffic
+	gpaTargetInfo *methodTargetInfo
+
+	// Public RPC used as a last resort when partner targets are exhausted, empty if disabled
+	publicFallbackURL string
+
 	// All providers configured in the system
 	providers map[string][]*ProxyTarget
 
@@ -45,6 +58,17 @@ type MethodBasedRouter struct {
 	// Set of all methods explicitly handled by this router (using struct{} for memory efficiency)
 	supportedMethods map[string]struct{}
 
+	// Target selection strategy per method, probabilistic if not set
+	methodStrategies map[string]configtypes.SelectionStrategy
+	compositeWeights configtypes.CompositeWeights
+
+	// Targets per method which jailing doesn't go below, 0 if disabled
+	minAvailableTargets int
+
+	// Selection weight multipliers of method balancers by the success and error streaks of targets, 0 if disabled
+	successStreakBoost float64
+	errorStreakPenalty float64
+
 	mutex sync.RWMutex
 }
 
@@ -55,15 +79,26 @@ func NewMethodBasedRouter(cfg *configtypes.SolanaConfig) (*MethodBasedRouter, er
 		providers:        make(map[string][]*ProxyTarget),
 		methodGroups:     make(map[string][]string),
 		supportedMethods: make(map[string]struct{}),
+		methodStrategies: cfg.MethodSelectionStrategy,
+		compositeWeights: configtypes.CompositeWeights{Latency: 1, ErrorRate: 1},
+	}
+	if cfg.CompositeWeights != nil {
+		router.compositeWeights = *cfg.CompositeWeights
+	}
+
+	if cfg.PublicFallbackURL != nil {
+		router.publicFallbackURL = cfg.PublicFallbackURL.String()
 	}
 
 	// Process method groups
-	for _, group := range cfg.MethodGroups {
-		router.methodGroups[group.Name] = group.Methods
+	methodGroups, err := resolveMethodGroups(cfg.MethodGroups)
+	if err != nil {
+		return nil, fmt.Errorf("resolving method groups: %w", err)
 	}
+	router.methodGroups = methodGroups
 
 	// Process provider configurations
-	if err := router.processProviders(cfg.Providers); err != nil {
+	if err := router.processProviders(cfg.Providers, cfg.MethodProviderBlacklist); err != nil {
 		return nil, fmt.Errorf("processing providers: %w", err)
 	}
 
@@ -72,11 +107,103 @@ func NewMethodBasedRouter(cfg *configtypes.SolanaConfig) (*MethodBasedRouter, er
 		return nil, fmt.Errorf("processing legacy config: %w", err)
 	}
 
+	if err := router.applySelectionStrategies(); err != nil {
+		return nil, fmt.Errorf("applying selection strategies: %w", err)
+	}
+
+	// Apply node type requirements on top of the resulting routing table
+	if err := router.applyNodeRequirements(cfg.MethodNodeRequirements); err != nil {
+		return nil, fmt.Errorf("applying node requirements: %w", err)
+	}
+	if err := router.applyProviderOrder(cfg.MethodProviderOrder); err != nil {
+		return nil, fmt.Errorf("applying provider order: %w", err)
+	}
+
 	return router, nil
 }
 
-// processProviders processes the provider configurations and builds the method routing table
-func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderConfig) error {
+// resolveMethodGroups expands the included groups of each method group transitively, failing on include cycles
+func resolveMethodGroups(groups []configtypes.MethodGroupConfig) (map[string][]string, error) {
+	configs := make(map[string]configtypes.MethodGroupConfig, len(groups))
+	for _, group := range groups {
+		configs[group.Name] = group
+	}
+
+	resolved := make(map[string][]string, len(groups))
+	resolving := make(map[string]bool) // groups on the current include path
+	var resolve func(name string, path []string) ([]string, error)
+	resolve = func(name string, path []string) ([]string, error) {
+		if methods, ok := resolved[name]; ok {
+			return methods, nil
+		}
+		path = append(path, name)
+		if resolving[name] {
+			return nil, fmt.Errorf("%w: %s", ErrMethodGroupCycle, strings.Join(path, " -> "))
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+
+		group := configs[name]
+		methods := slices.Clone(group.Methods)
+		for _, include := range group.Includes {
+			if _, ok := configs[include]; !ok {
+				log.Logger.Proxy.Warnf("Method group '%s' included by '%s' but not defined", include, name)
+				continue
+			}
+			included, err := resolve(include, path)
+			if err != nil {
+				return nil, err
+			}
+			for _, method := range included {
+				if !slices.Contains(methods, method) {
+					methods = append(methods, method)
+				}
+			}
+		}
+		resolved[name] = methods
+
+		return methods, nil
+	}
+
+	for _, group := range groups {
+		if _, err := resolve(group.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// validateWebSocketURL checks that the WebSocket reverse proxy can reach the endpoint, like WrappedURL.Validate of legacy WS hosts
+func validateWebSocketURL(rawURL string) error {
+	var wrapped configtypes.WrappedURL
+	if err := wrapped.UnmarshalText([]byte(rawURL)); err != nil {
+		return err
+	}
+
+	return wrapped.Validate()
+}
+
+// endpointWeight returns the configured weight of the endpoint. Without it, the provider capacity is split evenly
+// across its endpoints, or the default weight is used
+func endpointWeight(provider configtypes.ProviderConfig, endpoint configtypes.EndpointConfig) float64 {
+	switch {
+	case endpoint.Weight > 0:
+		return endpoint.Weight
+	case provider.Capacity > 0:
+		return provider.Capacity / float64(len(provider.Endpoints))
+	default:
+		return DefaultEndpointWeight
+	}
+}
+
+// processProviders processes the provider configurations and builds the method routing table.
+// Providers blacklisted for a method (method -> providers) contribute no target to it, whatever their endpoints handle
+func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderConfig, blacklist map[string][]string) error {
+	isBlacklisted := func(method, provider string) bool {
+		return contains(blacklist[method], provider)
+	}
+
 	for _, provider := range providers {
 		// Create targets for all endpoints in this provider
 		var providerTargets []*ProxyTarget
@@ -88,6 +215,9 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 				provider.Name,
 				endpoint.NodeType,
 			)
+			target.hostHeader = endpoint.HostHeader
+			target.deadlineHeader = endpoint.DeadlineHeader
+			target.region = provider.Region
 			providerTargets = append(providerTargets, target)
 
 			// First, expand method groups into concrete methods
@@ -105,16 +235,12 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 			// Add explicitly specified methods
 			expandedMethods = append(expandedMethods, endpoint.Methods...)
 
-			// Create a weight value (default to 1.0 if not specified)
-			weight := endpoint.Weight
-			if weight <= 0 {
-				weight = DefaultEndpointWeight
-			}
+			weight := endpointWeight(provider, endpoint)
 
 			// Add this target to the method map for each supported method
 			for _, method := range expandedMethods {
-				// Skip if method is in exclude list
-				if contains(endpoint.ExcludeMethods, method) {
+				// Skip if method is in exclude list or the provider is blacklisted for it
+				if contains(endpoint.ExcludeMethods, method) || isBlacklisted(method, provider.Name) {
 					continue
 				}
 
@@ -135,6 +261,10 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 
 			// Handle WebSocket connections
 			if endpoint.HandleWebSocket {
+				if err := validateWebSocketURL(endpoint.URL); err != nil {
+					return fmt.Errorf("provider %s: WebSocket endpoint: %w", provider.Name, err)
+				}
+
 				// Create wsTargetInfo if it doesn't exist
 				if r.wsTargetInfo == nil {
 					r.wsTargetInfo = &methodTargetInfo{}
@@ -145,6 +275,18 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 				r.wsTargetInfo.weights = append(r.wsTargetInfo.weights, weight)
 			}
 
+			// Handle getProgramAccounts requests
+			if endpoint.HandleGPA && !isBlacklisted(solana.GetProgramAccounts, provider.Name) {
+				// Create gpaTargetInfo if it doesn't exist
+				if r.gpaTargetInfo == nil {
+					r.gpaTargetInfo = &methodTargetInfo{}
+				}
+
+				// Add target and weight to GPA handler
+				r.gpaTargetInfo.targets = append(r.gpaTargetInfo.targets, target)
+				r.gpaTargetInfo.weights = append(r.gpaTargetInfo.weights, weight)
+			}
+
 			// Handle "other" methods
 			if endpoint.HandleOther {
 				// Create defaultTargetInfo if it doesn't exist
@@ -161,11 +303,14 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 		// Store all targets for this provider
 		r.providers[provider.Name] = providerTargets
 	}
+	if err := r.applyProviderBlacklist(blacklist); err != nil {
+		return err
+	}
 
 	// Create balancers for each method
 	for method, info := range r.methodMap {
 		if len(info.targets) > 0 {
-			balancer, err := balancer.NewProbabilisticBalancer(info.targets, info.weights)
+			balancer, err := r.newMethodBalancer(method, info.targets, info.weights)
 			if err != nil {
 				return fmt.Errorf("creating balancer for method %s: %w", method, err)
 			}
@@ -185,6 +330,18 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 		r.wsTargetInfo.balancer = balancer
 	}
 
+	// Create balancer for GPA target info if it exists
+	if r.gpaTargetInfo != nil && len(r.gpaTargetInfo.targets) > 0 {
+		balancer, err := balancer.NewProbabilisticBalancer(
+			r.gpaTargetInfo.targets,
+			r.gpaTargetInfo.weights,
+		)
+		if err != nil {
+			return fmt.Errorf("creating balancer for GPA requests: %w", err)
+		}
+		r.gpaTargetInfo.balancer = balancer
+	}
+
 	// Create balancer for default target info if it exists
 	if r.defaultTargetInfo != nil && len(r.defaultTargetInfo.targets) > 0 {
 		balancer, err := balancer.NewProbabilisticBalancer(
@@ -200,6 +357,39 @@ func (r *MethodBasedRouter) processProviders(providers []configtypes.ProviderCon
 	return nil
 }
 
+// applyProviderBlacklist routes blacklisted methods served by the default handler to its targets of other providers,
+// so the blacklisted ones aren't used for them. Explicitly mapped methods have no blacklisted targets already
+func (r *MethodBasedRouter) applyProviderBlacklist(blacklist map[string][]string) error {
+	for method, providers := range blacklist {
+		for _, provider := range providers {
+			if _, ok := r.providers[provider]; !ok {
+				return fmt.Errorf("method %s: unknown blacklisted provider %s", method, provider)
+			}
+		}
+		if _, ok := r.methodMap[method]; ok || r.defaultTargetInfo == nil {
+			continue
+		}
+
+		info := &methodTargetInfo{}
+		for i, target := range r.defaultTargetInfo.targets {
+			if !contains(providers, target.provider) {
+				info.targets = append(info.targets, target)
+				info.weights = append(info.weights, r.defaultTargetInfo.weights[i])
+			}
+		}
+		if len(info.targets) == 0 {
+			return fmt.Errorf("method %s: all targets are blacklisted", method)
+		}
+		if len(info.targets) == len(r.defaultTargetInfo.targets) {
+			continue // no blacklisted provider handles other methods
+		}
+		r.methodMap[method] = info
+		r.supportedMethods[method] = struct{}{}
+	}
+
+	return nil
+}
+
 // processBatchNodes is a helper function to process a batch of nodes into targets
 // and create a balancer from them.
 func (r *MethodBasedRouter) processBatchNodes(
@@ -226,7 +416,7 @@ func (r *MethodBasedRouter) processBatchNodes(
 	// Mark methods as supported
 	for method := range methodsToAdd {
 		if len(targets) > 0 {
-			balancer, err := balancer.NewProbabilisticBalancer(targets, weights)
+			balancer, err := r.newMethodBalancer(method, targets, weights)
 			if err != nil {
 				return nil, nil, fmt.Errorf("creating balancer for method %s: %w", method, err)
 			}
@@ -286,6 +476,30 @@ func (r *MethodBasedRouter) processLegacyConfig(cfg *configtypes.SolanaConfig) e
 		}
 	}
 
+	// Process GPANodes as a dedicated pool for getProgramAccounts
+	if len(cfg.GPANodes) > 0 {
+		// The GPA pool is selected by request type, not by method map
+		gpaMethods := map[string]struct{}{}
+
+		targets, weights, err := r.processBatchNodes(cfg.GPANodes, gpaMethods)
+		if err != nil {
+			return fmt.Errorf("processing GPA nodes: %w", err)
+		}
+
+		// Create GPA target info
+		if len(targets) > 0 {
+			balancer, err := balancer.NewProbabilisticBalancer(targets, weights)
+			if err != nil {
+				return fmt.Errorf("creating balancer for GPA nodes: %w", err)
+			}
+			r.gpaTargetInfo = &methodTargetInfo{
+				targets:  targets,
+				weights:  weights,
+				balancer: balancer,
+			}
+		}
+	}
+
 	// Process BasicRouteNodes as default routes
 	if len(cfg.BasicRouteNodes) > 0 {
 		// No specific methods to add for basic route nodes
@@ -313,6 +527,363 @@ func (r *MethodBasedRouter) processLegacyConfig(cfg *configtypes.SolanaConfig) e
 	return nil
 }
 
+// newMethodBalancer creates the target selector of the method by its selection strategy.
+// Weights are used by the probabilistic strategy only
+func (r *MethodBasedRouter) newMethodBalancer(method string, targets []*ProxyTarget, weights []float64) (balancer.TargetSelector[*ProxyTarget], error) {
+	responseTime := func(target *ProxyTarget) float64 {
+		return target.p95ResponseTimeMs(method)
+	}
+
+	switch r.methodStrategies[method] {
+	case configtypes.SelectionStrategyRoundRobin:
+		return balancer.NewRoundRobin(targets), nil
+	case configtypes.SelectionStrategyLeastLatency:
+		return balancer.NewLeastLatency(targets, responseTime)
+	case configtypes.SelectionStrategyP2C:
+		return balancer.NewPowerOfTwoChoices(targets, responseTime)
+	case configtypes.SelectionStrategyConsistentHash:
+		ids := make([]string, len(targets))
+		for i, target := range targets {
+			ids[i] = target.url
+		}
+		return balancer.NewConsistentHash(targets, ids)
+	case configtypes.SelectionStrategyComposite:
+		errorRate := func(target *ProxyTarget) float64 {
+			return target.errorRate(method)
+		}
+		return balancer.NewComposite(targets, responseTime, errorRate, r.compositeWeights.Latency, r.compositeWeights.ErrorRate)
+	default:
+		return balancer.NewProbabilisticBalancer(targets, weights)
+	}
+}
+
+// applySelectionStrategies gives methods routed to the default handler a dedicated entry with the configured strategy.
+// Explicitly routed methods get it on creation
+func (r *MethodBasedRouter) applySelectionStrategies() error {
+	for method := range r.methodStrategies {
+		if _, ok := r.methodMap[method]; ok {
+			continue
+		}
+		info := r.defaultTargetInfo
+		if info == nil || len(info.targets) == 0 {
+			continue // method is not routed at all
+		}
+
+		balancer, err := r.newMethodBalancer(method, info.targets, info.weights)
+		if err != nil {
+			return fmt.Errorf("creating balancer for method %s: %w", method, err)
+		}
+		r.methodMap[method] = &methodTargetInfo{
+			targets:  info.targets,
+			weights:  info.weights,
+			balancer: balancer,
+		}
+		r.supportedMethods[method] = struct{}{}
+	}
+
+	return nil
+}
+
+// applyNodeRequirements restricts the targets of each method to node types not less capable than required.
+// Methods routed to the default handler get a dedicated filtered entry
+func (r *MethodBasedRouter) applyNodeRequirements(requirements map[string]string) error {
+	for method, minType := range requirements {
+		info, ok := r.methodMap[method]
+		if !ok {
+			info = r.defaultTargetInfo
+		}
+		if info == nil || len(info.targets) == 0 {
+			continue // method is not routed at all
+		}
+
+		filtered := &methodTargetInfo{}
+		for i, target := range info.targets {
+			isAtLeast, err := target.targetType.IsAtLeast(minType)
+			if err != nil {
+				return fmt.Errorf("method %s: %w", method, err)
+			}
+			if isAtLeast {
+				filtered.targets = append(filtered.targets, target)
+				filtered.weights = append(filtered.weights, info.weights[i])
+			}
+		}
+		if len(filtered.targets) == 0 {
+			return fmt.Errorf("no %s targets for method %s", minType, method)
+		}
+
+		balancer, err := r.newMethodBalancer(method, filtered.targets, filtered.weights)
+		if err != nil {
+			return fmt.Errorf("creating balancer for method %s: %w", method, err)
+		}
+		filtered.balancer = balancer
+
+		r.methodMap[method] = filtered
+		r.supportedMethods[method] = struct{}{}
+	}
+
+	return nil
+}
+
+// applyProviderOrder replaces weighted selection of the method targets with ordered selection by provider.
+// Targets of each provider are selected by weight, providers not listed in the order are tried last
+func (r *MethodBasedRouter) applyProviderOrder(order map[string][]string) error {
+	for method, providers := range order {
+		info, ok := r.methodMap[method]
+		if !ok {
+			info = r.defaultTargetInfo
+		}
+		if info == nil || len(info.targets) == 0 {
+			continue // method is not routed at all
+		}
+
+		// Group targets into tiers by provider order
+		tierIdx := make(map[string]int, len(providers))
+		for i, provider := range providers {
+			if _, ok := r.providers[provider]; !ok {
+				return fmt.Errorf("method %s: unknown provider %s", method, provider)
+			}
+			tierIdx[provider] = i
+		}
+		tiers := make([]methodTargetInfo, len(providers)+1) // the last one is for unlisted providers
+		for i, target := range info.targets {
+			idx, ok := tierIdx[target.provider]
+			if !ok {
+				idx = len(providers)
+			}
+			tiers[idx].targets = append(tiers[idx].targets, target)
+			tiers[idx].weights = append(tiers[idx].weights, info.weights[i])
+		}
+
+		// Targets are stored in tier order, so indices of the ordered selector match them
+		ordered := &methodTargetInfo{}
+		selectors := make([]balancer.TargetSelector[*ProxyTarget], 0, len(tiers))
+		for _, tier := range tiers {
+			if len(tier.targets) == 0 {
+				continue
+			}
+			tierBalancer, err := r.newMethodBalancer(method, tier.targets, tier.weights)
+			if err != nil {
+				return fmt.Errorf("creating balancer for method %s: %w", method, err)
+			}
+			selectors = append(selectors, tierBalancer)
+			ordered.targets = append(ordered.targets, tier.targets...)
+			ordered.weights = append(ordered.weights, tier.weights...)
+			ordered.tiers = append(ordered.tiers, len(tier.targets))
+		}
+
+		orderedSelector, err := balancer.NewOrderedSelector(selectors...)
+		if err != nil {
+			return fmt.Errorf("creating ordered selector for method %s: %w", method, err)
+		}
+		ordered.balancer = orderedSelector
+
+		r.methodMap[method] = ordered
+		r.supportedMethods[method] = struct{}{}
+	}
+
+	return nil
+}
+
+// preferRegion splits the targets of method, default and GPA balancers into providers of the region, tried first,
+// and the rest, used after they are exhausted. Methods with a provider order keep it, WebSocket targets aren't affected.
+// Like provider ordered methods, split ones aren't boosted by success streaks
+func (r *MethodBasedRouter) preferRegion(region string) error {
+	if region == "" {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for method, info := range r.methodMap {
+		if _, ordered := info.balancer.(*balancer.OrderedSelector[*ProxyTarget]); ordered {
+			continue
+		}
+		if err := r.splitByRegion(method, info, region); err != nil {
+			return fmt.Errorf("method %s: %w", method, err)
+		}
+	}
+	if err := r.splitByRegion("", r.defaultTargetInfo, region); err != nil {
+		return fmt.Errorf("default routing: %w", err)
+	}
+	if err := r.splitByRegion("", r.gpaTargetInfo, region); err != nil {
+		return fmt.Errorf("GPA routing: %w", err)
+	}
+
+	return nil
+}
+
+// splitByRegion replaces the balancer with an ordered selector of the region targets and the rest,
+// when there are targets of both. Targets are stored in tier order, so indices of the ordered selector match them
+func (r *MethodBasedRouter) splitByRegion(method string, info *methodTargetInfo, region string) error {
+	if info == nil || len(info.targets) == 0 {
+		return nil
+	}
+
+	var local, remote methodTargetInfo
+	for i, target := range info.targets {
+		tier := &remote
+		if strings.EqualFold(target.region, region) {
+			tier = &local
+		}
+		tier.targets = append(tier.targets, target)
+		tier.weights = append(tier.weights, info.weights[i])
+	}
+	if len(local.targets) == 0 || len(remote.targets) == 0 {
+		return nil
+	}
+
+	selectors := make([]balancer.TargetSelector[*ProxyTarget], 0, 2)
+	for _, tier := range []*methodTargetInfo{&local, &remote} {
+		tierBalancer, err := r.newMethodBalancer(method, tier.targets, tier.weights)
+		if err != nil {
+			return err
+		}
+		selectors = append(selectors, tierBalancer)
+	}
+	ordered, err := balancer.NewOrderedSelector(selectors...)
+	if err != nil {
+		return err
+	}
+
+	info.targets = append(local.targets, remote.targets...)
+	info.weights = append(local.weights, remote.weights...)
+	info.balancer = ordered
+	info.tiers = []int{len(local.targets), len(remote.targets)}
+
+	return nil
+}
+
+// setTargetWarmUp sets the period after adding during which target stats aren't used to rank it
+func (r *MethodBasedRouter) setTargetWarmUp(period time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, targets := range r.providers {
+		for _, target := range targets {
+			target.warmUpPeriod = period
+		}
+	}
+}
+
+// setResponseTimesLen sets the number of the last response times averaged per method of a target, the default if 0
+func (r *MethodBasedRouter) setResponseTimesLen(historyLen int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, targets := range r.providers {
+		for _, target := range targets {
+			target.responseTimesLen = historyLen
+		}
+	}
+}
+
+// setSuccessStreakBoost makes method balancers favor targets with a long consecutive success streak of that method.
+// maxBoost is the multiplier of a full streak, bounded by maxSuccessStreakBoost; values <= 1 disable it.
+// Default, WebSocket and GPA balancers are shared across methods and are not affected, as well as ordered selectors
+func (r *MethodBasedRouter) setSuccessStreakBoost(maxBoost float64) {
+	if maxBoost <= 1 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.successStreakBoost = min(maxBoost, maxSuccessStreakBoost)
+	r.setWeightMultipliers()
+}
+
+// setErrorStreakPenalty makes method balancers avoid targets which error streak of that method started climbing,
+// before they fail enough to stay jailed. Their weight is divided by 1 + penalty * streak; values <= 0 disable it.
+// The same balancers as with the success streak boost are affected
+func (r *MethodBasedRouter) setErrorStreakPenalty(penalty float64) {
+	if penalty <= 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.errorStreakPenalty = penalty
+	r.setWeightMultipliers()
+}
+
+// setWeightMultipliers combines the enabled streak multipliers of probabilistic method balancers. The mutex must be held
+func (r *MethodBasedRouter) setWeightMultipliers() {
+	maxBoost, penalty := r.successStreakBoost, r.errorStreakPenalty
+	for method, info := range r.methodMap {
+		pb, ok := info.balancer.(*balancer.ProbabilisticBalancer[*ProxyTarget])
+		if !ok {
+			continue
+		}
+		pb.SetWeightMultiplier(func(target *ProxyTarget) float64 {
+			multiplier := 1.0
+			if maxBoost > 1 {
+				multiplier *= target.successStreakMultiplier(method, maxBoost)
+			}
+			if penalty > 0 {
+				multiplier *= target.errorStreakMultiplier(method, penalty)
+			}
+
+			return multiplier
+		})
+	}
+}
+
+// getClientOptions returns the HTTP client settings of the targets which override the defaults, by target URL
+func (r *MethodBasedRouter) getClientOptions() map[string]clientOptions {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	res := make(map[string]clientOptions)
+	for _, targets := range r.providers {
+		for _, target := range targets {
+			if target.hostHeader != "" || target.deadlineHeader {
+				res[target.url] = clientOptions{hostHeader: target.hostHeader, deadlineHeader: target.deadlineHeader}
+			}
+		}
+	}
+
+	return res
+}
+
+// getSoonestJailExpireTime returns the soonest unix time a jailed target is released, 0 if nothing is jailed
+func (r *MethodBasedRouter) getSoonestJailExpireTime(timeNow int64) (soonest int64) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, targets := range r.providers {
+		for _, target := range targets {
+			if expireTime := target.getSoonestJailExpireTime(timeNow); expireTime != 0 && (soonest == 0 || expireTime < soonest) {
+				soonest = expireTime
+			}
+		}
+	}
+
+	return soonest
+}
+
+// GetTargetStates returns the state of all configured targets ordered by provider
+func (r *MethodBasedRouter) GetTargetStates(maskURL bool) []TargetState {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	providers := make([]string, 0, len(r.providers))
+	for provider := range r.providers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	states := make([]TargetState, 0, len(r.providers))
+	for _, provider := range providers {
+		for _, target := range r.providers[provider] {
+			states = append(states, target.GetState(maskURL))
+		}
+	}
+
+	return states
+}
+
 // GetBalancerForMethod returns the appropriate balancer for the given method
 func (r *MethodBasedRouter) GetBalancerForMethod(method string) (balancer.TargetSelector[*ProxyTarget], bool) {
 	r.mutex.RLock()
@@ -331,6 +902,77 @@ func (r *MethodBasedRouter) GetBalancerForMethod(method string) (balancer.Target
 	return nil, false
 }
 
+// GetGPABalancer returns the balancer of the dedicated getProgramAccounts pool, if configured
+func (r *MethodBasedRouter) GetGPABalancer() (balancer.TargetSelector[*ProxyTarget], bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.gpaTargetInfo != nil && r.gpaTargetInfo.balancer != nil {
+		return r.gpaTargetInfo.balancer, true
+	}
+
+	return nil, false
+}
+
+// ExcludeProviders adds indices of all targets of the providers to exclude in one step.
+// selector must be a balancer returned for the method (the method or the GPA pool one)
+func (r *MethodBasedRouter) ExcludeProviders(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, providers []string) {
+	// targets are stored in the balancer indices order
+	for i, target := range r.selectorTargets(method, selector) {
+		if target.provider != "" && slices.Contains(providers, target.provider) {
+			exclude.Add(i)
+		}
+	}
+}
+
+// ExcludeOtherProviders adds the balancer indices of targets of other providers than the pinned one to exclude.
+// It reports whether a not excluded target of the provider is left
+func (r *MethodBasedRouter) ExcludeOtherProviders(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, provider string) (ok bool) {
+	for i, target := range r.selectorTargets(method, selector) {
+		if target.provider != provider {
+			exclude.Add(i)
+			continue
+		}
+		ok = ok || !exclude.Contains(i)
+	}
+
+	return ok
+}
+
+// ExcludeLaggingTargets adds the balancer indices of targets lagging more than maxLag slots behind the freshest target
+// of the selector to exclude. Targets without an observed slot are kept
+func (r *MethodBasedRouter) ExcludeLaggingTargets(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, maxLag int64) {
+	targets := r.selectorTargets(method, selector)
+	timeNow := time.Now()
+
+	slots := make([]int64, len(targets))
+	var freshest int64
+	for i, target := range targets {
+		slots[i] = target.estimatedSlot(timeNow)
+		freshest = max(freshest, slots[i])
+	}
+
+	for i, slot := range slots {
+		if slot != 0 && freshest-slot > maxLag {
+			exclude.Add(i)
+		}
+	}
+}
+
+// selectorTargets returns the targets of the method, default or GPA pool served by selector, in the balancer indices order
+func (r *MethodBasedRouter) selectorTargets(method string, selector balancer.TargetSelector[*ProxyTarget]) []*ProxyTarget {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, candidate := range []*methodTargetInfo{r.methodMap[method], r.defaultTargetInfo, r.gpaTargetInfo} {
+		if candidate != nil && candidate.balancer == selector {
+			return candidate.targets
+		}
+	}
+
+	return nil
+}
+
 // UpdateTargetStats updates the stats for a target after a request
 func (r *MethodBasedRouter) UpdateTargetStats(target *ProxyTarget, success bool, methods []string, responseTimeMs, slotAmount int64) {
 	if target == nil {
@@ -338,6 +980,19 @@ func (r *MethodBasedRouter) UpdateTargetStats(target *ProxyTarget, success bool,
 	}
 
 	target.UpdateStats(success, methods, responseTimeMs, slotAmount)
+	if !success {
+		r.keepMinAvailable(methods)
+	}
+}
+
+// JailTargetFor jails the target for the methods for a fixed time, e.g. after a gateway error in front of the node
+func (r *MethodBasedRouter) JailTargetFor(target *ProxyTarget, methods []string, jailTime time.Duration) {
+	if target == nil {
+		return
+	}
+
+	target.jailFor(methods, jailTime)
+	r.keepMinAvailable(methods)
 }
 
 // IsMethodSupported checks if a method is supported by this router
@@ -351,10 +1006,48 @@ func (r *MethodBasedRouter) IsMethodSupported(method string) bool {
 		return true
 	}
 
+	// getProgramAccounts is served by the dedicated pool when configured
+	if method == solana.GetProgramAccounts && r.gpaTargetInfo != nil && r.gpaTargetInfo.balancer != nil && r.gpaTargetInfo.balancer.IsAvailable() {
+		return true
+	}
+
 	// Check if we have a default handler
 	return r.defaultTargetInfo != nil && r.defaultTargetInfo.balancer != nil && r.defaultTargetInfo.balancer.IsAvailable()
 }
 
+// CanServeMethod checks if the method is supported and at least one of its targets isn't jailed for it or draining
+func (r *MethodBasedRouter) CanServeMethod(method string) bool {
+	if !r.IsMethodSupported(method) {
+		return false
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	timeNow := time.Now().Unix()
+	for _, target := range r.methodTargets(method) {
+		if !target.isJailed(method, timeNow) && !target.isDraining() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodTargets returns the targets serving the method, the mutex must be held
+func (r *MethodBasedRouter) methodTargets(method string) (targets []*ProxyTarget) {
+	if info, ok := r.methodMap[method]; ok {
+		targets = info.targets
+	} else if r.defaultTargetInfo != nil {
+		targets = r.defaultTargetInfo.targets
+	}
+	if method == solana.GetProgramAccounts && r.gpaTargetInfo != nil {
+		targets = append(slices.Clip(targets), r.gpaTargetInfo.targets...)
+	}
+
+	return targets
+}
+
 // IsAvailable checks if there are any available targets
 func (r *MethodBasedRouter) IsAvailable() bool {
 	r.mutex.RLock()
@@ -365,6 +1058,11 @@ func (r *MethodBasedRouter) IsAvailable() bool {
 		return true
 	}
 
+	// Check if GPA target is available
+	if r.gpaTargetInfo != nil && r.gpaTargetInfo.balancer != nil && r.gpaTargetInfo.balancer.IsAvailable() {
+		return true
+	}
+
 	// Check if any method-specific target is available
 	for _, info := range r.methodMap {
 		if info.balancer != nil && info.balancer.IsAvailable() {
@@ -3,6 +3,7 @@ package solana
 import (
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,8 +17,11 @@ import (
 type mockBalancer struct{}
 
 func (m *mockBalancer) GetNext(excludedIndices []int) (*ProxyTarget, int, error) { return nil, 0, nil }
-func (m *mockBalancer) IsAvailable() bool                                        { return true }
-func (m *mockBalancer) GetTargetsCount() int                                     { return 1 }
+func (m *mockBalancer) GetNextBatch(n int, excludedIndices []int) ([]*ProxyTarget, []int, error) {
+	return nil, nil, nil
+}
+func (m *mockBalancer) IsAvailable() bool    { return true }
+func (m *mockBalancer) GetTargetsCount() int { return 1 }
 
 // Helper function to create a minimal SolanaConfig
 func createTestConfig() *configtypes.SolanaConfig {
@@ -90,6 +94,88 @@ func TestMethodBasedRouter_MethodGroups(t *testing.T) {
 	assert.ElementsMatch(t, router.methodGroups["transaction"], []string{"getTransaction", "sendTransaction"})
 }
 
+// TestMethodBasedRouter_MethodGroupIncludes tests transitive group composition
+func TestMethodBasedRouter_MethodGroupIncludes(t *testing.T) {
+	config := createTestConfig()
+	config.MethodGroups = []configtypes.MethodGroupConfig{
+		{Name: "all_reads", Includes: []string{"accounts", "blocks", "undefined"}},
+		{Name: "accounts", Methods: []string{"getBalance", "getAccountInfo"}},
+		{Name: "blocks", Methods: []string{"getBlock", "getBalance"}, Includes: []string{"slots"}},
+		{Name: "slots", Methods: []string{"getSlot"}},
+	}
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:      "provider1",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://node1.example.com", NodeType: archiveNodeType(), MethodGroups: []string{"all_reads"}}},
+		},
+	}
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"getBalance", "getAccountInfo", "getBlock", "getSlot"}, router.methodGroups["all_reads"])
+	assert.ElementsMatch(t, []string{"getBlock", "getBalance", "getSlot"}, router.methodGroups["blocks"])
+	for _, method := range []string{"getBalance", "getAccountInfo", "getBlock", "getSlot"} {
+		assert.True(t, router.IsMethodSupported(method), method)
+	}
+}
+
+// TestMethodBasedRouter_MethodGroupCycle tests that include cycles fail the router build
+func TestMethodBasedRouter_MethodGroupCycle(t *testing.T) {
+	config := createTestConfig()
+	config.MethodGroups = []configtypes.MethodGroupConfig{
+		{Name: "a", Methods: []string{"getSlot"}, Includes: []string{"b"}},
+		{Name: "b", Includes: []string{"c"}},
+		{Name: "c", Includes: []string{"a"}},
+	}
+
+	_, err := NewMethodBasedRouter(config)
+	require.ErrorIs(t, err, ErrMethodGroupCycle)
+	assert.Contains(t, err.Error(), "a -> b -> c -> a")
+
+	config.MethodGroups = []configtypes.MethodGroupConfig{{Name: "self", Includes: []string{"self"}}}
+	_, err = NewMethodBasedRouter(config)
+	require.ErrorIs(t, err, ErrMethodGroupCycle)
+}
+
+// TestMethodBasedRouter_WebSocketURLValidation tests that malformed WebSocket endpoints fail the router build
+func TestMethodBasedRouter_WebSocketURLValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{name: "valid", url: "https://ws.example.com/key"},
+		{name: "ws scheme", url: "ws://ws.example.com", wantErr: "invalid scheme"},
+		{name: "empty host", url: "https:///path", wantErr: "invalid host"},
+		{name: "not a URL", url: "ws.example.com", wantErr: "invalid URI"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestConfig()
+			config.Providers = []configtypes.ProviderConfig{
+				{
+					Name: "provider1",
+					Endpoints: []configtypes.EndpointConfig{
+						{URL: "https://rpc.example.com", NodeType: archiveNodeType(), HandleOther: true},
+						{URL: tt.url, NodeType: archiveNodeType(), HandleWebSocket: true},
+					},
+				},
+			}
+
+			_, err := NewMethodBasedRouter(config)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "provider provider1: WebSocket endpoint")
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
 // TestMethodBasedRouter_Providers tests provider configuration processing
 func TestMethodBasedRouter_Providers(t *testing.T) {
 	config := createTestConfig()
@@ -623,3 +709,764 @@ func TestMethodBasedRouter_IsAvailable(t *testing.T) {
 		router.defaultTargetInfo.balancer.IsAvailable()
 	assert.True(t, available)
 }
+
+// TestMethodBasedRouter_GPAPool tests dedicated getProgramAccounts pool configuration
+func TestMethodBasedRouter_GPAPool(t *testing.T) {
+	config := createTestConfig()
+
+	// No GPA pool configured
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	_, found := router.GetGPABalancer()
+	assert.False(t, found)
+	assert.False(t, router.IsMethodSupported(solana.GetProgramAccounts))
+
+	// Provider endpoint marked as GPA handler
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "gpa_provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{
+					URL:       "https://gpa1.example.com",
+					HandleGPA: true,
+					NodeType:  extendedNodeType(),
+				},
+			},
+		},
+	}
+
+	router, err = NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	require.NotNil(t, router.gpaTargetInfo)
+	assert.Len(t, router.gpaTargetInfo.targets, 1)
+	gpaBalancer, found := router.GetGPABalancer()
+	assert.True(t, found)
+	assert.NotNil(t, gpaBalancer)
+	assert.True(t, router.IsMethodSupported(solana.GetProgramAccounts))
+	assert.True(t, router.IsAvailable())
+
+	// Test with legacy config
+	legacyConfig := createTestConfig()
+	legacyConfig.GPANodes = []configtypes.SolanaNode{
+		{
+			URL:      createURL("https://gpa2.example.com"),
+			Provider: "legacy_gpa",
+			NodeType: extendedNodeType(),
+		},
+	}
+
+	legacyRouter, err := NewMethodBasedRouter(legacyConfig)
+	require.NoError(t, err)
+	require.NotNil(t, legacyRouter.gpaTargetInfo)
+	assert.NotNil(t, legacyRouter.gpaTargetInfo.balancer)
+	assert.Len(t, legacyRouter.providers["legacy_gpa"], 1)
+}
+
+// TestMethodBasedRouter_NodeRequirements tests that targets below the required node type are excluded
+func TestMethodBasedRouter_NodeRequirements(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://basic.example.com", NodeType: basicNodeType(), HandleOther: true},
+				{URL: "https://extended.example.com", NodeType: extendedNodeType(), HandleOther: true},
+				{URL: "https://archive.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://tx-basic.example.com", NodeType: basicNodeType(), Methods: []string{solana.GetTransaction}},
+				{URL: "https://tx-archive.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetTransaction}},
+			},
+		},
+	}
+	config.MethodNodeRequirements = map[string]string{
+		solana.GetSignaturesForAddress: solana.ArchiveSolanaNode, // served by the default handler
+		solana.GetTransaction:          "extended_node",          // explicitly routed
+	}
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	targetURLs := func(method string) []string {
+		info, ok := router.methodMap[method]
+		require.True(t, ok, method)
+		urls := make([]string, 0, len(info.targets))
+		for _, target := range info.targets {
+			urls = append(urls, target.url)
+		}
+		return urls
+	}
+	assert.Equal(t, []string{"https://archive.example.com"}, targetURLs(solana.GetSignaturesForAddress))
+	assert.Equal(t, []string{"https://tx-archive.example.com"}, targetURLs(solana.GetTransaction))
+	assert.True(t, router.IsMethodSupported(solana.GetSignaturesForAddress))
+
+	// balancer never returns excluded targets
+	selector, found := router.GetBalancerForMethod(solana.GetSignaturesForAddress)
+	require.True(t, found)
+	for i := 0; i < 20; i++ {
+		target, _, err := selector.GetNext(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "https://archive.example.com", target.url)
+	}
+
+	// methods without requirements keep the default handler
+	assert.Len(t, router.defaultTargetInfo.targets, 3)
+
+	// no target satisfies the requirement
+	config.MethodNodeRequirements = map[string]string{solana.GetBlock: solana.ArchiveSolanaNode}
+	config.Providers[0].Endpoints = config.Providers[0].Endpoints[:2]
+	_, err = NewMethodBasedRouter(config)
+	assert.Error(t, err)
+}
+
+// TestMethodBasedRouter_SuccessStreakBoost tests that targets with a longer success streak are selected more often
+func TestMethodBasedRouter_SuccessStreakBoost(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://streak.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://half.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://fresh.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	router.setSuccessStreakBoost(10) // capped at maxSuccessStreakBoost
+
+	targets := router.methodMap[solana.GetSlot].targets
+	for i := 0; i < consecutiveSuccessResponses; i++ {
+		targets[0].UpdateStats(true, []string{solana.GetSlot}, 10, 0)
+	}
+	for i := 0; i < consecutiveSuccessResponses/2; i++ {
+		targets[1].UpdateStats(true, []string{solana.GetSlot}, 10, 0)
+	}
+
+	selector, found := router.GetBalancerForMethod(solana.GetSlot)
+	require.True(t, found)
+	countSelections := func() map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 30000; i++ {
+			target, _, err := selector.GetNext(nil)
+			require.NoError(t, err)
+			counts[target.url]++
+		}
+		return counts
+	}
+
+	// weights 3 : 2 : 1
+	counts := countSelections()
+	assert.InDelta(t, 0.5, float64(counts["https://streak.example.com"])/30000, 0.02)
+	assert.InDelta(t, 1.0/3, float64(counts["https://half.example.com"])/30000, 0.02)
+	assert.InDelta(t, 1.0/6, float64(counts["https://fresh.example.com"])/30000, 0.02)
+
+	// boost decays once the streak reaches the reset threshold
+	targets[0].UpdateStats(true, []string{solana.GetSlot}, 10, 0)
+	counts = countSelections()
+	assert.Less(t, counts["https://streak.example.com"], counts["https://half.example.com"])
+}
+
+// TestMethodBasedRouter_ErrorStreakPenalty tests that a target which started to error is selected less often once
+// released from its short jail, proportionally to its error streak
+func TestMethodBasedRouter_ErrorStreakPenalty(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://degrading.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://healthy1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://healthy2.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	router.setErrorStreakPenalty(1)
+
+	degrading := router.methodMap[solana.GetSlot].targets[0]
+	failAndRelease := func() {
+		degrading.UpdateStats(false, []string{solana.GetSlot}, 0, 0)
+		degrading.mx.Lock()
+		restriction := degrading.availableMethods[solana.GetSlot]
+		restriction.jailExpireTime = 0
+		degrading.availableMethods[solana.GetSlot] = restriction
+		degrading.mx.Unlock()
+	}
+
+	selector, found := router.GetBalancerForMethod(solana.GetSlot)
+	require.True(t, found)
+	degradingShare := func() float64 {
+		var count int
+		for i := 0; i < 30000; i++ {
+			target, _, err := selector.GetNext(nil)
+			require.NoError(t, err)
+			if target == degrading {
+				count++
+			}
+		}
+		return float64(count) / 30000
+	}
+
+	assert.InDelta(t, 1.0/3, degradingShare(), 0.02)
+
+	// weights 1/2 : 1 : 1
+	failAndRelease()
+	assert.InDelta(t, 0.2, degradingShare(), 0.02)
+
+	// weights 1/4 : 1 : 1
+	failAndRelease()
+	failAndRelease()
+	assert.InDelta(t, 1.0/9, degradingShare(), 0.02)
+}
+
+// TestMethodBasedRouter_ProviderOrder tests that the secondary provider is used only after the primary targets failed
+func TestMethodBasedRouter_ProviderOrder(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "secondary",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://secondary.example.com", NodeType: archiveNodeType(), Weight: 100, HandleOther: true},
+			},
+		},
+		{
+			Name: "primary",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://primary1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://primary2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{
+		solana.GetTransaction: {"primary", "secondary"},
+	}
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	selector, found := router.GetBalancerForMethod(solana.GetTransaction)
+	require.True(t, found)
+	assert.Equal(t, 3, selector.GetTargetsCount())
+
+	// primary targets only, despite the higher secondary weight
+	for i := 0; i < 100; i++ {
+		target, _, err := selector.GetNext(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "primary", target.provider)
+	}
+
+	// emulate retries excluding failed targets
+	var excluded []int
+	var providers []string
+	for {
+		target, idx, err := selector.GetNext(excluded)
+		if err != nil {
+			break
+		}
+		assert.Same(t, router.methodMap[solana.GetTransaction].targets[idx], target)
+		providers = append(providers, target.provider)
+		excluded = append(excluded, idx)
+	}
+	assert.Equal(t, []string{"primary", "primary", "secondary"}, providers)
+
+	// other methods keep the weighted default routing
+	_, ok := router.methodMap[solana.GetSlot]
+	assert.False(t, ok)
+
+	// unknown provider
+	config.MethodProviderOrder = map[string][]string{solana.GetTransaction: {"unknown"}}
+	_, err = NewMethodBasedRouter(config)
+	assert.Error(t, err)
+}
+
+// TestMethodBasedRouter_ProviderBlacklist tests that a blacklisted provider contributes no target to the method
+func TestMethodBasedRouter_ProviderBlacklist(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "bad_gpa",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://bad1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetProgramAccounts, solana.GetSlot}, HandleGPA: true},
+				{URL: "https://bad2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name: "good",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://good1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetProgramAccounts}, HandleGPA: true},
+				{URL: "https://good2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	config.MethodProviderBlacklist = map[string][]string{
+		solana.GetProgramAccounts: {"bad_gpa"},
+		solana.GetBlock:           {"bad_gpa"},
+	}
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	providersOf := func(targets []*ProxyTarget) (res []string) {
+		for _, target := range targets {
+			res = append(res, target.provider)
+		}
+		return res
+	}
+	// explicitly listed method and the dedicated pool
+	assert.Equal(t, []string{"good"}, providersOf(router.methodMap[solana.GetProgramAccounts].targets))
+	assert.Equal(t, []string{"good"}, providersOf(router.gpaTargetInfo.targets))
+	// method served by the default handler
+	selector, found := router.GetBalancerForMethod(solana.GetBlock)
+	require.True(t, found)
+	assert.Equal(t, 1, selector.GetTargetsCount())
+	assert.Equal(t, []string{"good"}, providersOf(router.methodMap[solana.GetBlock].targets))
+	assert.True(t, router.IsMethodSupported(solana.GetBlock))
+	// other methods of the provider aren't affected
+	assert.Equal(t, []string{"bad_gpa"}, providersOf(router.methodMap[solana.GetSlot].targets))
+	assert.Equal(t, []string{"bad_gpa", "good"}, providersOf(router.defaultTargetInfo.targets))
+
+	// unknown provider
+	config.MethodProviderBlacklist = map[string][]string{solana.GetBlock: {"unknown"}}
+	_, err = NewMethodBasedRouter(config)
+	assert.Error(t, err)
+
+	// no targets left
+	config.MethodProviderBlacklist = map[string][]string{solana.GetBlock: {"bad_gpa", "good"}}
+	_, err = NewMethodBasedRouter(config)
+	assert.Error(t, err)
+}
+
+// TestMethodBasedRouter_TargetWarmUp tests that a new target gets a neutral weight during warm-up and is ranked by stats after
+func TestMethodBasedRouter_TargetWarmUp(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://old.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://new.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	router.setSuccessStreakBoost(maxSuccessStreakBoost)
+	router.setTargetWarmUp(time.Minute)
+
+	targets := router.methodMap[solana.GetSlot].targets
+	oldTarget, newTarget := targets[0], targets[1]
+	oldTarget.addedAt = time.Now().Add(-time.Hour)
+	for _, target := range targets {
+		for i := 0; i < consecutiveSuccessResponses; i++ {
+			target.UpdateStats(true, []string{solana.GetSlot}, 10, 0)
+		}
+	}
+
+	selector, found := router.GetBalancerForMethod(solana.GetSlot)
+	require.True(t, found)
+	newTargetShare := func() float64 {
+		var count int
+		for i := 0; i < 20000; i++ {
+			target, _, err := selector.GetNext(nil)
+			require.NoError(t, err)
+			if target == newTarget {
+				count++
+			}
+		}
+		return float64(count) / 20000
+	}
+
+	// warming up: neutral weight 1 against the boosted old target
+	assert.InDelta(t, 0.25, newTargetShare(), 0.02)
+
+	// warmed up: ranked by the same streak as the old target
+	newTarget.addedAt = time.Now().Add(-time.Hour)
+	assert.InDelta(t, 0.5, newTargetShare(), 0.02)
+}
+
+func TestMethodBasedRouter_SelectionStrategy(t *testing.T) {
+	tests := []struct {
+		strategy configtypes.SelectionStrategy
+		expected interface{}
+	}{
+		{configtypes.SelectionStrategyProbabilistic, &balancer.ProbabilisticBalancer[*ProxyTarget]{}},
+		{configtypes.SelectionStrategyRoundRobin, &balancer.RoundRobin[*ProxyTarget]{}},
+		{configtypes.SelectionStrategyLeastLatency, &balancer.LeastLatency[*ProxyTarget]{}},
+		{configtypes.SelectionStrategyP2C, &balancer.PowerOfTwoChoices[*ProxyTarget]{}},
+		{configtypes.SelectionStrategyConsistentHash, &balancer.ConsistentHash[*ProxyTarget]{}},
+		{configtypes.SelectionStrategyComposite, &balancer.Composite[*ProxyTarget]{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			config := createTestConfig()
+			config.Providers = []configtypes.ProviderConfig{
+				{
+					Name: "provider1",
+					Endpoints: []configtypes.EndpointConfig{
+						{URL: "https://rpc1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetAccountInfo}, HandleOther: true},
+						{URL: "https://rpc2.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetAccountInfo}, HandleOther: true},
+					},
+				},
+			}
+			config.MethodSelectionStrategy = map[string]configtypes.SelectionStrategy{
+				solana.GetAccountInfo: tt.strategy, // explicitly routed
+				solana.GetBalance:     tt.strategy, // routed to the default handler
+			}
+			require.NoError(t, config.Validate())
+
+			router, err := NewMethodBasedRouter(config)
+			require.NoError(t, err)
+
+			for _, method := range []string{solana.GetAccountInfo, solana.GetBalance} {
+				selector, found := router.GetBalancerForMethod(method)
+				require.True(t, found, method)
+				assert.IsType(t, tt.expected, selector, method)
+				assert.Equal(t, 2, selector.GetTargetsCount(), method)
+
+				target, idx, err := selector.GetNext([]int{0})
+				require.NoError(t, err, method)
+				assert.Same(t, router.methodMap[method].targets[idx], target, method)
+				assert.Equal(t, 1, idx, method)
+			}
+
+			// methods without a strategy keep the probabilistic balancer
+			selector, found := router.GetBalancerForMethod(solana.GetSlot)
+			require.True(t, found)
+			assert.IsType(t, &balancer.ProbabilisticBalancer[*ProxyTarget]{}, selector)
+		})
+	}
+
+	config := createTestConfig()
+	config.MethodSelectionStrategy = map[string]configtypes.SelectionStrategy{solana.GetBalance: "fastest"}
+	assert.Error(t, config.Validate())
+
+	config = createTestConfig()
+	config.CompositeWeights = &configtypes.CompositeWeights{Latency: 0, ErrorRate: 0}
+	assert.Error(t, config.Validate())
+}
+
+func TestMethodBasedRouter_CompositeStrategy(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fast-failing.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetAccountInfo}, HandleOther: true},
+				{URL: "https://slow.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetAccountInfo}, HandleOther: true},
+				{URL: "https://balanced.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetAccountInfo}, HandleOther: true},
+			},
+		},
+	}
+	config.MethodSelectionStrategy = map[string]configtypes.SelectionStrategy{solana.GetAccountInfo: configtypes.SelectionStrategyComposite}
+	require.NoError(t, config.Validate())
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	targets := router.methodMap[solana.GetAccountInfo].targets
+	require.Len(t, targets, 3)
+
+	methods := []string{solana.GetAccountInfo}
+	for i := 0; i < 4; i++ {
+		router.UpdateTargetStats(targets[0], true, methods, 10, 0)
+		router.UpdateTargetStats(targets[1], true, methods, 200, 0)
+		router.UpdateTargetStats(targets[2], true, methods, 40, 0)
+	}
+	for i := 0; i < 4; i++ {
+		router.UpdateTargetStats(targets[0], false, methods, 0, 0)
+	}
+	router.UpdateTargetStats(targets[2], false, methods, 0, 0)
+	assert.InDelta(t, 0.5, targets[0].errorRate(solana.GetAccountInfo), 1e-9)
+	assert.InDelta(t, 0.2, targets[2].errorRate(solana.GetAccountInfo), 1e-9)
+	assert.Zero(t, targets[1].errorRate(solana.GetAccountInfo))
+
+	// equal weights: fast-failing 10/200 + 0.5, slow 1 + 0, balanced 40/200 + 0.2
+	selector, found := router.GetBalancerForMethod(solana.GetAccountInfo)
+	require.True(t, found)
+	for i := 0; i < 20; i++ {
+		target, _, err := selector.GetNext(nil)
+		require.NoError(t, err)
+		assert.Same(t, targets[2], target)
+	}
+}
+
+func TestMethodBasedRouter_CanServeMethod(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}, HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}, HandleOther: true},
+				{URL: "https://gpa.example.com", NodeType: archiveNodeType(), HandleGPA: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	node1, node2, gpa := router.providers["provider"][0], router.providers["provider"][1], router.providers["provider"][2]
+	for _, method := range []string{solana.GetSlot, solana.GetBalance, solana.GetProgramAccounts} {
+		assert.True(t, router.CanServeMethod(method), method)
+	}
+
+	// one of the targets is jailed
+	router.UpdateTargetStats(node1, false, []string{solana.GetSlot, solana.GetBalance}, 0, 0)
+	assert.True(t, router.CanServeMethod(solana.GetSlot))
+	assert.True(t, router.CanServeMethod(solana.GetBalance))
+
+	// all targets of the method are jailed
+	router.UpdateTargetStats(node2, false, []string{solana.GetSlot, solana.GetBalance}, 0, 0)
+	for _, method := range []string{solana.GetSlot, solana.GetBalance} {
+		assert.True(t, router.IsMethodSupported(method), method)
+		assert.False(t, router.CanServeMethod(method), method)
+	}
+	assert.True(t, router.CanServeMethod(solana.GetAccountInfo), "other methods are not affected")
+
+	// the dedicated pool serves getProgramAccounts
+	router.UpdateTargetStats(node1, false, []string{solana.GetProgramAccounts}, 0, 0)
+	router.UpdateTargetStats(node2, false, []string{solana.GetProgramAccounts}, 0, 0)
+	assert.True(t, router.CanServeMethod(solana.GetProgramAccounts))
+	router.UpdateTargetStats(gpa, false, []string{solana.GetProgramAccounts}, 0, 0)
+	assert.False(t, router.CanServeMethod(solana.GetProgramAccounts))
+
+	// jail applies during warm-up
+	router.setTargetWarmUp(time.Hour)
+	assert.False(t, router.CanServeMethod(solana.GetSlot))
+	router.setTargetWarmUp(0)
+
+	// released after the jail time
+	timeNow := time.Now().Unix()
+	node1.availableMethods[solana.GetSlot] = targetRestriction{jailExpireTime: timeNow - 1}
+	assert.True(t, router.CanServeMethod(solana.GetSlot))
+}
+
+func TestMethodBasedRouter_ProviderCapacity(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:     "large",
+			Capacity: 500,
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://large1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://large2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name:     "small",
+			Capacity: 100,
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://small1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://small2.example.com", Weight: 80, NodeType: archiveNodeType(), HandleOther: true}, // explicit weight
+			},
+		},
+		{
+			Name:      "unrated",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://unrated.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	weights := make(map[string]float64)
+	for i, target := range router.defaultTargetInfo.targets {
+		weights[target.url] = router.defaultTargetInfo.weights[i]
+	}
+	assert.Equal(t, map[string]float64{
+		"https://large1.example.com":  250,
+		"https://large2.example.com":  250,
+		"https://small1.example.com":  50,
+		"https://small2.example.com":  80,
+		"https://unrated.example.com": DefaultEndpointWeight,
+	}, weights)
+}
+
+func TestMethodBasedRouter_ExcludeProviders(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "providerA",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://a1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://a2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name: "providerB",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://b1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://b2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{solana.GetBlock: {"providerB"}}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	for _, method := range []string{solana.GetSlot, solana.GetBlock} { // the default and the ordered balancers
+		selector, ok := router.GetBalancerForMethod(method)
+		require.True(t, ok)
+
+		exclude := balancer.NewExclusions(selector.GetTargetsCount())
+		router.ExcludeProviders(method, selector, exclude, []string{"providerA"})
+		assert.Equal(t, 2, exclude.Len(), method)
+
+		for i := 0; i < 100; i++ {
+			target, _, err := getNextTarget(selector, nil, "", exclude)
+			require.NoError(t, err)
+			assert.Equal(t, "providerB", target.provider, method)
+		}
+	}
+
+	// a balancer of another method is not matched
+	selector, _ := router.GetBalancerForMethod(solana.GetBlock)
+	exclude := balancer.NewExclusions(selector.GetTargetsCount())
+	router.ExcludeProviders("unknownMethod", selector, exclude, []string{"providerA"})
+	assert.Zero(t, exclude.Len())
+}
+
+func TestMethodBasedRouter_ExcludeOtherProviders(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "providerA",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://a1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://a2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name:      "providerB",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://b1.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	selector, ok := router.GetBalancerForMethod(solana.GetSlot)
+	require.True(t, ok)
+
+	exclude := balancer.NewExclusions(selector.GetTargetsCount())
+	require.True(t, router.ExcludeOtherProviders(solana.GetSlot, selector, exclude, "providerB"))
+	assert.Equal(t, 2, exclude.Len())
+	for i := 0; i < 100; i++ {
+		target, _, err := getNextTarget(selector, nil, "", exclude)
+		require.NoError(t, err)
+		assert.Equal(t, "providerB", target.provider)
+	}
+
+	// the only target of the provider is already excluded
+	exclude = balancer.NewExclusions(selector.GetTargetsCount())
+	for i, target := range router.selectorTargets(solana.GetSlot, selector) {
+		if target.provider == "providerB" {
+			exclude.Add(i)
+		}
+	}
+	assert.False(t, router.ExcludeOtherProviders(solana.GetSlot, selector, exclude, "providerB"))
+
+	exclude = balancer.NewExclusions(selector.GetTargetsCount())
+	assert.False(t, router.ExcludeOtherProviders(solana.GetSlot, selector, exclude, "unknown"))
+	assert.Equal(t, 3, exclude.Len())
+}
+
+func TestMethodBasedRouter_ExcludeLaggingTargets(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fresh.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://lagging.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://unknown.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	timeNow := time.Now()
+	targets := router.defaultTargetInfo.targets
+	targets[0].observeSlot(1000, timeNow)
+	targets[1].observeSlot(950, timeNow)
+
+	selector, ok := router.GetBalancerForMethod(solana.GetSlot)
+	require.True(t, ok)
+
+	exclude := balancer.NewExclusions(selector.GetTargetsCount())
+	router.ExcludeLaggingTargets(solana.GetSlot, selector, exclude, 10)
+	assert.Equal(t, []int{1}, exclude.Indices()) // the target without an observed slot is kept
+
+	exclude = balancer.NewExclusions(selector.GetTargetsCount())
+	router.ExcludeLaggingTargets(solana.GetSlot, selector, exclude, 50)
+	assert.Zero(t, exclude.Len())
+
+	// the estimate of a target observed earlier is extrapolated
+	targets[1].observeSlot(980, timeNow.Add(-10*time.Second)) // estimated at 1005
+	exclude = balancer.NewExclusions(selector.GetTargetsCount())
+	router.ExcludeLaggingTargets(solana.GetSlot, selector, exclude, 10)
+	assert.Zero(t, exclude.Len())
+}
+
+func TestMethodBasedRouter_PreferRegion(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name:   "remote",
+			Region: "us-east",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://remote.example.com", NodeType: archiveNodeType(), Weight: 100, HandleOther: true, Methods: []string{solana.GetBalance}},
+			},
+		},
+		{
+			Name:   "local",
+			Region: "EU-West",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://local1.example.com", NodeType: archiveNodeType(), HandleOther: true, Methods: []string{solana.GetBalance}},
+				{URL: "https://local2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{solana.GetTransaction: {"remote", "local"}}
+
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	require.NoError(t, router.preferRegion("eu-west"))
+
+	tests := []struct {
+		method        string
+		wantProviders []string
+	}{
+		{method: solana.GetSlot, wantProviders: []string{"local", "local", "remote"}},        // default routing
+		{method: solana.GetBalance, wantProviders: []string{"local", "remote"}},              // method routing
+		{method: solana.GetTransaction, wantProviders: []string{"remote", "local", "local"}}, // provider order is kept
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			selector, found := router.GetBalancerForMethod(tt.method)
+			require.True(t, found)
+
+			// the same region is preferred despite the higher remote weight
+			if tt.wantProviders[0] == "local" {
+				for i := 0; i < 100; i++ {
+					target, _, err := selector.GetNext(nil)
+					require.NoError(t, err)
+					assert.Equal(t, "local", target.provider)
+				}
+			}
+
+			// emulate retries excluding failed targets, falling back cross-region on exhaustion
+			var excluded []int
+			var providers []string
+			for {
+				target, idx, err := selector.GetNext(excluded)
+				if err != nil {
+					break
+				}
+				assert.Same(t, router.selectorTargets(tt.method, selector)[idx], target)
+				providers = append(providers, target.provider)
+				excluded = append(excluded, idx)
+			}
+			assert.Equal(t, tt.wantProviders, providers)
+		})
+	}
+}
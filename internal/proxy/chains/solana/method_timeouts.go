@@ -0,0 +1,72 @@
+package solana
+
+import (
+	"context"
+	"time"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// fastMethodTimeout limits node requests of cheap methods, a node not answering them quickly is unlikely to answer at all
+const fastMethodTimeout = 5 * time.Second
+
+// defaultMethodTimeouts are node request timeouts of methods shorter than requestTimeout.
+// Heavy methods (getBlock, getProgramAccounts etc.) aren't limited beyond requestTimeout
+var defaultMethodTimeouts = map[string]time.Duration{
+	solana.GetSlot:            fastMethodTimeout,
+	solana.GetHealth:          fastMethodTimeout,
+	solana.GetBlockHeight:     fastMethodTimeout,
+	solana.GetEpochInfo:       fastMethodTimeout,
+	solana.GetVersion:         fastMethodTimeout,
+	solana.GetLatestBlockhash: fastMethodTimeout,
+	solana.GetIdentity:        fastMethodTimeout,
+	solana.GetGenesisHash:     fastMethodTimeout,
+}
+
+// newMethodTimeouts merges the configured timeouts over the defaults, 0 removes the limit of a method
+func newMethodTimeouts(overrides map[string]time.Duration) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(defaultMethodTimeouts)+len(overrides))
+	for method, timeout := range defaultMethodTimeouts {
+		timeouts[method] = timeout
+	}
+	for method, timeout := range overrides {
+		if timeout <= 0 {
+			delete(timeouts, method)
+			continue
+		}
+		timeouts[method] = timeout
+	}
+
+	return timeouts
+}
+
+// methodTimeout returns the node request timeout of the methods, the longest one of a batch. 0 if any method isn't limited
+func (t *UnifiedTransport) methodTimeout(methods []string) (timeout time.Duration) {
+	for _, method := range methods {
+		methodTimeout, ok := t.methodTimeouts[method]
+		if !ok {
+			return 0
+		}
+		timeout = max(timeout, methodTimeout)
+	}
+
+	return timeout
+}
+
+// withMethodTimeout limits the node request of the attempt by the methods timeout. The returned function restores the request
+func (t *UnifiedTransport) withMethodTimeout(c *echoUtil.CustomContext, methods []string) (restore func()) {
+	timeout := t.methodTimeout(methods)
+	if timeout == 0 {
+		return func() {}
+	}
+
+	req := c.Request()
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	c.SetRequest(req.WithContext(ctx))
+
+	return func() {
+		cancel()
+		c.SetRequest(req)
+	}
+}
@@ -0,0 +1,72 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// slowRequester responds after the delay unless the request context is done before
+type slowRequester struct {
+	delay time.Duration
+}
+
+func (r *slowRequester) DoRequest(c *echoUtil.CustomContext, _ string) ([]byte, int, error) {
+	select {
+	case <-time.After(r.delay):
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":1}`), http.StatusOK, nil
+	case <-c.Request().Context().Done():
+		return nil, 0, c.Request().Context().Err()
+	}
+}
+
+func TestNewMethodTimeouts(t *testing.T) {
+	timeouts := newMethodTimeouts(map[string]time.Duration{solana.GetSlot: time.Second, solana.GetHealth: 0, solana.GetBlock: time.Minute})
+
+	assert.Equal(t, time.Second, timeouts[solana.GetSlot])
+	assert.NotContains(t, timeouts, solana.GetHealth)
+	assert.Equal(t, time.Minute, timeouts[solana.GetBlock])
+	assert.Equal(t, fastMethodTimeout, timeouts[solana.GetVersion])
+	assert.NotContains(t, timeouts, solana.GetProgramAccounts)
+
+	transport := &UnifiedTransport{methodTimeouts: timeouts}
+	assert.Equal(t, time.Minute, transport.methodTimeout([]string{solana.GetSlot, solana.GetBlock}))
+	assert.Zero(t, transport.methodTimeout([]string{solana.GetSlot, solana.GetProgramAccounts}))
+}
+
+func TestUnifiedTransport_MethodTimeout(t *testing.T) {
+	router := newDrainTestRouter(t)
+	transport := NewUnifiedTransport("test_transport", router, &slowRequester{delay: 200 * time.Millisecond}, 1, false)
+	transport.methodTimeouts = newMethodTimeouts(map[string]time.Duration{solana.GetSlot: 20 * time.Millisecond})
+
+	send := func(method string) (time.Duration, error) {
+		body := []byte(`{"jsonrpc":"2.0","id":1,"method":"` + method + `"}`)
+		c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{method}, body)
+		reqCtx := c.Request().Context()
+		start := time.Now()
+		_, _, err := transport.SendRequest(c)
+		// the request context is restored after the attempt
+		assert.Equal(t, reqCtx, c.Request().Context())
+
+		return time.Since(start), err
+	}
+
+	// a fast method fails after its timeout
+	elapsed, err := send(solana.GetSlot)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, elapsed, 150*time.Millisecond)
+
+	// a block method is given longer
+	elapsed, err = send(solana.GetBlock)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+}
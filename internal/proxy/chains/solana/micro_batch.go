@@ -0,0 +1,256 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/labstack/echo/v4"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+var errMissingBatchResponse = errors.New("no response for the request in the upstream batch")
+
+// microBatcher coalesces concurrent single requests of compatible methods to the same target into one JSON-RPC batch.
+// A batch is sent when it reaches maxSize or window after its first request, responses are matched back by id
+type microBatcher struct {
+	requester HTTPRequester
+	window    time.Duration
+	maxSize   int
+	methods   map[string]struct{}
+
+	pending   map[string]*pendingBatch // by target URL
+	pendingMx sync.Mutex
+}
+
+type pendingBatch struct {
+	calls []*batchedCall
+	// the upstream request has the headers of the first call, detached from its cancellation
+	req  *http.Request
+	echo *echo.Echo
+}
+
+type batchedCall struct {
+	chainName   string
+	body        []byte
+	methods     []string
+	deadline    time.Time
+	hasDeadline bool
+	done        chan struct{}
+
+	respBody   []byte
+	statusCode int
+	header     http.Header // set on the upstream request, e.g. the upstream rate limit
+	err        error
+}
+
+// upstreamResponse collects the response headers set on the upstream request, nothing is written to it
+type upstreamResponse struct {
+	header http.Header
+}
+
+func (r *upstreamResponse) Header() http.Header {
+	return r.header
+}
+func (r *upstreamResponse) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+func (r *upstreamResponse) WriteHeader(int) {}
+
+func newMicroBatcher(requester HTTPRequester, window time.Duration, maxSize int, methods []string) *microBatcher {
+	b := &microBatcher{
+		requester: requester,
+		window:    window,
+		maxSize:   maxSize,
+		methods:   make(map[string]struct{}, len(methods)),
+		pending:   make(map[string]*pendingBatch),
+	}
+	for _, method := range methods {
+		b.methods[method] = struct{}{}
+	}
+
+	return b
+}
+
+// canBatch checks if the request is a single request of a batched method
+func (b *microBatcher) canBatch(c *echoUtil.CustomContext, methods []string) bool {
+	if c.GetArrayRequested() || len(methods) != 1 {
+		return false
+	}
+	_, ok := b.methods[methods[0]]
+
+	return ok
+}
+
+// DoRequest waits for the batch of the request to be sent and returns the response of the request. The wait ends
+// with the request context, the batch is still sent for the other requests
+func (b *microBatcher) DoRequest(c *echoUtil.CustomContext, targetURL string) (respBody []byte, statusCode int, err error) {
+	reqCtx := c.Request().Context()
+	call := &batchedCall{
+		chainName: c.GetChainName(),
+		body:      []byte(c.GetReqBodyString()),
+		methods:   c.GetReqMethods(),
+		done:      make(chan struct{}),
+	}
+	call.deadline, call.hasDeadline = reqCtx.Deadline()
+
+	b.pendingMx.Lock()
+	batch, ok := b.pending[targetURL]
+	if !ok {
+		batch = &pendingBatch{req: c.Request().Clone(context.WithoutCancel(reqCtx)), echo: c.Echo()}
+		b.pending[targetURL] = batch
+		time.AfterFunc(b.window, func() { b.flushPending(targetURL, batch) })
+	}
+	batch.calls = append(batch.calls, call)
+	full := len(batch.calls) >= b.maxSize
+	b.pendingMx.Unlock()
+
+	if full {
+		go b.flushPending(targetURL, batch)
+	}
+
+	select {
+	case <-call.done:
+		for key, values := range call.header {
+			if c.Response().Header().Get(key) == "" {
+				c.Response().Header()[key] = values
+			}
+		}
+		return call.respBody, call.statusCode, call.err
+	case <-reqCtx.Done():
+		return nil, http.StatusInternalServerError, reqCtx.Err()
+	}
+}
+
+// flushPending sends the batch unless it's already sent
+func (b *microBatcher) flushPending(targetURL string, batch *pendingBatch) {
+	b.pendingMx.Lock()
+	if b.pending[targetURL] != batch {
+		b.pendingMx.Unlock()
+		return
+	}
+	delete(b.pending, targetURL)
+	b.pendingMx.Unlock()
+
+	b.send(targetURL, batch)
+}
+
+// send requests the calls as one batch with their index as the id. A lone call is sent as is
+func (b *microBatcher) send(targetURL string, batch *pendingBatch) {
+	calls := batch.calls
+	response := &upstreamResponse{header: make(http.Header)}
+	defer func() {
+		for _, call := range calls {
+			call.header = response.header
+			close(call.done)
+		}
+	}()
+
+	if len(calls) == 1 {
+		call := calls[0]
+		upstreamCtx, cancel := batch.upstreamContext(response, call.body, call.methods)
+		defer cancel()
+		call.respBody, call.statusCode, call.err = b.requester.DoRequest(upstreamCtx, targetURL)
+		return
+	}
+
+	requests := make([][]byte, len(calls))
+	ids := make([][]byte, len(calls))
+	methods := make([]string, 0, len(calls))
+	for i, call := range calls {
+		ids[i] = rawID(call.body)
+		request, err := jsonparser.Set(call.body, []byte(strconv.Itoa(i)), "id")
+		if err != nil {
+			call.err = err
+			continue
+		}
+		requests[i] = request
+		methods = append(methods, call.methods...)
+	}
+
+	upstreamCtx, cancel := batch.upstreamContext(response, append(append([]byte{'['}, bytes.Join(nonEmpty(requests), []byte{','})...), ']'), methods)
+	defer cancel()
+	respBody, statusCode, err := b.requester.DoRequest(upstreamCtx, targetURL)
+
+	responses := make([][]byte, len(calls))
+	if err == nil {
+		_, err = jsonparser.ArrayEach(respBody, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+			index, idErr := jsonparser.GetInt(value, "id")
+			if idErr != nil || index < 0 || index >= int64(len(calls)) {
+				return
+			}
+			responses[index] = value
+		})
+	}
+	for i, call := range calls {
+		if call.err != nil {
+			continue
+		}
+		call.statusCode = statusCode
+		if err != nil {
+			call.err = err
+			continue
+		}
+		if responses[i] == nil {
+			call.err = errMissingBatchResponse
+			continue
+		}
+		call.respBody, call.err = jsonparser.Set(responses[i], ids[i], "id")
+	}
+}
+
+// upstreamContext returns the context of the upstream request of the batch. It isn't canceled with the requests of
+// the calls, so a client leaving doesn't fail the others, and it ends with the latest deadline of the calls
+func (b *pendingBatch) upstreamContext(response http.ResponseWriter, body []byte, methods []string) (*echoUtil.CustomContext, context.CancelFunc) {
+	ctx, cancel := b.req.Context(), context.CancelFunc(func() {})
+	var latest time.Time
+	for _, call := range b.calls {
+		if !call.hasDeadline {
+			latest = time.Time{}
+			break
+		}
+		if call.deadline.After(latest) {
+			latest = call.deadline
+		}
+	}
+	if !latest.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, latest)
+	}
+
+	c := &echoUtil.CustomContext{Context: b.echo.NewContext(b.req.WithContext(ctx), response)}
+	c.SetChainName(b.calls[0].chainName)
+	c.SetReqMethods(methods)
+	c.SetReqBody(body)
+
+	return c, cancel
+}
+
+// rawID returns the id of the request as JSON, null if it has none
+func rawID(body []byte) []byte {
+	value, dataType, _, err := jsonparser.Get(body, "id")
+	switch {
+	case err != nil:
+		return []byte("null")
+	case dataType == jsonparser.String:
+		return append(append([]byte{'"'}, value...), '"')
+	default:
+		return append([]byte(nil), value...)
+	}
+}
+
+func nonEmpty(items [][]byte) [][]byte {
+	result := make([][]byte, 0, len(items))
+	for _, item := range items {
+		if len(item) != 0 {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,172 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// batchEchoRequester answers each request of a batch with its id as the result, in reverse order
+type batchEchoRequester struct {
+	mx     sync.Mutex
+	bodies []string
+}
+
+func (r *batchEchoRequester) DoRequest(c *echoUtil.CustomContext, _ string) ([]byte, int, error) {
+	body := c.GetReqBodyString()
+	r.mx.Lock()
+	r.bodies = append(r.bodies, body)
+	r.mx.Unlock()
+
+	var responses []string
+	_, err := jsonparser.ArrayEach([]byte(body), func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		id, _, _, _ := jsonparser.Get(value, "id")
+		responses = append(responses, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, id, id))
+	})
+	if err != nil {
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":"single"}`), http.StatusOK, nil
+	}
+	slices.Reverse(responses)
+
+	return []byte("[" + string(bytes.Join(toBytes(responses), []byte{','})) + "]"), http.StatusOK, nil
+}
+
+func toBytes(items []string) [][]byte {
+	result := make([][]byte, len(items))
+	for i, item := range items {
+		result[i] = []byte(item)
+	}
+
+	return result
+}
+
+func TestMicroBatcher_ConcurrentRequests(t *testing.T) {
+	const requests = 5
+	requester := &batchEchoRequester{}
+	// the batch is sent as soon as it's full, the window is a fallback
+	batcher := newMicroBatcher(requester, time.Second, requests, []string{solana.GetAccountInfo})
+
+	responses := make([]string, requests)
+	wg := sync.WaitGroup{}
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":"req-%d","method":"getAccountInfo","params":["account"]}`, i))
+			c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{solana.GetAccountInfo}, body)
+			assert.True(t, batcher.canBatch(c, c.GetReqMethods()))
+
+			respBody, statusCode, err := batcher.DoRequest(c, "target1")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, statusCode)
+			responses[i] = string(respBody)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, requester.bodies, 1)
+	assert.Equal(t, requests, bytes.Count([]byte(requester.bodies[0]), []byte(solana.GetAccountInfo)))
+	for i, response := range responses {
+		// the original id is restored, the result is the index of the request in the batch
+		id, err := jsonparser.GetString([]byte(response), "id")
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("req-%d", i), id)
+		index, err := jsonparser.GetInt([]byte(response), "result")
+		require.NoError(t, err)
+		assert.Contains(t, requester.bodies[0], fmt.Sprintf(`"id":%d,"method":"getAccountInfo"`, index))
+	}
+}
+
+func TestMicroBatcher_SingleRequestAfterWindow(t *testing.T) {
+	requester := &batchEchoRequester{}
+	batcher := newMicroBatcher(requester, time.Millisecond, 10, []string{solana.GetAccountInfo})
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getAccountInfo","params":["account"]}`)
+	c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{solana.GetAccountInfo}, body)
+	respBody, _, err := batcher.DoRequest(c, "target1")
+	require.NoError(t, err)
+
+	// a lone request is sent as is
+	assert.Equal(t, []string{string(body)}, requester.bodies)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"single"}`, string(respBody))
+	assert.False(t, batcher.canBatch(c, []string{solana.GetBalance}))
+}
+
+// blockingBatchRequester answers like batchEchoRequester once released, recording the upstream context
+type blockingBatchRequester struct {
+	batchEchoRequester
+	release  chan struct{}
+	ctxErr   error
+	deadline time.Time
+}
+
+func (r *blockingBatchRequester) DoRequest(c *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	<-r.release
+	r.ctxErr = c.Request().Context().Err()
+	r.deadline, _ = c.Request().Context().Deadline()
+
+	return r.batchEchoRequester.DoRequest(c, targetURL)
+}
+
+func TestMicroBatcher_CanceledRequest(t *testing.T) {
+	requester := &blockingBatchRequester{release: make(chan struct{})}
+	batcher := newMicroBatcher(requester, time.Second, 2, []string{solana.GetAccountInfo})
+
+	newContext := func(id int, timeout time.Duration) (*echoUtil.CustomContext, context.CancelFunc) {
+		body := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"getAccountInfo","params":["account"]}`, id))
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+		return createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetAccountInfo}, body), cancel
+	}
+	// the first request leaves before the batch is answered
+	canceled, cancel := newContext(1, time.Minute)
+	kept, cancelKept := newContext(2, time.Hour)
+	defer cancelKept()
+
+	canceledErr := make(chan error, 1)
+	go func() {
+		_, _, err := batcher.DoRequest(canceled, "target1")
+		canceledErr <- err
+	}()
+	keptResp := make(chan []byte, 1)
+	go func() {
+		// waits for the first request to open the batch
+		for {
+			batcher.pendingMx.Lock()
+			opened := batcher.pending["target1"] != nil
+			batcher.pendingMx.Unlock()
+			if opened {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		respBody, _, err := batcher.DoRequest(kept, "target1")
+		assert.NoError(t, err)
+		keptResp <- respBody
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-canceledErr, context.Canceled)
+
+	// the batch isn't canceled with the first request and ends with the latest deadline
+	close(requester.release)
+	id, err := jsonparser.GetInt(<-keptResp, "id")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+	assert.NoError(t, requester.ctxErr)
+	expectedDeadline, _ := kept.Request().Context().Deadline()
+	assert.Equal(t, expectedDeadline, requester.deadline)
+}
@@ -0,0 +1,69 @@
+package solana
+
+import (
+	"time"
+
+	"github.com/buger/jsonparser"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/util/balancer"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// behindExcluder excludes targets behind a slot
+type behindExcluder interface {
+	ExcludeTargetsBehind(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, minSlot int64)
+}
+
+// minContextSlotNotReached checks if a single response is the MinContextSlotNotReachedErrCode error. It returns the
+// context slot of the node from the error data, 0 if missing
+func minContextSlotNotReached(respBody []byte) (contextSlot int64, ok bool) {
+	if len(respBody) == 0 || respBody[0] != '{' {
+		return 0, false
+	}
+	code, err := jsonparser.GetInt(respBody, errorField, codeField)
+	if err != nil || code != solana.MinContextSlotNotReachedErrCode {
+		return 0, false
+	}
+	contextSlot, _ = jsonparser.GetInt(respBody, errorField, "data", "contextSlot")
+
+	return contextSlot, true
+}
+
+// requestMinContextSlot returns the minContextSlot of the config object of a single request, 0 if not set
+func requestMinContextSlot(reqBody []byte) (minContextSlot int64) {
+	_, _ = jsonparser.ArrayEach(reqBody, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
+		if dataType != jsonparser.Object {
+			return
+		}
+		if slot, err := jsonparser.GetInt(value, "minContextSlot"); err == nil {
+			minContextSlot = slot
+		}
+	}, "params")
+
+	return minContextSlot
+}
+
+// excludeTargetsBehind excludes the targets which tracked slot is behind the min context slot of the request or not
+// past the slot of the target which failed it, so the retry goes to a more advanced node
+func (t *UnifiedTransport) excludeTargetsBehind(c *echoUtil.CustomContext, method string, selector balancer.TargetSelector[*ProxyTarget],
+	exclude *balancer.Exclusions, behind *ProxyTarget, contextSlot int64) {
+	minSlot := requestMinContextSlot([]byte(c.GetReqBodyString()))
+	if behindSlot := max(behind.estimatedSlot(time.Now()), contextSlot); behindSlot > 0 {
+		minSlot = max(minSlot, behindSlot+1)
+	}
+	if excluder, ok := t.methodRouter.(behindExcluder); ok && minSlot > 0 {
+		excluder.ExcludeTargetsBehind(method, selector, exclude, minSlot)
+	}
+}
+
+// ExcludeTargetsBehind adds the balancer indices of targets which estimated slot is before minSlot to exclude.
+// Targets without an observed slot are kept
+func (r *MethodBasedRouter) ExcludeTargetsBehind(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, minSlot int64) {
+	timeNow := time.Now()
+	for i, target := range r.selectorTargets(method, selector) {
+		if slot := target.estimatedSlot(timeNow); slot != 0 && slot < minSlot {
+			exclude.Add(i)
+		}
+	}
+}
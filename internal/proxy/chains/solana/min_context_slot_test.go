@@ -0,0 +1,112 @@
+package solana
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const minContextSlotNotReachedResp = `{"jsonrpc":"2.0","error":{"code":-32016,"message":"Minimum context slot has not been reached","data":{"contextSlot":900}},"id":1}`
+
+// urlRequester responds by target URL and records the requested ones
+type urlRequester struct {
+	responses map[string]string
+	urls      []string
+}
+
+func (r *urlRequester) DoRequest(_ *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.urls = append(r.urls, targetURL)
+	return []byte(r.responses[targetURL]), http.StatusOK, nil
+}
+
+func newMinContextSlotTestRouter(t *testing.T, urls ...string) *MethodBasedRouter {
+	endpoints := make([]configtypes.EndpointConfig, 0, len(urls))
+	for _, u := range urls {
+		endpoints = append(endpoints, configtypes.EndpointConfig{URL: u, NodeType: archiveNodeType(), HandleOther: true})
+	}
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{{Name: "provider", Endpoints: endpoints}}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	return router
+}
+
+func TestUnifiedTransport_MinContextSlotRetry(t *testing.T) {
+	router := newMinContextSlotTestRouter(t, "https://behind.example.com", "https://also-behind.example.com", "https://ahead.example.com")
+	timeNow := time.Now()
+	router.defaultTargetInfo.targets[0].observeSlot(900, timeNow)
+	router.defaultTargetInfo.targets[1].observeSlot(950, timeNow)
+	router.defaultTargetInfo.targets[2].observeSlot(1100, timeNow)
+
+	okResp := `{"jsonrpc":"2.0","result":{"context":{"slot":1100},"value":5},"id":1}`
+	body := []byte(`{"jsonrpc":"2.0","method":"getBalance","params":["addr",{"minContextSlot":1000}],"id":1}`)
+	var retried int
+	for range 20 {
+		requester := &urlRequester{responses: map[string]string{
+			"https://behind.example.com":      minContextSlotNotReachedResp,
+			"https://also-behind.example.com": minContextSlotNotReachedResp,
+			"https://ahead.example.com":       okResp,
+		}}
+		transport := NewUnifiedTransport("test_transport", router, requester, DefaultMaxAttempts, false)
+		transport.minContextSlotRetries = 2
+
+		c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{solana.GetBalance}, body)
+		respBody, _, err := transport.SendRequest(c)
+		require.NoError(t, err)
+		assert.JSONEq(t, okResp, string(respBody))
+		// a behind node is followed by the ahead one, the other behind node isn't tried
+		require.LessOrEqual(t, len(requester.urls), 2, requester.urls)
+		assert.Equal(t, "https://ahead.example.com", requester.urls[len(requester.urls)-1])
+		if len(requester.urls) == 2 {
+			retried++
+		}
+	}
+	assert.NotZero(t, retried, "no request was sent to a behind node first")
+}
+
+func TestUnifiedTransport_MinContextSlotRetriesExhausted(t *testing.T) {
+	urls := []string{"https://node1.example.com", "https://node2.example.com", "https://node3.example.com"}
+	router := newMinContextSlotTestRouter(t, urls...)
+	requester := &urlRequester{responses: map[string]string{}}
+	for _, u := range urls {
+		requester.responses[u] = minContextSlotNotReachedResp
+	}
+	transport := NewUnifiedTransport("test_transport", router, requester, DefaultMaxAttempts, false)
+	transport.minContextSlotRetries = 1
+
+	body := []byte(`{"jsonrpc":"2.0","method":"getBalance","params":["addr",{"minContextSlot":1000}],"id":1}`)
+	c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)), httptest.NewRecorder(), []string{solana.GetBalance}, body)
+	respBody, statusCode, err := transport.SendRequest(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, minContextSlotNotReachedResp, string(respBody))
+	assert.Len(t, requester.urls, 2, "the error is returned after the retries")
+	assert.Equal(t, []int{solana.MinContextSlotNotReachedErrCode}, c.GetRPCErrors())
+}
+
+func TestMinContextSlotNotReached(t *testing.T) {
+	contextSlot, ok := minContextSlotNotReached([]byte(minContextSlotNotReachedResp))
+	assert.True(t, ok)
+	assert.Equal(t, int64(900), contextSlot)
+
+	_, ok = minContextSlotNotReached([]byte(`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":1}`))
+	assert.False(t, ok)
+	_, ok = minContextSlotNotReached([]byte(`[` + minContextSlotNotReachedResp + `]`))
+	assert.False(t, ok, "batches aren't retried")
+}
+
+func TestRequestMinContextSlot(t *testing.T) {
+	assert.Equal(t, int64(1000), requestMinContextSlot([]byte(`{"method":"getBalance","params":["addr",{"minContextSlot":1000}]}`)))
+	assert.Zero(t, requestMinContextSlot([]byte(`{"method":"getBalance","params":["addr",{"commitment":"processed"}]}`)))
+	assert.Zero(t, requestMinContextSlot([]byte(`{"method":"getSlot"}`)))
+}
@@ -41,6 +41,21 @@ func (m *MockTargetSelector) GetNext(exclude []int) (*ProxyTarget, int, error) {
 	return resp.Target, resp.Index, resp.Error
 }
 
+func (m *MockTargetSelector) GetNextBatch(n int, exclude []int) (targets []*ProxyTarget, indices []int, err error) {
+	for len(indices) < n {
+		target, index, err := m.GetNext(exclude)
+		if err != nil || target == nil {
+			break
+		}
+		targets, indices = append(targets, target), append(indices, index)
+	}
+	if len(indices) == 0 {
+		return nil, nil, fmt.Errorf("mock TargetSelector: no targets selected")
+	}
+
+	return targets, indices, nil
+}
+
 func (m *MockTargetSelector) IsAvailable() bool {
 	return m.IsAvailableFn()
 }
@@ -54,10 +69,18 @@ func (m *MockTargetSelector) GetBalancerForMethod(method string) (balancer.Targe
 	return m, true
 }
 
+func (m *MockTargetSelector) GetGPABalancer() (balancer.TargetSelector[*ProxyTarget], bool) {
+	return nil, false
+}
+
 func (m *MockTargetSelector) IsMethodSupported(method string) bool {
 	return true
 }
 
+func (m *MockTargetSelector) CanServeMethod(method string) bool {
+	return true
+}
+
 func (m *MockTargetSelector) UpdateTargetStats(target *ProxyTarget, success bool, methods []string, responseTimeMs, slotAmount int64) {
 	m.UpdateStatsCallCount++
 	m.UpdateStatsArgs = append(m.UpdateStatsArgs, UpdateStatsArgs{
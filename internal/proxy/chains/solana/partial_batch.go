@@ -0,0 +1,84 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/buger/jsonparser"
+
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// batchResults collects elements of batch responses across attempts, so the succeeded ones can be returned
+// when the batch doesn't succeed as a whole. Elements are matched with requests by position like in decodeNodeResponse
+type batchResults struct {
+	succeeded [][]byte
+	failed    [][]byte // the last error element, returned when the element never succeeded
+}
+
+func newBatchResults(size int) *batchResults {
+	return &batchResults{succeeded: make([][]byte, size), failed: make([][]byte, size)}
+}
+
+// add keeps the elements of the batch response without an error, the first successful one of each request is kept
+func (b *batchResults) add(respBody []byte) {
+	index := 0
+	_, _ = jsonparser.ArrayEach(respBody, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		defer func() { index++ }()
+		if index >= len(b.succeeded) || b.succeeded[index] != nil {
+			return
+		}
+
+		element := bytes.Clone(value)
+		if _, _, _, err := jsonparser.Get(value, "error"); err == nil {
+			b.failed[index] = element
+			return
+		}
+		b.succeeded[index] = element
+	})
+}
+
+// hasSucceeded checks if any element succeeded
+func (b *batchResults) hasSucceeded() bool {
+	for _, element := range b.succeeded {
+		if element != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// build returns the batch response of the collected elements. Never succeeded requests get their last error element,
+// or the attempts exceeded error with the request id
+func (b *batchResults) build(c *echoUtil.CustomContext) []byte {
+	ids := make([][]byte, 0, len(b.succeeded))
+	_, _ = jsonparser.ArrayEach([]byte(c.GetReqBodyString()), func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		ids = append(ids, rawID(value))
+	})
+
+	elements := make([][]byte, len(b.succeeded))
+	var errCodes []int
+	for i := range b.succeeded {
+		switch {
+		case b.succeeded[i] != nil:
+			elements[i] = b.succeeded[i]
+		case b.failed[i] != nil:
+			elements[i] = b.failed[i]
+		default:
+			id := []byte("null")
+			if i < len(ids) {
+				id = ids[i]
+			}
+			elements[i], _ = json.Marshal(types.NewRPCErrorResponse(util.ExtraNodeAttemptsExceededErrorResponse.Error, json.RawMessage(id)))
+			errCodes = append(errCodes, util.ExtraNodeAttemptsExceededErrorResponse.Error.Code)
+		}
+	}
+	if len(errCodes) != 0 {
+		c.SetRPCErrors(append(c.GetRPCErrors(), errCodes...))
+	}
+
+	return append(append([]byte{'['}, bytes.Join(elements, []byte{','})...), ']')
+}
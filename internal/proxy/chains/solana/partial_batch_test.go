@@ -0,0 +1,89 @@
+package solana
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+)
+
+func TestUnifiedTransport_PartialBatchResults(t *testing.T) {
+	const serverErr = `{"code":-32000,"message":"server error"}`
+	tests := []struct {
+		name         string
+		partial      bool
+		responses    []HTTPResponseWrapper
+		wantResponse string
+	}{
+		{
+			name:    "elements succeeded on different attempts",
+			partial: true,
+			responses: []HTTPResponseWrapper{
+				{StatusCode: http.StatusOK, RespBody: []byte(`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":` + serverErr + `,"id":"b"},{"jsonrpc":"2.0","error":` + serverErr + `,"id":3}]`)},
+				{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")},
+				{StatusCode: http.StatusOK, RespBody: []byte(`[{"jsonrpc":"2.0","error":` + serverErr + `,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","error":` + serverErr + `,"id":3}]`)},
+			},
+			wantResponse: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":"b"},{"jsonrpc":"2.0","error":` + serverErr + `,"id":3}]`,
+		},
+		{
+			name:    "element never answered",
+			partial: true,
+			responses: []HTTPResponseWrapper{
+				{StatusCode: http.StatusOK, RespBody: []byte(`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":` + serverErr + `,"id":"b"}]`)},
+				{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")},
+				{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")},
+			},
+			// the element missing from the only batch response keeps its placeholder
+			wantResponse: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":` + serverErr + `,"id":"b"},` +
+				`{"jsonrpc":"2.0","error":{"code":2011,"message":"No response for the request in the node batch response"},"id":3}]`,
+		},
+		{
+			name:    "disabled",
+			partial: false,
+			responses: []HTTPResponseWrapper{
+				{StatusCode: http.StatusOK, RespBody: []byte(`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":` + serverErr + `,"id":"b"},{"jsonrpc":"2.0","error":` + serverErr + `,"id":3}]`)},
+				{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")},
+				{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{
+					{Target: &ProxyTarget{url: "target1"}, Index: 0},
+					{Target: &ProxyTarget{url: "target2"}, Index: 1},
+					{Target: &ProxyTarget{url: "target3"}, Index: 2},
+				},
+				TargetsCount:  3,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{Responses: tt.responses}
+			transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 3, false)
+			transport.partialBatchResults = tt.partial
+
+			methods := []string{solana.GetSlot, solana.GetBalance, solana.GetSlot}
+			requestBytes := []byte(`[{"jsonrpc":"2.0","method":"getSlot","id":1},{"jsonrpc":"2.0","method":"getBalance","params":["addr"],"id":"b"},` +
+				`{"jsonrpc":"2.0","method":"getSlot","id":3}]`)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), methods, requestBytes)
+			c.SetArrayRequested(true)
+
+			respBody, statusCode, err := transport.SendRequest(c)
+			assert.Equal(t, 3, mockRequester.CallCount)
+			if !tt.partial {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, statusCode)
+			assert.JSONEq(t, tt.wantResponse, string(respBody))
+		})
+	}
+}
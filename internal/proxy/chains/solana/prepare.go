@@ -2,6 +2,8 @@ package solana
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
 
 	"github.com/adm-metaex/aura-api/pkg/types"
 
@@ -30,11 +32,18 @@ func (s *Adapter) PreparePostReq(c *echoUtil.CustomContext) *types.RPCResponse {
 		return rpcErrResponse
 	}
 
+	if rpcErrResponse = gpaParamsValidation(parsedReqs, s.gpaLimits); rpcErrResponse != nil {
+		c.SetRPCErrors([]int{rpcErrResponse.Error.Code})
+		c.SetProxyUserError(true)
+		return rpcErrResponse
+	}
+
 	c.SetReqBlock(block)
+	c.SetReqCommitment(getRequestCommitment(parsedReqs))
 	c.SetArrayRequested(arrayRequested)
 	c.SetRPCRequestsParsed(parsedReqs)
 	c.SetReqMethods(util.Map(parsedReqs, func(r *types.RPCRequest) string { return r.Method }))
-	c.SetStatsAdditionalData(getContextValueForRequest(c.GetReqMethod(), parsedReqs))
+	c.SetStatsAdditionalData(getContextValueForRequest(c.GetReqMethod(), parsedReqs, s.routingKeyParams))
 
 	m := c.GetMetrics()
 	m.SetTitle(c.GetReqMethod())
@@ -43,10 +52,13 @@ func (s *Adapter) PreparePostReq(c *echoUtil.CustomContext) *types.RPCResponse {
 	return nil
 }
 
-func getContextValueForRequest(rpcMethod string, parsedRequests types.RPCRequests) (res string) {
+func getContextValueForRequest(rpcMethod string, parsedRequests types.RPCRequests, keyParams map[string]string) (res string) {
 	if rpcMethod == "" || rpcMethod == echoUtil.MultipleValuesRequested || len(parsedRequests) == 0 || parsedRequests[0] == nil {
 		return
 	}
+	if keyParam, ok := keyParams[parsedRequests[0].Method]; ok {
+		return getKeyParamValue(parsedRequests[0].Params, keyParam)
+	}
 
 	switch parsedRequests[0].Method {
 	case solanaTypes.GetSignaturesForAddress, solanaTypes.GetTokenAccountsByOwner, solanaTypes.GetAccountInfo, solanaTypes.GetProgramAccounts,
@@ -61,11 +73,150 @@ func getContextValueForRequest(rpcMethod string, parsedRequests types.RPCRequest
 			resNumber, _ := paramsArr[0].(json.Number)
 			res = resNumber.String()
 		}
+	default:
+		if _, ok := solanaTypes.CNFTMethodList[parsedRequests[0].Method]; ok {
+			res = getDASContextValue(parsedRequests[0].Params)
+		}
+	}
+
+	return
+}
+
+// getKeyParamValue extracts the param at the index (array-style params) or the field (object-style params)
+func getKeyParamValue(params interface{}, keyParam string) string {
+	var value interface{}
+	switch p := params.(type) {
+	case []interface{}:
+		index, err := strconv.Atoi(keyParam)
+		if err != nil || index < 0 || index >= len(p) {
+			return ""
+		}
+		value = p[index]
+	case map[string]interface{}:
+		value = p[keyParam]
+	}
+
+	if number, ok := value.(json.Number); ok {
+		return number.String()
+	}
+
+	return paramToString(value)
+}
+
+// dasIdentifierFields are checked in order to find a stable identifier in object-style DAS params
+var dasIdentifierFields = []string{"id", "ids", "ownerAddress", "authorityAddress", "creatorAddress", "groupValue", "owner", "mint"}
+
+// getDASContextValue extracts identifier from both object-style ({"id": ...}) and array-style ([...]) params
+func getDASContextValue(params interface{}) (res string) {
+	switch p := params.(type) {
+	case map[string]interface{}:
+		for _, field := range dasIdentifierFields {
+			if res = paramToString(p[field]); res != "" {
+				return res
+			}
+		}
+	case []interface{}:
+		if len(p) > 0 {
+			return paramToString(p[0])
+		}
 	}
 
 	return
 }
 
+func paramToString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []interface{}: // batch methods, e.g. getAssetBatch ids
+		if len(value) > 0 {
+			first, _ := value[0].(string)
+			return first
+		}
+	}
+
+	return ""
+}
+
+// getRequestCommitment returns the freshest commitment of the requests config params, empty if not specified
+func getRequestCommitment(parsedReqs types.RPCRequests) (commitment string) {
+	for _, req := range parsedReqs {
+		paramsArr, ok := req.Params.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, param := range paramsArr {
+			config, ok := param.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if value, _ := config["commitment"].(string); solanaTypes.CommitmentFreshness(value) > solanaTypes.CommitmentFreshness(commitment) {
+				commitment = value
+			}
+		}
+	}
+
+	return commitment
+}
+
+// gpaLimits bound getProgramAccounts params overloading upstreams, 0 disables a limit
+type gpaLimits struct {
+	maxFilters         int
+	maxMemcmpBytes     int // encoded length of the memcmp bytes
+	maxDataSliceLength int64
+}
+
+// gpaParamsValidation rejects getProgramAccounts requests exceeding the limits with an invalid params error
+func gpaParamsValidation(parsedReqs types.RPCRequests, limits gpaLimits) *types.RPCResponse {
+	for _, req := range parsedReqs {
+		if req.Method != solanaTypes.GetProgramAccounts {
+			continue
+		}
+
+		paramsArr, _ := req.Params.([]interface{})
+		for _, param := range paramsArr {
+			config, ok := param.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if msg := limits.check(config); msg != "" {
+				return types.NewRPCErrorResponse(types.NewRPCError(solanaTypes.InvalidParamsErrCode, msg, nil), req.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// check returns the message of the first exceeded limit of the request config, empty if within the limits
+func (l gpaLimits) check(config map[string]interface{}) string {
+	filters, _ := config["filters"].([]interface{})
+	if l.maxFilters > 0 && len(filters) > l.maxFilters {
+		return fmt.Sprintf("Too many filters provided; max %d", l.maxFilters)
+	}
+
+	if l.maxMemcmpBytes > 0 {
+		for _, filter := range filters {
+			filterMap, _ := filter.(map[string]interface{})
+			memcmp, _ := filterMap["memcmp"].(map[string]interface{})
+			if bytes, _ := memcmp["bytes"].(string); len(bytes) > l.maxMemcmpBytes {
+				return fmt.Sprintf("memcmp bytes too long; max %d encoded characters", l.maxMemcmpBytes)
+			}
+		}
+	}
+
+	if l.maxDataSliceLength > 0 {
+		dataSlice, _ := config["dataSlice"].(map[string]interface{})
+		if length, ok := dataSlice["length"].(json.Number); ok {
+			if n, err := length.Int64(); err == nil && n > l.maxDataSliceLength {
+				return fmt.Sprintf("dataSlice length too large; max %d", l.maxDataSliceLength)
+			}
+		}
+	}
+
+	return ""
+}
+
 func blockMethodsValidation(parsedReqs types.RPCRequests) (int64, *types.RPCResponse) {
 	var block int64
 
@@ -75,10 +226,10 @@ func blockMethodsValidation(parsedReqs types.RPCRequests) (int64, *types.RPCResp
 		}
 
 		paramsArr, ok := req.Params.([]interface{})
-		if !ok {
+		if !ok && req.Params != nil {
 			return block, types.NewRPCErrorResponse(types.InvalidReqError, req.ID)
 		}
-		if len(paramsArr) == 0 {
+		if len(paramsArr) == 0 { // missing params are reported like empty ones, as nodes do
 			return block, types.NewRPCErrorResponse(BlockMethodsParamsRPCErr, req.ID)
 		}
 
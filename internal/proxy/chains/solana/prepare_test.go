@@ -0,0 +1,148 @@
+package solana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	solanaTypes "aura-proxy/internal/pkg/chains/solana"
+)
+
+func TestGetContextValueForRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		params   interface{}
+		expected string
+	}{
+		{name: "array params", method: "getBalance", params: []interface{}{"addr1"}, expected: "addr1"},
+		{name: "block number", method: "getBlock", params: []interface{}{json.Number("123")}, expected: "123"},
+		{name: "DAS object id", method: "getAsset", params: map[string]interface{}{"id": "asset1"}, expected: "asset1"},
+		{name: "DAS object owner", method: "getAssetsByOwner", params: map[string]interface{}{"ownerAddress": "owner1", "page": json.Number("1")}, expected: "owner1"},
+		{name: "DAS object ids", method: "getAssetBatch", params: map[string]interface{}{"ids": []interface{}{"asset1", "asset2"}}, expected: "asset1"},
+		{name: "DAS group", method: "getAssetsByGroup", params: map[string]interface{}{"groupKey": "collection", "groupValue": "col1"}, expected: "col1"},
+		{name: "DAS array params", method: "getAssetProof", params: []interface{}{"asset1"}, expected: "asset1"},
+		{name: "DAS unknown fields", method: "searchAssets", params: map[string]interface{}{"limit": json.Number("10")}, expected: ""},
+		{name: "DAS no params", method: "getAsset", params: nil, expected: ""},
+		{name: "RPC object params", method: "getBalance", params: map[string]interface{}{"id": "addr1"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs := types.RPCRequests{{JSONRPC: types.JSONRPCVersion, Method: tt.method, Params: tt.params}}
+			assert.Equal(t, tt.expected, getContextValueForRequest(tt.method, reqs, nil))
+		})
+	}
+}
+
+func TestGetContextValueForRequest_KeyParams(t *testing.T) {
+	keyParams := map[string]string{
+		"getTokenAccountsByOwner": "1",
+		"getBlocks":               "1",
+		"searchAssets":            "ownerAddress",
+	}
+	tests := []struct {
+		name     string
+		method   string
+		params   interface{}
+		expected string
+	}{
+		{name: "key at index 1", method: "getTokenAccountsByOwner", params: []interface{}{"owner1", "mint1"}, expected: "mint1"},
+		{name: "number at index 1", method: "getBlocks", params: []interface{}{json.Number("100"), json.Number("200")}, expected: "200"},
+		{name: "index out of range", method: "getTokenAccountsByOwner", params: []interface{}{"owner1"}, expected: ""},
+		{name: "object field", method: "searchAssets", params: map[string]interface{}{"ownerAddress": "owner1", "limit": json.Number("10")}, expected: "owner1"},
+		{name: "index of object params", method: "getTokenAccountsByOwner", params: map[string]interface{}{"1": "mint1"}, expected: "mint1"},
+		{name: "not configured method", method: "getBalance", params: []interface{}{"addr1", "addr2"}, expected: "addr1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs := types.RPCRequests{{JSONRPC: types.JSONRPCVersion, Method: tt.method, Params: tt.params}}
+			assert.Equal(t, tt.expected, getContextValueForRequest(tt.method, reqs, keyParams))
+		})
+	}
+}
+
+func TestGetRequestCommitment(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []interface{}
+		expected string
+	}{
+		{name: "no params", params: nil, expected: ""},
+		{name: "no config", params: []interface{}{"addr1"}, expected: ""},
+		{name: "finalized", params: []interface{}{"addr1", map[string]interface{}{"commitment": "finalized"}}, expected: "finalized"},
+		{name: "processed", params: []interface{}{map[string]interface{}{"commitment": "processed", "minContextSlot": json.Number("1")}}, expected: "processed"},
+		{name: "unknown commitment", params: []interface{}{map[string]interface{}{"commitment": "recent"}}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs := types.RPCRequests{{JSONRPC: types.JSONRPCVersion, Method: "getBalance", Params: tt.params}}
+			assert.Equal(t, tt.expected, getRequestCommitment(reqs))
+		})
+	}
+
+	// the freshest commitment of a batch wins
+	reqs := types.RPCRequests{
+		{JSONRPC: types.JSONRPCVersion, Method: "getBalance", Params: []interface{}{"addr1", map[string]interface{}{"commitment": "finalized"}}},
+		{JSONRPC: types.JSONRPCVersion, Method: "getSlot", Params: []interface{}{map[string]interface{}{"commitment": "processed"}}},
+		{JSONRPC: types.JSONRPCVersion, Method: "getBalance", Params: []interface{}{"addr2", map[string]interface{}{"commitment": "confirmed"}}},
+	}
+	assert.Equal(t, "processed", getRequestCommitment(reqs))
+}
+
+func TestPreparePostReq_GPALimits(t *testing.T) {
+	adapter := &Adapter{
+		availableMethods: solanaTypes.MethodList,
+		gpaLimits:        gpaLimits{maxFilters: 2, maxMemcmpBytes: 8, maxDataSliceLength: 100},
+	}
+	memcmp := func(bytes string) string { return `{"memcmp":{"offset":0,"bytes":"` + bytes + `"}}` }
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "within limits", config: `{"filters":[{"dataSize":165},` + memcmp("abc") + `],"dataSlice":{"offset":0,"length":100}}`},
+		{name: "no config"},
+		{name: "too many filters", config: `{"filters":[{"dataSize":165},` + memcmp("a") + `,` + memcmp("b") + `]}`, wantErr: "Too many filters provided; max 2"},
+		{name: "memcmp too long", config: `{"filters":[` + memcmp("123456789") + `]}`, wantErr: "memcmp bytes too long"},
+		{name: "data slice too large", config: `{"dataSlice":{"offset":0,"length":101}}`, wantErr: "dataSlice length too large; max 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := `["program1"]`
+			if tt.config != "" {
+				params = `["program1",` + tt.config + `]`
+			}
+			body := []byte(`{"jsonrpc":"2.0","id":7,"method":"getProgramAccounts","params":` + params + `}`)
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+			c := createTestCustomContext(req, httptest.NewRecorder(), nil, body)
+
+			resp := adapter.PreparePostReq(c)
+			if tt.wantErr == "" {
+				assert.Nil(t, resp)
+				return
+			}
+			require.NotNil(t, resp)
+			assert.Equal(t, solanaTypes.InvalidParamsErrCode, resp.Error.Code)
+			assert.Contains(t, resp.Error.Message, tt.wantErr)
+			assert.Equal(t, []int{solanaTypes.InvalidParamsErrCode}, c.GetRPCErrors())
+		})
+	}
+
+	// other methods and disabled limits are not checked
+	many := []interface{}{"program1", map[string]interface{}{"filters": []interface{}{1, 2, 3}}}
+	reqs := types.RPCRequests{{JSONRPC: types.JSONRPCVersion, Method: "getAccountInfo", Params: many}}
+	assert.Nil(t, gpaParamsValidation(reqs, gpaLimits{maxFilters: 2}))
+	reqs[0].Method = solanaTypes.GetProgramAccounts
+	assert.Nil(t, gpaParamsValidation(reqs, gpaLimits{}))
+}
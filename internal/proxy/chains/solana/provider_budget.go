@@ -0,0 +1,60 @@
+package solana
+
+import (
+	"context"
+	"time"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// providerBudget limits the attempts and the time spent on targets of one provider in a request, so a slow or failing
+// provider can't consume the whole request budget before other providers are tried. Zero values disable a limit
+type providerBudget struct {
+	maxAttempts int
+	maxTime     time.Duration
+}
+
+// providerUsage is the budget use of a request by provider
+type providerUsage struct {
+	budget   providerBudget
+	attempts map[string]int
+	elapsed  map[string]time.Duration
+}
+
+// newProviderUsage returns nil if the budget isn't limited
+func newProviderUsage(budget providerBudget) *providerUsage {
+	if budget.maxAttempts <= 0 && budget.maxTime <= 0 {
+		return nil
+	}
+
+	return &providerUsage{budget: budget, attempts: make(map[string]int), elapsed: make(map[string]time.Duration)}
+}
+
+// withTimeout limits the node request of the attempt by the time budget left to the provider. The returned function
+// restores the request. Targets without a provider aren't limited
+func (u *providerUsage) withTimeout(c *echoUtil.CustomContext, provider string) (restore func()) {
+	if u == nil || u.budget.maxTime <= 0 || provider == "" {
+		return func() {}
+	}
+
+	req := c.Request()
+	ctx, cancel := context.WithTimeout(req.Context(), max(u.budget.maxTime-u.elapsed[provider], 0))
+	c.SetRequest(req.WithContext(ctx))
+
+	return func() {
+		cancel()
+		c.SetRequest(req)
+	}
+}
+
+// add records an attempt on a target of the provider and reports whether the provider budget is exhausted
+func (u *providerUsage) add(provider string, elapsed time.Duration) (exhausted bool) {
+	if u == nil || provider == "" {
+		return false
+	}
+	u.attempts[provider]++
+	u.elapsed[provider] += elapsed
+
+	return (u.budget.maxAttempts > 0 && u.attempts[provider] >= u.budget.maxAttempts) ||
+		(u.budget.maxTime > 0 && u.elapsed[provider] >= u.budget.maxTime)
+}
@@ -0,0 +1,124 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// providerRequester hangs on targets of the slow provider until the request context is done, or fails them if failSlow.
+// Other targets respond at once
+type providerRequester struct {
+	mx       sync.Mutex
+	failSlow bool
+	urls     []string
+}
+
+func (r *providerRequester) DoRequest(c *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.mx.Lock()
+	r.urls = append(r.urls, targetURL)
+	r.mx.Unlock()
+
+	if !strings.Contains(targetURL, "slow") {
+		return []byte(`{"jsonrpc":"2.0","result":1000,"id":1}`), http.StatusOK, nil
+	}
+	if r.failSlow {
+		return nil, http.StatusBadGateway, util.ErrBadStatusCode
+	}
+	<-c.Request().Context().Done()
+	return nil, http.StatusInternalServerError, c.Request().Context().Err()
+}
+
+func newProviderBudgetTestRouter(t *testing.T) *MethodBasedRouter {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "slow",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://slow1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://slow2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://slow3.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name:      "fast",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://fast.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{solana.GetSlot: {"slow", "fast"}} // the slow provider is tried first
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+
+	return router
+}
+
+func TestUnifiedTransport_ProviderBudget(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`)
+	send := func(transport *UnifiedTransport, deadline time.Duration) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), deadline)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetSlot}, body)
+		respBody, _, err := transport.SendRequest(c)
+		return respBody, err
+	}
+
+	t.Run("time budget", func(t *testing.T) {
+		requester := &providerRequester{}
+		transport := NewUnifiedTransport("test_transport", newProviderBudgetTestRouter(t), requester, DefaultMaxAttempts, false)
+		transport.providerBudget = providerBudget{maxTime: 50 * time.Millisecond}
+
+		startTime := time.Now()
+		respBody, err := send(transport, time.Second)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"jsonrpc":"2.0","result":1000,"id":1}`, string(respBody))
+		assert.Less(t, time.Since(startTime), 500*time.Millisecond, "the fast provider is reached within the deadline")
+		require.Len(t, requester.urls, 2, requester.urls)
+		assert.Contains(t, requester.urls[0], "slow")
+		assert.Equal(t, "https://fast.example.com", requester.urls[1])
+	})
+
+	t.Run("attempts budget", func(t *testing.T) {
+		requester := &providerRequester{failSlow: true}
+		transport := NewUnifiedTransport("test_transport", newProviderBudgetTestRouter(t), requester, DefaultMaxAttempts, false)
+		transport.providerBudget = providerBudget{maxAttempts: 2}
+
+		_, err := send(transport, time.Second)
+		require.NoError(t, err)
+		require.Len(t, requester.urls, 3, requester.urls)
+		assert.Equal(t, "https://fast.example.com", requester.urls[2])
+	})
+
+	t.Run("without budgets", func(t *testing.T) {
+		requester := &providerRequester{}
+		transport := NewUnifiedTransport("test_transport", newProviderBudgetTestRouter(t), requester, DefaultMaxAttempts, false)
+
+		_, err := send(transport, 100*time.Millisecond)
+		require.Error(t, err, "the slow provider takes the whole deadline")
+		assert.NotContains(t, requester.urls, "https://fast.example.com")
+	})
+}
+
+func TestProviderUsage(t *testing.T) {
+	assert.Nil(t, newProviderUsage(providerBudget{}))
+
+	usage := newProviderUsage(providerBudget{maxAttempts: 2, maxTime: time.Second})
+	assert.False(t, usage.add("provider", 100*time.Millisecond))
+	assert.True(t, usage.add("provider", 100*time.Millisecond), "out of attempts")
+	assert.False(t, usage.add("other", 100*time.Millisecond))
+	assert.True(t, usage.add("other", 2*time.Second), "out of time")
+	assert.False(t, usage.add("", time.Hour), "targets without a provider aren't limited")
+}
@@ -1,7 +1,12 @@
 package solana
 
 import (
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"aura-proxy/internal/pkg/chains/solana"
@@ -13,19 +18,54 @@ import (
 type (
 	ProxyTarget struct {
 		availableMethods map[string]targetRestriction
+		supportedMethods map[string]bool // precomputed targetType.IsSupportMethod decisions
 		provider         string
 		targetType       solana.NodeType
 		url              string
+		hostHeader       string // sent instead of the URL host, empty if not overridden
+		deadlineHeader   bool   // X-Deadline-Ms is sent
+		region           string // provider region
 		reqCounter       uint64
 		reqLimit         uint64
 		reqWindow        int64
 		slotAmount       int64
+		addedAt          time.Time
+		warmUpPeriod     time.Duration // streaks of a new target don't change its weight during this period
+		responseTimesLen int           // response times averaged per method, lastResponsesTimeMsArrLen if 0
+		observedSlot     int64         // context slot of the last processed commitment response, 0 if none
+		observedSlotAt   time.Time
+		inFlight         atomic.Int64 // requests sent and not finished yet
+		draining         atomic.Bool  // the target is being removed
 
 		mx sync.RWMutex
 	}
 
+	// TargetState is a point-in-time dump of a target for troubleshooting
+	TargetState struct {
+		URL        string                 `json:"url"`
+		Provider   string                 `json:"provider"`
+		NodeType   string                 `json:"nodeType"`
+		SlotAmount int64                  `json:"slotAmount"`
+		WarmingUp  bool                   `json:"warmingUp"`
+		Draining   bool                   `json:"draining,omitempty"`
+		Methods    map[string]MethodState `json:"methods"`
+	}
+	MethodState struct {
+		Jailed            bool   `json:"jailed"`
+		JailExpireTime    int64  `json:"jailExpireTime,omitempty"` // unix seconds
+		ErrCounter        uint64 `json:"errCounter"`
+		SuccessCounter    uint64 `json:"successCounter"`
+		AvgResponseTimeMs int64  `json:"avgResponseTimeMs"`
+		P50ResponseTimeMs int64  `json:"p50ResponseTimeMs"`
+		P95ResponseTimeMs int64  `json:"p95ResponseTimeMs"`
+		P99ResponseTimeMs int64  `json:"p99ResponseTimeMs"`
+	}
+
 	targetRestriction struct {
-		lastResponsesTimeMs []int64 // store last 10 value
+		lastResponsesTimeMs []int64 // store last responseTimesLen (default 10) value
+		responseTimeSamples []int64 // store last 100 value for percentiles
+		p50, p95, p99       int64   // percentiles of responseTimeSamples, updated when a sample is added
+		recentFailures      []bool  // outcomes of the last 20 responses, for the error rate
 		jailExpireTime      int64
 		errCounter          uint64
 		successCounter      uint64
@@ -34,11 +74,18 @@ type (
 
 const (
 	lastResponsesTimeMsArrLen = 10
+	responseTimeSamplesLen    = 100
+	recentOutcomesLen         = 20
 	noFullHistoryPenalty      = 1
 
 	targetJailTime              = time.Second
 	consecutiveSuccessResponses = 10
+	maxSuccessStreakBoost       = 3 // upper bound of the streak weight multiplier, so one node doesn't monopolize selection
 	limitWindowSeconds          = 10
+	// observed slots expire, so lagging targets excluded from processed commitment requests are probed again
+	slotObservationTTL = 30 * time.Second
+
+	maskedURLPart = "***"
 )
 
 func NewProxyTarget(urlWithMethods models.URLWithMethods, reqLimit uint64, provider string, targetType solana.NodeType) *ProxyTarget {
@@ -48,7 +95,9 @@ func NewProxyTarget(urlWithMethods models.URLWithMethods, reqLimit uint64, provi
 		provider:         provider,
 		targetType:       targetType,
 		availableMethods: make(map[string]targetRestriction, len(urlWithMethods.SupportedMethods)),
+		supportedMethods: targetType.SupportedMethods(),
 		slotAmount:       urlWithMethods.SlotAmount,
+		addedAt:          time.Now(),
 	}
 	for _, sm := range urlWithMethods.SupportedMethods {
 		pt.availableMethods[sm.Name] = targetRestriction{
@@ -69,15 +118,15 @@ func (t *ProxyTarget) isAvailable(reqMethods []string, reqType models.TokenType,
 		return false, failedReqs, lastRespTime
 	}
 
+	// The node type is optional: targets without a known one (e.g. DAS endpoints) serve the methods the config routes
+	// to them, and methods unknown to the node type aren't rejected. The block history is checked by known types only
+	knownNodeType := solana.IsKnownNodeType(t.targetType.Name)
 	for _, rm := range reqMethods {
-		iSupportedMethod, err := t.targetType.IsSupportMethod(rm)
-		if err != nil {
-
-		}
-		if !iSupportedMethod {
+		iSupportedMethod, err := t.isSupportMethod(rm)
+		if knownNodeType && err == nil && !iSupportedMethod {
 			return false, failedReqs, lastRespTime
 		}
-		if solana.BlockRelatedMethod(rm) {
+		if knownNodeType && solana.BlockRelatedMethod(rm) {
 			notContainBlock := t.targetType.Name != solana.ArchiveSolanaNode && c.GetReqBlock() < calculateSlot(mainnetSlot, getSlotTime, t.targetType.AvailableSlotsHistory)
 			if notContainBlock {
 				return false, failedReqs, lastRespTime
@@ -102,6 +151,79 @@ func (t *ProxyTarget) isAvailable(reqMethods []string, reqType models.TokenType,
 
 	return true, failedReqs, lastRespTime
 }
+
+// getSoonestJailExpireTime returns the soonest unix time a jailed method of the target is released, 0 if nothing is jailed
+func (t *ProxyTarget) getSoonestJailExpireTime(timeNow int64) (soonest int64) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	for _, restriction := range t.availableMethods {
+		if restriction.jailExpireTime > timeNow && (soonest == 0 || restriction.jailExpireTime < soonest) {
+			soonest = restriction.jailExpireTime
+		}
+	}
+
+	return soonest
+}
+
+// isJailed checks if the method is jailed on the target
+func (t *ProxyTarget) isJailed(method string, timeNow int64) bool {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	return t.availableMethods[method].jailExpireTime > timeNow
+}
+
+// isWarmingUp checks if the target was added less than warmUpPeriod ago
+func (t *ProxyTarget) isWarmingUp() bool {
+	return t.warmUpPeriod > 0 && time.Since(t.addedAt) < t.warmUpPeriod
+}
+
+// jailFor jails the target for the methods for a fixed time, the error counter of the escalating jail isn't changed
+func (t *ProxyTarget) jailFor(reqMethods []string, jailTime time.Duration) {
+	jailExpireTime := time.Now().Add(jailTime).Unix()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	for _, rm := range reqMethods {
+		restriction := t.availableMethods[rm]
+		restriction.successCounter = 0
+		restriction.jailExpireTime = max(restriction.jailExpireTime, jailExpireTime)
+		t.availableMethods[rm] = restriction
+	}
+}
+
+// observeSlot records the context slot of a processed commitment response
+func (t *ProxyTarget) observeSlot(slot int64, timeNow time.Time) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.observedSlot, t.observedSlotAt = slot, timeNow
+}
+
+// estimatedSlot extrapolates the last observed slot to timeNow, 0 if no slot was observed within slotObservationTTL
+func (t *ProxyTarget) estimatedSlot(timeNow time.Time) int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	if t.observedSlot == 0 || timeNow.Sub(t.observedSlotAt) > slotObservationTTL {
+		return 0
+	}
+
+	return t.observedSlot + int64(timeNow.Sub(t.observedSlotAt).Seconds()*slotsPerSec)
+}
+
+// isSupportMethod is equivalent of targetType.IsSupportMethod without the method switch on the hot path
+func (t *ProxyTarget) isSupportMethod(method string) (bool, error) {
+	supported, ok := t.supportedMethods[method]
+	if !ok {
+		return false, fmt.Errorf("invalid requested method: %s", method)
+	}
+
+	return supported, nil
+}
+
 func (t *ProxyTarget) UpdateStats(success bool, reqMethods []string, responseTimeMs, slotAmount int64) {
 	currentWindow, _ := getCurrentTimeWindow()
 
@@ -124,17 +246,19 @@ func (t *ProxyTarget) UpdateStats(success bool, reqMethods []string, responseTim
 		restriction, ok := t.availableMethods[rm]
 		if ok {
 			if success { // apply only success req time
-				restriction.addLastResponsesTimeMs(responseTimeMs)
+				restriction.addLastResponsesTimeMs(responseTimeMs, t.getResponseTimesLen())
 			}
 		} else {
 			if !success {
 				log.Logger.Proxy.Debugf("UpdateStats: banned %s %s", t.url, rm) // TODO: temp log
 			} else {
-				restriction.lastResponsesTimeMs = []int64{responseTimeMs}                    // init new
+				restriction.addLastResponsesTimeMs(responseTimeMs, t.getResponseTimesLen())  // init new
 				log.Logger.Proxy.Debugf("UpdateStats: successfully tested %s %s", t.url, rm) // TODO: temp log
 			}
 		}
 
+		restriction.addOutcome(!success)
+
 		switch {
 		case !success:
 			restriction.successCounter = 0
@@ -154,17 +278,184 @@ func (t *ProxyTarget) UpdateStats(success bool, reqMethods []string, responseTim
 	t.mx.Unlock()
 }
 
+// getResponseTimesLen returns the length of the response time history of a method
+func (t *ProxyTarget) getResponseTimesLen() int {
+	if t.responseTimesLen <= 0 {
+		return lastResponsesTimeMsArrLen
+	}
+
+	return t.responseTimesLen
+}
+
+// successStreakMultiplier returns the selection weight multiplier of the method on this target.
+// It grows linearly with the consecutive success streak up to maxBoost and falls back to 1 when the streak is reset
+func (t *ProxyTarget) successStreakMultiplier(method string, maxBoost float64) float64 {
+	t.mx.RLock()
+	streak := t.availableMethods[method].successCounter
+	t.mx.RUnlock()
+
+	if t.isWarmingUp() { // neutral weight until the target has enough stats
+		return 1
+	}
+
+	return 1 + (maxBoost-1)*float64(streak)/consecutiveSuccessResponses
+}
+
+// errorStreakMultiplier returns the selection weight multiplier of the method on this target by its error streak,
+// which is reset after consecutiveSuccessResponses successes. It's 1 without errors and while the target warms up
+func (t *ProxyTarget) errorStreakMultiplier(method string, penalty float64) float64 {
+	t.mx.RLock()
+	streak := t.availableMethods[method].errCounter
+	t.mx.RUnlock()
+
+	if t.isWarmingUp() {
+		return 1
+	}
+
+	return 1 / (1 + penalty*float64(streak))
+}
+
+// GetState returns the current target state. maskURL hides the URL path and query, which usually contain API keys
+func (t *ProxyTarget) GetState(maskURL bool) TargetState {
+	_, timeNow := getCurrentTimeWindow()
+
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	state := TargetState{
+		URL:        t.url,
+		Provider:   t.provider,
+		NodeType:   t.targetType.Name,
+		SlotAmount: t.slotAmount,
+		WarmingUp:  t.isWarmingUp(),
+		Draining:   t.isDraining(),
+		Methods:    make(map[string]MethodState, len(t.availableMethods)),
+	}
+	if maskURL {
+		state.URL = maskTargetURL(t.url)
+	}
+	for method, restriction := range t.availableMethods {
+		p50, p95, p99 := restriction.getResponseTimePercentiles()
+		state.Methods[method] = MethodState{
+			Jailed:            restriction.jailExpireTime > timeNow,
+			JailExpireTime:    restriction.jailExpireTime,
+			ErrCounter:        restriction.errCounter,
+			SuccessCounter:    restriction.successCounter,
+			AvgResponseTimeMs: restriction.getLastResponsesTimeMs(),
+			P50ResponseTimeMs: p50,
+			P95ResponseTimeMs: p95,
+			P99ResponseTimeMs: p99,
+		}
+	}
+
+	return state
+}
+
+// maskTargetURL keeps only the scheme and host of the URL
+func maskTargetURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return maskedURLPart
+	}
+	masked := url.URL{Scheme: u.Scheme, Host: u.Host}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return masked.String() + "/" + maskedURLPart
+	}
+
+	return masked.String()
+}
+
+// p95ResponseTimeMs returns the p95 response time of the method on this target, 0 if there are no stats.
+// Latency-aware strategies rank by it, so occasional slow responses of a target aren't hidden by an average
+func (t *ProxyTarget) p95ResponseTimeMs(method string) float64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	am := t.availableMethods[method]
+	_, p95, _ := am.getResponseTimePercentiles()
+
+	return float64(p95)
+}
+
+// errorRate returns the share of failures among the last recentOutcomesLen responses of the method on this target,
+// 0 if there are no stats
+func (t *ProxyTarget) errorRate(method string) float64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	am := t.availableMethods[method]
+
+	return am.getErrorRate()
+}
+
+// GetResponseTimePercentiles returns response time percentiles of the method on this target
+func (t *ProxyTarget) GetResponseTimePercentiles(method string) (p50, p95, p99 int64) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+
+	am := t.availableMethods[method]
+
+	return am.getResponseTimePercentiles()
+}
+
 func getCurrentTimeWindow() (int64, int64) { //nolint:gocritic,revive
 	timeNow := time.Now()
 	return timeNow.Truncate(time.Second * limitWindowSeconds).Unix(), timeNow.Unix()
 }
 
-func (t *targetRestriction) addLastResponsesTimeMs(v int64) {
+func (t *targetRestriction) addLastResponsesTimeMs(v int64, historyLen int) {
 	t.lastResponsesTimeMs = append(t.lastResponsesTimeMs, v)
-	if len(t.lastResponsesTimeMs) > lastResponsesTimeMsArrLen {
-		t.lastResponsesTimeMs = t.lastResponsesTimeMs[len(t.lastResponsesTimeMs)-lastResponsesTimeMsArrLen:]
+	if len(t.lastResponsesTimeMs) > historyLen {
+		t.lastResponsesTimeMs = t.lastResponsesTimeMs[len(t.lastResponsesTimeMs)-historyLen:]
+	}
+
+	t.responseTimeSamples = append(t.responseTimeSamples, v)
+	if len(t.responseTimeSamples) > responseTimeSamplesLen {
+		t.responseTimeSamples = t.responseTimeSamples[len(t.responseTimeSamples)-responseTimeSamplesLen:]
+	}
+
+	// computed here rather than on selection, which reads the percentiles of every candidate target
+	sorted := slices.Clone(t.responseTimeSamples)
+	slices.Sort(sorted)
+	t.p50, t.p95, t.p99 = percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99) //nolint:revive
+}
+
+func (t *targetRestriction) addOutcome(failed bool) {
+	t.recentFailures = append(t.recentFailures, failed)
+	if len(t.recentFailures) > recentOutcomesLen {
+		t.recentFailures = t.recentFailures[len(t.recentFailures)-recentOutcomesLen:]
 	}
 }
+
+func (t *targetRestriction) getErrorRate() float64 {
+	if len(t.recentFailures) == 0 {
+		return 0
+	}
+
+	failures := 0
+	for _, failed := range t.recentFailures {
+		if failed {
+			failures++
+		}
+	}
+
+	return float64(failures) / float64(len(t.recentFailures))
+}
+
+// getResponseTimePercentiles returns nearest-rank p50/p95/p99 over the last responseTimeSamplesLen responses
+func (t *targetRestriction) getResponseTimePercentiles() (p50, p95, p99 int64) {
+	return t.p50, t.p95, t.p99
+}
+
+func percentile(sorted []int64, p float64) int64 {
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+
+	return sorted[idx]
+}
+
 func (t *targetRestriction) getLastResponsesTimeMs() (res int64) {
 	if len(t.lastResponsesTimeMs) == 0 {
 		return
@@ -0,0 +1,181 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/transport"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const (
+	replayProvider = "replay"
+	replayReqID    = "replay" // seeds the target selection, so replays are deterministic
+)
+
+var errReplayExhausted = errors.New("no more recorded responses")
+
+type (
+	// ReplayFixture is a captured request with the recorded upstream responses, served in order to the attempts.
+	// A payload captured by /debug/payloads is a fixture with a single response, Status and Response
+	ReplayFixture struct {
+		Request   string           `json:"request"`
+		Status    int              `json:"status,omitempty"` // of Response, 200 if not set
+		Response  string           `json:"response,omitempty"`
+		Responses []ReplayResponse `json:"responses,omitempty"`
+		// Targets of the method, the number of responses if not set
+		Targets int `json:"targets,omitempty"`
+	}
+
+	// ReplayResponse is an upstream response, or a transport error if Error is set
+	ReplayResponse struct {
+		Status int    `json:"status"`
+		Body   string `json:"body,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// ReplayResult is how the pipeline classified and answered the request
+	ReplayResult struct {
+		Status    int      `json:"status"`
+		Body      string   `json:"body,omitempty"`
+		Error     string   `json:"error,omitempty"`
+		Attempts  int      `json:"attempts"`
+		Targets   []string `json:"targets"` // requested targets in the attempts order
+		UserError bool     `json:"userError"`
+		RPCErrors []int    `json:"rpcErrors,omitempty"`
+	}
+)
+
+func (f *ReplayFixture) responses() []ReplayResponse {
+	if len(f.Responses) == 0 && (f.Response != "" || f.Status != 0) {
+		status := f.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		return []ReplayResponse{{Status: status, Body: f.Response}}
+	}
+
+	return f.Responses
+}
+
+// replayRequester returns the recorded responses in order, as RealHTTPRequester would
+type replayRequester struct {
+	mx        sync.Mutex
+	responses []ReplayResponse
+	urls      []string
+}
+
+func (r *replayRequester) DoRequest(_ *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.urls = append(r.urls, targetURL)
+	if len(r.urls) > len(r.responses) {
+		return nil, http.StatusInternalServerError, errReplayExhausted
+	}
+	resp := r.responses[len(r.urls)-1]
+	switch {
+	case resp.Error != "":
+		return nil, http.StatusInternalServerError, errors.New(resp.Error)
+	case resp.Status >= http.StatusMultipleChoices:
+		return nil, resp.Status, util.ErrBadStatusCode
+	default:
+		return []byte(resp.Body), resp.Status, nil
+	}
+}
+
+// Replay runs the request of the fixture through the request preparation, routing and transport of a Solana adapter
+// with the transport settings of cfg. Upstream requests get the recorded responses, so the routing and the response
+// analysis are reproduced deterministically
+func Replay(cfg *configtypes.ProxyConfig, fixture ReplayFixture) (res ReplayResult, err error) { //nolint:gocritic
+	responses := fixture.responses()
+	targets := fixture.Targets
+	if targets <= 0 {
+		targets = len(responses)
+	}
+	if targets == 0 {
+		return res, errors.New("no targets: the fixture has no responses")
+	}
+
+	endpoints := make([]configtypes.EndpointConfig, targets)
+	for i := range endpoints {
+		endpoints[i] = configtypes.EndpointConfig{
+			URL:         fmt.Sprintf("https://replay-%d.invalid", i),
+			NodeType:    solana.NodeType{Name: solana.ArchiveSolanaNode},
+			HandleOther: true,
+			HandleGPA:   true,
+		}
+	}
+	router, err := NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{{Name: replayProvider, Endpoints: endpoints}},
+	})
+	if err != nil {
+		return res, fmt.Errorf("NewMethodBasedRouter: %w", err)
+	}
+	replayCfg := *cfg
+	replayCfg.DebugSeededRouting = true
+	replayCfg.Region = ""
+	requester := &replayRequester{responses: responses}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	adapter, err := newAdapterWithRequester(ctx, router, &replayCfg, solana.ChainName, solana.MethodList, nil, requester)
+	if err != nil {
+		return res, fmt.Errorf("newAdapter: %w", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(fixture.Request))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(req, rec)}
+	c.InitMetrics()
+	c.SetReqID(replayReqID)
+
+	var body []byte
+	if err = transport.PreparePostRequest(c, solana.ChainName); err == nil {
+		if rpcErr := adapter.PreparePostReq(c); rpcErr != nil {
+			err = echo.NewHTTPError(http.StatusOK, rpcErr)
+		} else {
+			body, res.Status, err = adapter.ProxyPostRequest(c)
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		res.Status = httpErr.Code
+		res.Error = replayErrorMessage(httpErr.Message)
+	case err != nil:
+		res.Error = err.Error()
+	}
+	res.Body = string(body)
+	res.Attempts = len(requester.urls)
+	res.Targets = requester.urls
+	res.UserError = c.GetProxyUserError()
+	res.RPCErrors = c.GetRPCErrors()
+
+	return res, nil
+}
+
+// replayErrorMessage formats the message of an HTTP error, JSON-RPC error responses are marshaled
+func replayErrorMessage(message any) string {
+	if s, ok := message.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Sprint(message)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,90 @@
+package solana
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+// replayFixture replays testdata/replay/<name>.json with the default transport settings
+func replayFixture(t *testing.T, name string) ReplayResult {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "replay", name+".json"))
+	require.NoError(t, err)
+	var fixture ReplayFixture
+	require.NoError(t, json.Unmarshal(data, &fixture))
+
+	res, err := Replay(&configtypes.ProxyConfig{}, fixture)
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("invalid params are a user error", func(t *testing.T) {
+		res := replayFixture(t, "invalid_params")
+		assert.Equal(t, http.StatusOK, res.Status)
+		assert.Empty(t, res.Error)
+		assert.Equal(t, 1, res.Attempts, "user errors aren't retried")
+		assert.True(t, res.UserError)
+		assert.Equal(t, []int{solana.InvalidParamsErrCode}, res.RPCErrors)
+		assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid param: WrongSize"},"id":1}`, res.Body)
+	})
+
+	t.Run("unhealthy node is retried on another target", func(t *testing.T) {
+		res := replayFixture(t, "node_unhealthy")
+		assert.Equal(t, http.StatusOK, res.Status)
+		assert.Empty(t, res.Error)
+		require.Equal(t, 2, res.Attempts)
+		assert.NotEqual(t, res.Targets[0], res.Targets[1])
+		assert.False(t, res.UserError)
+		assert.Empty(t, res.RPCErrors)
+		assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"context":{"slot":1000},"value":5},"id":1}`, res.Body)
+	})
+
+	t.Run("all targets fail", func(t *testing.T) {
+		res := replayFixture(t, "gateway_errors")
+		assert.Equal(t, 2, res.Attempts)
+		assert.Empty(t, res.Body)
+		assert.NotEmpty(t, res.Error)
+		assert.False(t, res.UserError)
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		assert.Equal(t, replayFixture(t, "node_unhealthy").Targets, replayFixture(t, "node_unhealthy").Targets)
+	})
+}
+
+func TestReplay_CapturedPayload(t *testing.T) {
+	res, err := Replay(&configtypes.ProxyConfig{}, ReplayFixture{
+		Request:  `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`,
+		Response: `{"jsonrpc":"2.0","result":1000,"id":1}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.Equal(t, 1, res.Attempts)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":1000,"id":1}`, res.Body)
+
+	// the captured status is honored, a failed response isn't replayed as a successful one
+	res, err = Replay(&configtypes.ProxyConfig{}, ReplayFixture{
+		Request:  `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`,
+		Status:   http.StatusBadGateway,
+		Response: `<html>502 Bad Gateway</html>`,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, http.StatusOK, res.Status)
+	assert.NotEmpty(t, res.Error)
+	assert.Equal(t, 1, res.Attempts)
+
+	_, err = Replay(&configtypes.ProxyConfig{}, ReplayFixture{Request: `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`})
+	assert.Error(t, err)
+}
@@ -20,10 +20,84 @@ const (
 	codeField    = "code"
 	resultField  = "result"
 	messageField = "message"
+
+	// getHealth result of a catching up node (older versions reply it instead of NodeUnhealthyErrCode)
+	healthBehindResult = "behind"
 )
 
 var EmptyResponse = []byte("null")
 
+// dasPagedMethods are DAS methods which results are pages with an items array
+var dasPagedMethods = map[string]struct{}{
+	solana.GetAssetsByOwner:          {},
+	solana.GetAssetsByAuthority:      {},
+	solana.GetAssetsByCreator:        {},
+	solana.GetAssetsByGroup:          {},
+	solana.SearchAssets:              {},
+	solana.GetTokenAccounts:          {},
+	solana.GetSignaturesForAsset:     {},
+	solana.GetSignaturesForAssetV2:   {},
+	solana.GetAssetSignatures:        {},
+	solana.GetAssetSignaturesAlias:   {},
+	solana.GetAssetSignaturesV2:      {},
+	solana.GetAssetSignaturesV2Alias: {},
+}
+
+// validateDASResponse checks the envelope of DAS responses skipping the full analysis: every response has an error or
+// a result, and results of paged methods have items. Methods of a batch are matched by position
+func validateDASResponse(body []byte, methods []string) error {
+	if len(body) == 0 || body[0] != '[' {
+		return validateDASEnvelope(body, firstMethod(methods))
+	}
+
+	var i int
+	var err error
+	_, arrErr := jsonparser.ArrayEach(body, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		var method string
+		if i < len(methods) {
+			method = methods[i]
+		}
+		i++
+		if err == nil {
+			err = validateDASEnvelope(value, method)
+		}
+	})
+	if arrErr != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedDASResponse, arrErr)
+	}
+
+	return err
+}
+
+func validateDASEnvelope(body []byte, method string) error {
+	if _, _, _, err := jsonparser.Get(body, errorField); err == nil {
+		return nil
+	}
+	result, dataType, _, err := jsonparser.Get(body, resultField)
+	if err != nil {
+		return fmt.Errorf("%w: no %s", ErrMalformedDASResponse, resultField)
+	}
+	if _, paged := dasPagedMethods[method]; !paged {
+		return nil
+	}
+	if dataType != jsonparser.Object {
+		return fmt.Errorf("%w: %s result isn't an object", ErrMalformedDASResponse, method)
+	}
+	if _, itemsType, _, err := jsonparser.Get(result, "items"); err != nil || itemsType != jsonparser.Array {
+		return fmt.Errorf("%w: %s result has no items", ErrMalformedDASResponse, method)
+	}
+
+	return nil
+}
+
+func firstMethod(methods []string) string {
+	if len(methods) == 0 {
+		return ""
+	}
+
+	return methods[0]
+}
+
 func rpcErrorAnalysis(errs []error) (firstSlotOnNode int64, invalidReqErr bool, analyzeErr *AnalyzeError, err error) {
 	if len(errs) == 0 {
 		return
@@ -73,9 +147,6 @@ func rpcErrorAnalysis(errs []error) (firstSlotOnNode int64, invalidReqErr bool,
 
 			continue
 		}
-		// TODO: maybe jail all methods in this case
-		// if rpcErr.Code == solana.NodeUnhealthyErrCode {}
-
 		joinedErr = fmt.Sprintf("%srpcErr: code %d %s; ", joinedErr, rpcErr.Code, rpcErr.Message)
 	}
 
@@ -85,9 +156,30 @@ func rpcErrorAnalysis(errs []error) (firstSlotOnNode int64, invalidReqErr bool,
 
 	return
 }
+
+// isNodeBehind reports whether the node is catching up, by the NodeUnhealthyErrCode error or the "behind" getHealth result
+func isNodeBehind(errs []error) bool {
+	for _, e := range errs {
+		if errors.Is(e, ErrNodeBehind) {
+			return true
+		}
+		var rpcErr *jsonrpc.RPCError
+		if errors.As(e, &rpcErr) && rpcErr.Code == solana.NodeUnhealthyErrCode {
+			return true
+		}
+	}
+
+	return false
+}
+
 func isMethodNotAvailableByErrCode(code int) bool {
 	return code == solana.MethodNotFoundErrCode || code == solana.TransactionHistoryNotAvailableErrCode
 }
+
+// isJSONBody checks the first byte only, same as decodeNodeResponse
+func isJSONBody(body []byte) bool {
+	return len(body) != 0 && (body[0] == '{' || body[0] == '[')
+}
 func decodeNodeResponse(c *echo.CustomContext, body []byte) (errs []error) {
 	// clean possible old value
 	c.SetRPCErrors(nil)
@@ -133,7 +225,7 @@ func decodeNodeResponse(c *echo.CustomContext, body []byte) (errs []error) {
 			errs = append(errs, err)
 		}
 	default:
-		return append(errs, fmt.Errorf("invalid json first symbol: %s", string(fs)))
+		return append(errs, fmt.Errorf("%w: invalid json first symbol: %s", ErrNonJSONResponse, string(fs)))
 	}
 
 	if len(errCodes) != 0 {
@@ -161,6 +253,12 @@ func checkRPCResp(body []byte, reqMethod string) (int, error) {
 		}
 	}
 
+	if reqMethod == solana.GetHealth {
+		if res, _ := jsonparser.GetString(body, resultField); res == healthBehindResult {
+			return 0, ErrNodeBehind
+		}
+	}
+
 	if reqMethod == solana.GetBlock {
 		res, _, _, _ := jsonparser.Get(body, resultField)
 		if bytes.Equal(res, EmptyResponse) {
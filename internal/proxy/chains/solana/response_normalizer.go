@@ -0,0 +1,116 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/buger/jsonparser"
+
+	"aura-proxy/internal/pkg/configtypes"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// responseNormalizer canonicalizes string values of result fields, normalizations by field name by method
+type responseNormalizer map[string]map[string]string
+
+// newResponseNormalizer parses "method.field" keys of the configured normalizations, nil if none
+func newResponseNormalizer(normalizations map[string]string) responseNormalizer {
+	if len(normalizations) == 0 {
+		return nil
+	}
+
+	n := make(responseNormalizer)
+	for key, normalization := range normalizations {
+		method, field, ok := strings.Cut(key, ".")
+		if !ok {
+			continue // rejected by the config validation
+		}
+		if n[method] == nil {
+			n[method] = make(map[string]string)
+		}
+		n[method][field] = normalization
+	}
+
+	return n
+}
+
+// normalize applies the normalizations of the method to fields of a successful single response at any nesting level
+// of the result. The body is returned as is for batches, errors and bodies which can't be normalized
+func (n responseNormalizer) normalize(c *echoUtil.CustomContext, methods []string, body []byte) []byte {
+	if len(n) == 0 || c.GetArrayRequested() || len(methods) != 1 {
+		return body
+	}
+	fields, ok := n[methods[0]]
+	if !ok {
+		return body
+	}
+	result, _, _, err := jsonparser.Get(body, resultField)
+	if err != nil {
+		return body
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(normalizeValue(value, fields))
+	if err != nil {
+		return body
+	}
+	res, err := jsonparser.Set(body, normalized, resultField)
+	if err != nil {
+		return body
+	}
+
+	return res
+}
+
+func normalizeValue(value interface{}, fields map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if s, ok := field.(string); ok {
+				if normalization, ok := fields[key]; ok {
+					v[key] = normalizeString(s, normalization)
+				}
+				continue
+			}
+			v[key] = normalizeValue(field, fields)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeValue(v[i], fields)
+		}
+	}
+
+	return value
+}
+
+func normalizeString(s, normalization string) string {
+	switch normalization {
+	case configtypes.ResponseNormalizationTrim:
+		return strings.TrimSpace(s)
+	case configtypes.ResponseNormalizationLowerHex:
+		if isHex(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")) {
+			return strings.ToLower(s)
+		}
+	}
+
+	return s
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') && (r < 'A' || r > 'F') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package solana
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+func TestResponseNormalizer_Normalize(t *testing.T) {
+	n := newResponseNormalizer(map[string]string{
+		solana.GetAsset + ".data_hash": configtypes.ResponseNormalizationLowerHex,
+		solana.GetAsset + ".owner":     configtypes.ResponseNormalizationLowerHex,
+		solana.GetAsset + ".json_uri":  configtypes.ResponseNormalizationTrim,
+	})
+	require.NotNil(t, n)
+	assert.Nil(t, newResponseNormalizer(nil))
+
+	body := []byte(`{"jsonrpc":"2.0","result":{"id":"Asset1","ownership":{"owner":"9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"},` +
+		`"content":{"json_uri":" https://example.com/1.json "},"compression":{"data_hash":"0xAbCdEF","leaf_id":12345678901234567890},` +
+		`"creators":[{"data_hash":"FF"}],"name":" Name "},"id":1}`)
+	normalize := func(methods []string, body []byte) []byte {
+		c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), methods, nil)
+		return n.normalize(c, methods, bytes.Clone(body))
+	}
+
+	// only configured fields are normalized, at any nesting level, base58 owners aren't hex and large numbers are kept
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"id":"Asset1","ownership":{"owner":"9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"},`+
+		`"content":{"json_uri":"https://example.com/1.json"},"compression":{"data_hash":"0xabcdef","leaf_id":12345678901234567890},`+
+		`"creators":[{"data_hash":"ff"}],"name":" Name "},"id":1}`, string(normalize([]string{solana.GetAsset}, body)))
+
+	// other methods, batches and errors are kept
+	assert.Equal(t, body, normalize([]string{solana.GetAssetProof}, body))
+	assert.Equal(t, body, normalize([]string{solana.GetAsset, solana.GetAsset}, body))
+	errBody := []byte(`{"jsonrpc":"2.0","error":{"code":-32602,"message":" Invalid "},"id":1}`)
+	assert.Equal(t, errBody, normalize([]string{solana.GetAsset}, errBody))
+}
+
+func TestNormalizeString(t *testing.T) {
+	tests := []struct {
+		value, normalization, expected string
+	}{
+		{value: "0XABC", normalization: configtypes.ResponseNormalizationLowerHex, expected: "0xabc"},
+		{value: "DEADbeef", normalization: configtypes.ResponseNormalizationLowerHex, expected: "deadbeef"},
+		{value: "So11111111111111111111111111111111111111112", normalization: configtypes.ResponseNormalizationLowerHex, expected: "So11111111111111111111111111111111111111112"},
+		{value: "0x", normalization: configtypes.ResponseNormalizationLowerHex, expected: "0x"},
+		{value: "\t value \n", normalization: configtypes.ResponseNormalizationTrim, expected: "value"},
+		{value: " ABC ", normalization: "unknown", expected: " ABC "},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, normalizeString(tt.value, tt.normalization), tt.value)
+	}
+}
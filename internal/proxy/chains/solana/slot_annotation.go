@@ -0,0 +1,38 @@
+package solana
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/buger/jsonparser"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// proxyContextField is the extension field of annotated responses. JSON-RPC clients ignore unknown envelope fields
+const proxyContextField = "proxyContext"
+
+// annotateSlot adds the estimated slot of the target to a successful single response of an annotated method.
+// The body is returned as is for batches, errors, unknown slots and bodies which can't be annotated
+func (t *UnifiedTransport) annotateSlot(c *echoUtil.CustomContext, methods []string, target *ProxyTarget, body []byte) []byte {
+	if len(t.slotAnnotatedMethods) == 0 || c.GetArrayRequested() || len(methods) != 1 {
+		return body
+	}
+	if _, ok := t.slotAnnotatedMethods[methods[0]]; !ok {
+		return body
+	}
+	if _, _, _, err := jsonparser.Get(body, "result"); err != nil {
+		return body
+	}
+	slot := target.estimatedSlot(time.Now())
+	if slot == 0 {
+		return body
+	}
+
+	annotated, err := jsonparser.Set(body, []byte(strconv.FormatInt(slot, 10)), proxyContextField, "slot")
+	if err != nil {
+		return body
+	}
+
+	return annotated
+}
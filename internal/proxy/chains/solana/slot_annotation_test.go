@@ -0,0 +1,76 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+)
+
+func TestUnifiedTransport_SlotAnnotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		observedSlot int64
+		response     string
+		wantResponse string
+	}{
+		{
+			name:         "annotated method",
+			method:       solana.GetBalance,
+			observedSlot: 100,
+			response:     `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1}`,
+			wantResponse: `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1,"proxyContext":{"slot":100}}`,
+		},
+		{
+			name:         "other method",
+			method:       solana.GetSlot,
+			observedSlot: 100,
+			response:     `{"jsonrpc":"2.0","result":99,"id":1}`,
+			wantResponse: `{"jsonrpc":"2.0","result":99,"id":1}`,
+		},
+		{
+			name:         "error response",
+			method:       solana.GetBalance,
+			observedSlot: 100,
+			response:     `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid param"},"id":1}`,
+			wantResponse: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid param"},"id":1}`,
+		},
+		{
+			name:         "unknown slot",
+			method:       solana.GetBalance,
+			response:     `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1}`,
+			wantResponse: `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &ProxyTarget{url: "target1"}
+			if tt.observedSlot != 0 {
+				target.observeSlot(tt.observedSlot, time.Now())
+			}
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{{Target: target, Index: 0}},
+				TargetsCount:  1,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{{StatusCode: http.StatusOK, RespBody: []byte(tt.response)}}}
+			transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 1, false)
+			transport.slotAnnotatedMethods = map[string]struct{}{solana.GetBalance: {}}
+
+			requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": tt.method, "id": 1})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{tt.method}, requestBytes)
+			respBody, _, err := transport.SendRequest(c)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantResponse, string(respBody))
+		})
+	}
+}
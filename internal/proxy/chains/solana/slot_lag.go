@@ -0,0 +1,45 @@
+package solana
+
+import (
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// maxSlotLag returns the max slots a target may lag behind the freshest one to serve the methods, the strictest one
+// of a batch. The threshold of a method defaults to the global one, processed commitment requests are limited by
+// commitmentMaxSlotLag too. 0 if the methods aren't limited
+func (t *UnifiedTransport) maxSlotLag(c *echoUtil.CustomContext, methods []string) (maxLag int64) {
+	limit := func(lag int64) {
+		if lag > 0 && (maxLag == 0 || lag < maxLag) {
+			maxLag = lag
+		}
+	}
+
+	for _, method := range methods {
+		lag, ok := t.methodMaxSlotLag[method]
+		if !ok {
+			lag = t.globalMaxSlotLag
+		}
+		limit(lag)
+	}
+	// Processed commitment needs the freshest state, while confirmed and finalized ones tolerate lagging targets
+	if c.GetReqCommitment() == solana.CommitmentProcessed {
+		limit(t.commitmentMaxSlotLag)
+	}
+
+	return maxLag
+}
+
+// newMethodMaxSlotLag converts the configured thresholds, 0 removes the global threshold of a method
+func newMethodMaxSlotLag(thresholds map[string]uint64) map[string]int64 {
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	res := make(map[string]int64, len(thresholds))
+	for method, lag := range thresholds {
+		res[method] = int64(lag) //nolint:gosec
+	}
+
+	return res
+}
@@ -0,0 +1,95 @@
+package solana
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/util/balancer"
+)
+
+// minSlowTargetFleet is the least targets with response times of a method needed to compare them with the median
+const minSlowTargetFleet = 3
+
+// slowTargetDetection down-weights targets which p95 response time of a method is over factor times the median p95
+// of the method targets. Their weight is multiplied by weightFactor until they recover
+type slowTargetDetection struct {
+	factor       float64
+	weightFactor float64
+	interval     time.Duration
+}
+
+// startSlowTargetDetection evaluates target latencies every interval until ctx is done, factor <= 1 disables it.
+// Only probabilistic method balancers are affected, like with the success streak boost
+func (r *MethodBasedRouter) startSlowTargetDetection(ctx context.Context, detection slowTargetDetection) {
+	if detection.factor <= 1 || detection.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(detection.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.downWeightSlowTargets(detection)
+			}
+		}
+	}()
+}
+
+// downWeightSlowTargets sets the weights of all method balancers from the current target latencies
+func (r *MethodBasedRouter) downWeightSlowTargets(detection slowTargetDetection) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for method, info := range r.methodMap {
+		pb, ok := info.balancer.(*balancer.ProbabilisticBalancer[*ProxyTarget])
+		if !ok {
+			continue
+		}
+		slow := slowTargets(method, info.targets, detection.factor)
+
+		weights := slices.Clone(info.weights)
+		for i := range weights {
+			if slow[i] {
+				weights[i] *= detection.weightFactor
+			}
+		}
+		if err := pb.SetWeights(weights); err != nil { // all targets are slow with a zero weight factor
+			log.Logger.Proxy.Errorf("downWeightSlowTargets: %s: %s", method, err)
+		}
+	}
+}
+
+// slowTargets marks the targets which p95 response time of the method is over factor times the median p95.
+// Targets without response times aren't slow, nothing is slow with fewer than minSlowTargetFleet measured targets
+func slowTargets(method string, targets []*ProxyTarget, factor float64) []bool {
+	p95s := make([]int64, len(targets))
+	measured := make([]int64, 0, len(targets))
+	for i, target := range targets {
+		_, p95s[i], _ = target.GetResponseTimePercentiles(method)
+		if p95s[i] > 0 {
+			measured = append(measured, p95s[i])
+		}
+	}
+
+	slow := make([]bool, len(targets))
+	if len(measured) < minSlowTargetFleet {
+		return slow
+	}
+	slices.Sort(measured)
+	median := float64(measured[len(measured)/2])
+	if len(measured)%2 == 0 {
+		median = float64(measured[len(measured)/2-1]+measured[len(measured)/2]) / 2
+	}
+	for i := range targets {
+		slow[i] = p95s[i] > 0 && float64(p95s[i]) > factor*median
+	}
+
+	return slow
+}
@@ -0,0 +1,81 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/util/balancer"
+)
+
+func TestMethodBasedRouter_DownWeightSlowTargets(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fast1.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://fast2.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://fast3.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+				{URL: "https://slow.example.com", NodeType: archiveNodeType(), Methods: []string{solana.GetSlot}},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	require.NoError(t, err)
+	detection := slowTargetDetection{factor: 3, weightFactor: 0.25}
+
+	info := router.methodMap[solana.GetSlot]
+	pb, ok := info.balancer.(*balancer.ProbabilisticBalancer[*ProxyTarget])
+	require.True(t, ok)
+	respond := func(responseTimes ...int64) {
+		for range responseTimeSamplesLen {
+			for i, target := range info.targets {
+				target.UpdateStats(true, []string{solana.GetSlot}, responseTimes[i], 0)
+			}
+		}
+	}
+
+	// p95 of the slow target is 10 times the median
+	respond(10, 12, 10, 110)
+	router.downWeightSlowTargets(detection)
+	assert.InDeltaSlice(t, []float64{1 / 3.25, 1 / 3.25, 1 / 3.25, 0.25 / 3.25}, pb.Weights(), 1e-9)
+
+	// restored after recovery
+	respond(10, 12, 10, 20)
+	router.downWeightSlowTargets(detection)
+	assert.InDeltaSlice(t, []float64{0.25, 0.25, 0.25, 0.25}, pb.Weights(), 1e-9)
+}
+
+func TestSlowTargets(t *testing.T) {
+	newTarget := func(responseTimeMs int64) *ProxyTarget {
+		target := &ProxyTarget{url: "target", availableMethods: map[string]targetRestriction{}}
+		if responseTimeMs != 0 {
+			target.UpdateStats(true, []string{solana.GetSlot}, responseTimeMs, 0)
+		}
+		return target
+	}
+
+	tests := []struct {
+		name          string
+		responseTimes []int64
+		expected      []bool
+	}{
+		{name: "slow target", responseTimes: []int64{10, 20, 30, 100}, expected: []bool{false, false, false, true}},
+		{name: "within the factor", responseTimes: []int64{10, 20, 30, 75}, expected: []bool{false, false, false, false}},
+		{name: "too few measured targets", responseTimes: []int64{10, 100, 0}, expected: []bool{false, false, false}},
+		{name: "unmeasured target", responseTimes: []int64{10, 10, 10, 0}, expected: []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := make([]*ProxyTarget, len(tt.responseTimes))
+			for i, responseTime := range tt.responseTimes {
+				targets[i] = newTarget(responseTime)
+			}
+			assert.Equal(t, tt.expected, slowTargets(solana.GetSlot, targets, 3))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package solana
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/patrickmn/go-cache"
+
+	"aura-proxy/internal/pkg/metrics"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const (
+	// HeaderStaleResponse marks responses served from the stale cache, with the age of the entry in seconds
+	HeaderStaleResponse = "X-Aura-Stale-Age"
+
+	staleCacheMaxEntries      = 100_000
+	staleCacheCleanupInterval = time.Minute
+)
+
+// staleCache keeps the last successful responses of single requests of the configured methods by method and params.
+// They are never served while upstreams respond, only after all targets failed and until the max staleness of the method
+type staleCache struct {
+	maxStale map[string]time.Duration // by method
+	entries  *cache.Cache             // bounded by staleCacheMaxEntries
+}
+
+type staleEntry struct {
+	body     []byte
+	storedAt time.Time
+}
+
+// newStaleCache returns nil if no method is configured
+func newStaleCache(maxStale map[string]time.Duration) *staleCache {
+	s := &staleCache{maxStale: make(map[string]time.Duration, len(maxStale))}
+	for method, d := range maxStale {
+		if d > 0 {
+			s.maxStale[method] = d
+		}
+	}
+	if len(s.maxStale) == 0 {
+		return nil
+	}
+	s.entries = cache.New(cache.NoExpiration, staleCacheCleanupInterval)
+
+	return s
+}
+
+// key returns the cache key of a single request of a configured method, false for other requests
+func (s *staleCache) key(c *echoUtil.CustomContext, methods []string) (key string, maxStale time.Duration, ok bool) {
+	if s == nil || c.GetArrayRequested() || len(methods) != 1 {
+		return "", 0, false
+	}
+	maxStale, ok = s.maxStale[methods[0]]
+	if !ok {
+		return "", 0, false
+	}
+	params, _, _, _ := jsonparser.Get([]byte(c.GetReqBodyString()), "params") //nolint:dogsled
+
+	return methods[0] + "\x00" + string(params), maxStale, true
+}
+
+// store keeps a response with a result. Bodies of errors aren't kept
+func (s *staleCache) store(c *echoUtil.CustomContext, methods []string, body []byte) {
+	key, maxStale, ok := s.key(c, methods)
+	if !ok {
+		return
+	}
+	if _, _, _, err := jsonparser.Get(body, "result"); err != nil {
+		return
+	}
+	if _, found := s.entries.Get(key); !found && s.entries.ItemCount() >= staleCacheMaxEntries {
+		return
+	}
+
+	s.entries.Set(key, staleEntry{body: bytes.Clone(body), storedAt: time.Now()}, maxStale)
+}
+
+// serve returns the kept response with the id of the request and marks it with the stale header
+func (s *staleCache) serve(c *echoUtil.CustomContext, methods []string) ([]byte, bool) {
+	key, _, ok := s.key(c, methods)
+	if !ok {
+		return nil, false
+	}
+	cached, found := s.entries.Get(key)
+	if !found {
+		return nil, false
+	}
+	entry := cached.(staleEntry) //nolint:errcheck
+
+	body, err := jsonparser.Set(bytes.Clone(entry.body), rawID([]byte(c.GetReqBodyString())), "id")
+	if err != nil {
+		return nil, false
+	}
+	c.Response().Header().Set(HeaderStaleResponse, strconv.FormatInt(int64(time.Since(entry.storedAt).Seconds()), 10))
+	metrics.IncStaleResponses(c.GetChainName(), methods[0])
+
+	return body, true
+}
@@ -0,0 +1,80 @@
+package solana
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+)
+
+func TestUnifiedTransport_StaleOnError(t *testing.T) {
+	target1, target2 := &ProxyTarget{url: "target1"}, &ProxyTarget{url: "target2"}
+	mockSelector := &MockTargetSelector{
+		NextResponses: []NextResponse{
+			{Target: target1, Index: 0},                              // live response
+			{Target: target1, Index: 0}, {Target: target2, Index: 1}, // all targets fail
+			{Target: target1, Index: 0}, {Target: target2, Index: 1}, // all targets fail, other params
+		},
+		TargetsCount:  2,
+		IsAvailableFn: func() bool { return true },
+	}
+	failure := HTTPResponseWrapper{StatusCode: http.StatusBadGateway, Error: errors.New("bad gateway")}
+	mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{StatusCode: http.StatusOK, RespBody: []byte(`{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1}`)},
+		failure, failure,
+		failure, failure,
+	}}
+	transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 2, false)
+	transport.staleCache = newStaleCache(map[string]time.Duration{solana.GetBalance: time.Minute})
+
+	send := func(body string) (*httptest.ResponseRecorder, []byte, error) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		rec := httptest.NewRecorder()
+		c := createTestCustomContext(req, rec, []string{solana.GetBalance}, []byte(body))
+		respBody, _, err := transport.SendRequest(c)
+		return rec, respBody, err
+	}
+
+	rec, respBody, err := send(`{"jsonrpc":"2.0","method":"getBalance","params":["addr"],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":1}`, string(respBody))
+	assert.Empty(t, rec.Header().Get(HeaderStaleResponse))
+
+	// the kept response is served with the id of the request and the marker
+	rec, respBody, err = send(`{"jsonrpc":"2.0","method":"getBalance","params":["addr"],"id":"abc"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"context":{"slot":99},"value":5},"id":"abc"}`, string(respBody))
+	assert.Equal(t, "0", rec.Header().Get(HeaderStaleResponse))
+
+	rec, _, err = send(`{"jsonrpc":"2.0","method":"getBalance","params":["other"],"id":1}`)
+	require.Error(t, err)
+	assert.Empty(t, rec.Header().Get(HeaderStaleResponse))
+}
+
+func TestStaleCache_MaxStale(t *testing.T) {
+	s := newStaleCache(map[string]time.Duration{solana.GetBalance: 50 * time.Millisecond, solana.GetSlot: 0})
+	require.NotNil(t, s)
+	assert.NotContains(t, s.maxStale, solana.GetSlot)
+	assert.Nil(t, newStaleCache(map[string]time.Duration{solana.GetSlot: 0}))
+
+	body := []byte(`{"jsonrpc":"2.0","method":"getBalance","params":["addr"],"id":1}`)
+	c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), []string{solana.GetBalance}, body)
+	s.store(c, []string{solana.GetBalance}, []byte(`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid param"},"id":1}`))
+	_, ok := s.serve(c, []string{solana.GetBalance})
+	assert.False(t, ok, "errors aren't kept")
+
+	s.store(c, []string{solana.GetBalance}, []byte(`{"jsonrpc":"2.0","result":5,"id":1}`))
+	_, ok = s.serve(c, []string{solana.GetBalance})
+	assert.True(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = s.serve(c, []string{solana.GetBalance})
+	assert.False(t, ok, "entries aren't served after the max staleness")
+}
@@ -2,15 +2,19 @@ package solana
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/buger/jsonparser"
 	"github.com/labstack/echo/v4"
 
 	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
 	"aura-proxy/internal/pkg/log"
 	"aura-proxy/internal/pkg/metrics"
 	"aura-proxy/internal/pkg/transport"
@@ -26,6 +30,15 @@ const (
 	// Default values
 	DefaultMaxAttempts = 10
 
+	// Upstream request timeout, leaves time to write the response within the server write timeout
+	requestTimeout = echoUtil.APIWriteTimeout - time.Second
+
+	// Jail time of the methods of a catching up target, it's probed again after the node had time to catch up
+	behindJailTime = 10 * time.Second
+
+	// Provider name reported for requests served by the public fallback
+	PublicFallbackProvider = "public_fallback"
+
 	// Constants copied from publicTransport for slot calculations
 	slotsPerSec                    = 2.5
 	mainnetPreSetUpSlot            = 245091957
@@ -40,9 +53,15 @@ type MethodRouter interface {
 	// This allows the caller to handle target selection with exclude functionality
 	GetBalancerForMethod(method string) (balancer.TargetSelector[*ProxyTarget], bool)
 
+	// GetGPABalancer returns the balancer of the dedicated getProgramAccounts pool, if configured
+	GetGPABalancer() (balancer.TargetSelector[*ProxyTarget], bool)
+
 	// IsMethodSupported checks if a method is supported by this router
 	IsMethodSupported(method string) bool
 
+	// CanServeMethod checks if a method is supported and has targets not jailed for it
+	CanServeMethod(method string) bool
+
 	// IsAvailable checks if there are any available targets
 	IsAvailable() bool
 
@@ -55,11 +74,55 @@ type HTTPRequester interface {
 	DoRequest(c *echoUtil.CustomContext, targetURL string) (respBody []byte, statusCode int, err error)
 }
 
+// StreamingHTTPRequester is implemented by requesters able to copy the upstream body directly to the client response.
+type StreamingHTTPRequester interface {
+	StreamRequest(c *echoUtil.CustomContext, targetURL string, beforeWrite func(head []byte) error) (statusCode int, err error)
+}
+
 // RealHTTPRequester is the production implementation of HTTPRequester.
-type RealHTTPRequester struct{}
+type RealHTTPRequester struct {
+	// Clients of targets with custom client options, by target URL
+	hostClients map[string]*http.Client
+}
+
+// clientOptions are the HTTP client settings of a target
+type clientOptions struct {
+	hostHeader     string // Host header (and SNI) sent instead of the URL host, empty if not overridden
+	deadlineHeader bool   // the remaining time of the client request is sent as X-Deadline-Ms
+}
+
+// NewRealHTTPRequester creates the requester. options maps target URLs to their client settings
+func NewRealHTTPRequester(options map[string]clientOptions) *RealHTTPRequester {
+	r := &RealHTTPRequester{hostClients: make(map[string]*http.Client, len(options))}
+	for targetURL, opts := range options {
+		client := &http.Client{Timeout: requestTimeout}
+		if opts.hostHeader != "" {
+			client = transport.NewHostHeaderClient(opts.hostHeader, requestTimeout, nil)
+		}
+		if opts.deadlineHeader {
+			client = transport.WithDeadlineHeader(client)
+		}
+		r.hostClients[targetURL] = client
+	}
+
+	return r
+}
 
 func (r *RealHTTPRequester) DoRequest(c *echoUtil.CustomContext, targetURL string) (respBody []byte, statusCode int, err error) {
-	return transport.MakeHTTPRequest(c, &http.Client{Timeout: echoUtil.APIWriteTimeout - time.Second}, http.MethodPost, targetURL, false)
+	return transport.MakeHTTPRequest(c, r.getClient(targetURL), http.MethodPost, targetURL, false)
+}
+
+func (r *RealHTTPRequester) StreamRequest(c *echoUtil.CustomContext, targetURL string, beforeWrite func(head []byte) error) (statusCode int, err error) {
+	_, statusCode, err = transport.StreamHTTPRequest(c, r.getClient(targetURL), targetURL, beforeWrite)
+	return statusCode, err
+}
+
+func (r *RealHTTPRequester) getClient(targetURL string) *http.Client {
+	if client, ok := r.hostClients[targetURL]; ok {
+		return client
+	}
+
+	return &http.Client{Timeout: requestTimeout}
 }
 
 type UnifiedTransport struct {
@@ -79,16 +142,113 @@ type UnifiedTransport struct {
 	currentSlot int64
 	getSlotTime time.Time
 	isMainnet   bool
+
+	// Debug: seed target selection from the request id
+	seededSelection bool
+
+	// Public RPC used after all partner targets are exhausted, empty if disabled
+	publicFallbackURL string
+
+	// Normalizations of result fields of successful single responses by method, nil if none
+	responseNormalizer responseNormalizer
+
+	// Methods which responses are copied to the client without buffering and analysis
+	streamedMethods map[string]struct{}
+
+	// Methods jailed on a catching up target, configtypes.NodeBehindPolicy* (slot sensitive if empty)
+	nodeBehindPolicy string
+	// Retries of requests failed with MinContextSlotNotReachedErrCode on more advanced targets, 0 handles the error as
+	// any other node error
+	minContextSlotRetries int
+
+	// Attempts and time per provider in a request, unlimited if zero
+	providerBudget providerBudget
+
+	// Skip all targets of a provider after one of them responds 429
+	excludeRateLimitedProviders bool
+
+	// Max slots a target may lag behind the freshest one to serve processed commitment requests, 0 if disabled
+	commitmentMaxSlotLag int64
+	// Max slots a target may lag behind the freshest one to serve a method, globalMaxSlotLag if the method isn't set
+	methodMaxSlotLag map[string]int64
+	globalMaxSlotLag int64
+
+	// Max provider names in the log of a request which exhausted all targets
+	failedProvidersLogLimit int
+
+	// Fixed jail time by upstream status code, instead of the jail escalating with errors
+	statusJailTimes map[int]time.Duration
+
+	// Check the envelope of DAS responses on the fast path, retrying malformed ones
+	dasResponseValidation bool
+
+	// Targets queried for a merged getClusterNodes response, 0 or 1 disables the aggregation
+	clusterNodesTargets int
+
+	// Coalesces concurrent single requests of compatible methods into upstream batches, nil if disabled
+	microBatcher *microBatcher
+
+	// Methods which successful responses get the slot of the serving target in the proxyContext field
+	slotAnnotatedMethods map[string]struct{}
+
+	// Node request timeouts per method shorter than requestTimeout, applied per attempt except streamed responses
+	methodTimeouts map[string]time.Duration
+
+	// Return the succeeded elements of a batch with error elements for the rest, instead of failing the whole batch
+	partialBatchResults bool
+
+	// Last successful responses served after all targets failed, nil if disabled
+	staleCache *staleCache
+
+	// Try the last successful target of a method first, the balancer is used after it fails
+	stickyTargets bool
+	lastTargets   map[string]stickyTarget // by method
+	lastTargetsMx sync.RWMutex
+}
+
+// stickyTarget is the last target which served a method successfully
+type stickyTarget struct {
+	selector balancer.TargetSelector[*ProxyTarget]
+	target   *ProxyTarget
+	index    int
+}
+
+// providerExcluder is implemented by method routers mapping providers to balancer indices
+type providerExcluder interface {
+	ExcludeProviders(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, providers []string)
+}
+
+// providerPinner is implemented by method routers able to restrict a request to the targets of one provider
+type providerPinner interface {
+	ExcludeOtherProviders(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, provider string) bool
+}
+
+// targetJailer is implemented by method routers supporting a fixed jail time
+type targetJailer interface {
+	JailTargetFor(target *ProxyTarget, methods []string, jailTime time.Duration)
+}
+
+// lagExcluder is implemented by method routers tracking the observed slots of targets
+type lagExcluder interface {
+	ExcludeLaggingTargets(method string, selector balancer.TargetSelector[*ProxyTarget], exclude *balancer.Exclusions, maxLag int64)
 }
 
 func NewUnifiedTransport(transportType string, methodRouter MethodRouter, httpRequester HTTPRequester, maxAttempts int, isMainnet bool) *UnifiedTransport {
-	return &UnifiedTransport{
-		transportType: transportType,
-		methodRouter:  methodRouter,
-		httpRequester: httpRequester,
-		maxAttempts:   maxAttempts,
-		isMainnet:     isMainnet,
+	t := &UnifiedTransport{
+		transportType:  transportType,
+		methodRouter:   methodRouter,
+		httpRequester:  httpRequester,
+		maxAttempts:    maxAttempts,
+		isMainnet:      isMainnet,
+		methodTimeouts: newMethodTimeouts(nil),
+		currentSlot:    devnetPreSetUpSlot,
+		getSlotTime:    time.Unix(devnetPreSetUpGetSlotTimeUnix, 0),
 	}
+	if isMainnet {
+		t.currentSlot, t.getSlotTime = mainnetPreSetUpSlot, time.Unix(mainnetPreSetUpGetSlotTimeUnix, 0)
+	}
+
+	return t
 }
 
 func (t *UnifiedTransport) isAvailable() bool {
@@ -109,6 +269,17 @@ func (t *UnifiedTransport) canHandle(methods []string) bool {
 	return true
 }
 
+// canServe checks if all methods have targets not jailed for them, canHandle is expected to be checked before
+func (t *UnifiedTransport) canServe(methods []string) bool {
+	for _, method := range methods {
+		if !t.methodRouter.CanServeMethod(method) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (t *UnifiedTransport) SendRequest(c *echoUtil.CustomContext) (respBody []byte, statusCode int, err error) {
 	startTime := time.Now()
 
@@ -122,28 +293,86 @@ func (t *UnifiedTransport) SendRequest(c *echoUtil.CustomContext) (respBody []by
 func (t *UnifiedTransport) executeWithRetries(c *echoUtil.CustomContext) (respBody []byte, statusCode int, attempts int, err error) {
 	methods := c.GetReqMethods()
 	if len(methods) == 0 {
-		return nil, http.StatusBadRequest, 0, fmt.Errorf("no methods specified in request")
+		statusCode, err = noMethodsError(c)
+		return nil, statusCode, 0, err
 	}
 
 	// Get primary method (first method in the list)
 	primaryMethod := methods[0]
 
 	// Get load balancer for the primary method
-	balancer, found := t.methodRouter.GetBalancerForMethod(primaryMethod)
-	if !found || !balancer.IsAvailable() {
+	selector, found := t.getBalancer(c, primaryMethod)
+	if !found || !selector.IsAvailable() {
 		return nil, http.StatusServiceUnavailable, 0, fmt.Errorf("no balancer available for method %s", primaryMethod)
 	}
 
+	// With all targets jailed, requests which may use the public fallback are sent to it without trying the jailed ones
+	if !t.canServe(methods) && t.canUsePublicFallback(c, methods) {
+		respBody, statusCode, err = t.sendToPublicFallback(c)
+		if err != nil {
+			if stale, ok := t.staleCache.serve(c, methods); ok {
+				return stale, http.StatusOK, 1, nil
+			}
+		}
+		return respBody, statusCode, 1, err
+	}
+
 	reqCtx := c.Request().Context()
-	excludedTargets := make([]int, 0)
+	reqStartTime := time.Now()
+	excludedTargets := balancer.NewExclusions(selector.GetTargetsCount())
+	if excluder, ok := t.methodRouter.(lagExcluder); ok {
+		if maxLag := t.maxSlotLag(c, methods); maxLag > 0 {
+			excluder.ExcludeLaggingTargets(primaryMethod, selector, excludedTargets, maxLag)
+		}
+	}
+	if excluder, ok := t.methodRouter.(drainExcluder); ok {
+		excluder.ExcludeDrainingTargets(primaryMethod, selector, excludedTargets)
+	}
+	// Debug routing bypasses the balancer selection across providers, with no fallback to other ones
+	if pinnedProvider := c.GetPinnedProvider(); pinnedProvider != "" {
+		pinner, ok := t.methodRouter.(providerPinner)
+		if !ok || !pinner.ExcludeOtherProviders(primaryMethod, selector, excludedTargets, pinnedProvider) {
+			statusCode, err = pinnedProviderError(c)
+			return nil, statusCode, 0, err
+		}
+	}
+	streamer := t.getStreamer(c)
+
+	// Per-request RNG makes the target sequence reproducible from the request id
+	var rng *rand.Rand
+	if t.seededSelection {
+		rng = rand.New(rand.NewSource(balancer.SeedFromString(c.GetReqID()))) //nolint:gosec
+	}
 
 	var target *ProxyTarget
 	var targetIndex int
+	var excludedProviders, failedProviders []string
+	usage := newProviderUsage(t.providerBudget)
+	// The last response of a node behind the min context slot of the request, returned if no advanced node serves it
+	var minContextSlotResp []byte
+	var minContextSlotRetries int
+
+	// Merged gossip view; if no target returns nodes, the request is retried on the rest ones as usual,
+	// with the remaining attempts
+	var aggregationAttempts int
+	if streamer == nil && t.canAggregateClusterNodes(c, methods) {
+		merged, mergedStatusCode, mergedAttempts, ok := t.aggregateClusterNodes(c, selector, excludedTargets)
+		if ok {
+			return merged, mergedStatusCode, mergedAttempts, nil
+		}
+		aggregationAttempts = mergedAttempts
+	}
 
 	// Check if this is a DAS method to enable fast path
 	_, isDASMethod := solana.CNFTMethodList[primaryMethod]
 
-	for attempts = 0; attempts < t.maxAttempts; attempts++ {
+	// Succeeded elements of batches failed as a whole are returned after the retries
+	var partialResults *batchResults
+	if t.partialBatchResults && streamer == nil && c.GetArrayRequested() {
+		partialResults = newBatchResults(len(methods))
+	}
+
+	for attempts = aggregationAttempts; attempts < t.maxAttempts; attempts++ {
 		// Check for context cancellation
 		select {
 		case <-reqCtx.Done():
@@ -151,42 +380,163 @@ func (t *UnifiedTransport) executeWithRetries(c *echoUtil.CustomContext) (respBo
 		default:
 		}
 
-		// Get next target from the balancer
-		target, targetIndex, err = balancer.GetNext(excludedTargets)
-		if err != nil {
-			break // No more available targets
+		// A target of the preferred provider of the client session is tried first, then the last successful target.
+		// The balancer is used for the next attempts
+		if preferred, preferredIndex, ok := t.getPreferredTarget(c, primaryMethod, selector, rng, excludedTargets, attempts); ok {
+			target, targetIndex = preferred, preferredIndex
+		} else if sticky, stickyIndex, ok := t.getStickyTarget(c, methods, selector, rng, excludedTargets, attempts); ok {
+			target, targetIndex = sticky, stickyIndex
+		} else {
+			// Get next target from the balancer. The last target and its error are kept when there are no more targets
+			nextTarget, nextIndex, nextErr := t.getNextExcludingProviders(primaryMethod, selector, rng, c.GetStatsAdditionalData(), excludedTargets, excludedProviders)
+			if nextErr != nil || nextTarget == nil {
+				break // No more available targets
+			}
+			target, targetIndex = nextTarget, nextIndex
+		}
+
+		// A target removed after its selection is skipped without counting the attempt
+		if !target.startRequest() {
+			excludedTargets.Add(targetIndex)
+			attempts--
+			continue
 		}
 
 		// Record provider for metrics
 		c.SetProvider(target.provider)
 
+		if streamer != nil {
+			committed, streamStatusCode, streamErr := t.streamFromTarget(c, streamer, target, methods, attempts, reqStartTime)
+			if committed {
+				attempts++
+				return nil, streamStatusCode, attempts, streamErr
+			}
+
+			statusCode, err = streamStatusCode, streamErr
+			t.dropStickyTarget(primaryMethod, target)
+			excludedTargets.Add(targetIndex)
+			failedProviders = appendProvider(failedProviders, target.provider)
+			continue
+		}
+
 		// Execute request to the target
 		startTime := time.Now()
-		respBody, statusCode, err = t.httpRequester.DoRequest(c, target.url)
+		restoreRequest := t.withMethodTimeout(c, methods)
+		restoreBudget := usage.withTimeout(c, target.provider)
+		if t.microBatcher != nil && t.microBatcher.canBatch(c, methods) {
+			respBody, statusCode, err = t.microBatcher.DoRequest(c, target.url)
+		} else {
+			respBody, statusCode, err = t.httpRequester.DoRequest(c, target.url)
+		}
+		restoreBudget()
+		restoreRequest()
+		target.finishRequest()
 		responseTime := time.Since(startTime).Milliseconds()
+		// targets of a provider out of its budget aren't tried again in the request
+		if usage.add(target.provider, time.Since(startTime)) && !slices.Contains(excludedProviders, target.provider) {
+			excludedProviders = append(excludedProviders, target.provider)
+		}
+		if err == nil && c.GetArrayRequested() {
+			respBody = orderBatchResponse([]byte(c.GetReqBodyString()), respBody)
+		}
+
+		// Upstreams behind a CDN may return an HTML error page with 200. Treat it as a node failure
+		if err == nil && len(respBody) != 0 && !isJSONBody(respBody) {
+			err = fmt.Errorf("%w from %s (status %d)", ErrNonJSONResponse, target.url, statusCode)
+			respBody = nil
+		}
+
+		// Truncated or malformed DAS responses are retried when validation is enabled
+		if isDASMethod && t.dasResponseValidation && err == nil && len(respBody) > 0 {
+			if validationErr := validateDASResponse(respBody, methods); validationErr != nil {
+				err = fmt.Errorf("%w from %s", validationErr, target.url)
+				respBody = nil
+			}
+		}
 
 		// For DAS methods, skip response analysis and return immediately if we have a response
 		if isDASMethod && err == nil && len(respBody) > 0 {
 			// Still update metrics but assume everything is healthy
-			t.updateMetricsAndStats(c, target, methods, false, true, responseTime, 0)
+			t.updateMetricsAndStats(c, target, methods, statusCode, false, true, responseTime, 0)
+			t.setStickyTarget(primaryMethod, selector, target, targetIndex)
+			t.staleCache.store(c, methods, respBody)
 
 			attempts++ // Count this successful attempt
-			return respBody, statusCode, attempts, nil
+			return t.responseNormalizer.normalize(c, methods, respBody), statusCode, attempts, nil
+		}
+
+		// A node behind the min context slot of the request is healthy, the request is retried on a more advanced one
+		if contextSlot, ok := minContextSlotNotReached(respBody); ok && err == nil && t.minContextSlotRetries > 0 {
+			t.updateMetricsAndStats(c, target, methods, statusCode, false, true, responseTime, 0)
+			minContextSlotResp = respBody
+			if minContextSlotRetries >= t.minContextSlotRetries {
+				attempts++
+				break
+			}
+			minContextSlotRetries++
+			excludedTargets.Add(targetIndex)
+			t.excludeTargetsBehind(c, primaryMethod, selector, excludedTargets, target, contextSlot)
+			continue
 		}
 
 		// Process response and determine if retry is needed
 		shouldRetry, isHealthy, firstSlotOnNode := t.processResponse(c, target, reqCtx, respBody, err)
 
 		// Update metrics and stats
-		t.updateMetricsAndStats(c, target, methods, shouldRetry, isHealthy, responseTime, firstSlotOnNode)
+		t.updateMetricsAndStats(c, target, methods, statusCode, shouldRetry, isHealthy, responseTime, firstSlotOnNode)
 
 		if !shouldRetry {
+			if isHealthy {
+				t.setStickyTarget(primaryMethod, selector, target, targetIndex)
+			}
+			if streamer == nil && err == nil {
+				if isHealthy {
+					t.staleCache.store(c, methods, respBody)
+				}
+				respBody = t.annotateSlot(c, methods, target, respBody)
+				respBody = t.responseNormalizer.normalize(c, methods, respBody)
+			}
 			attempts++ // Count successful attempt
 			return respBody, statusCode, attempts, err
 		}
 
+		if partialResults != nil && err == nil && len(respBody) != 0 && respBody[0] == '[' {
+			partialResults.add(respBody)
+		}
+
 		// Mark this target as excluded for next attempts
-		excludedTargets = append(excludedTargets, targetIndex)
+		t.dropStickyTarget(primaryMethod, target)
+		excludedTargets.Add(targetIndex)
+		failedProviders = appendProvider(failedProviders, target.provider)
+		if t.excludeRateLimitedProviders && statusCode == http.StatusTooManyRequests && target.provider != "" && !slices.Contains(excludedProviders, target.provider) {
+			excludedProviders = append(excludedProviders, target.provider) // provider-wide rate limit
+		}
+	}
+
+	if minContextSlotResp != nil && reqCtx.Err() == nil {
+		c.SetRPCErrors([]int{solana.MinContextSlotNotReachedErrCode})
+		return minContextSlotResp, http.StatusOK, attempts, nil
+	}
+
+	// Last resort: partner targets are exhausted
+	if t.canUsePublicFallback(c, methods) && reqCtx.Err() == nil {
+		fallbackBody, fallbackStatusCode, fallbackErr := t.sendToPublicFallback(c)
+		attempts++
+		if fallbackErr == nil {
+			return fallbackBody, fallbackStatusCode, attempts, nil
+		}
+	}
+
+	if reqCtx.Err() == nil {
+		t.logFailedProviders(c, failedProviders)
+	}
+	if partialResults != nil && partialResults.hasSucceeded() && reqCtx.Err() == nil {
+		return partialResults.build(c), http.StatusOK, attempts, nil
+	}
+	if reqCtx.Err() == nil {
+		if stale, ok := t.staleCache.serve(c, methods); ok {
+			return stale, http.StatusOK, attempts, nil
+		}
 	}
 
 	// Handle case with no valid response
@@ -197,11 +547,281 @@ func (t *UnifiedTransport) executeWithRetries(c *echoUtil.CustomContext) (respBo
 	return respBody, statusCode, attempts, err
 }
 
+// noMethodsError rejects requests without methods, the same way in the adapter and the transport
+func noMethodsError(c *echoUtil.CustomContext) (int, error) {
+	c.SetRPCErrors([]int{util.ErrNoMethodsRequested.Error.Code})
+	c.SetProxyUserError(true)
+
+	return http.StatusBadRequest, echo.NewHTTPError(http.StatusBadRequest, util.ErrNoMethodsRequested)
+}
+
+// pinnedProviderError rejects requests pinned to a provider without targets able to serve the method
+func pinnedProviderError(c *echoUtil.CustomContext) (int, error) {
+	c.SetRPCErrors([]int{util.ErrPinnedProviderUnavailable.Error.Code})
+	c.SetProxyUserError(true)
+
+	return http.StatusBadRequest, echo.NewHTTPError(http.StatusBadRequest, util.ErrPinnedProviderUnavailable)
+}
+
+// appendProvider adds a named provider once
+func appendProvider(providers []string, provider string) []string {
+	if provider == "" || slices.Contains(providers, provider) {
+		return providers
+	}
+
+	return append(providers, provider)
+}
+
+// logFailedProviders reports the providers of a request which exhausted all targets.
+// At most failedProvidersLogLimit names are logged, the full count goes to the metric
+func (t *UnifiedTransport) logFailedProviders(c *echoUtil.CustomContext, providers []string) {
+	if len(providers) == 0 {
+		return
+	}
+
+	metrics.AddFailedRequestProviders(c.GetChainName(), len(providers))
+	log.Logger.Proxy.Warnf("all targets failed (id %s), providers: %s", c.GetReqID(), formatProviders(providers, t.failedProvidersLogLimit))
+}
+
+// formatProviders joins up to limit provider names, the rest is summarized with a "+N more" suffix
+func formatProviders(providers []string, limit int) string {
+	if len(providers) <= limit {
+		return strings.Join(providers, ", ")
+	}
+
+	more := fmt.Sprintf("+%d more", len(providers)-limit)
+	if limit <= 0 {
+		return more
+	}
+
+	return strings.Join(providers[:limit], ", ") + " " + more
+}
+
+// getStreamer returns the streaming requester if the request is a single streamed method, nil otherwise
+func (t *UnifiedTransport) getStreamer(c *echoUtil.CustomContext) StreamingHTTPRequester {
+	methods := c.GetReqMethods()
+	if len(t.streamedMethods) == 0 || c.GetArrayRequested() || len(methods) != 1 {
+		return nil
+	}
+	if _, ok := t.streamedMethods[methods[0]]; !ok {
+		return nil
+	}
+	streamer, _ := t.httpRequester.(StreamingHTTPRequester)
+
+	return streamer
+}
+
+// streamFromTarget copies the target response to the client skipping the response analysis. The request must be
+// started on the target. Not committed responses (transport errors, bad status codes, non-JSON bodies) can be retried
+// on another target. Committed responses are recorded by the status and the head of the body: heads holding the whole
+// body are analyzed as buffered responses, so a target streaming RPC errors is jailed
+func (t *UnifiedTransport) streamFromTarget(c *echoUtil.CustomContext, streamer StreamingHTTPRequester, target *ProxyTarget, methods []string, attempts int, reqStartTime time.Time) (committed bool, statusCode int, err error) {
+	startTime := time.Now()
+	defer target.finishRequest()
+	isHealthy := true
+	statusCode, err = streamer.StreamRequest(c, target.url, func(head []byte) error {
+		if head[0] != '{' && head[0] != '[' {
+			return fmt.Errorf("%w from %s", ErrNonJSONResponse, target.url)
+		}
+		if len(head) < transport.StreamHeadSize {
+			_, isHealthy, _ = t.processResponse(c, target, c.Request().Context(), head, nil)
+		}
+		// service headers are written with the response, so they must be known before
+		c.SetProxyAttempts(attempts + 1)
+		c.SetProxyResponseTime(time.Since(reqStartTime).Milliseconds())
+		return nil
+	})
+	responseTime := time.Since(startTime).Milliseconds()
+
+	// a copy error after the commit is most likely the client gone, the target already answered
+	if c.Response().Committed {
+		t.updateMetricsAndStats(c, target, methods, statusCode, !isHealthy, isHealthy, responseTime, 0)
+		return true, statusCode, err
+	}
+
+	shouldRetry, isHealthy, _ := t.processResponse(c, target, c.Request().Context(), nil, err)
+	t.updateMetricsAndStats(c, target, methods, statusCode, shouldRetry, isHealthy, responseTime, 0)
+
+	return false, statusCode, err
+}
+
+// IsStreamed reports whether the response of the request is copied to the client by the transport
+func (t *UnifiedTransport) IsStreamed(c *echoUtil.CustomContext) bool {
+	return t.getStreamer(c) != nil
+}
+
+// canUsePublicFallback reports whether the request may be served by the public fallback.
+// DAS methods, dedicated GPA pool requests and requests pinned to a provider are served by partner nodes only
+func (t *UnifiedTransport) canUsePublicFallback(c *echoUtil.CustomContext, methods []string) bool {
+	if t.publicFallbackURL == "" || c.GetIsGPARequest() || c.GetPinnedProvider() != "" {
+		return false
+	}
+	for _, method := range methods {
+		if _, ok := solana.CNFTMethodList[method]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sendToPublicFallback sends the request to the public RPC. Only responses without node errors are accepted
+func (t *UnifiedTransport) sendToPublicFallback(c *echoUtil.CustomContext) (respBody []byte, statusCode int, err error) {
+	c.SetProvider(PublicFallbackProvider)
+
+	respBody, statusCode, err = t.httpRequester.DoRequest(c, t.publicFallbackURL)
+	if err == nil && c.GetArrayRequested() {
+		respBody = orderBatchResponse([]byte(c.GetReqBodyString()), respBody)
+	}
+	if err == nil {
+		_, isUserError, analyzeErr, responseErr := rpcErrorAnalysis(decodeNodeResponse(c, respBody))
+		switch {
+		case isUserError:
+			c.SetProxyUserError(true)
+		case responseErr != nil:
+			err = responseErr
+		case analyzeErr != nil:
+			err = analyzeErr
+		}
+	}
+	metrics.IncPublicFallbackUsage(c.GetChainName(), err == nil)
+	if err != nil {
+		log.Logger.Proxy.Errorf("public fallback request failed (id %s): %s", c.GetReqID(), err)
+	}
+
+	return respBody, statusCode, err
+}
+
+// getNextTarget maps the request key (account, signature, asset id etc.) to a target when supported by the selector,
+// otherwise draws from the per-request RNG when provided and supported by the selector
+func getNextTarget(selector balancer.TargetSelector[*ProxyTarget], rng *rand.Rand, key string, exclude *balancer.Exclusions) (*ProxyTarget, int, error) {
+	if keyed, ok := selector.(balancer.KeyedTargetSelector[*ProxyTarget]); ok && key != "" {
+		return keyed.GetNextForKey(key, exclude.Indices())
+	}
+	if rng != nil {
+		if seeded, ok := selector.(balancer.SeededTargetSelector[*ProxyTarget]); ok {
+			return seeded.GetNextWithRand(rng, exclude.Indices())
+		}
+	}
+	if excluding, ok := selector.(balancer.ExclusionsTargetSelector[*ProxyTarget]); ok {
+		return excluding.GetNextExcluding(exclude)
+	}
+
+	return selector.GetNext(exclude.Indices())
+}
+
+// getNextExcludingProviders selects the next target like getNextTarget, skipping all targets of the providers at once
+func (t *UnifiedTransport) getNextExcludingProviders(method string, selector balancer.TargetSelector[*ProxyTarget], rng *rand.Rand, key string,
+	exclude *balancer.Exclusions, providers []string) (*ProxyTarget, int, error) {
+	if excluder, ok := t.methodRouter.(providerExcluder); ok && len(providers) != 0 {
+		excluder.ExcludeProviders(method, selector, exclude, providers)
+	}
+
+	return getNextTarget(selector, rng, key, exclude)
+}
+
+// getStickyTarget returns the last successful target of the primary method for the first attempt, unless it's excluded
+// or can't serve all methods of the request (request limit, support of the methods, jail and block history).
+// Seeded and keyed selections keep their own target sequence
+func (t *UnifiedTransport) getStickyTarget(c *echoUtil.CustomContext, methods []string, selector balancer.TargetSelector[*ProxyTarget], rng *rand.Rand,
+	exclude *balancer.Exclusions, attempt int) (*ProxyTarget, int, bool) {
+	if !t.stickyTargets || attempt != 0 || rng != nil {
+		return nil, 0, false
+	}
+	if _, ok := selector.(balancer.KeyedTargetSelector[*ProxyTarget]); ok && c.GetStatsAdditionalData() != "" {
+		return nil, 0, false
+	}
+
+	t.lastTargetsMx.RLock()
+	last, ok := t.lastTargets[methods[0]]
+	t.lastTargetsMx.RUnlock()
+	// the balancer is replaced on config reload, so indices of the previous one are meaningless
+	if !ok || last.selector != selector || exclude.Contains(last.index) {
+		return nil, 0, false
+	}
+	if available, _, _ := last.target.isAvailable(methods, c.GetTokenType(), t.currentSlot, t.getSlotTime, c); !available {
+		return nil, 0, false
+	}
+
+	return last.target, last.index, true
+}
+
+// getPreferredTarget selects a target of the preferred provider of the request for the first attempt, unless all its
+// targets are excluded or jailed. The request falls back to other providers after it fails
+func (t *UnifiedTransport) getPreferredTarget(c *echoUtil.CustomContext, method string, selector balancer.TargetSelector[*ProxyTarget], rng *rand.Rand,
+	exclude *balancer.Exclusions, attempt int) (*ProxyTarget, int, bool) {
+	provider := c.GetPreferredProvider()
+	if provider == "" || attempt != 0 {
+		return nil, 0, false
+	}
+	pinner, ok := t.methodRouter.(providerPinner)
+	if !ok {
+		return nil, 0, false
+	}
+
+	preferred := balancer.NewExclusions(selector.GetTargetsCount())
+	for _, index := range exclude.Indices() {
+		preferred.Add(index)
+	}
+	if !pinner.ExcludeOtherProviders(method, selector, preferred, provider) {
+		return nil, 0, false
+	}
+	target, index, err := getNextTarget(selector, rng, c.GetStatsAdditionalData(), preferred)
+	if err != nil || target == nil || target.provider != provider || target.isJailed(method, time.Now().Unix()) {
+		return nil, 0, false
+	}
+
+	return target, index, true
+}
+
+func (t *UnifiedTransport) setStickyTarget(method string, selector balancer.TargetSelector[*ProxyTarget], target *ProxyTarget, index int) {
+	if !t.stickyTargets {
+		return
+	}
+
+	t.lastTargetsMx.Lock()
+	defer t.lastTargetsMx.Unlock()
+
+	if t.lastTargets == nil {
+		t.lastTargets = make(map[string]stickyTarget)
+	}
+	t.lastTargets[method] = stickyTarget{selector: selector, target: target, index: index}
+}
+
+// dropStickyTarget forgets the failed target, so the next request of the method uses the balancer
+func (t *UnifiedTransport) dropStickyTarget(method string, target *ProxyTarget) {
+	if !t.stickyTargets {
+		return
+	}
+
+	t.lastTargetsMx.Lock()
+	defer t.lastTargetsMx.Unlock()
+
+	if last, ok := t.lastTargets[method]; ok && last.target == target {
+		delete(t.lastTargets, method)
+	}
+}
+
+// getBalancer selects the dedicated GPA pool for getProgramAccounts requests, if configured. GPA requests don't fall
+// back to the method balancer when the pool is unavailable, to keep heavy scans off latency-sensitive targets
+func (t *UnifiedTransport) getBalancer(c *echoUtil.CustomContext, method string) (balancer.TargetSelector[*ProxyTarget], bool) {
+	if c.GetIsGPARequest() {
+		if gpaBalancer, ok := t.methodRouter.GetGPABalancer(); ok {
+			return gpaBalancer, true
+		}
+	}
+
+	return t.methodRouter.GetBalancerForMethod(method)
+}
+
 // processResponse analyzes response and determines if retry is needed
 func (t *UnifiedTransport) processResponse(c *echoUtil.CustomContext, target *ProxyTarget, reqCtx context.Context, respBody []byte, err error) (shouldRetry bool, isHealthy bool, firstSlotOnNode int64) {
 	// Check for HTTP/transport errors
 	if err != nil {
-		isSilent, isHealthy := isMutedErr(err, reqCtx.Err())
+		isSilent, isHealthy := transport.IsMutedErr(err, reqCtx.Err())
+		if transport.IsDeadlineErr(err, reqCtx.Err()) {
+			metrics.IncMethodTimeouts(c.GetChainName(), c.GetReqMethods()[0])
+		}
 		if !isSilent {
 			log.Logger.Proxy.Errorf("HTTP request failed (id %s): %s", c.GetReqID(), err)
 		}
@@ -209,7 +829,11 @@ func (t *UnifiedTransport) processResponse(c *echoUtil.CustomContext, target *Pr
 	}
 
 	// Analyze response for RPC errors
-	firstSlotOnNode, isUserError, analyzeErr, responseErr := rpcErrorAnalysis(decodeNodeResponse(c, respBody))
+	errs := decodeNodeResponse(c, respBody)
+	if isNodeBehind(errs) {
+		t.jailBehindTarget(target, c.GetReqMethods())
+	}
+	firstSlotOnNode, isUserError, analyzeErr, responseErr := rpcErrorAnalysis(errs)
 
 	if responseErr != nil {
 		log.Logger.Proxy.Errorf("RPC error (id %s) (%s): %s", c.GetReqID(), target.url, responseErr)
@@ -224,18 +848,60 @@ func (t *UnifiedTransport) processResponse(c *echoUtil.CustomContext, target *Pr
 		return true, false, firstSlotOnNode
 	}
 
-	// Success case
+	// Success case. Only processed commitment responses carry the tip slot of the node
+	if c.GetReqCommitment() == solana.CommitmentProcessed {
+		if slot, err := jsonparser.GetInt(respBody, "result", "context", "slot"); err == nil && slot > 0 {
+			target.observeSlot(slot, time.Now())
+		}
+	}
 	return false, true, firstSlotOnNode
 }
 
+// jailBehindTarget jails a catching up target for the methods of the node behind policy for behindJailTime,
+// without escalating the jail of methods which didn't fail. The request methods are skipped,
+// updateMetricsAndStats jails them as for any failed response
+func (t *UnifiedTransport) jailBehindTarget(target *ProxyTarget, reqMethods []string) {
+	var methods []string
+	for method := range solana.MethodList {
+		if slices.Contains(reqMethods, method) {
+			continue
+		}
+		if t.nodeBehindPolicy == configtypes.NodeBehindPolicyFull || solana.SlotSensitiveMethod(method) {
+			methods = append(methods, method)
+		}
+	}
+
+	log.Logger.Proxy.Warnf("target %s is behind, jailing %d methods (%s policy)", target.url, len(methods), t.nodeBehindPolicy)
+	if len(methods) == 0 {
+		return
+	}
+	if jailer, ok := t.methodRouter.(targetJailer); ok {
+		jailer.JailTargetFor(target, methods, behindJailTime)
+		return
+	}
+	target.jailFor(methods, behindJailTime)
+}
+
 // updateMetricsAndStats updates metrics and performance statistics for a request
-func (t *UnifiedTransport) updateMetricsAndStats(c *echoUtil.CustomContext, target *ProxyTarget, methods []string, shouldRetry bool, isHealthy bool, responseTime int64, firstSlotOnNode int64) {
+func (t *UnifiedTransport) updateMetricsAndStats(c *echoUtil.CustomContext, target *ProxyTarget, methods []string, statusCode int, shouldRetry bool, isHealthy bool, responseTime int64, firstSlotOnNode int64) {
+	if target == nil {
+		return // no target was selected
+	}
+
 	// Update metrics for partner node
 	if target.provider != "" {
 		metrics.IncPartnerNodeUsage(target.provider, !shouldRetry)
 		c.ReachPartnerNode()
 	}
 
+	// Statuses with a fixed jail time (e.g. gateway errors in front of a healthy node) skip the escalating jail
+	if jailTime, ok := t.statusJailTimes[statusCode]; ok && shouldRetry && !isHealthy {
+		if jailer, ok := t.methodRouter.(targetJailer); ok {
+			jailer.JailTargetFor(target, methods, jailTime)
+			return
+		}
+	}
+
 	// Update target stats
 	if firstSlotOnNode != 0 {
 		// slotAmount calculation if available
@@ -263,24 +929,3 @@ func (t *UnifiedTransport) handleEmptyResponse(c *echoUtil.CustomContext, reqCtx
 func calculateSlot(mainnetSlot int64, getSlotTime time.Time, slot int64) int64 {
 	return mainnetSlot + int64(time.Since(getSlotTime).Seconds()*slotsPerSec) - slot
 }
-
-func isMutedErr(err, contextErr error) (mute, isAvailable bool) {
-	if errors.Is(err, util.ErrBadStatusCode) {
-		return true, false
-	}
-	errS := err.Error()
-	if errS == "EOF" || strings.Contains(errS, "i/o timeout") || strings.Contains(errS, "reset by peer") ||
-		strings.Contains(errS, "connection refused") || strings.Contains(errS, "no route to host") {
-		return true, false
-	}
-
-	// possible cases when the node is not guilty:
-	// - context.DeadlineExceeded - node response timeout. Slow node or multiple attempts are passed
-	// - context.Canceled - user cancelled request
-	if err == context.DeadlineExceeded || err == context.Canceled || contextErr == context.DeadlineExceeded || contextErr == context.Canceled || //nolint:errorlint
-		strings.Contains(errS, "deadline exceeded") || strings.Contains(errS, "canceled") {
-		return true, true
-	}
-
-	return false, false
-}
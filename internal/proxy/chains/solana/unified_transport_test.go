@@ -3,19 +3,40 @@ package solana
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/transport"
+	"aura-proxy/internal/pkg/util"
 	"aura-proxy/internal/pkg/util/balancer"
 	echoUtil "aura-proxy/internal/pkg/util/echo"
 )
 
+// upstream errors as returned by transport.MakeHTTPRequest
+var (
+	errConnRefused   = fmt.Errorf("do: %w: dial tcp: connect: connection refused", transport.ErrUpstreamConnRefused)
+	errClientTimeout = fmt.Errorf("do: Post \"http://node\": %w (Client.Timeout exceeded while awaiting headers)", context.DeadlineExceeded)
+)
+
 // Helper function to properly initialize the CustomContext with metrics
 // to avoid the nil pointer dereference in decodeNodeResponse
 func createTestCustomContext(request *http.Request, response http.ResponseWriter, methods []string, body []byte) *echoUtil.CustomContext {
@@ -105,8 +126,8 @@ func TestUnifiedTransport_SendRequest(t *testing.T) {
 
 	mockRequester = &MockHTTPRequesterWrapper{
 		Responses: []HTTPResponseWrapper{
-			{RespBody: nil, StatusCode: 0, Error: errors.New("connection refused")}, // Simulate a failure
-			{RespBody: validResponseBytes, StatusCode: http.StatusOK, Error: nil},   // Successful retry
+			{RespBody: nil, StatusCode: 0, Error: errConnRefused},                 // Simulate a failure
+			{RespBody: validResponseBytes, StatusCode: http.StatusOK, Error: nil}, // Successful retry
 		},
 	}
 
@@ -151,8 +172,8 @@ func TestUnifiedTransport_SendRequest(t *testing.T) {
 
 	mockRequester = &MockHTTPRequesterWrapper{
 		Responses: []HTTPResponseWrapper{
-			{RespBody: nil, StatusCode: 0, Error: errors.New("connection refused")},
-			{RespBody: nil, StatusCode: 0, Error: errors.New("connection refused")},
+			{RespBody: nil, StatusCode: 0, Error: errConnRefused},
+			{RespBody: nil, StatusCode: 0, Error: errConnRefused},
 		},
 	}
 
@@ -333,6 +354,7 @@ func TestUnifiedTransport_ContextCancellation(t *testing.T) {
 type MockHTTPRequesterWrapper struct {
 	Responses []HTTPResponseWrapper
 	CallCount int
+	URLs      []string // Requested target URLs in call order
 }
 
 type HTTPResponseWrapper struct {
@@ -342,6 +364,7 @@ type HTTPResponseWrapper struct {
 }
 
 func (m *MockHTTPRequesterWrapper) DoRequest(c *echoUtil.CustomContext, targetURL string) ([]byte, int, error) {
+	m.URLs = append(m.URLs, targetURL)
 	if m.CallCount >= len(m.Responses) {
 		return nil, 0, errors.New("no more mock responses")
 	}
@@ -367,6 +390,7 @@ func (c *ContextAwareHTTPRequester) DoRequest(ctx *echoUtil.CustomContext, targe
 // MethodRouterWrapper wraps a MockTargetSelector as a MethodRouter
 type MethodRouterWrapper struct {
 	mockSelector      *MockTargetSelector
+	gpaSelector       *MockTargetSelector
 	isAvailableFn     func() bool
 	updateStatsCalled bool
 }
@@ -375,10 +399,21 @@ func (m *MethodRouterWrapper) GetBalancerForMethod(method string) (balancer.Targ
 	return m.mockSelector, true
 }
 
+func (m *MethodRouterWrapper) GetGPABalancer() (balancer.TargetSelector[*ProxyTarget], bool) {
+	if m.gpaSelector == nil {
+		return nil, false
+	}
+	return m.gpaSelector, true
+}
+
 func (m *MethodRouterWrapper) IsMethodSupported(method string) bool {
 	return true
 }
 
+func (m *MethodRouterWrapper) CanServeMethod(method string) bool {
+	return true
+}
+
 func (m *MethodRouterWrapper) IsAvailable() bool {
 	if m.isAvailableFn != nil {
 		return m.isAvailableFn()
@@ -389,3 +424,1270 @@ func (m *MethodRouterWrapper) IsAvailable() bool {
 func (m *MethodRouterWrapper) UpdateTargetStats(target *ProxyTarget, success bool, methods []string, responseTime, slotAmount int64) {
 	m.updateStatsCalled = true
 }
+
+// TestUnifiedTransport_GPAPool tests that getProgramAccounts requests are routed to the dedicated pool
+func TestUnifiedTransport_GPAPool(t *testing.T) {
+	requestJSON := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "getProgramAccounts",
+		"id":      1,
+	}
+	requestBytes, _ := json.Marshal(requestJSON)
+
+	validResponseJSON := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  []interface{}{},
+		"id":      1,
+	}
+	validResponseBytes, _ := json.Marshal(validResponseJSON)
+
+	newContext := func(isGPA bool) *echoUtil.CustomContext {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getProgramAccounts"}, requestBytes)
+		c.SetIsGPARequest(isGPA)
+		return c
+	}
+	newSelector := func(url string) *MockTargetSelector {
+		return &MockTargetSelector{
+			NextResponses: []NextResponse{
+				{Target: &ProxyTarget{url: url}, Index: 0, Error: nil},
+			},
+			TargetsCount:  1,
+			IsAvailableFn: func() bool { return true },
+		}
+	}
+
+	// --- GPA request hits the GPA pool ---
+	mockSelector := newSelector("general")
+	gpaSelector := newSelector("gpa")
+	mockRouter := &MethodRouterWrapper{mockSelector: mockSelector, gpaSelector: gpaSelector}
+	mockRequester := &MockHTTPRequesterWrapper{
+		Responses: []HTTPResponseWrapper{{RespBody: validResponseBytes, StatusCode: http.StatusOK}},
+	}
+
+	transport := NewUnifiedTransport("test_transport", mockRouter, mockRequester, 3, false)
+	_, _, err := transport.SendRequest(newContext(true))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gpaSelector.CallCount != 1 {
+		t.Errorf("Expected GPA pool to be called once, got %d", gpaSelector.CallCount)
+	}
+	if mockSelector.CallCount != 0 {
+		t.Errorf("Expected general pool not to be called, got %d", mockSelector.CallCount)
+	}
+
+	// --- GPA request doesn't fall back to the method pool when the GPA pool is unavailable ---
+	mockSelector = newSelector("general")
+	gpaSelector = newSelector("gpa")
+	gpaSelector.IsAvailableFn = func() bool { return false }
+	mockRouter = &MethodRouterWrapper{mockSelector: mockSelector, gpaSelector: gpaSelector}
+
+	transport = NewUnifiedTransport("test_transport", mockRouter, &MockHTTPRequesterWrapper{}, 3, false)
+	_, statusCode, err := transport.SendRequest(newContext(true))
+	if err == nil || statusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected service unavailable, got status %d, error %v", statusCode, err)
+	}
+	if mockSelector.CallCount != 0 {
+		t.Errorf("Expected general pool not to be called, got %d", mockSelector.CallCount)
+	}
+
+	// --- GPA request falls back to the method pool when GPA pool is unconfigured ---
+	mockSelector = newSelector("general")
+	mockRouter = &MethodRouterWrapper{mockSelector: mockSelector}
+	mockRequester = &MockHTTPRequesterWrapper{
+		Responses: []HTTPResponseWrapper{{RespBody: validResponseBytes, StatusCode: http.StatusOK}},
+	}
+
+	transport = NewUnifiedTransport("test_transport", mockRouter, mockRequester, 3, false)
+	_, _, err = transport.SendRequest(newContext(true))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mockSelector.CallCount != 1 {
+		t.Errorf("Expected general pool to be called once, got %d", mockSelector.CallCount)
+	}
+}
+
+// counterValue returns the value of the counter with exactly matching labels from the default registry
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			metricLabels := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				metricLabels[l.GetName()] = l.GetValue()
+			}
+			if reflect.DeepEqual(metricLabels, labels) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func publicFallbackCount(t *testing.T, chain string, success bool) float64 {
+	t.Helper()
+	return counterValue(t, "public_fallback_usage", map[string]string{"chain": chain, "success": fmt.Sprint(success)})
+}
+
+func TestUnifiedTransport_PublicFallback(t *testing.T) {
+	const (
+		chain       = "fallback_test"
+		fallbackURL = "https://public.example.com"
+	)
+	validResponseBytes, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  123,
+		"id":      1,
+	})
+
+	newContext := func(method string) *echoUtil.CustomContext {
+		requestBytes, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  method,
+			"id":      1,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{method}, requestBytes)
+		c.SetChainName(chain)
+		return c
+	}
+	newSelector := func() *MockTargetSelector {
+		return &MockTargetSelector{
+			NextResponses: []NextResponse{
+				{Target: &ProxyTarget{url: "partner1", provider: "p1"}, Index: 0},
+				{Target: &ProxyTarget{url: "partner2", provider: "p2"}, Index: 1},
+				{Error: errors.New("no more targets")},
+			},
+			TargetsCount:  2,
+			IsAvailableFn: func() bool { return true },
+		}
+	}
+	// both partners fail, then the fallback answers
+	newRequester := func() *MockHTTPRequesterWrapper {
+		return &MockHTTPRequesterWrapper{
+			Responses: []HTTPResponseWrapper{
+				{Error: errConnRefused},
+				{Error: errConnRefused},
+				{RespBody: validResponseBytes, StatusCode: http.StatusOK},
+			},
+		}
+	}
+
+	// --- Partners exhausted, public fallback serves the request ---
+	before := publicFallbackCount(t, chain, true)
+	mockRequester := newRequester()
+	transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: newSelector()}, mockRequester, 5, false)
+	transport.publicFallbackURL = fallbackURL
+
+	c := newContext("getSlot")
+	resp, statusCode, err := transport.SendRequest(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if statusCode != http.StatusOK || !bytes.Equal(resp, validResponseBytes) {
+		t.Errorf("Expected fallback response, got %d %s", statusCode, resp)
+	}
+	if len(mockRequester.URLs) != 3 || mockRequester.URLs[2] != fallbackURL {
+		t.Errorf("Expected partners then fallback to be requested, got %v", mockRequester.URLs)
+	}
+	if c.GetProvider() != PublicFallbackProvider {
+		t.Errorf("Expected provider %s, got %s", PublicFallbackProvider, c.GetProvider())
+	}
+	if c.GetProxyAttempts() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", c.GetProxyAttempts())
+	}
+	if got := publicFallbackCount(t, chain, true); got != before+1 {
+		t.Errorf("Expected public fallback usage metric %v, got %v", before+1, got)
+	}
+
+	// --- DAS methods are never sent to the public fallback ---
+	mockRequester = newRequester()
+	transport = NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: newSelector()}, mockRequester, 5, false)
+	transport.publicFallbackURL = fallbackURL
+
+	_, _, err = transport.SendRequest(newContext("getAsset"))
+	if err == nil {
+		t.Error("Expected error when partners are exhausted for DAS method")
+	}
+	for _, u := range mockRequester.URLs {
+		if u == fallbackURL {
+			t.Error("Expected DAS method not to use the public fallback")
+		}
+	}
+
+	// --- Fallback disabled keeps the previous behavior ---
+	mockRequester = newRequester()
+	transport = NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: newSelector()}, mockRequester, 5, false)
+
+	_, _, err = transport.SendRequest(newContext("getSlot"))
+	if err == nil {
+		t.Error("Expected error when partners are exhausted and fallback is disabled")
+	}
+	if mockRequester.CallCount != 2 {
+		t.Errorf("Expected only partner requests, got %d", mockRequester.CallCount)
+	}
+}
+
+// TestUnifiedTransport_NonJSONResponse tests that an HTML error page benches the target and the request is retried
+func TestUnifiedTransport_NonJSONResponse(t *testing.T) {
+	htmlBody := []byte("<html><body><h1>502 Bad Gateway</h1></body></html>")
+	validResponseBytes, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  map[string]interface{}{},
+		"id":      1,
+	})
+
+	for _, method := range []string{"getSlot", "getAsset"} { // getAsset goes through the DAS fast path
+		t.Run(method, func(t *testing.T) {
+			requestBytes, _ := json.Marshal(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  method,
+				"id":      1,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{method}, requestBytes)
+
+			htmlTarget := &ProxyTarget{url: "html_target"}
+			validTarget := &ProxyTarget{url: "valid_target"}
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{
+					{Target: htmlTarget, Index: 0},
+					{Target: validTarget, Index: 1},
+				},
+				TargetsCount:  2,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{
+				Responses: []HTTPResponseWrapper{
+					{RespBody: htmlBody, StatusCode: http.StatusOK},
+					{RespBody: validResponseBytes, StatusCode: http.StatusOK},
+				},
+			}
+
+			transport := NewUnifiedTransport("test_transport", mockSelector, mockRequester, 3, false)
+			resp, _, err := transport.SendRequest(c)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(resp, validResponseBytes) {
+				t.Errorf("Expected response from the retried target, got %s", resp)
+			}
+			if mockRequester.CallCount != 2 {
+				t.Errorf("Expected 2 requests, got %d", mockRequester.CallCount)
+			}
+			if len(mockSelector.UpdateStatsArgs) != 2 {
+				t.Fatalf("Expected 2 stats updates, got %d", len(mockSelector.UpdateStatsArgs))
+			}
+			if stats := mockSelector.UpdateStatsArgs[0]; stats.Target != htmlTarget || stats.Success {
+				t.Errorf("Expected HTML target to be marked as failed, got %+v", stats)
+			}
+			if stats := mockSelector.UpdateStatsArgs[1]; stats.Target != validTarget || !stats.Success {
+				t.Errorf("Expected valid target to be marked as healthy, got %+v", stats)
+			}
+		})
+	}
+}
+
+// hashingResponseWriter keeps only the hash of the written body and the largest write, so the body isn't buffered
+// by the test itself. written is closed on the first write
+type hashingResponseWriter struct {
+	header   http.Header
+	status   int
+	hash     hash.Hash
+	size     int
+	maxWrite int
+	written  chan struct{}
+}
+
+func newHashingResponseWriter() *hashingResponseWriter {
+	return &hashingResponseWriter{header: make(http.Header), hash: sha256.New(), written: make(chan struct{})}
+}
+
+func (w *hashingResponseWriter) Header() http.Header { return w.header }
+func (w *hashingResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+func (w *hashingResponseWriter) Write(b []byte) (int, error) {
+	if w.size == 0 {
+		close(w.written)
+	}
+	w.size += len(b)
+	w.maxWrite = max(w.maxWrite, len(b))
+	return w.hash.Write(b)
+}
+
+// TestUnifiedTransport_Streaming tests that streamed responses match buffered ones without buffering the body
+func TestUnifiedTransport_Streaming(t *testing.T) {
+	const (
+		method      = "getBlock"
+		chunkSize   = 64 * 1024
+		chunksCount = 64 // 4MB
+	)
+	chunk := bytes.Repeat([]byte("a"), chunkSize)
+	w := newHashingResponseWriter()
+	var htmlRequests atomic.Int32
+	var streamedBeforeEnd atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			htmlRequests.Add(1)
+			_, _ = rw.Write([]byte("<html>502 Bad Gateway</html>"))
+			return
+		case "/error":
+			_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":1}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","result":"`))
+		for i := 0; i < chunksCount; i++ {
+			_, _ = rw.Write(chunk)
+			// the rest of the body is held until the client got the first part, which a buffered copy never does
+			if i == chunksCount/2 && r.URL.Path == "/stream" {
+				rw.(http.Flusher).Flush()
+				select {
+				case <-w.written:
+					streamedBeforeEnd.Store(true)
+				case <-time.After(5 * time.Second):
+				}
+			}
+		}
+		_, _ = rw.Write([]byte(`","id":1}`))
+	}))
+	defer server.Close()
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"id":      1,
+	})
+	newContext := func(w http.ResponseWriter) *echoUtil.CustomContext {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		return createTestCustomContext(req, w, []string{method}, requestBytes)
+	}
+
+	// --- Buffered ---
+	buffered, _, err := (&RealHTTPRequester{}).DoRequest(newContext(httptest.NewRecorder()), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bufferedHash := sha256.Sum256(buffered)
+
+	// --- Streamed, the first target returns an HTML page and is retried ---
+	mockSelector := &MockTargetSelector{
+		NextResponses: []NextResponse{
+			{Target: &ProxyTarget{url: server.URL + "/html"}, Index: 0},
+			{Target: &ProxyTarget{url: server.URL + "/stream"}, Index: 1},
+		},
+		TargetsCount:  2,
+		IsAvailableFn: func() bool { return true },
+	}
+	transport := NewUnifiedTransport("test_transport", mockSelector, &RealHTTPRequester{}, 3, false)
+	transport.streamedMethods = map[string]struct{}{method: {}}
+
+	c := newContext(w)
+	resp, statusCode, err := transport.SendRequest(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp != nil {
+		t.Errorf("Expected body to be written to the client directly, got %d bytes", len(resp))
+	}
+	if statusCode != http.StatusOK || w.status != http.StatusOK {
+		t.Errorf("Expected status 200, got %d (written %d)", statusCode, w.status)
+	}
+	if htmlRequests.Load() != 1 || c.GetProxyAttempts() != 2 {
+		t.Errorf("Expected HTML target to be retried, got %d HTML requests and %d attempts", htmlRequests.Load(), c.GetProxyAttempts())
+	}
+	if !bytes.Equal(w.hash.Sum(nil), bufferedHash[:]) {
+		t.Error("Streamed body differs from the buffered one")
+	}
+	if mockSelector.UpdateStatsCallCount != 2 || mockSelector.UpdateStatsArgs[0].Success || !mockSelector.UpdateStatsArgs[1].Success {
+		t.Errorf("Expected HTML target failed and streamed target healthy, got %+v", mockSelector.UpdateStatsArgs)
+	}
+	if !streamedBeforeEnd.Load() {
+		t.Error("Expected the body to reach the client before the upstream finished it")
+	}
+	if w.maxWrite > chunkSize {
+		t.Errorf("Expected writes bounded by the copy buffer, got a %d bytes write of %d", w.maxWrite, w.size)
+	}
+
+	// --- Streamed RPC error, recorded as a failure of the target ---
+	mockSelector = &MockTargetSelector{
+		NextResponses: []NextResponse{{Target: &ProxyTarget{url: server.URL + "/error"}, Index: 0}},
+		TargetsCount:  1,
+		IsAvailableFn: func() bool { return true },
+	}
+	transport = NewUnifiedTransport("test_transport", mockSelector, &RealHTTPRequester{}, 3, false)
+	transport.streamedMethods = map[string]struct{}{method: {}}
+
+	rec := httptest.NewRecorder()
+	if _, _, err = transport.SendRequest(newContext(rec)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "Internal error") {
+		t.Errorf("Expected the RPC error to be streamed, got %s", rec.Body.String())
+	}
+	if mockSelector.UpdateStatsCallCount != 1 || mockSelector.UpdateStatsArgs[0].Success {
+		t.Errorf("Expected the streamed RPC error recorded as a failure, got %+v", mockSelector.UpdateStatsArgs)
+	}
+}
+
+// TestUnifiedTransport_MethodTimeouts tests that node requests exceeded the deadline are counted by primary method
+func TestUnifiedTransport_MethodTimeouts(t *testing.T) {
+	const chain = "timeout_test"
+	timeoutsCount := func(method string) float64 {
+		return counterValue(t, "method_timeouts_total", map[string]string{"chain": chain, "method": method})
+	}
+	newContext := func(ctx context.Context, methods []string) *echoUtil.CustomContext {
+		requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": methods[0], "id": 1})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes)).WithContext(ctx)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		c := createTestCustomContext(req, httptest.NewRecorder(), methods, requestBytes)
+		c.SetChainName(chain)
+		return c
+	}
+	newSelector := func() *MockTargetSelector {
+		return &MockTargetSelector{
+			NextResponses: []NextResponse{
+				{Target: &ProxyTarget{url: "target1"}, Index: 0},
+				{Target: &ProxyTarget{url: "target2"}, Index: 1},
+			},
+			TargetsCount:  2,
+			IsAvailableFn: func() bool { return true },
+		}
+	}
+
+	// --- Request deadline exceeded ---
+	blockBefore, slotBefore := timeoutsCount("getBlock"), timeoutsCount("getSlot")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	transport := NewUnifiedTransport("test_transport", newSelector(), &ContextAwareHTTPRequester{blockForever: make(chan struct{})}, 3, false)
+	_, _, err := transport.SendRequest(newContext(ctx, []string{"getBlock", "getSlot"}))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded error, got %v", err)
+	}
+	if got := timeoutsCount("getBlock"); got != blockBefore+1 {
+		t.Errorf("Expected getBlock timeouts %v, got %v", blockBefore+1, got)
+	}
+	if got := timeoutsCount("getSlot"); got != slotBefore {
+		t.Errorf("Expected only the primary method to be counted, got getSlot timeouts %v", got)
+	}
+
+	// --- HTTP client timeout on the first target, success on the second ---
+	validResponseBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "result": 1, "id": 1})
+	mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{Error: errClientTimeout},
+		{RespBody: validResponseBytes, StatusCode: http.StatusOK},
+	}}
+	transport = NewUnifiedTransport("test_transport", newSelector(), mockRequester, 3, false)
+	_, _, err = transport.SendRequest(newContext(context.Background(), []string{"getSlot"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := timeoutsCount("getSlot"); got != slotBefore+1 {
+		t.Errorf("Expected getSlot timeouts %v, got %v", slotBefore+1, got)
+	}
+
+	// --- Other errors are not counted ---
+	mockRequester = &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{
+		{Error: errConnRefused},
+		{RespBody: validResponseBytes, StatusCode: http.StatusOK},
+	}}
+	transport = NewUnifiedTransport("test_transport", newSelector(), mockRequester, 3, false)
+	_, _, err = transport.SendRequest(newContext(context.Background(), []string{"getSlot"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := timeoutsCount("getSlot"); got != slotBefore+1 {
+		t.Errorf("Expected getSlot timeouts %v, got %v", slotBefore+1, got)
+	}
+}
+
+func TestUnifiedTransport_NodeBehindPolicy(t *testing.T) {
+	behindResult := []byte(`{"jsonrpc":"2.0","result":"behind","id":1}`)
+	behindErr := []byte(`{"jsonrpc":"2.0","error":{"code":-32005,"message":"Node is behind by 42 slots","data":{"numSlotsBehind":42}},"id":1}`)
+	okResponse := []byte(`{"jsonrpc":"2.0","result":"ok","id":1}`)
+
+	tests := []struct {
+		name            string
+		policy          string
+		behindBody      []byte
+		expectJailed    []string
+		expectNotJailed []string
+	}{
+		{"behind result, slot sensitive policy", configtypes.NodeBehindPolicySlotSensitive, behindResult,
+			[]string{"getHealth", "getSlot", "getLatestBlockhash", "getBlock", "sendTransaction"}, []string{"getAccountInfo", "getAsset"}},
+		{"unhealthy error, slot sensitive policy", configtypes.NodeBehindPolicySlotSensitive, behindErr,
+			[]string{"getHealth", "getSlot", "getTransaction"}, []string{"getProgramAccounts", "getBalance"}},
+		{"empty policy is slot sensitive", "", behindResult,
+			[]string{"getHealth", "getSlot"}, []string{"getAccountInfo"}},
+		{"behind result, full policy", configtypes.NodeBehindPolicyFull, behindResult,
+			[]string{"getHealth", "getSlot", "getAccountInfo", "getAsset", "getProgramAccounts"}, nil},
+		{"unhealthy error, full policy", configtypes.NodeBehindPolicyFull, behindErr,
+			[]string{"getHealth", "getBalance", "getAccountInfo"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getHealth", "id": 1})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getHealth"}, requestBytes)
+
+			behindTarget := NewProxyTarget(models.URLWithMethods{URL: "behind_target"}, 0, "", solana.NodeType{})
+			healthyTarget := NewProxyTarget(models.URLWithMethods{URL: "healthy_target"}, 0, "", solana.NodeType{})
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{
+					{Target: behindTarget, Index: 0},
+					{Target: healthyTarget, Index: 1},
+				},
+				TargetsCount:  2,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{
+				Responses: []HTTPResponseWrapper{
+					{RespBody: tt.behindBody, StatusCode: http.StatusOK},
+					{RespBody: okResponse, StatusCode: http.StatusOK},
+				},
+			}
+
+			transport := NewUnifiedTransport("test_transport", mockSelector, mockRequester, 3, false)
+			transport.nodeBehindPolicy = tt.policy
+			resp, _, err := transport.SendRequest(c)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(resp, okResponse) {
+				t.Errorf("Expected getHealth to be answered by the healthy target, got %s", resp)
+			}
+
+			jailed := make(map[string]bool)
+			for _, stats := range mockSelector.UpdateStatsArgs {
+				switch stats.Target {
+				case behindTarget:
+					if stats.Success {
+						t.Errorf("Expected behind target to be marked as failed, got %+v", stats)
+					}
+					for _, method := range stats.Methods {
+						jailed[method] = true
+					}
+					if !slices.Equal(stats.Methods, []string{"getHealth"}) {
+						t.Errorf("Expected only the request methods to fail on the behind target, got %v", stats.Methods)
+					}
+				case healthyTarget:
+					if !stats.Success {
+						t.Errorf("Expected healthy target to be marked as healthy, got %+v", stats)
+					}
+				}
+			}
+			// the other methods get the fixed jail time, their escalating jail isn't changed
+			timeNow := time.Now().Unix()
+			for method := range solana.MethodList {
+				if behindTarget.isJailed(method, timeNow) {
+					jailed[method] = true
+				}
+				if errCounter := behindTarget.availableMethods[method].errCounter; errCounter != 0 {
+					t.Errorf("Expected the error counter of %s to be unchanged, got %d", method, errCounter)
+				}
+			}
+			for _, method := range tt.expectJailed {
+				if !jailed[method] {
+					t.Errorf("Expected %s to be jailed on the behind target", method)
+				}
+			}
+			for _, method := range tt.expectNotJailed {
+				if jailed[method] {
+					t.Errorf("Expected %s not to be jailed on the behind target", method)
+				}
+			}
+		})
+	}
+}
+
+func TestRealHTTPRequester_HostHeader(t *testing.T) {
+	hosts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":1,"id":1}`))
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: server.URL + "/custom", NodeType: archiveNodeType(), HandleOther: true, HostHeader: "rpc.provider.example"},
+				{URL: server.URL + "/default", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	if err != nil {
+		t.Fatalf("NewMethodBasedRouter: %v", err)
+	}
+	requester := NewRealHTTPRequester(router.getClientOptions())
+
+	for targetURL, expectedHost := range map[string]string{
+		server.URL + "/custom":  "rpc.provider.example",
+		server.URL + "/default": server.Listener.Addr().String(), // the URL host
+	} {
+		requestBytes := []byte(`{"jsonrpc":"2.0","method":"getSlot","id":1}`)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+
+		if _, _, err = requester.DoRequest(c, targetURL); err != nil {
+			t.Fatalf("DoRequest %s: %v", targetURL, err)
+		}
+		if got := <-hosts; got != expectedHost {
+			t.Errorf("%s: expected Host %s, got %s", targetURL, expectedHost, got)
+		}
+
+		c = createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+		if _, err = requester.StreamRequest(c, targetURL, nil); err != nil {
+			t.Fatalf("StreamRequest %s: %v", targetURL, err)
+		}
+		if got := <-hosts; got != expectedHost {
+			t.Errorf("%s: expected streamed Host %s, got %s", targetURL, expectedHost, got)
+		}
+	}
+}
+
+// TestUnifiedTransport_NoTargetSelected tests that a failed target selection on the first attempt
+// doesn't touch stats and returns the no available targets error
+func TestUnifiedTransport_NoTargetSelected(t *testing.T) {
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getSlot", "id": 1})
+	tests := []struct {
+		name     string
+		response NextResponse
+	}{
+		{name: "selection error", response: NextResponse{Error: errors.New("all targets excluded")}},
+		{name: "nil target", response: NextResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{tt.response},
+				TargetsCount:  1,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{}
+			transport := NewUnifiedTransport("test_transport", mockSelector, mockRequester, 3, false)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+
+			var err error
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("SendRequest panicked: %v", r)
+					}
+				}()
+				_, _, err = transport.SendRequest(c)
+			}()
+
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected 503 error, got %v", err)
+			}
+			if !reflect.DeepEqual(c.GetRPCErrors(), []int{util.ExtraNodeNoAvailableTargetsErrorResponse.Error.Code}) {
+				t.Errorf("Expected no available targets RPC error, got %v", c.GetRPCErrors())
+			}
+			if mockRequester.CallCount != 0 || mockSelector.UpdateStatsCallCount != 0 {
+				t.Errorf("Expected no requests and stats updates, got %d requests, %d updates", mockRequester.CallCount, mockSelector.UpdateStatsCallCount)
+			}
+		})
+	}
+}
+
+// TestUnifiedTransport_ExcludeRateLimitedProviders tests that a provider responding 429 is skipped for the rest of the request
+func TestUnifiedTransport_ExcludeRateLimitedProviders(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "limited",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://limited1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://limited2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://limited3.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+		{
+			Name:      "other",
+			Endpoints: []configtypes.EndpointConfig{{URL: "https://other.example.com", NodeType: archiveNodeType(), HandleOther: true}},
+		},
+	}
+	config.MethodProviderOrder = map[string][]string{"getSlot": {"limited", "other"}} // the limited provider is tried first
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getSlot", "id": 1})
+	rateLimited := HTTPResponseWrapper{StatusCode: http.StatusTooManyRequests, Error: util.ErrBadStatusCode}
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK}
+
+	for _, exclude := range []bool{true, false} {
+		router, err := NewMethodBasedRouter(config)
+		if err != nil {
+			t.Fatalf("NewMethodBasedRouter: %v", err)
+		}
+		mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{rateLimited, okResponse}}
+		if !exclude {
+			mockRequester.Responses = []HTTPResponseWrapper{rateLimited, rateLimited, rateLimited}
+		}
+		transport := NewUnifiedTransport("test_transport", router, mockRequester, 3, false)
+		transport.excludeRateLimitedProviders = exclude
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+		_, _, err = transport.SendRequest(c)
+
+		if exclude {
+			// the second attempt skips the rest of the limited provider targets
+			if len(mockRequester.URLs) != 2 || mockRequester.URLs[1] != "https://other.example.com" {
+				t.Errorf("Expected the other provider on the second attempt, got %v", mockRequester.URLs)
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			continue
+		}
+		if len(mockRequester.URLs) != 3 {
+			t.Fatalf("Expected all attempts on the limited provider, got %v", mockRequester.URLs)
+		}
+		for _, u := range mockRequester.URLs {
+			if !strings.HasPrefix(u, "https://limited") {
+				t.Errorf("Expected a limited provider target, got %s", u)
+			}
+		}
+	}
+}
+
+func TestUnifiedTransport_CommitmentRouting(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fresh.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://lagging.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	if err != nil {
+		t.Fatalf("NewMethodBasedRouter: %v", err)
+	}
+	timeNow := time.Now()
+	router.defaultTargetInfo.targets[0].observeSlot(1000, timeNow)
+	router.defaultTargetInfo.targets[1].observeSlot(900, timeNow)
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getBalance", "id": 1})
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK}
+
+	const requests = 50
+	for _, commitment := range []string{solana.CommitmentProcessed, solana.CommitmentFinalized} {
+		mockRequester := &MockHTTPRequesterWrapper{}
+		for i := 0; i < requests; i++ {
+			mockRequester.Responses = append(mockRequester.Responses, okResponse)
+		}
+		transport := NewUnifiedTransport("test_transport", router, mockRequester, 1, false)
+		transport.commitmentMaxSlotLag = 10
+
+		for i := 0; i < requests; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getBalance"}, requestBytes)
+			c.SetReqCommitment(commitment)
+			if _, _, err := transport.SendRequest(c); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		lagging := 0
+		for _, u := range mockRequester.URLs {
+			if u == "https://lagging.example.com" {
+				lagging++
+			}
+		}
+		switch {
+		case commitment == solana.CommitmentProcessed && lagging != 0:
+			t.Errorf("Expected processed requests on the fresh target only, got %d on the lagging one", lagging)
+		case commitment == solana.CommitmentFinalized && lagging == 0:
+			t.Errorf("Expected finalized requests to use the lagging target")
+		}
+	}
+}
+
+func TestUnifiedTransport_MethodMaxSlotLag(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fresh.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://lagging.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	if err != nil {
+		t.Fatalf("NewMethodBasedRouter: %v", err)
+	}
+	timeNow := time.Now()
+	router.defaultTargetInfo.targets[0].observeSlot(1000, timeNow)
+	router.defaultTargetInfo.targets[1].observeSlot(950, timeNow)
+
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK}
+
+	const requests = 50
+	for _, method := range []string{solana.GetBlock, solana.GetLatestBlockhash, solana.GetBalance} {
+		mockRequester := &MockHTTPRequesterWrapper{}
+		for i := 0; i < requests; i++ {
+			mockRequester.Responses = append(mockRequester.Responses, okResponse)
+		}
+		transport := NewUnifiedTransport("test_transport", router, mockRequester, 1, false)
+		transport.globalMaxSlotLag = 20
+		transport.methodMaxSlotLag = newMethodMaxSlotLag(map[string]uint64{solana.GetBlock: 1000, solana.GetLatestBlockhash: 5})
+
+		requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "id": 1})
+		for i := 0; i < requests; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{method}, requestBytes)
+			if _, _, err := transport.SendRequest(c); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		lagging := 0
+		for _, u := range mockRequester.URLs {
+			if u == "https://lagging.example.com" {
+				lagging++
+			}
+		}
+		switch {
+		case method == solana.GetBlock && lagging == 0:
+			t.Errorf("Expected %s requests to use the lagging target", method)
+		case method != solana.GetBlock && lagging != 0:
+			// getBalance falls back to the global threshold
+			t.Errorf("Expected %s requests on the fresh target only, got %d on the lagging one", method, lagging)
+		}
+	}
+}
+
+func TestUnifiedTransport_LaggingTargetCatchesUp(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://fresh.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://lagging.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	router, err := NewMethodBasedRouter(config)
+	if err != nil {
+		t.Fatalf("NewMethodBasedRouter: %v", err)
+	}
+	fresh, lagging := router.defaultTargetInfo.targets[0], router.defaultTargetInfo.targets[1]
+	timeNow := time.Now()
+	fresh.observeSlot(1000, timeNow)
+	lagging.observeSlot(900, timeNow)
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getBalance", "id": 1})
+	// both targets are at the same slot now
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":{"context":{"slot":1000},"value":1},"id":1}`), StatusCode: http.StatusOK}
+
+	const requests = 50
+	laggingRequests := func() (count int) {
+		t.Helper()
+		mockRequester := &MockHTTPRequesterWrapper{}
+		for i := 0; i < requests; i++ {
+			mockRequester.Responses = append(mockRequester.Responses, okResponse)
+		}
+		transport := NewUnifiedTransport("test_transport", router, mockRequester, 1, false)
+		transport.commitmentMaxSlotLag = 10
+
+		for i := 0; i < requests; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getBalance"}, requestBytes)
+			c.SetReqCommitment(solana.CommitmentProcessed)
+			if _, _, err := transport.SendRequest(c); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		for _, u := range mockRequester.URLs {
+			if u == lagging.url {
+				count++
+			}
+		}
+		return count
+	}
+
+	if count := laggingRequests(); count != 0 {
+		t.Fatalf("Expected no requests on the lagging target, got %d", count)
+	}
+
+	// the observation of the lagging target expires, it's probed again and observed at the fresh slot
+	lagging.observeSlot(900, timeNow.Add(-slotObservationTTL-time.Second))
+	if count := laggingRequests(); count == 0 {
+		t.Fatal("Expected requests on the caught up target")
+	}
+	if slot := lagging.estimatedSlot(time.Now()); slot < 1000 {
+		t.Errorf("Expected the caught up target to be observed at 1000, got %d", slot)
+	}
+
+	// it stays included while it keeps up
+	if count := laggingRequests(); count == 0 {
+		t.Error("Expected the caught up target to keep serving requests")
+	}
+}
+
+func TestUnifiedTransport_MaxSlotLag(t *testing.T) {
+	transport := NewUnifiedTransport("test_transport", nil, &MockHTTPRequesterWrapper{}, 1, false)
+	transport.globalMaxSlotLag = 20
+	transport.commitmentMaxSlotLag = 10
+	transport.methodMaxSlotLag = newMethodMaxSlotLag(map[string]uint64{solana.GetBlock: 1000, solana.GetLatestBlockhash: 5, solana.GetSlot: 0})
+
+	tests := []struct {
+		name       string
+		methods    []string
+		commitment string
+		want       int64
+	}{
+		{name: "method threshold", methods: []string{solana.GetBlock}, want: 1000},
+		{name: "global threshold", methods: []string{solana.GetBalance}, want: 20},
+		{name: "method without limit", methods: []string{solana.GetSlot}, want: 0},
+		{name: "strictest of batch", methods: []string{solana.GetBlock, solana.GetLatestBlockhash, solana.GetSlot}, want: 5},
+		{name: "processed commitment", methods: []string{solana.GetBlock}, commitment: solana.CommitmentProcessed, want: 10},
+		{name: "processed commitment without method limit", methods: []string{solana.GetSlot}, commitment: solana.CommitmentProcessed, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), tt.methods, nil)
+			c.SetReqCommitment(tt.commitment)
+			if got := transport.maxSlotLag(c, tt.methods); got != tt.want {
+				t.Errorf("maxSlotLag() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedTransport_FailedProvidersLog(t *testing.T) {
+	const providers = 8
+	config := createTestConfig()
+	config.Providers = nil
+	for i := 0; i < providers; i++ {
+		config.Providers = append(config.Providers, configtypes.ProviderConfig{
+			Name:      fmt.Sprintf("provider%d", i),
+			Endpoints: []configtypes.EndpointConfig{{URL: fmt.Sprintf("https://node%d.example.com", i), NodeType: archiveNodeType(), HandleOther: true}},
+		})
+	}
+	router, err := NewMethodBasedRouter(config)
+	if err != nil {
+		t.Fatalf("NewMethodBasedRouter: %v", err)
+	}
+	mockRequester := &MockHTTPRequesterWrapper{}
+	for i := 0; i < providers; i++ {
+		mockRequester.Responses = append(mockRequester.Responses, HTTPResponseWrapper{Error: errConnRefused})
+	}
+	transport := NewUnifiedTransport("test_transport", router, mockRequester, providers, false)
+	transport.failedProvidersLogLimit = 3
+
+	hook := logtest.NewLocal(log.Logger.Proxy.Logger)
+	defer hook.Reset()
+
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getSlot", "id": 1})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+	c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+	labels := map[string]string{"chain": c.GetChainName()}
+	before := counterValue(t, "failed_request_providers_total", labels)
+
+	if _, _, err = transport.SendRequest(c); err == nil {
+		t.Fatal("Expected an error when all targets fail")
+	}
+
+	var message string
+	for _, entry := range hook.AllEntries() {
+		if strings.HasPrefix(entry.Message, "all targets failed") {
+			message = entry.Message
+		}
+	}
+	if !strings.HasSuffix(message, "+5 more") || strings.Count(message, "provider") != 4 { // "providers:" and 3 names
+		t.Errorf("Expected 3 provider names and a +5 more suffix, got %q", message)
+	}
+	if got := counterValue(t, "failed_request_providers_total", labels) - before; got != providers {
+		t.Errorf("Expected %d failed providers counted, got %v", providers, got)
+	}
+}
+
+func TestFormatProviders(t *testing.T) {
+	providers := []string{"a", "b", "c"}
+	tests := []struct {
+		limit    int
+		expected string
+	}{
+		{limit: 5, expected: "a, b, c"},
+		{limit: 3, expected: "a, b, c"},
+		{limit: 2, expected: "a, b +1 more"},
+		{limit: 0, expected: "+3 more"},
+	}
+	for _, tt := range tests {
+		if got := formatProviders(providers, tt.limit); got != tt.expected {
+			t.Errorf("formatProviders(limit %d) = %q, expected %q", tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestUnifiedTransport_StatusJailTimes(t *testing.T) {
+	config := createTestConfig()
+	config.Providers = []configtypes.ProviderConfig{
+		{
+			Name: "provider1",
+			Endpoints: []configtypes.EndpointConfig{
+				{URL: "https://node1.example.com", NodeType: archiveNodeType(), HandleOther: true},
+				{URL: "https://node2.example.com", NodeType: archiveNodeType(), HandleOther: true},
+			},
+		},
+	}
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getSlot", "id": 1})
+	badGateway := HTTPResponseWrapper{StatusCode: http.StatusBadGateway, Error: util.ErrBadStatusCode}
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK}
+
+	tests := []struct {
+		name            string
+		statusJailTimes map[int]time.Duration
+		wantJailed      bool
+		wantErrCounter  uint64
+	}{
+		{name: "escalating jail", wantJailed: true, wantErrCounter: 1},
+		{name: "retry only", statusJailTimes: map[int]time.Duration{http.StatusBadGateway: 0}},
+		{name: "short jail", statusJailTimes: map[int]time.Duration{http.StatusBadGateway: 2 * time.Second}, wantJailed: true},
+		{name: "other status", statusJailTimes: map[int]time.Duration{http.StatusGatewayTimeout: 0}, wantJailed: true, wantErrCounter: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewMethodBasedRouter(config)
+			if err != nil {
+				t.Fatalf("NewMethodBasedRouter: %v", err)
+			}
+			mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{badGateway, okResponse}}
+			transport := NewUnifiedTransport("test_transport", router, mockRequester, 3, false)
+			transport.statusJailTimes = tt.statusJailTimes
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+			startUnix := time.Now().Unix()
+			respBody, statusCode, err := transport.SendRequest(c)
+			if err != nil || statusCode != http.StatusOK || !strings.Contains(string(respBody), `"result":1`) {
+				t.Fatalf("Expected the retry to succeed, got %d %s %v", statusCode, respBody, err)
+			}
+			if len(mockRequester.URLs) != 2 || mockRequester.URLs[0] == mockRequester.URLs[1] {
+				t.Fatalf("Expected a retry on the other target, got %v", mockRequester.URLs)
+			}
+
+			var failed *ProxyTarget
+			for _, target := range router.defaultTargetInfo.targets {
+				if target.url == mockRequester.URLs[0] {
+					failed = target
+				}
+			}
+			restriction := failed.availableMethods["getSlot"]
+			// jail expire times have a second granularity
+			if tt.wantJailed && restriction.jailExpireTime <= startUnix {
+				t.Errorf("Expected the target to be jailed, expire time %d", restriction.jailExpireTime)
+			}
+			if !tt.wantJailed && failed.isJailed("getSlot", time.Now().Unix()) {
+				t.Errorf("Expected the target not to be jailed, expire time %d", restriction.jailExpireTime)
+			}
+			if restriction.errCounter != tt.wantErrCounter {
+				t.Errorf("Expected error counter %d, got %d", tt.wantErrCounter, restriction.errCounter)
+			}
+		})
+	}
+}
+
+func TestUnifiedTransport_StickyTargets(t *testing.T) {
+	requestBytes, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "getSlot", "id": 1})
+	okResponse := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","result":1,"id":1}`), StatusCode: http.StatusOK}
+	target1 := NewProxyTarget(models.URLWithMethods{URL: "target1"}, 0, "", archiveNodeType())
+	target2 := NewProxyTarget(models.URLWithMethods{URL: "target2"}, 0, "", archiveNodeType())
+
+	mockSelector := &MockTargetSelector{
+		NextResponses: []NextResponse{
+			{Target: target1, Index: 0},
+			{Target: target2, Index: 1},
+			{Target: target1, Index: 0},
+			{Target: target1, Index: 0},
+		},
+		TargetsCount:  2,
+		IsAvailableFn: func() bool { return true },
+	}
+	mockRequester := &MockHTTPRequesterWrapper{
+		Responses: []HTTPResponseWrapper{okResponse, okResponse, {Error: errConnRefused}, okResponse, okResponse, okResponse, okResponse},
+	}
+	transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 3, false)
+	transport.stickyTargets = true
+
+	send := func(wantURLs []string, wantSelections int) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+		c := createTestCustomContext(req, httptest.NewRecorder(), []string{"getSlot"}, requestBytes)
+		mockRequester.URLs = nil
+		if _, statusCode, err := transport.SendRequest(c); err != nil || statusCode != http.StatusOK {
+			t.Fatalf("Expected success, got %d %v", statusCode, err)
+		}
+		if !slices.Equal(mockRequester.URLs, wantURLs) {
+			t.Errorf("Expected targets %v, got %v", wantURLs, mockRequester.URLs)
+		}
+		if mockSelector.CallCount != wantSelections {
+			t.Errorf("Expected %d balancer selections, got %d", wantSelections, mockSelector.CallCount)
+		}
+	}
+
+	// the balancer selects the first target, the next request skips the balancer
+	send([]string{"target1"}, 1)
+	send([]string{"target1"}, 1)
+	// the cached target fails, so the request falls back to the balancer and the new target is cached
+	send([]string{"target1", "target2"}, 2)
+	send([]string{"target2"}, 2)
+
+	// the cached target out of its request limit isn't used
+	target2.reqLimit = 1
+	target2.reqWindow, _ = getCurrentTimeWindow()
+	target2.reqCounter = 1
+	send([]string{"target1"}, 3)
+
+	// disabled stickiness always uses the balancer
+	transport.stickyTargets = false
+	send([]string{"target1"}, 4)
+}
+
+// TestUnifiedTransport_StickyTargetBlockHistory tests that the sticky target is checked against the network slot
+// reference, before any slot is observed on it
+func TestUnifiedTransport_StickyTargetBlockHistory(t *testing.T) {
+	target := NewProxyTarget(models.URLWithMethods{URL: "target"}, 0, "", solana.NodeType{Name: "extended_node", AvailableSlotsHistory: 1000})
+	selector := &MockTargetSelector{TargetsCount: 1, IsAvailableFn: func() bool { return true }}
+	transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: selector}, &MockHTTPRequesterWrapper{}, 3, true)
+	transport.stickyTargets = true
+	transport.setStickyTarget(solana.GetBlock, selector, target, 0)
+
+	recentBlock := calculateSlot(transport.currentSlot, transport.getSlotTime, 10)
+	for _, tt := range []struct {
+		reqBlock int64
+		sticky   bool
+	}{
+		{reqBlock: recentBlock, sticky: true},
+		{reqBlock: 1},
+	} {
+		c := createTestCustomContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder(), []string{solana.GetBlock}, nil)
+		c.SetReqBlock(tt.reqBlock)
+
+		if _, _, ok := transport.getStickyTarget(c, []string{solana.GetBlock}, selector, nil, balancer.NewExclusions(1), 0); ok != tt.sticky {
+			t.Errorf("Expected sticky %t for block %d, got %t", tt.sticky, tt.reqBlock, ok)
+		}
+	}
+}
+
+func TestUnifiedTransport_DASResponseValidation(t *testing.T) {
+	truncated := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","id":1,"result":{"total":1,"limit":1`), StatusCode: http.StatusOK}
+	noItems := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","id":1,"result":{"total":1,"limit":1}}`), StatusCode: http.StatusOK}
+	valid := HTTPResponseWrapper{RespBody: []byte(`{"jsonrpc":"2.0","id":1,"result":{"total":1,"limit":1,"items":[{"id":"asset"}]}}`), StatusCode: http.StatusOK}
+
+	tests := []struct {
+		name       string
+		validation bool
+		first      HTTPResponseWrapper
+		wantCalls  int
+	}{
+		{name: "truncated, validation off", first: truncated, wantCalls: 1},
+		{name: "truncated", validation: true, first: truncated, wantCalls: 2},
+		{name: "no items", validation: true, first: noItems, wantCalls: 2},
+		{name: "valid", validation: true, first: valid, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSelector := &MockTargetSelector{
+				NextResponses: []NextResponse{
+					{Target: &ProxyTarget{url: "target1"}, Index: 0},
+					{Target: &ProxyTarget{url: "target2"}, Index: 1},
+				},
+				TargetsCount:  2,
+				IsAvailableFn: func() bool { return true },
+			}
+			mockRequester := &MockHTTPRequesterWrapper{Responses: []HTTPResponseWrapper{tt.first, valid}}
+			transport := NewUnifiedTransport("test_transport", &MethodRouterWrapper{mockSelector: mockSelector}, mockRequester, 3, false)
+			transport.dasResponseValidation = tt.validation
+
+			requestBytes := []byte(`{"jsonrpc":"2.0","id":1,"method":"getAssetsByOwner","params":{"ownerAddress":"owner"}}`)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBytes))
+			c := createTestCustomContext(req, httptest.NewRecorder(), []string{solana.GetAssetsByOwner}, requestBytes)
+			respBody, statusCode, err := transport.SendRequest(c)
+			if err != nil || statusCode != http.StatusOK {
+				t.Fatalf("Expected success, got %d %v", statusCode, err)
+			}
+			if mockRequester.CallCount != tt.wantCalls {
+				t.Errorf("Expected %d upstream calls, got %d", tt.wantCalls, mockRequester.CallCount)
+			}
+			wantBody := valid.RespBody
+			if tt.wantCalls == 1 {
+				wantBody = tt.first.RespBody
+			}
+			if !bytes.Equal(respBody, wantBody) {
+				t.Errorf("Expected response %s, got %s", wantBody, respBody)
+			}
+		})
+	}
+}
+
+func TestValidateDASResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		methods []string
+		wantErr bool
+	}{
+		{name: "asset", body: `{"jsonrpc":"2.0","id":1,"result":{"id":"asset"}}`, methods: []string{solana.GetAsset}},
+		{name: "asset not found", body: `{"jsonrpc":"2.0","id":1,"result":null}`, methods: []string{solana.GetAsset}},
+		{name: "rpc error", body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"not found"}}`, methods: []string{solana.SearchAssets}},
+		{name: "no result", body: `{"jsonrpc":"2.0","id":1}`, methods: []string{solana.GetAsset}, wantErr: true},
+		{name: "page without items", body: `{"jsonrpc":"2.0","id":1,"result":{"total":0}}`, methods: []string{solana.SearchAssets}, wantErr: true},
+		{name: "page result isn't an object", body: `{"jsonrpc":"2.0","id":1,"result":[]}`, methods: []string{solana.SearchAssets}, wantErr: true},
+		{
+			name:    "batch",
+			body:    `[{"jsonrpc":"2.0","id":1,"result":{"id":"asset"}},{"jsonrpc":"2.0","id":2,"result":{"items":[]}}]`,
+			methods: []string{solana.GetAsset, solana.GetAssetsByOwner},
+		},
+		{
+			name:    "batch with malformed page",
+			body:    `[{"jsonrpc":"2.0","id":1,"result":{"id":"asset"}},{"jsonrpc":"2.0","id":2,"result":{}}]`,
+			methods: []string{solana.GetAsset, solana.GetAssetsByOwner},
+			wantErr: true,
+		},
+		{name: "truncated batch", body: `[{"jsonrpc":"2.0","id":1,"result":{"id":"asset"}},{"jsonrpc"`, methods: []string{solana.GetAsset, solana.GetAsset}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDASResponse([]byte(tt.body), tt.methods)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Expected error %t, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedDASResponse) {
+				t.Errorf("Expected ErrMalformedDASResponse, got %v", err)
+			}
+		})
+	}
+}
@@ -20,13 +20,17 @@ type (
 	ProxyTransport struct {
 		httpClient *http.Client
 		wsTargets  balancer.TargetSelector[*ProxyTarget]
+
+		// upstream response headers removed before returning to the client
+		stripResponseHeaders []string
 	}
 )
 
-func NewDefaultProxyTransport(target balancer.TargetSelector[*ProxyTarget]) *ProxyTransport {
+func NewDefaultProxyTransport(target balancer.TargetSelector[*ProxyTarget], stripResponseHeaders []string) *ProxyTransport {
 	return &ProxyTransport{
-		httpClient: &http.Client{Timeout: echoUtil.APIWriteTimeout - time.Second},
-		wsTargets:  target,
+		httpClient:           &http.Client{Timeout: echoUtil.APIWriteTimeout - time.Second},
+		wsTargets:            target,
+		stripResponseHeaders: stripResponseHeaders,
 	}
 }
 
@@ -57,12 +61,23 @@ func (p *ProxyTransport) DefaultProxyWS(c echo.Context) (err error) {
 		}
 	}
 
-	reverseProxy := &httputil.ReverseProxy{Director: func(req *http.Request) { rewriteRequestURL(req, wrapped.ToURLPtr()) }}
+	reverseProxy := &httputil.ReverseProxy{
+		Director:       func(req *http.Request) { rewriteRequestURL(req, wrapped.ToURLPtr()) },
+		ModifyResponse: p.modifyResponse,
+	}
 	reverseProxy.ServeHTTP(c.Response(), c.Request())
 
 	return nil
 }
 
+func (p *ProxyTransport) modifyResponse(resp *http.Response) error {
+	for _, h := range p.stripResponseHeaders {
+		resp.Header.Del(h)
+	}
+
+	return nil
+}
+
 func rewriteRequestURL(req *http.Request, target *url.URL) {
 	targetQuery := target.RawQuery
 	req.URL.Scheme = target.Scheme
@@ -0,0 +1,42 @@
+package solana
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProxyTransport_StripResponseHeaders tests that configured upstream headers are removed
+func TestProxyTransport_StripResponseHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Provider-Name", "secret_provider")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Custom", "keep")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	mockSelector := &MockTargetSelector{
+		NextResponses: []NextResponse{
+			{Target: &ProxyTarget{url: upstream.URL, provider: "provider"}, Index: 0},
+		},
+		TargetsCount:  1,
+		IsAvailableFn: func() bool { return true },
+	}
+	transport := NewDefaultProxyTransport(mockSelector, []string{"X-Provider-Name", "cache-control"})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := createTestCustomContext(req, rec, nil, nil)
+	c.SetPath("/:token")
+
+	require.NoError(t, transport.DefaultProxyWS(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Provider-Name"))
+	assert.Empty(t, rec.Header().Get(echo.HeaderCacheControl))
+	assert.Equal(t, "keep", rec.Header().Get("X-Custom"))
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+// configVersion identifies the routing config loaded by the running instance
+type configVersion struct {
+	fingerprint string
+	loadedAt    time.Time
+	mx          sync.RWMutex
+}
+
+type configVersionResponse struct {
+	Fingerprint string    `json:"fingerprint"`
+	LoadedAt    time.Time `json:"loadedAt"`
+}
+
+// update recalculates the fingerprint. Must be called on every (re)load of the chains config
+func (v *configVersion) update(solanaCfg, eclipseCfg *configtypes.SolanaConfig, solanaChains configtypes.SolanaChains) error {
+	fingerprint, err := configFingerprint(solanaCfg, eclipseCfg, solanaChains)
+	if err != nil {
+		return err
+	}
+
+	v.mx.Lock()
+	v.fingerprint = fingerprint
+	v.loadedAt = time.Now().UTC()
+	v.mx.Unlock()
+
+	return nil
+}
+
+func (v *configVersion) get() configVersionResponse {
+	v.mx.RLock()
+	defer v.mx.RUnlock()
+
+	return configVersionResponse{
+		Fingerprint: v.fingerprint,
+		LoadedAt:    v.loadedAt,
+	}
+}
+
+// configFingerprint hashes the chain configs. Additional chains are hashed in the order of their names
+func configFingerprint(solanaCfg, eclipseCfg *configtypes.SolanaConfig, solanaChains configtypes.SolanaChains) (string, error) {
+	type namedChain struct {
+		Name   string                        `json:"name"`
+		Config configtypes.SolanaChainConfig `json:"config"`
+	}
+	chains := make([]namedChain, 0, len(solanaChains))
+	for _, name := range slices.Sorted(maps.Keys(solanaChains)) {
+		chains = append(chains, namedChain{Name: name, Config: solanaChains[name]})
+	}
+
+	raw, err := json.Marshal(struct {
+		Solana  *configtypes.SolanaConfig `json:"solana"`
+		Eclipse *configtypes.SolanaConfig `json:"eclipse"`
+		Chains  []namedChain              `json:"chains"`
+	}{Solana: solanaCfg, Eclipse: eclipseCfg, Chains: chains})
+	if err != nil {
+		return "", fmt.Errorf("marshal: %s", err)
+	}
+	sum := sha256.Sum256(raw)
+
+	return hex.EncodeToString(sum[:]), nil
+}
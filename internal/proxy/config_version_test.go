@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/configtypes"
+)
+
+func TestConfigVersion_FingerprintChangesOnUpdate(t *testing.T) {
+	p := &proxy{
+		metricsServer: echo.New(),
+		deniedMethods: newMethodDenyList(nil),
+	}
+	p.initAdminHandlers()
+
+	getVersion := func() configVersionResponse {
+		rec := httptest.NewRecorder()
+		p.metricsServer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config/version", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res configVersionResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	solanaCfg := configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: "https://node1.example.com", HandleOther: true}}},
+		},
+	}
+	require.NoError(t, p.configVersion.update(&solanaCfg, &configtypes.SolanaConfig{}, nil))
+	first := getVersion()
+	assert.Len(t, first.Fingerprint, 64)
+	assert.False(t, first.LoadedAt.IsZero())
+
+	// same config - same fingerprint
+	require.NoError(t, p.configVersion.update(&solanaCfg, &configtypes.SolanaConfig{}, nil))
+	assert.Equal(t, first.Fingerprint, getVersion().Fingerprint)
+
+	// different config - different fingerprint
+	solanaCfg.Providers[0].Endpoints[0].Weight = 5
+	require.NoError(t, p.configVersion.update(&solanaCfg, &configtypes.SolanaConfig{}, nil))
+	second := getVersion()
+	assert.NotEqual(t, first.Fingerprint, second.Fingerprint)
+	assert.False(t, second.LoadedAt.Before(first.LoadedAt))
+}
+
+func TestConfigFingerprint_SolanaChains(t *testing.T) {
+	newChain := func(url string) configtypes.SolanaChainConfig {
+		return configtypes.SolanaChainConfig{SolanaConfig: configtypes.SolanaConfig{
+			HostNames: []string{"aura-" + url},
+			Providers: []configtypes.ProviderConfig{
+				{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: "https://" + url, HandleOther: true}}},
+			},
+		}}
+	}
+	fingerprint := func(chains configtypes.SolanaChains) string {
+		res, err := configFingerprint(&configtypes.SolanaConfig{}, &configtypes.SolanaConfig{}, chains)
+		require.NoError(t, err)
+		return res
+	}
+
+	chains := configtypes.SolanaChains{"sonic": newChain("sonic.example.com"), "soon": newChain("soon.example.com")}
+	first := fingerprint(chains)
+	assert.NotEqual(t, fingerprint(nil), first)
+	for range 10 { // independent of the map order
+		assert.Equal(t, first, fingerprint(maps.Clone(chains)))
+	}
+
+	// a change of an additional chain only
+	chains["soon"] = newChain("soon2.example.com")
+	assert.NotEqual(t, first, fingerprint(chains))
+}
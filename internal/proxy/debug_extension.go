@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/buger/jsonparser"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// debugExtensionField is the extension field of responses of debug tokens. JSON-RPC clients ignore unknown envelope fields
+const debugExtensionField = "_aura"
+
+type debugExtension struct {
+	Attempts       int    `json:"attempts"`
+	Provider       string `json:"provider"`
+	ResponseTimeMs int64  `json:"responseTimeMs"`
+}
+
+// withDebugExtension checks if the request token gets the debug extension field in responses
+func (p *proxy) withDebugExtension(cc *echoUtil.CustomContext) bool {
+	if len(p.debugExtensionTokens) == 0 || cc.GetAPIToken() == "" {
+		return false
+	}
+	_, ok := p.debugExtensionTokens[cc.GetAPIToken()]
+
+	return ok
+}
+
+// addDebugExtension adds the attempts, provider and node response time of the request to successful responses,
+// every successful element of batches. The body is returned as is when it can't be extended
+func addDebugExtension(cc *echoUtil.CustomContext, body []byte) []byte {
+	extension, err := json.Marshal(debugExtension{
+		Attempts:       cc.GetProxyAttempts(),
+		Provider:       cc.GetProvider(),
+		ResponseTimeMs: cc.GetProxyResponseTime(),
+	})
+	if err != nil {
+		return body
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return extendResponse(body, extension)
+	}
+
+	var elements [][]byte
+	_, err = jsonparser.ArrayEach(trimmed, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		elements = append(elements, extendResponse(bytes.Clone(value), extension))
+	})
+	if err != nil {
+		return body
+	}
+
+	return append(append([]byte{'['}, bytes.Join(elements, []byte{','})...), ']')
+}
+
+// extendResponse sets the extension field of a response with a result
+func extendResponse(response, extension []byte) []byte {
+	if _, _, _, err := jsonparser.Get(response, "result"); err != nil {
+		return response
+	}
+	extended, err := jsonparser.Set(response, extension, debugExtensionField)
+	if err != nil {
+		return response
+	}
+
+	return extended
+}
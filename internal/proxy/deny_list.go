@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"slices"
+	"sync"
+)
+
+// methodDenyList is a runtime-changeable set of methods the proxy refuses to serve
+type methodDenyList struct {
+	methods map[string]struct{}
+	mx      sync.RWMutex
+}
+
+func newMethodDenyList(methods []string) *methodDenyList {
+	d := &methodDenyList{}
+	d.Set(methods)
+
+	return d
+}
+
+func (d *methodDenyList) IsDenied(method string) bool {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+
+	_, ok := d.methods[method]
+
+	return ok
+}
+
+func (d *methodDenyList) Set(methods []string) {
+	m := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		if method != "" {
+			m[method] = struct{}{}
+		}
+	}
+
+	d.mx.Lock()
+	d.methods = m
+	d.mx.Unlock()
+}
+
+func (d *methodDenyList) List() []string {
+	d.mx.RLock()
+	res := make([]string, 0, len(d.methods))
+	for method := range d.methods {
+		res = append(res, method)
+	}
+	d.mx.RUnlock()
+
+	slices.Sort(res)
+
+	return res
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func newTestContextWithMethods(methods ...string) *echoUtil.CustomContext {
+	cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+	var reqs types.RPCRequests
+	for i, m := range methods {
+		reqs = append(reqs, &types.RPCRequest{JSONRPC: types.JSONRPCVersion, Method: m, ID: i})
+	}
+	cc.SetRPCRequestsParsed(reqs)
+	cc.SetReqMethods(methods)
+
+	return cc
+}
+
+func TestDeniedMethods_RuntimeToggle(t *testing.T) {
+	p := &proxy{
+		metricsServer: echo.New(),
+		deniedMethods: newMethodDenyList([]string{"getSupply"}),
+		adminToken:    testAdminToken,
+	}
+	p.initAdminHandlers()
+	setDeniedMethods := func(body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/denied-methods", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		p.metricsServer.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.NotNil(t, p.deniedMethodResponse(newTestContextWithMethods("getSupply")))
+	assert.Nil(t, p.deniedMethodResponse(newTestContextWithMethods("getProgramAccounts")))
+
+	// the deny list is changed with the admin token only
+	assert.Equal(t, http.StatusBadRequest, setDeniedMethods(`{"methods":["getProgramAccounts"]}`, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, setDeniedMethods(`{"methods":["getProgramAccounts"]}`, "wrong").Code)
+	assert.Nil(t, p.deniedMethodResponse(newTestContextWithMethods("getProgramAccounts")))
+
+	// deny getProgramAccounts at runtime
+	rec := setDeniedMethods(`{"methods":["getProgramAccounts"]}`, testAdminToken)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"methods":["getProgramAccounts"]}`, rec.Body.String())
+
+	cc := newTestContextWithMethods("getBalance", "getProgramAccounts")
+	resp := p.deniedMethodResponse(cc)
+	require.NotNil(t, resp)
+	assert.Equal(t, util.MethodDeniedRPCError.Code, resp.Error.Code)
+	assert.Equal(t, 1, resp.ID)
+	assert.Equal(t, []int{util.MethodDeniedRPCError.Code}, cc.GetRPCErrors())
+	assert.Nil(t, p.deniedMethodResponse(newTestContextWithMethods("getBalance", "getSupply")))
+
+	// clear the deny list
+	require.Equal(t, http.StatusOK, setDeniedMethods(`{"methods":[]}`, testAdminToken).Code)
+	assert.Nil(t, p.deniedMethodResponse(newTestContextWithMethods("getProgramAccounts")))
+
+	rec = httptest.NewRecorder()
+	p.metricsServer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/denied-methods", nil))
+	assert.JSONEq(t, `{"methods":[]}`, rec.Body.String())
+}
+
+func TestDeniedMethods_NoAdminToken(t *testing.T) {
+	p := &proxy{
+		metricsServer: echo.New(),
+		deniedMethods: newMethodDenyList([]string{"getSupply"}),
+	}
+	p.initAdminHandlers()
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/denied-methods", strings.NewReader(`{"methods":[]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	p.metricsServer.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.NotNil(t, p.deniedMethodResponse(newTestContextWithMethods("getSupply")))
+}
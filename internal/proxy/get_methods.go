@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const (
+	getMethodQueryParam = "method"
+	getParamsQueryParam = "params" // JSON array
+)
+
+var errInvalidGetParams = errors.New("params query parameter must be a JSON array")
+
+type getRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCOverGetMiddleware turns GET requests of allowed methods (e.g. /?method=getSlot&params=[{"commitment":"finalized"}])
+// into JSON-RPC POST requests, so they go through the regular request preparation. Other non WebSocket GET requests are rejected
+func (p *proxy) RPCOverGetMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.IsWebSocket() {
+				return next(c)
+			}
+			method := c.QueryParam(getMethodQueryParam)
+			if _, ok := p.getMethods[method]; !ok {
+				return echo.NewHTTPError(http.StatusMethodNotAllowed)
+			}
+
+			body, err := buildGetRPCRequest(method, c.QueryParam(getParamsQueryParam))
+			if err != nil {
+				c.(*echoUtil.CustomContext).SetProxyUserError(true) //nolint:errcheck
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			req := c.Request()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.Header.Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			return next(c)
+		}
+	}
+}
+
+func buildGetRPCRequest(method, params string) ([]byte, error) {
+	req := getRPCRequest{JSONRPC: "2.0", ID: 1, Method: method}
+	if params != "" {
+		var parsed []json.RawMessage
+		if err := json.Unmarshal([]byte(params), &parsed); err != nil {
+			return nil, errInvalidGetParams
+		}
+		req.Params = json.RawMessage(params)
+	}
+
+	return json.Marshal(req)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/adm-metaex/aura-api/pkg/types"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 
 	"aura-proxy/internal/pkg/chains/solana"
 	"aura-proxy/internal/pkg/metrics"
@@ -22,17 +23,44 @@ const (
 	headerNodeReqAttempts  = "X-NODE-REQ-ATTEMPTS"
 	headerNodeResponseTime = "X-NODE-RESPONSE-TIME"
 	headerNodeEndpoint     = "X-NODE-ENDPOINT"
+	headerCreditsUsed      = "X-Credits-Used"
+	headerCreditsRemaining = "X-Credits-Remaining"
+	headerPinnedProvider   = "X-Aura-Provider"
 
 	websocketMethodName = "WSConnect"
 )
 
-func setServiceHeaders(h http.Header, cc *echoUtil.CustomContext) {
+func setServiceHeaders(h http.Header, cc *echoUtil.CustomContext, withCredits bool) {
 	if endpoint := cc.GetProxyEndpoint(); endpoint != "" {
 		h.Set(headerNodeEndpoint, endpoint)
 	}
 	h.Set(headerNodeReqAttempts, fmt.Sprintf("%d", cc.GetProxyAttempts()))
 	h.Set(headerNodeResponseTime, fmt.Sprintf("%dms", cc.GetProxyResponseTime()))
 	h.Set(echo.HeaderXRequestID, cc.GetReqID())
+	// the balance is already charged by the user balance middleware
+	if withCredits && cc.GetUserInfo() != nil {
+		h.Set(headerCreditsUsed, fmt.Sprintf("%d", cc.GetCreditsUsed()))
+		h.Set(headerCreditsRemaining, fmt.Sprintf("%d", cc.GetUserInfo().GetMplxBalance()))
+	}
+}
+
+// withCreditHeaders checks if the credit headers are returned for the tier of the request
+func (p *proxy) withCreditHeaders(cc *echoUtil.CustomContext) bool {
+	if !p.creditHeaders {
+		return false
+	}
+
+	return len(p.creditHeadersTiers) == 0 || slices.Contains(p.creditHeadersTiers, string(cc.GetTokenType()))
+}
+
+// pinProvider routes the request to the provider named in the header, honored for the privileged tiers only
+func (p *proxy) pinProvider(cc *echoUtil.CustomContext) {
+	provider := cc.Request().Header.Get(headerPinnedProvider)
+	if provider == "" || !slices.Contains(p.providerPinTiers, string(cc.GetTokenType())) {
+		return
+	}
+
+	cc.SetPinnedProvider(provider)
 }
 
 func (p *proxy) serviceStatusHandler(c echo.Context) error {
@@ -42,6 +70,12 @@ func (p *proxy) serviceStatusHandler(c echo.Context) error {
 	})
 }
 
+// skipUnlimitedTokens skips rate limiting for tiers without limits. CustomContext must be inited before
+func skipUnlimitedTokens(c echo.Context) bool {
+	cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+	return !cc.GetTokenType().IsTokenRateLimited()
+}
+
 type ITokenChecker interface {
 	middlewares.ITokenChecker
 	UserBalanceMiddleware() echo.MiddlewareFunc
@@ -49,12 +83,7 @@ type ITokenChecker interface {
 
 func (p *proxy) initProxyHandlers(tokenChecker ITokenChecker) {
 	apiTokenCheckerMiddleware := middlewares.APITokenCheckerMiddleware(tokenChecker)
-	rateLimiterMiddleware := echoUtil.NewRateLimiter(func(c echo.Context) bool {
-		return false
-		// CustomContext must be inited before
-		//cc := c.(*echoUtil.CustomContext) //nolint:errcheck
-		//return !cc.GetTokenType().IsTokenRateLimited()
-	})
+	rateLimiterMiddleware := echoUtil.NewRateLimiter(skipUnlimitedTokens)
 
 	proxyMiddlewares := []echo.MiddlewareFunc{
 		p.RequestPrepareMiddleware(),
@@ -62,20 +91,41 @@ func (p *proxy) initProxyHandlers(tokenChecker ITokenChecker) {
 		// the request id middleware should be the first in the chain as it sets the request id for the context used by other middlewares including the clickhouse stats collector
 		middlewares.RequestIDMiddleware(),
 		// it's not only a logger, but also the clickhouse stats collector, so it should be the first middleware in the chain after the request prepare, user token check and request id middlewares
-		middlewares.NewLoggerMiddleware(p.statsCollector.Add, p.isMainnet),
+		middlewares.NewLoggerMiddleware(p.statsCollector.Add, p.isMainnet, p.statsSampleRate),
+		// ahead of the limits, so the requests they reject are captured too. It needs the token to select the requests
+		middlewares.PayloadCaptureMiddleware(p.payloadStore, func(c echo.Context) bool { return p.payloadStore == nil || c.IsWebSocket() }),
 		rateLimiterMiddleware,
-		middlewares.StreamRateLimitMiddleware(func(c echo.Context) bool { return !c.IsWebSocket() }), // WS rate limiter
+		middlewares.TokenConcurrencyLimitMiddleware(p.tokenConcurrency, func(c echo.Context) bool { return c.IsWebSocket() }),
+		middlewares.BodyLimitMiddleware(p.bodyLimits, func(c echo.Context) bool { return c.IsWebSocket() }),
+		middlewares.StreamRateLimitMiddleware(p.wsRateLimiter, func(c echo.Context) bool { return !c.IsWebSocket() }), // WS rate limiter
+		// shed load before user balance is charged. Bulkheads go first, so requests waiting for their type don't hold the shared slots
+		middlewares.RequestTypeLimitMiddleware(p.requestTypeLimiters, func(c echo.Context) bool { return c.IsWebSocket() }),
+		middlewares.ConcurrencyLimitMiddleware(p.concurrencyLimiter, func(c echo.Context) bool { return c.IsWebSocket() }),
 		tokenChecker.UserBalanceMiddleware(),
-		echoUtil.RequestTimeoutMiddleware(func(c echo.Context) bool { return c.IsWebSocket() }),
+		echoUtil.RequestTimeoutMiddleware(p.requestTimeout, func(c echo.Context) bool { return c.IsWebSocket() }),
 		// post-processing middlewares
 		middlewares.NewMetricsMiddleware(),
 	}
+	if p.responseCompression {
+		// first, so error responses of other middlewares are compressed too
+		proxyMiddlewares = append([]echo.MiddlewareFunc{p.CompressMiddleware()}, proxyMiddlewares...)
+	}
 	p.router.POST("/", p.ProxyPostRouteHandler, proxyMiddlewares...)
 	p.router.POST("/:token", p.ProxyPostRouteHandler, proxyMiddlewares...)
 	p.router.GET("/service-status", p.serviceStatusHandler)
-	p.router.GET("/", p.ProxyGetRouteHandler, proxyMiddlewares...)
-	p.router.GET(echoUtil.ProxyPathWithToken, p.ProxyGetRouteHandler, proxyMiddlewares...)
-	p.router.GET("/:token/", p.ProxyGetRouteHandler, proxyMiddlewares...)
+	// allowed methods are served over GET as POST requests
+	getMiddlewares := append([]echo.MiddlewareFunc{p.RPCOverGetMiddleware()}, proxyMiddlewares...)
+	p.router.GET("/", p.ProxyGetRouteHandler, getMiddlewares...)
+	p.router.GET(echoUtil.ProxyPathWithToken, p.ProxyGetRouteHandler, getMiddlewares...)
+	p.router.GET("/:token/", p.ProxyGetRouteHandler, getMiddlewares...)
+}
+
+// CompressMiddleware gzips responses of at least compressionMinLength bytes for clients accepting gzip
+func (p *proxy) CompressMiddleware() echo.MiddlewareFunc {
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		Skipper:   func(c echo.Context) bool { return c.IsWebSocket() },
+		MinLength: p.compressionMinLength,
+	})
 }
 
 func (p *proxy) ProxyGetRouteHandler(c echo.Context) error {
@@ -101,6 +151,9 @@ func (p *proxy) ProxyGetRouteHandler(c echo.Context) error {
 		}
 		return err
 	}
+	if cc.GetRPCRequestsParsed() != nil { // converted by RPCOverGetMiddleware
+		return p.ProxyPostRouteHandler(c)
+	}
 	return echo.NewHTTPError(http.StatusMethodNotAllowed)
 }
 
@@ -114,17 +167,59 @@ func (p *proxy) ProxyPostRouteHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, util.ErrChainNotSupported)
 	}
 
+	p.pinProvider(cc)
+	p.providerAffinity.prefer(cc)
+
+	// streamed responses are written by the adapter, so service headers are set right before the response is committed
+	if s, ok := adapter.(streamingAdapter); ok && s.IsStreamed(cc) {
+		cc.Response().Before(func() {
+			p.setResponseHeaders(cc, cc.Response().Status)
+		})
+	}
+
 	resBody, resCode, err := adapter.ProxyPostRequest(cc)
 	if err != nil {
 		return transport.HandleError(err)
 	}
 	p.requestCounter.IncUserRequests(cc.GetUserInfo(), cc.GetCreditsUsed(), cc.GetChainName(), cc.GetAPIToken(), cc.GetRequestType().String(), p.isMainnet)
 
-	setServiceHeaders(cc.Response().Header(), cc)
+	if cc.Response().Committed { // already streamed
+		return nil
+	}
+	p.setResponseHeaders(cc, resCode)
+	if resCode == http.StatusOK && p.withDebugExtension(cc) {
+		resBody = addDebugExtension(cc, resBody)
+	}
 
 	return c.JSONBlob(resCode, resBody)
 }
 
+// streamingAdapter is implemented by adapters able to copy upstream responses directly to the client
+type streamingAdapter interface {
+	IsStreamed(c *echoUtil.CustomContext) bool
+}
+
+// setResponseHeaders sets the service headers and the provider affinity cookie of successful responses
+func (p *proxy) setResponseHeaders(cc *echoUtil.CustomContext, status int) {
+	if status < http.StatusMultipleChoices {
+		setServiceHeaders(cc.Response().Header(), cc, p.withCreditHeaders(cc))
+		p.providerAffinity.issue(cc)
+	}
+}
+
+// deniedMethodResponse returns JSON-RPC error for the first request which method is in the deny list
+func (p *proxy) deniedMethodResponse(cc *echoUtil.CustomContext) *types.RPCResponse {
+	for _, req := range cc.GetRPCRequestsParsed() {
+		if p.deniedMethods.IsDenied(req.Method) {
+			cc.SetRPCErrors([]int{util.MethodDeniedRPCError.Code})
+			cc.SetProxyUserError(true)
+			return types.NewRPCErrorResponse(util.MethodDeniedRPCError, req.ID)
+		}
+	}
+
+	return nil
+}
+
 func (p *proxy) RequestPrepareMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -138,6 +233,10 @@ func (p *proxy) RequestPrepareMiddleware() echo.MiddlewareFunc {
 			if !ok {
 				return echo.NewHTTPError(http.StatusBadRequest, util.ErrChainNotSupported)
 			}
+			// a proxy in the upstreams of itself (or of another proxy upstream of it) would loop until timeout
+			if transport.ProxyHops(c.Request().Header) >= transport.MaxProxyHops {
+				return echo.NewHTTPError(http.StatusLoopDetected, util.ErrProxyLoop)
+			}
 			if c.IsWebSocket() {
 				cc.SetRequestType(types.Websocket)
 				cc.SetChainName(adapter.GetName())
@@ -151,7 +250,7 @@ func (p *proxy) RequestPrepareMiddleware() echo.MiddlewareFunc {
 			// also here we set chain name, taken from adapter, to custom context
 			err := transport.PreparePostRequest(cc, adapter.GetName())
 			if err != nil {
-				if errors.Is(err, transport.ErrInvalidContentType) {
+				if errors.Is(err, transport.ErrInvalidContentType) || errors.Is(err, transport.ErrUnsupportedContentEncoding) {
 					return c.String(http.StatusUnsupportedMediaType, err.Error())
 				}
 
@@ -159,15 +258,19 @@ func (p *proxy) RequestPrepareMiddleware() echo.MiddlewareFunc {
 			}
 
 			// chain specific prepare
+			// invalid requests get a JSON-RPC error body with 200, as nodes respond
 			rpcErrResponse := adapter.PreparePostReq(cc)
 			if rpcErrResponse != nil {
-				return echo.NewHTTPError(http.StatusOK, rpcErrResponse)
+				return c.JSON(http.StatusOK, rpcErrResponse)
 			}
 			if len(cc.GetRPCRequestsParsed()) == 0 {
 				return c.NoContent(http.StatusOK)
 			}
+			if rpcErrResponse := p.deniedMethodResponse(cc); rpcErrResponse != nil {
+				return c.JSON(http.StatusOK, rpcErrResponse)
+			}
 			isGPARequest := slices.Contains(cc.GetReqMethods(), solana.GetProgramAccounts)
-			if isGPARequest && cc.GetArrayRequested() {
+			if isGPARequest && cc.GetArrayRequested() && !p.allowGPABatch {
 				return echo.NewHTTPError(http.StatusBadRequest, util.ErrGPAArrayRequest)
 			}
 			cc.SetIsGPARequest(isGPARequest)
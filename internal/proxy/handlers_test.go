@@ -0,0 +1,503 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/transport"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+	solanaAdapter "aura-proxy/internal/proxy/chains/solana"
+)
+
+func TestRequestPrepareMiddleware_BlockParamsError(t *testing.T) {
+	p := newDebugTestProxy(t, "", false)
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", func(c echo.Context) error { return c.NoContent(http.StatusTeapot) }, p.RequestPrepareMiddleware())
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "no params",
+			body:     `{"jsonrpc":"2.0","id":1,"method":"getBlock"}`,
+			expected: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"` + "`params`" + ` should have at least 1 argument(s)"}}`,
+		},
+		{
+			name:     "empty params",
+			body:     `{"jsonrpc":"2.0","id":"abc","method":"getBlock","params":[]}`,
+			expected: `{"jsonrpc":"2.0","id":"abc","error":{"code":-32602,"message":"` + "`params`" + ` should have at least 1 argument(s)"}}`,
+		},
+		{
+			name:     "object params",
+			body:     `{"jsonrpc":"2.0","id":2,"method":"getBlock","params":{"slot":1}}`,
+			expected: `{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"Invalid request"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Host = "mainnet-aura.metaplex.com"
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+			assert.JSONEq(t, tt.expected, rec.Body.String())
+		})
+	}
+}
+
+func TestRateLimiter_TokenTypes(t *testing.T) {
+	tests := []struct {
+		subscription string
+		limited      bool
+	}{
+		{subscription: "", limited: true},
+		{subscription: "Basic", limited: true},
+		{subscription: "pro", limited: true},
+		{subscription: "enterprise", limited: true},
+		{subscription: "Unlimited", limited: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subscription, func(t *testing.T) {
+			handler := echoUtil.NewRateLimiter(skipUnlimitedTokens)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			codes := make([]int, 0, 3)
+			for range 3 {
+				rec := httptest.NewRecorder()
+				cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)}
+				cc.SetChainName(solana.ChainName)
+				cc.SetRequestType(types.RPC)
+				cc.SetUserInfo(&auraProto.UserWithTokens{User: "user"})
+				cc.SetSubscription(&auraProto.SubscriptionWithPricing{
+					Name:    tt.subscription,
+					Pricing: &auraProto.Pricing{SolanaRpc: &auraProto.PricingModel{RequestsPerSecond: 1}},
+				})
+				cc.SetTokenType(models.TokenTypeFromSubscription(tt.subscription))
+
+				require.NoError(t, handler(cc))
+				codes = append(codes, rec.Code)
+			}
+
+			if tt.limited {
+				assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}, codes)
+			} else {
+				assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK}, codes)
+			}
+		})
+	}
+}
+
+func TestRequestPrepareMiddleware_GPABatch(t *testing.T) {
+	const body = `[{"jsonrpc":"2.0","id":1,"method":"getProgramAccounts","params":["11111111111111111111111111111111"]},{"jsonrpc":"2.0","id":2,"method":"getSlot"}]`
+
+	tests := []struct {
+		name          string
+		allowGPABatch bool
+		expectedCode  int
+	}{
+		{name: "rejected by default", allowGPABatch: false, expectedCode: http.StatusBadRequest},
+		{name: "allowed", allowGPABatch: true, expectedCode: http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newDebugTestProxy(t, "", false)
+			p.allowGPABatch = tt.allowGPABatch
+			var isGPARequest bool
+			e := echo.New()
+			echoUtil.InitBaseMiddlewares(e, nil)
+			e.POST("/", func(c echo.Context) error {
+				isGPARequest = c.(*echoUtil.CustomContext).GetIsGPARequest()
+				return c.NoContent(http.StatusTeapot)
+			}, p.RequestPrepareMiddleware())
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Host = "mainnet-aura.metaplex.com"
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.allowGPABatch {
+				assert.True(t, isGPARequest)
+			} else {
+				assert.Contains(t, rec.Body.String(), "Forbidden to use getProgramAccounts with batch request")
+			}
+		})
+	}
+}
+
+func TestSetServiceHeaders_Credits(t *testing.T) {
+	tests := []struct {
+		name          string
+		creditHeaders bool
+		tiers         []string
+		tokenType     models.TokenType
+		expected      bool
+	}{
+		{name: "disabled", creditHeaders: false, tokenType: models.ProTokenType, expected: false},
+		{name: "all tiers", creditHeaders: true, tokenType: models.BasicTokenType, expected: true},
+		{name: "listed tier", creditHeaders: true, tiers: []string{"pro"}, tokenType: models.ProTokenType, expected: true},
+		{name: "other tier", creditHeaders: true, tiers: []string{"pro"}, tokenType: models.BasicTokenType, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &proxy{creditHeaders: tt.creditHeaders, creditHeadersTiers: tt.tiers}
+			cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+			cc.SetUserInfo(&auraProto.UserWithTokens{User: "user", MplxBalance: 90})
+			cc.SetCreditsUsed(10)
+			cc.SetTokenType(tt.tokenType)
+
+			h := http.Header{}
+			setServiceHeaders(h, cc, p.withCreditHeaders(cc))
+
+			if !tt.expected {
+				assert.Empty(t, h.Get(headerCreditsUsed))
+				assert.Empty(t, h.Get(headerCreditsRemaining))
+				return
+			}
+			assert.Equal(t, "10", h.Get(headerCreditsUsed))
+			assert.Equal(t, "90", h.Get(headerCreditsRemaining))
+		})
+	}
+}
+
+func TestRPCOverGetMiddleware(t *testing.T) {
+	var upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+	}))
+	defer upstream.Close()
+
+	router, err := solanaAdapter.NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: upstream.URL, HandleOther: true}}},
+		},
+	})
+	require.NoError(t, err)
+	adapter, err := solanaAdapter.NewSolanaAdapter(context.Background(), router, &configtypes.ProxyConfig{})
+	require.NoError(t, err)
+	p := &proxy{
+		adapters:       map[string]Adapter{"mainnet-aura.metaplex.com": adapter},
+		deniedMethods:  newMethodDenyList(nil),
+		getMethods:     map[string]struct{}{solana.GetSlot: {}},
+		requestCounter: &testFlushCounter{},
+	}
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.GET("/", p.ProxyGetRouteHandler, p.RPCOverGetMiddleware(), p.RequestPrepareMiddleware())
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "allowed method", query: "method=getSlot", expectedCode: http.StatusOK, expectedBody: `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`},
+		{name: "allowed method with params", query: `method=getSlot&params=[{"commitment":"finalized"}]`, expectedCode: http.StatusOK,
+			expectedBody: `{"jsonrpc":"2.0","id":1,"method":"getSlot","params":[{"commitment":"finalized"}]}`},
+		{name: "invalid params", query: `method=getSlot&params={}`, expectedCode: http.StatusBadRequest},
+		{name: "disallowed method", query: "method=sendTransaction", expectedCode: http.StatusMethodNotAllowed},
+		{name: "no method", expectedCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamBody = ""
+			req := httptest.NewRequest(http.MethodGet, "/?"+url.PathEscape(tt.query), nil)
+			req.Host = "mainnet-aura.metaplex.com"
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.Empty(t, upstreamBody)
+				return
+			}
+			assert.JSONEq(t, tt.expectedBody, upstreamBody)
+			assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":42}`, rec.Body.String())
+		})
+	}
+}
+
+func TestProxyPostRouteHandler_PinnedProvider(t *testing.T) {
+	newUpstream := func(hits *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			*hits++
+			w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+		}))
+	}
+	var hits1, hits2 int
+	upstream1, upstream2 := newUpstream(&hits1), newUpstream(&hits2)
+	defer upstream1.Close()
+	defer upstream2.Close()
+
+	router, err := solanaAdapter.NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{Name: "provider1", Endpoints: []configtypes.EndpointConfig{{URL: upstream1.URL, HandleOther: true}}},
+			{Name: "provider2", Endpoints: []configtypes.EndpointConfig{{URL: upstream2.URL, HandleOther: true}}},
+		},
+	})
+	require.NoError(t, err)
+	adapter, err := solanaAdapter.NewSolanaAdapter(context.Background(), router, &configtypes.ProxyConfig{})
+	require.NoError(t, err)
+	p := &proxy{
+		adapters:         map[string]Adapter{"mainnet-aura.metaplex.com": adapter},
+		deniedMethods:    newMethodDenyList(nil),
+		requestCounter:   &testFlushCounter{},
+		providerPinTiers: []string{string(models.UnlimitedTokenType)},
+	}
+
+	var tokenType models.TokenType
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", p.ProxyPostRouteHandler, p.RequestPrepareMiddleware(), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.(*echoUtil.CustomContext).SetTokenType(tokenType)
+			return next(c)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		tokenType    models.TokenType
+		provider     string
+		expectedCode int
+		expectedHits [2]int
+	}{
+		{name: "privileged token", tokenType: models.UnlimitedTokenType, provider: "provider2", expectedCode: http.StatusOK, expectedHits: [2]int{0, 10}},
+		{name: "privileged token, unknown provider", tokenType: models.UnlimitedTokenType, provider: "unknown", expectedCode: http.StatusBadRequest},
+		{name: "other token, unknown provider", tokenType: models.ProTokenType, provider: "unknown", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits1, hits2 = 0, 0
+			tokenType = tt.tokenType
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
+				req.Host = "mainnet-aura.metaplex.com"
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				req.Header.Set(headerPinnedProvider, tt.provider)
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				require.Equal(t, tt.expectedCode, rec.Code)
+				if tt.expectedCode != http.StatusOK {
+					assert.Contains(t, rec.Body.String(), "Pinned provider can't serve the method")
+				}
+			}
+
+			switch tt.expectedCode {
+			case http.StatusOK:
+				assert.Equal(t, 10, hits1+hits2)
+				if tt.expectedHits != [2]int{} {
+					assert.Equal(t, tt.expectedHits, [2]int{hits1, hits2})
+				}
+			default:
+				assert.Zero(t, hits1+hits2)
+			}
+		})
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	p := &proxy{responseCompression: true, compressionMinLength: 1024}
+	large := `{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("a", 2048) + `"}`
+	small := `{"jsonrpc":"2.0","id":1,"result":42}`
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/large", func(c echo.Context) error { return c.JSONBlob(http.StatusOK, []byte(large)) }, p.CompressMiddleware())
+	e.POST("/small", func(c echo.Context) error { return c.JSONBlob(http.StatusOK, []byte(small)) }, p.CompressMiddleware())
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		websocket      bool
+		expectedBody   string
+		gzipped        bool
+	}{
+		{name: "large response", path: "/large", acceptEncoding: "gzip", expectedBody: large, gzipped: true},
+		{name: "small response", path: "/small", acceptEncoding: "gzip", expectedBody: small},
+		{name: "client without gzip", path: "/large", expectedBody: large},
+		{name: "websocket", path: "/large", acceptEncoding: "gzip", websocket: true, expectedBody: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			}
+			if tt.websocket {
+				req.Header.Set(echo.HeaderUpgrade, "websocket")
+				req.Header.Set(echo.HeaderConnection, "Upgrade")
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			body := rec.Body.Bytes()
+			if tt.gzipped {
+				assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+				reader, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				body, err = io.ReadAll(reader)
+				require.NoError(t, err)
+			} else {
+				assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+			}
+			assert.JSONEq(t, tt.expectedBody, string(body))
+		})
+	}
+}
+
+func TestRequestPrepareMiddleware_ProxyLoop(t *testing.T) {
+	p := newDebugTestProxy(t, "", false)
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", func(c echo.Context) error { return c.NoContent(http.StatusTeapot) }, p.RequestPrepareMiddleware())
+
+	for hops, expectedCode := range map[int]int{0: http.StatusTeapot, transport.MaxProxyHops - 1: http.StatusTeapot, transport.MaxProxyHops: http.StatusLoopDetected} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
+		req.Host = "mainnet-aura.metaplex.com"
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(transport.HeaderProxyHops, strconv.Itoa(hops))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, expectedCode, rec.Code, "hops %d", hops)
+	}
+}
+
+func TestProxyPostRouteHandler_DebugExtension(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if strings.HasPrefix(string(body), "[") {
+			_, _ = w.Write([]byte(`[{"jsonrpc":"2.0","id":1,"result":42},{"jsonrpc":"2.0","id":2,"result":43}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+	}))
+	defer upstream.Close()
+
+	router, err := solanaAdapter.NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: upstream.URL, HandleOther: true}}},
+		},
+	})
+	require.NoError(t, err)
+	adapter, err := solanaAdapter.NewSolanaAdapter(context.Background(), router, &configtypes.ProxyConfig{})
+	require.NoError(t, err)
+	p := &proxy{
+		adapters:             map[string]Adapter{"mainnet-aura.metaplex.com": adapter},
+		deniedMethods:        newMethodDenyList(nil),
+		requestCounter:       &testFlushCounter{},
+		debugExtensionTokens: map[string]struct{}{"debug-token": {}},
+	}
+
+	responseTimeRegexp := regexp.MustCompile(`"responseTimeMs":\d+`)
+	var apiToken string
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", p.ProxyPostRouteHandler, p.RequestPrepareMiddleware(), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.(*echoUtil.CustomContext).SetAPIToken(apiToken)
+			return next(c)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		apiToken     string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "other token",
+			apiToken:     "token",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`,
+			expectedBody: `{"jsonrpc":"2.0","id":1,"result":42}`,
+		},
+		{
+			name:         "debug token",
+			apiToken:     "debug-token",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"getSlot"}`,
+			expectedBody: `{"jsonrpc":"2.0","id":1,"result":42,"_aura":{"attempts":1,"provider":"provider","responseTimeMs":0}}`,
+		},
+		{
+			name:     "debug token, batch",
+			apiToken: "debug-token",
+			body:     `[{"jsonrpc":"2.0","id":1,"method":"getSlot"},{"jsonrpc":"2.0","id":2,"method":"getSlot"}]`,
+			expectedBody: `[{"jsonrpc":"2.0","id":1,"result":42,"_aura":{"attempts":1,"provider":"provider","responseTimeMs":0}},` +
+				`{"jsonrpc":"2.0","id":2,"result":43,"_aura":{"attempts":1,"provider":"provider","responseTimeMs":0}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiToken = tt.apiToken
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Host = "mainnet-aura.metaplex.com"
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			// the response time depends on the upstream, so it's compared separately
+			body := rec.Body.String()
+			if tt.apiToken == "debug-token" {
+				assert.Contains(t, body, `"_aura":{"attempts":1,"provider":"provider","responseTimeMs":`)
+				body = responseTimeRegexp.ReplaceAllString(body, `"responseTimeMs":0`)
+			}
+			assert.JSONEq(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestAddDebugExtension_Errors(t *testing.T) {
+	cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+	cc.SetProxyAttempts(2)
+	cc.SetProvider("provider")
+	cc.SetProxyResponseTime(15)
+
+	single := `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"Invalid param"}}`
+	assert.Equal(t, single, string(addDebugExtension(cc, []byte(single))))
+
+	batch := `[{"jsonrpc":"2.0","id":1,"result":42},{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"Invalid param"}}]`
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":42,"_aura":{"attempts":2,"provider":"provider","responseTimeMs":15}},`+
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"Invalid param"}}]`, string(addDebugExtension(cc, []byte(batch))))
+}
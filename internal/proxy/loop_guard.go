@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"aura-proxy/internal/pkg/configtypes"
+	"aura-proxy/internal/proxy/config"
+)
+
+var errSelfReferencingUpstream = errors.New("upstream points at the proxy itself")
+
+// checkSelfReference fails if an upstream of any chain is served by the proxy: one of its host names,
+// or a loopback address on its port. Requests to it would loop until timeout
+func (p *proxy) checkSelfReference(cfg *config.Config) error { //nolint:gocritic
+	chains := map[string]*configtypes.SolanaConfig{"solana": &cfg.Proxy.Solana, "eclipse": &cfg.Proxy.Eclipse}
+	for chainName := range cfg.Proxy.SolanaChains {
+		chainCfg := cfg.Proxy.SolanaChains[chainName]
+		chains[chainName] = &chainCfg.SolanaConfig
+	}
+
+	for chainName, chainCfg := range chains {
+		for _, upstream := range chainCfg.UpstreamHosts() {
+			if p.isSelf(upstream, cfg.Proxy.Port) {
+				return fmt.Errorf("chain %s: %w: %s", chainName, errSelfReferencingUpstream, upstream)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *proxy) isSelf(upstream string, port uint64) bool {
+	host, upstreamPort, err := net.SplitHostPort(upstream)
+	if err != nil {
+		host, upstreamPort = upstream, ""
+	}
+	for hostName := range p.adapters {
+		if strings.EqualFold(host, hostName) || strings.EqualFold(upstream, hostName) {
+			return true
+		}
+	}
+	if upstreamPort != strconv.FormatUint(port, 10) {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
@@ -4,18 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/patrickmn/go-cache"
-	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/proto"
 
 	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/metrics"
+	"aura-proxy/internal/pkg/models"
 	"aura-proxy/internal/pkg/util"
 	echoUtil "aura-proxy/internal/pkg/util/echo"
 )
@@ -24,6 +26,17 @@ const (
 	subscriptionsListUpdateInterval = 5 * time.Minute
 	userCacheTTL                    = 10 * time.Minute
 	userInfoCacheInterval           = time.Minute
+	// the shared user lookup isn't bound to the request which started it, so it has its own timeout
+	userLookupTimeout = 10 * time.Second
+	// invalid tokens are rejected locally for a while, so they can't be used to hammer the auth backend
+	invalidTokenCacheTTL  = 30 * time.Second
+	invalidTokenCacheSize = 100_000
+)
+
+// token rejection reasons of the metrics
+const (
+	tokenRejectionEmpty   = "empty"
+	tokenRejectionInvalid = "invalid"
 )
 
 var (
@@ -46,12 +59,15 @@ func APITokenCheckerMiddleware(tokenChecker ITokenChecker) echo.MiddlewareFunc {
 			cc.GetMetrics().AddCheckpoint(cp)
 			if err != nil {
 				if errors.Is(err, ErrEmptyAPIToken) {
+					metrics.IncTokenRejections(cc.GetChainName(), tokenRejectionEmpty)
 					return echo.NewHTTPError(http.StatusUnauthorized, ErrEmptyAPIToken.Error())
 				}
 				if errors.Is(err, ErrCreditsExhausted) {
+					metrics.IncCreditsExhausted(cc.GetChainName(), string(cc.GetTokenType()))
 					return echo.NewHTTPError(http.StatusUnauthorized, ErrCreditsExhausted.Error())
 				}
 
+				metrics.IncTokenRejections(cc.GetChainName(), tokenRejectionInvalid)
 				log.Logger.Proxy.Warnf("APITokenCheckerMiddleware: CheckToken (%s): %s", token, err)
 				return util.ErrTokenInvalid
 			}
@@ -68,13 +84,10 @@ func (t *TokenChecker) UserBalanceMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			cc := c.(*echoUtil.CustomContext)
+			// the user is shared with the cache entries of all its tokens. They keep their expiration, so the balance
+			// and the subscription of active users are reconciled with the backend on expiry
 			u := cc.GetUserInfo()
 			u.MplxBalance -= cc.GetCreditsUsed()
-			for _, tkn := range u.GetTokens() {
-				t.userCache.Set(tkn, &auraProto.GetUserInfoResp{
-					User: u,
-				}, userInfoCacheInterval)
-			}
 
 			return next(c)
 		}
@@ -83,24 +96,42 @@ func (t *TokenChecker) UserBalanceMiddleware() echo.MiddlewareFunc {
 
 type TokenChecker struct {
 	userCache          *cache.Cache
-	auraAPI            auraProto.AuraClient
+	invalidTokens      *cache.Cache // bounded by invalidTokenCacheSize
+	authenticator      Authenticator
 	subscriptionList   map[int64]*auraProto.SubscriptionWithPricing
 	subscriptionListMx sync.RWMutex
+	// fraction of the refresh intervals added randomly, so users cached together don't expire together
+	refreshJitter float64
+
+	// concurrent cache misses of a token wait for the first one instead of calling the authenticator
+	userCalls   map[string]*userCall // token
+	userCallsMx sync.Mutex
 }
 
-func NewTokenChecker(ctx context.Context, auraAPI auraProto.AuraClient) (*TokenChecker, error) {
-	if auraAPI == nil {
-		return nil, errors.New("empty auraAPI")
+type userCall struct {
+	done    chan struct{}
+	user    *auraProto.GetUserInfoResp
+	err     error
+	waiters int // fetchUser calls sharing the lookup, guarded by userCallsMx
+}
+
+func NewTokenChecker(ctx context.Context, authenticator Authenticator, refreshJitter float64) (*TokenChecker, error) {
+	if authenticator == nil {
+		return nil, errors.New("empty authenticator")
 	}
 
 	t := &TokenChecker{
 		userCache:          cache.New(userCacheTTL, userCacheTTL),
+		invalidTokens:      cache.New(invalidTokenCacheTTL, invalidTokenCacheTTL),
 		subscriptionList:   make(map[int64]*auraProto.SubscriptionWithPricing),
 		subscriptionListMx: sync.RWMutex{},
-		auraAPI:            auraAPI,
+		authenticator:      authenticator,
+		refreshJitter:      refreshJitter,
+		userCalls:          make(map[string]*userCall),
 	}
 
-	err := util.AsyncRunWithInterval(ctx, nil, subscriptionsListUpdateInterval, true, false, func(ctx context.Context) error {
+	// the interval is jittered once, so proxy instances started together don't refresh together
+	err := util.AsyncRunWithInterval(ctx, nil, t.withJitter(subscriptionsListUpdateInterval), true, false, func(ctx context.Context) error {
 		err := t.updateSubscriptionList(ctx)
 		if err != nil {
 			err = fmt.Errorf("TokenChecker.updateSubscriptionList: %s", err)
@@ -122,12 +153,6 @@ func (t *TokenChecker) CheckToken(cc *echoUtil.CustomContext, token string) (use
 		return userInfo, ErrEmptyAPIToken
 	}
 
-	// validate token
-	_, err = uuid.Parse(token)
-	if err != nil {
-		return userInfo, fmt.Errorf("uuid.Parse(%s): %s", token, err)
-	}
-
 	user, err := t.getUserFromAPICached(cc, token)
 	if err != nil {
 		return userInfo, fmt.Errorf("getUserFromAPICached: %w", err)
@@ -138,32 +163,56 @@ func (t *TokenChecker) CheckToken(cc *echoUtil.CustomContext, token string) (use
 }
 
 func (t *TokenChecker) updateSubscriptionList(ctx context.Context) error {
-	subscriptions, err := t.auraAPI.GetSubscriptions(ctx, new(emptypb.Empty))
+	subscriptions, err := t.authenticator.GetSubscriptions(ctx)
 	if err != nil {
-		return fmt.Errorf("GetSubscriptions: %s", err)
+		return fmt.Errorf("authenticator.GetSubscriptions: %s", err)
 	}
-	convertedSubscriptions := make(map[int64]*auraProto.SubscriptionWithPricing, len(subscriptions.GetSubscriptions()))
-	for _, sub := range subscriptions.GetSubscriptions() {
+	convertedSubscriptions := make(map[int64]*auraProto.SubscriptionWithPricing, len(subscriptions))
+	for _, sub := range subscriptions {
 		convertedSubscriptions[sub.GetId()] = sub
 	}
 
 	t.subscriptionListMx.Lock()
+	previous := t.subscriptionList
 	t.subscriptionList = convertedSubscriptions
 	t.subscriptionListMx.Unlock()
 
+	t.invalidateChangedSubscriptions(previous, convertedSubscriptions)
+
 	return nil
 }
 
+// invalidateChangedSubscriptions drops cached users of changed or removed subscriptions (e.g. a downgraded plan),
+// so their balance and limits are fetched again instead of drifting from the backend
+func (t *TokenChecker) invalidateChangedSubscriptions(previous, current map[int64]*auraProto.SubscriptionWithPricing) {
+	changed := make(map[int64]struct{})
+	for id, sub := range previous {
+		if !proto.Equal(sub, current[id]) {
+			changed[id] = struct{}{}
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	for token, item := range t.userCache.Items() {
+		user, _ := item.Object.(*auraProto.GetUserInfoResp)
+		if _, ok := changed[user.GetUser().GetSubscriptionId()]; ok {
+			t.userCache.Delete(token)
+		}
+	}
+}
+
 func (t *TokenChecker) getUserFromAPICached(cc *echoUtil.CustomContext, token string) (user *auraProto.GetUserInfoResp, err error) {
 	cachedUserInterface, ok := t.userCache.Get(token)
 	user, _ = cachedUserInterface.(*auraProto.GetUserInfoResp)
 	if !ok || (user.GetUser().GetSubscriptionEndsOn() != nil && time.Now().After(user.GetUser().GetSubscriptionEndsOn().AsTime())) {
-		user, err = t.auraAPI.GetUserInfo(cc.Request().Context(), &auraProto.GetUserInfoReq{ApiToken: token})
-		if err != nil {
-			return user, fmt.Errorf("GetUserInfo: %s", err)
+		if cachedErr, invalid := t.invalidTokens.Get(token); invalid {
+			return nil, cachedErr.(error) //nolint:errcheck
 		}
-		for _, tkn := range user.GetUser().GetTokens() {
-			t.userCache.Set(tkn, user, userInfoCacheInterval)
+		user, err = t.fetchUser(cc.Request().Context(), token)
+		if err != nil {
+			return user, err
 		}
 	}
 
@@ -177,6 +226,7 @@ func (t *TokenChecker) getUserFromAPICached(cc *echoUtil.CustomContext, token st
 		log.Logger.Proxy.Errorf("invalid SubscriptionId: %d", user.GetUser().GetSubscriptionId())
 	}
 	cc.SetSubscription(subcription)
+	cc.SetTokenType(models.TokenTypeFromSubscription(subcription.GetName()))
 
 	credits := int64(len(cc.GetReqMethods())) * cc.GetReqCost()
 	if user.GetUser().GetMplxBalance() < credits {
@@ -186,3 +236,61 @@ func (t *TokenChecker) getUserFromAPICached(cc *echoUtil.CustomContext, token st
 
 	return
 }
+
+// fetchUser gets the user from the authenticator and caches it. Concurrent calls for the same token share
+// one lookup, which runs on a detached context, so a canceled request doesn't fail the others waiting for it
+func (t *TokenChecker) fetchUser(ctx context.Context, token string) (*auraProto.GetUserInfoResp, error) {
+	t.userCallsMx.Lock()
+	call, ok := t.userCalls[token]
+	if !ok {
+		call = &userCall{done: make(chan struct{})}
+		t.userCalls[token] = call
+		go t.lookupUser(token, call)
+	}
+	call.waiters++
+	t.userCallsMx.Unlock()
+
+	select {
+	case <-call.done:
+		return call.user, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookupUser runs the shared lookup of fetchUser and caches its result
+func (t *TokenChecker) lookupUser(token string, call *userCall) {
+	defer func() {
+		t.userCallsMx.Lock()
+		delete(t.userCalls, token)
+		t.userCallsMx.Unlock()
+		close(call.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), userLookupTimeout)
+	defer cancel()
+
+	call.user, call.err = t.authenticator.GetUserInfo(ctx, token)
+	if call.err != nil {
+		call.user = nil
+		call.err = fmt.Errorf("authenticator.GetUserInfo: %w", call.err)
+		// backend failures aren't cached, the token may be valid
+		if errors.Is(call.err, ErrUnknownAPIToken) && t.invalidTokens.ItemCount() < invalidTokenCacheSize {
+			t.invalidTokens.Set(token, call.err, cache.DefaultExpiration)
+		}
+		return
+	}
+	ttl := t.withJitter(userInfoCacheInterval)
+	for _, tkn := range call.user.GetUser().GetTokens() {
+		t.userCache.Set(tkn, call.user, ttl)
+	}
+}
+
+// withJitter adds up to refreshJitter of the interval randomly
+func (t *TokenChecker) withJitter(interval time.Duration) time.Duration {
+	if t.refreshJitter <= 0 {
+		return interval
+	}
+
+	return interval + time.Duration(rand.Float64()*t.refreshJitter*float64(interval)) //nolint:gosec
+}
@@ -0,0 +1,343 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/chains/solana"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func TestAPITokenCheckerMiddleware_StaticKeyAuthenticator(t *testing.T) {
+	authenticator := NewStaticKeyAuthenticator(
+		[]*auraProto.UserWithTokens{{User: "user", SubscriptionId: 1, Tokens: []string{"static-key"}, MplxBalance: 100}},
+		[]*auraProto.SubscriptionWithPricing{{Id: 1, Name: "pro"}},
+	)
+	tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0)
+	require.NoError(t, err)
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST(echoUtil.ProxyPathWithToken, func(c echo.Context) error {
+		cc := c.(*echoUtil.CustomContext)
+		assert.Equal(t, "user", cc.GetUserInfo().GetUser())
+		assert.Equal(t, "pro", cc.GetSubscription().GetName())
+		return c.NoContent(http.StatusTeapot)
+	}, APITokenCheckerMiddleware(tokenChecker))
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{name: "known token", token: "static-key", expectedCode: http.StatusTeapot},
+		{name: "unknown token", token: "other-key", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/"+tt.token, nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestAuraAuthenticator_NonUUIDToken(t *testing.T) {
+	_, err := NewAuraAuthenticator(nil)
+	require.Error(t, err)
+
+	// the token format is checked before the aura API is called
+	authenticator := &auraAuthenticator{}
+	_, err = authenticator.GetUserInfo(context.Background(), "static-key")
+	require.ErrorContains(t, err, "uuid.Parse")
+}
+
+type countingAuthenticator struct {
+	Authenticator
+	userInfoCalls atomic.Int64
+	err           error
+}
+
+func (a *countingAuthenticator) GetUserInfo(ctx context.Context, token string) (*auraProto.GetUserInfoResp, error) {
+	a.userInfoCalls.Add(1)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.Authenticator.GetUserInfo(ctx, token)
+}
+
+// noSubscriptionsAuthenticator serves no subscriptions, e.g. for an aura authenticator without a client
+type noSubscriptionsAuthenticator struct {
+	Authenticator
+}
+
+func (a noSubscriptionsAuthenticator) GetSubscriptions(context.Context) ([]*auraProto.SubscriptionWithPricing, error) {
+	return nil, nil
+}
+
+func TestTokenChecker_InvalidTokenCache(t *testing.T) {
+	static := NewStaticKeyAuthenticator([]*auraProto.UserWithTokens{{User: "user", Tokens: []string{"static-key"}}}, nil)
+	tests := []struct {
+		name          string
+		token         string
+		backend       Authenticator
+		backendErr    error
+		expectedCalls int64
+	}{
+		{name: "unknown token", token: "other-key", backend: static, expectedCalls: 1},
+		{name: "malformed aura token", token: "not-a-uuid", backend: noSubscriptionsAuthenticator{&auraAuthenticator{}}, expectedCalls: 1},
+		{name: "backend failure isn't cached", token: "static-key", backend: static, backendErr: errors.New("unavailable"), expectedCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := &countingAuthenticator{
+				Authenticator: tt.backend,
+				err:           tt.backendErr,
+			}
+			tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0)
+			require.NoError(t, err)
+
+			for range 2 {
+				cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+				_, err = tokenChecker.CheckToken(cc, tt.token)
+				require.Error(t, err)
+			}
+			assert.Equal(t, tt.expectedCalls, authenticator.userInfoCalls.Load())
+		})
+	}
+}
+
+type blockingAuthenticator struct {
+	countingAuthenticator
+	release chan struct{}
+}
+
+func (a *blockingAuthenticator) GetUserInfo(ctx context.Context, token string) (*auraProto.GetUserInfoResp, error) {
+	<-a.release
+	return a.countingAuthenticator.GetUserInfo(ctx, token)
+}
+
+func TestTokenChecker_ConcurrentMissesSingleFlight(t *testing.T) {
+	const token = "static-key"
+	authenticator := &blockingAuthenticator{
+		countingAuthenticator: countingAuthenticator{
+			Authenticator: NewStaticKeyAuthenticator([]*auraProto.UserWithTokens{{User: "user", Tokens: []string{token}, MplxBalance: 100}}, nil),
+		},
+		release: make(chan struct{}),
+	}
+	tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0.2)
+	require.NoError(t, err)
+
+	const requests = 20
+	wg := sync.WaitGroup{}
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+			userInfo, err := tokenChecker.CheckToken(cc, token)
+			assert.NoError(t, err)
+			assert.Equal(t, "user", userInfo.GetUser())
+		}()
+	}
+	// let the misses pile up behind the first backend call
+	time.Sleep(50 * time.Millisecond)
+	close(authenticator.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), authenticator.userInfoCalls.Load())
+}
+
+func TestTokenChecker_CanceledFirstMiss(t *testing.T) {
+	const token = "static-key"
+	authenticator := &blockingAuthenticator{
+		countingAuthenticator: countingAuthenticator{
+			Authenticator: NewStaticKeyAuthenticator([]*auraProto.UserWithTokens{{User: "user", Tokens: []string{token}, MplxBalance: 100}}, nil),
+		},
+		release: make(chan struct{}),
+	}
+	tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0)
+	require.NoError(t, err)
+
+	waiters := func() int {
+		tokenChecker.userCallsMx.Lock()
+		defer tokenChecker.userCallsMx.Unlock()
+		if call, ok := tokenChecker.userCalls[token]; ok {
+			return call.waiters
+		}
+		return 0
+	}
+
+	// the request starting the lookup leaves, the lookup goes on for the other waiters
+	ctx, cancel := context.WithCancel(context.Background())
+	firstDone := make(chan error)
+	go func() {
+		_, err := tokenChecker.fetchUser(ctx, token)
+		firstDone <- err
+	}()
+	require.Eventually(t, func() bool { return waiters() == 1 }, time.Second, time.Millisecond)
+
+	secondDone := make(chan error)
+	go func() {
+		user, err := tokenChecker.fetchUser(context.Background(), token)
+		assert.Equal(t, "user", user.GetUser().GetUser())
+		secondDone <- err
+	}()
+	// the second request joins the pending lookup before it's released
+	require.Eventually(t, func() bool { return waiters() == 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-firstDone, context.Canceled)
+	close(authenticator.release)
+	require.NoError(t, <-secondDone)
+	assert.Equal(t, int64(1), authenticator.userInfoCalls.Load())
+}
+
+func TestTokenChecker_WithJitter(t *testing.T) {
+	tokenChecker := &TokenChecker{refreshJitter: 0.2}
+	for range 100 {
+		interval := tokenChecker.withJitter(time.Minute)
+		assert.GreaterOrEqual(t, interval, time.Minute)
+		assert.Less(t, interval, time.Minute+12*time.Second)
+	}
+
+	tokenChecker.refreshJitter = 0
+	assert.Equal(t, time.Minute, tokenChecker.withJitter(time.Minute))
+}
+
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			matched := 0
+			for _, l := range m.GetLabel() {
+				if v, ok := labels[l.GetName()]; ok && v == l.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+func TestAPITokenCheckerMiddleware_RejectionMetrics(t *testing.T) {
+	authenticator := NewStaticKeyAuthenticator(
+		[]*auraProto.UserWithTokens{{User: "user", SubscriptionId: 1, Tokens: []string{"static-key"}}},
+		[]*auraProto.SubscriptionWithPricing{{Id: 1, Name: "pro"}},
+	)
+	tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0)
+	require.NoError(t, err)
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	prepare := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cc := c.(*echoUtil.CustomContext)
+			cc.SetChainName(solana.ChainName)
+			cc.SetReqMethods([]string{solana.GetSlot})
+			return next(c)
+		}
+	}
+	handler := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.POST("/", handler, prepare, APITokenCheckerMiddleware(tokenChecker))
+	e.POST(echoUtil.ProxyPathWithToken, handler, prepare, APITokenCheckerMiddleware(tokenChecker))
+
+	tests := []struct {
+		name   string
+		path   string
+		metric string
+		labels map[string]string
+	}{
+		{name: "credits exhausted", path: "/static-key", metric: "credits_exhausted_total", labels: map[string]string{"chain": solana.ChainName, "tier": "pro"}},
+		{name: "empty token", path: "/", metric: "token_rejections_total", labels: map[string]string{"chain": solana.ChainName, "reason": tokenRejectionEmpty}},
+		{name: "invalid token", path: "/other-key", metric: "token_rejections_total", labels: map[string]string{"chain": solana.ChainName, "reason": tokenRejectionInvalid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := counterValue(t, tt.metric, tt.labels)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, before+1, counterValue(t, tt.metric, tt.labels))
+		})
+	}
+}
+
+func TestTokenChecker_SubscriptionDowngrade(t *testing.T) {
+	const token = "static-key"
+	pro := &auraProto.SubscriptionWithPricing{Id: 1, Name: "pro", Pricing: &auraProto.Pricing{SolanaRpc: &auraProto.PricingModel{RequestsPerSecond: 100}}}
+	authenticator := &countingAuthenticator{
+		Authenticator: NewStaticKeyAuthenticator(
+			[]*auraProto.UserWithTokens{{User: "user", SubscriptionId: 1, Tokens: []string{token}, MplxBalance: 1000}},
+			[]*auraProto.SubscriptionWithPricing{pro},
+		),
+	}
+	tokenChecker, err := NewTokenChecker(context.Background(), authenticator, 0)
+	require.NoError(t, err)
+
+	checkToken := func() *echoUtil.CustomContext {
+		cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+		cc.SetChainName(solana.ChainName)
+		cc.SetRequestType(types.RPC)
+		cc.SetReqMethods([]string{solana.GetSlot})
+		userInfo, err := tokenChecker.CheckToken(cc, token)
+		require.NoError(t, err)
+		cc.SetUserInfo(userInfo)
+		return cc
+	}
+
+	cc := checkToken()
+	require.Equal(t, int64(100), cc.GetLimitForRequest())
+	// the locally charged balance doesn't extend the cache entry
+	_, expiration, ok := tokenChecker.userCache.GetWithExpiration(token)
+	require.True(t, ok)
+	require.NoError(t, tokenChecker.UserBalanceMiddleware()(func(echo.Context) error { return nil })(cc))
+	_, expirationAfterCharge, _ := tokenChecker.userCache.GetWithExpiration(token)
+	assert.Equal(t, expiration, expirationAfterCharge)
+
+	// the plan is downgraded on the backend, the user is fetched again on the next refresh
+	downgraded := &auraProto.SubscriptionWithPricing{Id: 1, Name: "pro", Pricing: &auraProto.Pricing{SolanaRpc: &auraProto.PricingModel{RequestsPerSecond: 10}}}
+	authenticator.Authenticator = NewStaticKeyAuthenticator(
+		[]*auraProto.UserWithTokens{{User: "user", SubscriptionId: 1, Tokens: []string{token}, MplxBalance: 500}},
+		[]*auraProto.SubscriptionWithPricing{downgraded},
+	)
+	require.NoError(t, tokenChecker.updateSubscriptionList(context.Background()))
+
+	cc = checkToken()
+	assert.Equal(t, int64(10), cc.GetLimitForRequest())
+	assert.Equal(t, int64(500), cc.GetUserInfo().GetMplxBalance())
+	assert.Equal(t, int64(2), authenticator.userInfoCalls.Load())
+
+	// unchanged subscriptions keep the cached users
+	require.NoError(t, tokenChecker.updateSubscriptionList(context.Background()))
+	checkToken()
+	assert.Equal(t, int64(2), authenticator.userInfoCalls.Load())
+}
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ErrUnknownAPIToken is wrapped by authenticators for tokens which are invalid rather than failed to be checked,
+// they are cached negatively by TokenChecker
+var ErrUnknownAPIToken = errors.New("unknown API token")
+
+// Authenticator is the token validation backend of TokenChecker
+type Authenticator interface {
+	// GetUserInfo returns the user owning the API token. Invalid tokens must wrap ErrUnknownAPIToken
+	GetUserInfo(ctx context.Context, token string) (*auraProto.GetUserInfoResp, error)
+	// GetSubscriptions returns all subscriptions with their pricing
+	GetSubscriptions(ctx context.Context) ([]*auraProto.SubscriptionWithPricing, error)
+}
+
+// auraAuthenticator validates tokens with the aura API, the default backend
+type auraAuthenticator struct {
+	auraAPI auraProto.AuraClient
+}
+
+func NewAuraAuthenticator(auraAPI auraProto.AuraClient) (Authenticator, error) {
+	if auraAPI == nil {
+		return nil, errors.New("empty auraAPI")
+	}
+
+	return &auraAuthenticator{auraAPI: auraAPI}, nil
+}
+
+func (a *auraAuthenticator) GetUserInfo(ctx context.Context, token string) (*auraProto.GetUserInfoResp, error) {
+	// aura tokens are uuids, others are rejected without a backend call
+	_, err := uuid.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: uuid.Parse(%s): %s", ErrUnknownAPIToken, token, err)
+	}
+
+	user, err := a.auraAPI.GetUserInfo(ctx, &auraProto.GetUserInfoReq{ApiToken: token})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.NotFound, codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied:
+			return nil, fmt.Errorf("%w: GetUserInfo: %s", ErrUnknownAPIToken, err)
+		default:
+			return nil, fmt.Errorf("GetUserInfo: %s", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (a *auraAuthenticator) GetSubscriptions(ctx context.Context) ([]*auraProto.SubscriptionWithPricing, error) {
+	subscriptions, err := a.auraAPI.GetSubscriptions(ctx, new(emptypb.Empty))
+	if err != nil {
+		return nil, fmt.Errorf("GetSubscriptions: %s", err)
+	}
+
+	return subscriptions.GetSubscriptions(), nil
+}
+
+// StaticKeyAuthenticator validates tokens against a fixed set of users, e.g. for tests and local setups
+type StaticKeyAuthenticator struct {
+	users         map[string]*auraProto.UserWithTokens // token
+	subscriptions []*auraProto.SubscriptionWithPricing
+}
+
+func NewStaticKeyAuthenticator(users []*auraProto.UserWithTokens, subscriptions []*auraProto.SubscriptionWithPricing) *StaticKeyAuthenticator {
+	a := &StaticKeyAuthenticator{
+		users:         make(map[string]*auraProto.UserWithTokens),
+		subscriptions: subscriptions,
+	}
+	for _, user := range users {
+		for _, token := range user.GetTokens() {
+			a.users[token] = user
+		}
+	}
+
+	return a
+}
+
+func (a *StaticKeyAuthenticator) GetUserInfo(_ context.Context, token string) (*auraProto.GetUserInfoResp, error) {
+	user, ok := a.users[token]
+	if !ok {
+		return nil, ErrUnknownAPIToken
+	}
+
+	return &auraProto.GetUserInfoResp{User: user}, nil
+}
+
+func (a *StaticKeyAuthenticator) GetSubscriptions(context.Context) ([]*auraProto.SubscriptionWithPricing, error) {
+	return a.subscriptions, nil
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// TierBodyLimits are the max request body sizes in bytes by tier (token type), over the default one
+type TierBodyLimits struct {
+	defaultLimit uint64
+	tiers        map[models.TokenType]uint64
+}
+
+// NewTierBodyLimits creates limits of tiers (case-insensitive), defaultLimit applies to other tiers
+func NewTierBodyLimits(defaultLimit uint64, tiers map[string]uint64) *TierBodyLimits {
+	l := &TierBodyLimits{defaultLimit: defaultLimit, tiers: make(map[models.TokenType]uint64, len(tiers))}
+	for tier, limit := range tiers {
+		l.tiers[models.TokenType(strings.ToLower(tier))] = limit
+	}
+
+	return l
+}
+
+// ServerLimit returns the limit of the server, the largest one of all tiers
+func (l *TierBodyLimits) ServerLimit() uint64 {
+	res := l.defaultLimit
+	for _, limit := range l.tiers {
+		res = max(res, limit)
+	}
+
+	return res
+}
+
+func (l *TierBodyLimits) limit(tokenType models.TokenType) uint64 {
+	if limit, ok := l.tiers[tokenType]; ok {
+		return limit
+	}
+
+	return l.defaultLimit
+}
+
+// BodyLimitMiddleware rejects requests which body (as forwarded upstream) is over the limit of the token tier with 413.
+// The body is read before the token is resolved, so the server limit is the largest one. Nil limits disable the middleware.
+// CustomContext token type must be set before
+func BodyLimitMiddleware(limits *TierBodyLimits, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if limits == nil || skipper(c) {
+				return next(c)
+			}
+			cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+			reqBody := cc.GetReqBody()
+			if reqBody != nil && uint64(reqBody.Size()) > limits.limit(cc.GetTokenType()) { //nolint:gosec
+				cc.SetProxyUserError(true)
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, util.ErrRequestBodyTooLarge)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	limits := NewTierBodyLimits(100, map[string]uint64{"BASIC": 10, "unlimited": 1000})
+	assert.Equal(t, uint64(1000), limits.ServerLimit())
+
+	handler := BodyLimitMiddleware(limits, nil)(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	body := bytes.Repeat([]byte{'a'}, 50)
+
+	tests := []struct {
+		name      string
+		tokenType models.TokenType
+		allowed   bool
+	}{
+		{name: "over the tier limit", tokenType: models.BasicTokenType, allowed: false},
+		{name: "under the tier limit", tokenType: models.UnlimitedTokenType, allowed: true},
+		{name: "under the default limit", tokenType: models.ProTokenType, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+			cc.SetReqBody(body)
+			cc.SetTokenType(tt.tokenType)
+
+			err := handler(cc)
+			if tt.allowed {
+				require.NoError(t, err)
+				return
+			}
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+			assert.Equal(t, util.ErrRequestBodyTooLarge, httpErr.Message)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const defaultRetryAfter = time.Second
+
+// ConcurrencyLimiter caps the number of in-flight requests. Requests above the cap wait in a bounded
+// queue for a free slot; when the queue is full or the wait times out they are rejected immediately.
+type ConcurrencyLimiter struct {
+	slots     chan struct{}
+	queued    atomic.Int64
+	queueSize int64
+	queueWait time.Duration
+}
+
+func NewConcurrencyLimiter(maxInFlight, queueSize uint64, queueWait time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		slots:     make(chan struct{}, maxInFlight),
+		queueSize: int64(queueSize),
+		queueWait: queueWait,
+	}
+}
+
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) bool {
+	// fast path: free slot
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if l.queued.Add(1) > l.queueSize {
+		l.queued.Add(-1)
+		return false
+	}
+	defer l.queued.Add(-1)
+
+	timer := time.NewTimer(l.queueWait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *ConcurrencyLimiter) release() {
+	<-l.slots
+}
+
+// ConcurrencyLimitMiddleware sheds load with 503 and Retry-After once the limiter is saturated.
+// A nil limiter disables the middleware.
+func ConcurrencyLimitMiddleware(limiter *ConcurrencyLimiter, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if limiter == nil || skipper(c) {
+				return next(c)
+			}
+
+			return limitConcurrency(c, limiter, next)
+		}
+	}
+}
+
+// NewRequestTypeLimiters creates concurrency bulkheads by request type name (types.RequestType.String()). 0 disables a limit
+func NewRequestTypeLimiters(maxInFlight map[string]uint64, queueSize uint64, queueWait time.Duration) map[string]*ConcurrencyLimiter {
+	limiters := make(map[string]*ConcurrencyLimiter, len(maxInFlight))
+	for requestType, limit := range maxInFlight {
+		if limit == 0 {
+			continue
+		}
+		limiters[requestType] = NewConcurrencyLimiter(limit, queueSize, queueWait)
+	}
+
+	return limiters
+}
+
+// RequestTypeLimitMiddleware is ConcurrencyLimitMiddleware with a separate limiter by request type (bulkheads), so saturation
+// of one type doesn't starve others. CustomContext request type must be set before. Types without a limiter aren't limited
+func RequestTypeLimitMiddleware(limiters map[string]*ConcurrencyLimiter, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(limiters) == 0 || skipper(c) {
+				return next(c)
+			}
+			cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+			limiter, ok := limiters[cc.GetRequestType().String()]
+			if !ok {
+				return next(c)
+			}
+
+			return limitConcurrency(c, limiter, next)
+		}
+	}
+}
+
+func limitConcurrency(c echo.Context, limiter *ConcurrencyLimiter, next echo.HandlerFunc) error {
+	if !limiter.acquire(c.Request().Context()) {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(defaultRetryAfter.Seconds())))
+		return echo.NewHTTPError(http.StatusServiceUnavailable, util.ErrServerOverloaded)
+	}
+	defer limiter.release()
+
+	return next(c)
+}
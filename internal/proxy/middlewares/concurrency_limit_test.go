@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adm-metaex/aura-api/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func TestConcurrencyLimitMiddleware_Saturation(t *testing.T) {
+	e := echo.New()
+	limiter := NewConcurrencyLimiter(1, 0, 50*time.Millisecond)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := ConcurrencyLimitMiddleware(limiter, nil)(func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+
+	// occupy the only slot
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+		assert.NoError(t, handler(c))
+	}()
+	<-started
+
+	// excess request is rejected without waiting for the slot
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	startTime := time.Now()
+	err := handler(c)
+	require.Error(t, err)
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Less(t, time.Since(startTime), 50*time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	// slot is released
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+	handler = ConcurrencyLimitMiddleware(limiter, nil)(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	require.NoError(t, handler(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestConcurrencyLimitMiddleware_Queue(t *testing.T) {
+	e := echo.New()
+	limiter := NewConcurrencyLimiter(1, 1, time.Second)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	handler := ConcurrencyLimitMiddleware(limiter, nil)(func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+		assert.NoError(t, handler(c))
+	}()
+	<-started
+
+	// queued request is served once the slot is free
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+		assert.NoError(t, handler(c))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Len(t, started, 1)
+}
+
+func TestRequestTypeLimitMiddleware_Bulkheads(t *testing.T) {
+	e := echo.New()
+	limiters := NewRequestTypeLimiters(map[string]uint64{types.GPA.String(): 1, types.RPC.String(): 0}, 0, 50*time.Millisecond)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var (
+		callsMx sync.Mutex
+		calls   []string
+	)
+	served := func(call string) {
+		callsMx.Lock()
+		calls = append(calls, call)
+		callsMx.Unlock()
+	}
+	handler := RequestTypeLimitMiddleware(limiters, nil)(func(c echo.Context) error {
+		requestType := c.(*echoUtil.CustomContext).GetRequestType()
+		if requestType == types.GPA {
+			started <- struct{}{}
+			<-release
+		}
+		served(requestType.String())
+		return c.NoContent(http.StatusOK)
+	})
+	newContext := func(requestType types.RequestType) (*echoUtil.CustomContext, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		c := &echoUtil.CustomContext{Context: e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)}
+		c.SetRequestType(requestType)
+		return c, rec
+	}
+
+	// a slow GPA request saturates its bulkhead
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c, _ := newContext(types.GPA)
+		assert.NoError(t, handler(c))
+	}()
+	<-started
+
+	c, _ := newContext(types.GPA)
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, handler(c), &httpErr)
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.Code)
+
+	// cheap reads are served while the GPA request is still in flight
+	for _, requestType := range []types.RequestType{types.RPC, types.DAS} {
+		c, rec := newContext(requestType)
+		require.NoError(t, handler(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []string{types.RPC.String(), types.DAS.String(), types.GPA.String()}, calls)
+}
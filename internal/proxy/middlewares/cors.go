@@ -45,6 +45,13 @@ type (
 		//
 		// See also: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Access-Control-Allow-Headers
 		AllowHeaders []string `yaml:"allow_headers"`
+
+		// AllowCredentials sets the Access-Control-Allow-Credentials response header, so browsers send and accept
+		// cookies of cross-origin requests. The request origin is returned instead of the '*' wildcard, as
+		// browsers reject credentialed responses allowed for any origin.
+		//
+		// Optional. Default value false.
+		AllowCredentials bool `yaml:"allow_credentials"`
 	}
 )
 
@@ -153,6 +160,12 @@ func CORSWithConfig(config CORSConfig) echo.MiddlewareFunc {
 				return c.NoContent(http.StatusNoContent)
 			}
 
+			if config.AllowCredentials {
+				if allowOrigin == "*" {
+					allowOrigin = origin
+				}
+				res.Header().Set(echo.HeaderAccessControlAllowCredentials, "true")
+			}
 			res.Header().Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
 
 			// Simple request
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,7 +23,9 @@ const (
 	durationThreshold = 31 * time.Second
 )
 
-func NewLoggerMiddleware(saveLog func(s *proto.Stat), isMainnet bool) echo.MiddlewareFunc {
+// NewLoggerMiddleware logs failed requests and saves request stats. Only statsSampleRate fraction of successful
+// requests is saved. Errored requests and requests which used credits are always saved, as stats are used for billing
+func NewLoggerMiddleware(saveLog func(s *proto.Stat), isMainnet bool, statsSampleRate float64) echo.MiddlewareFunc {
 	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		LogStatus:       true,
 		LogMethod:       true,
@@ -40,15 +43,19 @@ func NewLoggerMiddleware(saveLog func(s *proto.Stat), isMainnet bool) echo.Middl
 				v.Status = http.StatusRequestTimeout // because this err code assigned after NewLoggerMiddlewares
 			}
 
-			saveLog(buildStatStruct(cc.GetReqID(), v.Status, v.Latency.Milliseconds(), endpoint,
-				cc.GetProxyAttempts(), cc.GetProxyResponseTime(), cc.GetReqMethod(), cc.GetRPCError(), v.UserAgent,
-				cc.GetStatsAdditionalData(), cc.GetUserInfo().GetUser(), cc.GetChainName(), cc.GetAPIToken(), cc.GetProvider(),
-				v.ResponseSize, cc.GetCreditsUsed(), cc.GetTargetType(), isMainnet, cc.GetUserInfo().SubscriptionId, cc.GetRequestType(), cc.GetReqTime()))
+			isFailed := (v.Error != nil || len(cc.GetRPCErrors()) != 0) && !cc.GetProxyUserError() || v.Status >= http.StatusBadRequest
+			hasErr := v.Error != nil || len(cc.GetRPCErrors()) != 0 || v.Status >= http.StatusBadRequest
+			if hasErr || cc.GetCreditsUsed() > 0 || isSampled(statsSampleRate) {
+				saveLog(buildStatStruct(cc.GetReqID(), v.Status, v.Latency.Milliseconds(), endpoint,
+					cc.GetProxyAttempts(), cc.GetProxyResponseTime(), cc.GetReqMethod(), cc.GetRPCError(), v.UserAgent,
+					cc.GetStatsAdditionalData(), cc.GetUserInfo().GetUser(), cc.GetChainName(), cc.GetAPIToken(), cc.GetProvider(),
+					v.ResponseSize, cc.GetCreditsUsed(), cc.GetTargetType(), isMainnet, cc.GetUserInfo().SubscriptionId, cc.GetRequestType(), cc.GetReqTime()))
+			}
 
 			m := cc.GetMetrics()
 			m.AddCheckpoint(cp)
 			metricsLog := m.String()
-			if (v.Error != nil || len(cc.GetRPCErrors()) != 0) && !cc.GetProxyUserError() || v.Status >= http.StatusBadRequest {
+			if isFailed {
 				log.Logger.Proxy.Errorf("%d %s, id: %s, latency: %d, endpoint: %s, rpc_method: %v, chain: %s, attempts: %d, node_response_time: %dms, "+
 					"rpc_error_code: %v, error: %s, user_err: %t, request_body: %s,  user_agent: %s, path: %s, host: %s, metrics: %s, isWs: %t",
 					v.Status, v.Method, cc.GetReqID(), v.Latency.Milliseconds(), endpoint, cc.GetReqMethods(), cc.GetChainName(), cc.GetProxyAttempts(), cc.GetProxyResponseTime(),
@@ -65,6 +72,11 @@ func NewLoggerMiddleware(saveLog func(s *proto.Stat), isMainnet bool) echo.Middl
 	})
 }
 
+// isSampled reports if the request stats should be saved with the given sample rate
+func isSampled(rate float64) bool {
+	return rate >= 1 || rand.Float64() < rate //nolint:gosec
+}
+
 func buildStatStruct(requestUUID string, statusCode int, latency int64, endpoint string, attempts int, responseTime int64,
 	rpcMethod string, rpcErrorCode int, userAgent, statsAdditionalData, userUID, chainName, token, provider string,
 	responseSizeBytes, methodCost int64, targetType string, isMainnet bool, subscription_id int64, requestType types.RequestType, requestTime int64) *proto.Stat {
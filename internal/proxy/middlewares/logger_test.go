@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func serveLogged(t *testing.T, mw echo.MiddlewareFunc, status int, rpcErrors []int) {
+	t.Helper()
+
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+	c.InitMetrics()
+	c.SetUserInfo(&proto.UserWithTokens{User: "user1"})
+	c.SetRPCErrors(rpcErrors)
+	require.NoError(t, mw(func(c echo.Context) error {
+		return c.NoContent(status)
+	})(c))
+}
+
+func TestLoggerMiddleware_StatsSampling(t *testing.T) {
+	const requests = 10000
+	for _, rate := range []float64{0, 0.3, 1} {
+		var saved int
+		mw := NewLoggerMiddleware(func(*proto.Stat) { saved++ }, true, rate)
+		for i := 0; i < requests; i++ {
+			serveLogged(t, mw, http.StatusOK, nil)
+		}
+
+		assert.InDelta(t, rate*requests, saved, 0.05*requests, "rate %v", rate)
+	}
+}
+
+func TestLoggerMiddleware_StatsErrorsAlwaysSaved(t *testing.T) {
+	var stats []*proto.Stat
+	mw := NewLoggerMiddleware(func(s *proto.Stat) { stats = append(stats, s) }, true, 0)
+
+	serveLogged(t, mw, http.StatusOK, nil)
+	serveLogged(t, mw, http.StatusBadGateway, nil)
+	serveLogged(t, mw, http.StatusOK, []int{-32005})
+
+	require.Len(t, stats, 2)
+	assert.Equal(t, uint32(http.StatusBadGateway), stats[0].GetStatus())
+	assert.Equal(t, uint32(http.StatusOK), stats[1].GetStatus())
+}
+
+func TestLoggerMiddleware_StatsBilledAlwaysSaved(t *testing.T) {
+	var stats []*proto.Stat
+	mw := NewLoggerMiddleware(func(s *proto.Stat) { stats = append(stats, s) }, true, 0)
+
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+	c.InitMetrics()
+	c.SetUserInfo(&proto.UserWithTokens{User: "user1"})
+	c.SetCreditsUsed(10)
+	require.NoError(t, mw(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c))
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(10), stats[0].GetMethodCost())
+}
@@ -0,0 +1,229 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+const redactedValue = "[REDACTED]"
+
+type (
+	// PayloadCaptureConfig bounds the debug capture of request and response bodies
+	PayloadCaptureConfig struct {
+		SampleRate   float64  // fraction of requests captured
+		Tokens       []string // API tokens which requests are always captured
+		MaxBodySize  int      // larger bodies are dropped, as truncated JSON can't be redacted
+		MaxPerSecond int
+		Capacity     int      // the oldest captures are overwritten
+		RedactFields []string // JSON fields which values are replaced at any nesting level
+	}
+
+	// PayloadCapture is a request/response pair captured for reproducing client issues
+	PayloadCapture struct {
+		Time      time.Time `json:"time"`
+		ReqID     string    `json:"reqId"`
+		User      string    `json:"user"`
+		Chain     string    `json:"chain"`
+		Status    int       `json:"status"`
+		Error     string    `json:"error,omitempty"`
+		Request   string    `json:"request"`
+		Response  string    `json:"response"`
+		Truncated bool      `json:"truncated,omitempty"` // a body exceeded MaxBodySize and was dropped
+	}
+
+	// PayloadStore keeps the last captured payloads in memory
+	PayloadStore struct {
+		cfg          PayloadCaptureConfig
+		redactFields map[string]struct{}
+
+		captures    []PayloadCapture // ring buffer
+		next        int
+		window      int64 // unix second of windowCount
+		windowCount int
+		mx          sync.Mutex
+	}
+)
+
+func NewPayloadStore(cfg PayloadCaptureConfig) *PayloadStore { //nolint:gocritic
+	s := &PayloadStore{
+		cfg:          cfg,
+		redactFields: make(map[string]struct{}, len(cfg.RedactFields)),
+		captures:     make([]PayloadCapture, 0, cfg.Capacity),
+	}
+	for _, field := range cfg.RedactFields {
+		s.redactFields[field] = struct{}{}
+	}
+
+	return s
+}
+
+// shouldCapture decides if the request of the token is captured, counting it in the per second limit
+func (s *PayloadStore) shouldCapture(token string, timeNow time.Time) bool {
+	if s.cfg.Capacity <= 0 || (!slices.Contains(s.cfg.Tokens, token) && !isSampled(s.cfg.SampleRate)) {
+		return false
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if window := timeNow.Unix(); window != s.window {
+		s.window, s.windowCount = window, 0
+	}
+	if s.windowCount >= s.cfg.MaxPerSecond {
+		return false
+	}
+	s.windowCount++
+
+	return true
+}
+
+// add redacts and bounds the bodies and stores the capture. respOverflow reports the response exceeded MaxBodySize
+func (s *PayloadStore) add(capture *PayloadCapture, reqBody, respBody []byte, respOverflow bool) {
+	var reqTruncated, respTruncated bool
+	capture.Request, reqTruncated = s.prepareBody(reqBody, false)
+	capture.Response, respTruncated = s.prepareBody(respBody, respOverflow)
+	capture.Truncated = reqTruncated || respTruncated
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if len(s.captures) < s.cfg.Capacity {
+		s.captures = append(s.captures, *capture)
+		return
+	}
+	s.captures[s.next] = *capture
+	s.next = (s.next + 1) % s.cfg.Capacity
+}
+
+func (s *PayloadStore) prepareBody(body []byte, overflow bool) (res string, truncated bool) {
+	if overflow || len(body) > s.cfg.MaxBodySize {
+		return "", true
+	}
+
+	return string(redactJSON(body, s.redactFields)), false
+}
+
+// List returns the captures from the oldest one
+func (s *PayloadStore) List() []PayloadCapture {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return append(slices.Clone(s.captures[s.next:]), s.captures[:s.next]...)
+}
+
+// redactJSON replaces values of the fields at any nesting level. Non-JSON bodies are dropped, as they can't be redacted
+func redactJSON(body []byte, fields map[string]struct{}) []byte {
+	if len(fields) == 0 || len(body) == 0 {
+		return body
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	res, err := json.Marshal(redactValue(value, fields))
+	if err != nil {
+		return nil
+	}
+
+	return res
+}
+
+func redactValue(value interface{}, fields map[string]struct{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if _, ok := fields[key]; ok {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(field, fields)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], fields)
+		}
+	}
+
+	return value
+}
+
+// PayloadCaptureMiddleware captures request and response bodies of sampled requests into the store.
+// Errors of the next handlers are written by the middleware, so rejected requests are captured with their response
+func PayloadCaptureMiddleware(store *PayloadStore, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+			timeNow := time.Now()
+			if skipper(c) || !store.shouldCapture(cc.GetAPIToken(), timeNow) {
+				return next(c)
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer, limit: store.cfg.MaxBodySize}
+			c.Response().Writer = recorder
+			err := next(c)
+			if err != nil {
+				// the error response is written here, so the capture has its status and body.
+				// The error handler skips committed responses, so it isn't written twice
+				c.Error(err)
+			}
+			c.Response().Writer = recorder.ResponseWriter
+
+			capture := PayloadCapture{
+				Time:   timeNow,
+				ReqID:  cc.GetReqID(),
+				User:   cc.GetUserInfo().GetUser(),
+				Chain:  cc.GetChainName(),
+				Status: c.Response().Status,
+			}
+			if err != nil {
+				capture.Error = err.Error()
+			}
+			store.add(&capture, []byte(cc.GetReqBodyString()), recorder.body.Bytes(), recorder.overflow)
+
+			return err
+		}
+	}
+}
+
+// bodyRecorder copies up to limit bytes of the response body
+type bodyRecorder struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(b) > r.limit {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func newCaptureConfig() PayloadCaptureConfig {
+	return PayloadCaptureConfig{MaxBodySize: 1024, MaxPerSecond: 1 << 30, Capacity: 20000}
+}
+
+func serveCaptured(t *testing.T, store *PayloadStore, token, reqBody, respBody string) {
+	t.Helper()
+
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+	c.SetUserInfo(&proto.UserWithTokens{User: "user1"})
+	c.SetAPIToken(token)
+	c.SetReqBody([]byte(reqBody))
+	require.NoError(t, PayloadCaptureMiddleware(store, nil)(func(c echo.Context) error {
+		return c.JSONBlob(http.StatusOK, []byte(respBody))
+	})(c))
+}
+
+func TestPayloadCapture_SampleRate(t *testing.T) {
+	const requests = 10000
+	for _, rate := range []float64{0, 0.3, 1} {
+		cfg := newCaptureConfig()
+		cfg.SampleRate = rate
+		store := NewPayloadStore(cfg)
+		for i := 0; i < requests; i++ {
+			serveCaptured(t, store, "token1", `{"method":"getSlot"}`, `{"result":1}`)
+		}
+
+		assert.InDelta(t, rate*requests, len(store.List()), 0.05*requests, "rate %v", rate)
+	}
+}
+
+func TestPayloadCapture_Tokens(t *testing.T) {
+	cfg := newCaptureConfig()
+	cfg.Tokens = []string{"debugToken"}
+	store := NewPayloadStore(cfg)
+
+	serveCaptured(t, store, "otherToken", `{"method":"getSlot"}`, `{"result":1}`)
+	serveCaptured(t, store, "debugToken", `{"method":"getBalance"}`, `{"result":2}`)
+
+	captures := store.List()
+	require.Len(t, captures, 1)
+	assert.Equal(t, `{"method":"getBalance"}`, captures[0].Request)
+	assert.Equal(t, `{"result":2}`, captures[0].Response)
+	assert.Equal(t, "user1", captures[0].User)
+	assert.Equal(t, http.StatusOK, captures[0].Status)
+}
+
+func TestPayloadCapture_Redaction(t *testing.T) {
+	cfg := newCaptureConfig()
+	cfg.SampleRate = 1
+	cfg.RedactFields = []string{"secretKey", "email"}
+	store := NewPayloadStore(cfg)
+
+	serveCaptured(t, store, "token1",
+		`{"method":"sendTransaction","params":["tx",{"secretKey":"abc","encoding":"base64"}]}`,
+		`{"result":[{"email":"user@example.com","nested":{"secretKey":[1,2]},"lamports":12345678901234567890}]}`)
+	serveCaptured(t, store, "token1", `not json with secretKey`, `{"result":1}`)
+
+	captures := store.List()
+	require.Len(t, captures, 2)
+	assert.JSONEq(t, `{"method":"sendTransaction","params":["tx",{"secretKey":"[REDACTED]","encoding":"base64"}]}`, captures[0].Request)
+	assert.JSONEq(t, `{"result":[{"email":"[REDACTED]","nested":{"secretKey":"[REDACTED]"},"lamports":12345678901234567890}]}`, captures[0].Response)
+	assert.Empty(t, captures[1].Request) // can't be redacted
+}
+
+func TestPayloadCapture_Bounds(t *testing.T) {
+	cfg := newCaptureConfig()
+	cfg.SampleRate = 1
+	cfg.MaxPerSecond = 2
+	cfg.Capacity = 3
+	cfg.MaxBodySize = 32
+	store := NewPayloadStore(cfg)
+
+	// rate per second
+	timeNow := time.Unix(1000, 0)
+	assert.True(t, store.shouldCapture("", timeNow))
+	assert.True(t, store.shouldCapture("", timeNow))
+	assert.False(t, store.shouldCapture("", timeNow))
+	assert.True(t, store.shouldCapture("", timeNow.Add(time.Second)))
+
+	// capacity, the oldest captures are overwritten
+	for i := 0; i < 5; i++ {
+		store.add(&PayloadCapture{ReqID: fmt.Sprint(i)}, nil, nil, false)
+	}
+	captures := store.List()
+	require.Len(t, captures, 3)
+	assert.Equal(t, []string{"2", "3", "4"}, []string{captures[0].ReqID, captures[1].ReqID, captures[2].ReqID})
+
+	// oversized bodies are dropped
+	store = NewPayloadStore(cfg)
+	serveCaptured(t, store, "token1", `{"method":"getBlock"}`, `{"result":"`+strings.Repeat("a", 64)+`"}`)
+	captures = store.List()
+	require.Len(t, captures, 1)
+	assert.Equal(t, `{"method":"getBlock"}`, captures[0].Request)
+	assert.Empty(t, captures[0].Response)
+	assert.True(t, captures[0].Truncated)
+}
+
+func TestPayloadCapture_ErrorResponse(t *testing.T) {
+	cfg := newCaptureConfig()
+	cfg.SampleRate = 1
+	store := NewPayloadStore(cfg)
+
+	rec := httptest.NewRecorder()
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)}
+	c.SetReqBody([]byte(`{"method":"getSlot"}`))
+	err := PayloadCaptureMiddleware(store, nil)(func(echo.Context) error {
+		return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+	})(c)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	captures := store.List()
+	require.Len(t, captures, 1)
+	assert.Equal(t, http.StatusTooManyRequests, captures[0].Status)
+	assert.JSONEq(t, `{"message":"rate limit exceeded"}`, captures[0].Response)
+	assert.Contains(t, captures[0].Error, "rate limit exceeded")
+}
@@ -13,20 +13,15 @@ import (
 )
 
 const (
-	temporaryPremiumUserID   = "bCEdDk8w8zMt8RvEOQwojQR3LRw1" // TODO: remove temp logic
-	vipMaxConnectionsPerHost = 30
-	maxConnectionsPerHost    = 5
-	headerXForwardedFor      = "X-Forwarded-For"
-	headerXRealIP            = "X-Real-Ip"
+	headerXForwardedFor = "X-Forwarded-For"
+	headerXRealIP       = "X-Real-Ip"
 )
 
-func StreamRateLimitMiddleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+// StreamRateLimitMiddleware limits concurrent WebSocket connections per user (per IP for requests without a user)
+func StreamRateLimitMiddleware(limiter *WSRateLimiter, skipper middleware.Skipper) echo.MiddlewareFunc {
 	if skipper == nil {
 		skipper = middleware.DefaultSkipper
 	}
-	limiter := &WSRateLimiter{
-		rateLimitMap: make(map[string]byte),
-	}
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) (err error) {
@@ -34,7 +29,8 @@ func StreamRateLimitMiddleware(skipper middleware.Skipper) echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			uID := c.(*echoUtil.CustomContext).GetUserInfo().GetUser()
+			cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+			uID := cc.GetUserInfo().GetUser()
 			if uID == "" {
 				uID, err = limiter.getRealIP(c.Request())
 				if err != nil {
@@ -42,7 +38,7 @@ func StreamRateLimitMiddleware(skipper middleware.Skipper) echo.MiddlewareFunc {
 				}
 			}
 
-			if !limiter.checkAndIncRateLimits(uID) {
+			if !limiter.checkAndIncRateLimits(uID, limiter.getMaxConnections(cc)) {
 				return echo.ErrTooManyRequests
 			}
 			defer limiter.decHostConnections(uID)
@@ -53,8 +49,29 @@ func StreamRateLimitMiddleware(skipper middleware.Skipper) echo.MiddlewareFunc {
 }
 
 type WSRateLimiter struct {
-	rateLimitMap map[string]byte
-	mutex        sync.Mutex
+	rateLimitMap               map[string]uint64
+	maxConnections             uint64
+	subscriptionMaxConnections map[string]uint64 // by subscription name
+	mutex                      sync.Mutex
+}
+
+func NewWSRateLimiter(maxConnections uint64, subscriptionMaxConnections map[string]uint64) *WSRateLimiter {
+	return &WSRateLimiter{
+		rateLimitMap:               make(map[string]uint64),
+		maxConnections:             maxConnections,
+		subscriptionMaxConnections: subscriptionMaxConnections,
+	}
+}
+
+// getMaxConnections returns the limit of the user subscription, maxConnections for subscriptions without a limit
+func (rl *WSRateLimiter) getMaxConnections(c *echoUtil.CustomContext) uint64 {
+	if subscription := c.GetSubscription(); subscription != nil {
+		if limit, ok := rl.subscriptionMaxConnections[subscription.GetName()]; ok {
+			return limit
+		}
+	}
+
+	return rl.maxConnections
 }
 
 // forked method
@@ -83,15 +100,11 @@ func (*WSRateLimiter) getRealIP(request *http.Request) (string, error) {
 	return host, nil
 }
 
-func (rl *WSRateLimiter) checkAndIncRateLimits(accID string) bool {
+func (rl *WSRateLimiter) checkAndIncRateLimits(accID string, maxConnections uint64) bool {
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
-	if accID == temporaryPremiumUserID {
-		if rl.rateLimitMap[accID] >= vipMaxConnectionsPerHost {
-			return false
-		}
-	} else if rl.rateLimitMap[accID] >= maxConnectionsPerHost {
+	if rl.rateLimitMap[accID] >= maxConnections {
 		return false
 	}
 
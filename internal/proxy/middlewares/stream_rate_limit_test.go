@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func newWSContext(user, subscription string) *echoUtil.CustomContext {
+	c := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())}
+	c.SetUserInfo(&proto.UserWithTokens{User: user})
+	if subscription != "" {
+		c.SetSubscription(&proto.SubscriptionWithPricing{Name: subscription})
+	}
+
+	return c
+}
+
+func TestStreamRateLimitMiddleware_SubscriptionLimits(t *testing.T) {
+	limiter := NewWSRateLimiter(2, map[string]uint64{"pro": 3, "enterprise": 5})
+
+	tests := []struct {
+		name         string
+		user         string
+		subscription string
+		limit        int
+	}{
+		{name: "normal", user: "user1", subscription: "free", limit: 2},
+		{name: "no subscription", user: "user2", limit: 2},
+		{name: "pro", user: "user3", subscription: "pro", limit: 3},
+		{name: "enterprise", user: "user4", subscription: "enterprise", limit: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			started := sync.WaitGroup{}
+			done := sync.WaitGroup{}
+			handler := StreamRateLimitMiddleware(limiter, nil)(func(echo.Context) error {
+				started.Done()
+				<-release
+				return nil
+			})
+
+			// occupy all connections of the user
+			for i := 0; i < tt.limit; i++ {
+				started.Add(1)
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					assert.NoError(t, handler(newWSContext(tt.user, tt.subscription)))
+				}()
+			}
+			started.Wait()
+
+			nonBlocking := StreamRateLimitMiddleware(limiter, nil)(func(echo.Context) error { return nil })
+			require.ErrorIs(t, nonBlocking(newWSContext(tt.user, tt.subscription)), echo.ErrTooManyRequests)
+			require.NoError(t, nonBlocking(newWSContext("other", ""))) // other users aren't affected
+
+			// connections are released
+			close(release)
+			done.Wait()
+			require.NoError(t, nonBlocking(newWSContext(tt.user, tt.subscription)))
+		})
+	}
+}
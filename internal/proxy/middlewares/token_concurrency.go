@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+// TokenConcurrencyLimiter caps the in-flight requests of every API token by its tier (token type), independently of
+// the requests per second. Requests above the cap are rejected immediately, without a queue
+type TokenConcurrencyLimiter struct {
+	defaultLimit uint64
+	tiers        map[models.TokenType]uint64
+
+	mx       sync.Mutex
+	inFlight map[string]uint64 // by API token, tokens without requests are removed
+}
+
+// NewTokenConcurrencyLimiter creates limits of tiers (case-insensitive), defaultLimit applies to other tiers. 0 disables
+// a limit, nil is returned if all limits are disabled
+func NewTokenConcurrencyLimiter(defaultLimit uint64, tiers map[string]uint64) *TokenConcurrencyLimiter {
+	l := &TokenConcurrencyLimiter{
+		defaultLimit: defaultLimit,
+		tiers:        make(map[models.TokenType]uint64, len(tiers)),
+		inFlight:     make(map[string]uint64),
+	}
+	enabled := defaultLimit > 0
+	for tier, limit := range tiers {
+		l.tiers[models.TokenType(strings.ToLower(tier))] = limit
+		enabled = enabled || limit > 0
+	}
+	if !enabled {
+		return nil
+	}
+
+	return l
+}
+
+func (l *TokenConcurrencyLimiter) limit(tokenType models.TokenType) uint64 {
+	if limit, ok := l.tiers[tokenType]; ok {
+		return limit
+	}
+
+	return l.defaultLimit
+}
+
+func (l *TokenConcurrencyLimiter) acquire(token string, tokenType models.TokenType) bool {
+	limit := l.limit(tokenType)
+	if limit == 0 {
+		return true
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if l.inFlight[token] >= limit {
+		return false
+	}
+	l.inFlight[token]++
+
+	return true
+}
+
+func (l *TokenConcurrencyLimiter) release(token string, tokenType models.TokenType) {
+	if l.limit(tokenType) == 0 {
+		return
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if l.inFlight[token] <= 1 {
+		delete(l.inFlight, token)
+		return
+	}
+	l.inFlight[token]--
+}
+
+// TokenConcurrencyLimitMiddleware rejects requests over the in-flight limit of their API token with 429, the slot is
+// released when the request completes. Requests without a token aren't limited. A nil limiter disables the middleware.
+// CustomContext API token and token type must be set before
+func TokenConcurrencyLimitMiddleware(limiter *TokenConcurrencyLimiter, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if limiter == nil || skipper(c) {
+				return next(c)
+			}
+			cc := c.(*echoUtil.CustomContext) //nolint:errcheck
+			token, tokenType := cc.GetAPIToken(), cc.GetTokenType()
+			if token == "" {
+				return next(c)
+			}
+			if !limiter.acquire(token, tokenType) {
+				cc.SetProxyUserError(true)
+				return echo.NewHTTPError(http.StatusTooManyRequests, util.ErrTooManyConcurrentRequests)
+			}
+			defer limiter.release(token, tokenType)
+
+			return next(c)
+		}
+	}
+}
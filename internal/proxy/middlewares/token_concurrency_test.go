@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/models"
+	"aura-proxy/internal/pkg/util"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+)
+
+func TestTokenConcurrencyLimitMiddleware(t *testing.T) {
+	limiter := NewTokenConcurrencyLimiter(2, map[string]uint64{"UNLIMITED": 0})
+	require.NotNil(t, limiter)
+	assert.Nil(t, NewTokenConcurrencyLimiter(0, map[string]uint64{"basic": 0}))
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	handler := TokenConcurrencyLimitMiddleware(limiter, nil)(func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	newContext := func(token string, tokenType models.TokenType) *echoUtil.CustomContext {
+		cc := &echoUtil.CustomContext{Context: echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())}
+		cc.SetAPIToken(token)
+		cc.SetTokenType(tokenType)
+		return cc
+	}
+
+	// the cap of the token is occupied, as well as slots of another token and of a tier without a limit
+	wg := sync.WaitGroup{}
+	for _, cc := range []*echoUtil.CustomContext{
+		newContext("token1", models.BasicTokenType), newContext("token1", models.BasicTokenType),
+		newContext("token2", models.BasicTokenType),
+		newContext("token3", models.UnlimitedTokenType), newContext("token3", models.UnlimitedTokenType), newContext("token3", models.UnlimitedTokenType),
+		newContext("", models.BasicTokenType), newContext("", models.BasicTokenType), newContext("", models.BasicTokenType),
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, handler(cc))
+		}()
+		<-started
+	}
+
+	// overflow of the token is rejected immediately
+	cc := newContext("token1", models.BasicTokenType)
+	err := handler(cc)
+	var httpErr *echo.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+	assert.Equal(t, util.ErrTooManyConcurrentRequests, httpErr.Message)
+	assert.True(t, cc.GetProxyUserError())
+
+	close(release)
+	wg.Wait()
+
+	// slots are released on completion
+	assert.Empty(t, limiter.inFlight)
+	require.NoError(t, handler(newContext("token1", models.BasicTokenType)))
+	<-started
+}
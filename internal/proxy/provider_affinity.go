@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+	"aura-proxy/internal/proxy/chains/solana"
+)
+
+// providerAffinityCookie keeps the provider of a client session, as provider.expiry.signature
+const providerAffinityCookie = "aura_provider"
+
+// providerAffinity issues and verifies signed provider affinity cookies. The signature covers the chain, provider and
+// expiry, so a cookie can't be edited or reused for another chain
+type providerAffinity struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// newProviderAffinity returns nil if the secret is empty
+func newProviderAffinity(secret string, ttl time.Duration) *providerAffinity {
+	if secret == "" {
+		return nil
+	}
+
+	return &providerAffinity{secret: []byte(secret), ttl: ttl}
+}
+
+func (a *providerAffinity) sign(chainName, provider string, expiry int64) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(chainName + "\x00" + provider + "\x00" + strconv.FormatInt(expiry, 10)))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// provider returns the provider of a valid cookie of the request chain, tampered and expired cookies are ignored
+func (a *providerAffinity) provider(cc *echoUtil.CustomContext) (string, bool) {
+	cookie, err := cc.Cookie(providerAffinityCookie)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 { //nolint:mnd
+		return "", false
+	}
+	provider, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return "", false
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(cc.GetChainName(), string(provider), expiry))) {
+		return "", false
+	}
+
+	return string(provider), true
+}
+
+// prefer routes the request to the provider of the session cookie first
+func (a *providerAffinity) prefer(cc *echoUtil.CustomContext) {
+	if a == nil {
+		return
+	}
+	if provider, ok := a.provider(cc); ok {
+		cc.SetPreferredProvider(provider)
+	}
+}
+
+// issue sets the cookie with the provider which served the request, when it isn't the preferred one already.
+// It must be called before the response is committed
+func (a *providerAffinity) issue(cc *echoUtil.CustomContext) {
+	if a == nil {
+		return
+	}
+	provider := cc.GetProvider()
+	if provider == "" || provider == solana.PublicFallbackProvider || provider == cc.GetPreferredProvider() {
+		return
+	}
+
+	expiresAt := time.Now().Add(a.ttl)
+	cc.SetCookie(&http.Cookie{
+		Name:     providerAffinityCookie,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(provider)) + "." + strconv.FormatInt(expiresAt.Unix(), 10) + "." + a.sign(cc.GetChainName(), provider, expiresAt.Unix()),
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode, // sent by dApps on other origins
+	})
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/configtypes"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+	solanaAdapter "aura-proxy/internal/proxy/chains/solana"
+)
+
+func TestProxyPostRouteHandler_ProviderAffinity(t *testing.T) {
+	hits := map[string]int{}
+	failing := map[string]bool{}
+	newUpstream := func(provider string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits[provider]++
+			if failing[provider] {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+		}))
+	}
+	upstream1, upstream2 := newUpstream("provider1"), newUpstream("provider2")
+	defer upstream1.Close()
+	defer upstream2.Close()
+
+	router, err := solanaAdapter.NewMethodBasedRouter(&configtypes.SolanaConfig{
+		Providers: []configtypes.ProviderConfig{
+			{Name: "provider1", Endpoints: []configtypes.EndpointConfig{{URL: upstream1.URL, HandleOther: true}}},
+			{Name: "provider2", Endpoints: []configtypes.EndpointConfig{{URL: upstream2.URL, HandleOther: true}}},
+		},
+	})
+	require.NoError(t, err)
+	adapter, err := solanaAdapter.NewSolanaAdapter(context.Background(), router, &configtypes.ProxyConfig{})
+	require.NoError(t, err)
+	p := &proxy{
+		adapters:         map[string]Adapter{"mainnet-aura.metaplex.com": adapter},
+		deniedMethods:    newMethodDenyList(nil),
+		requestCounter:   &testFlushCounter{},
+		providerAffinity: newProviderAffinity("secret", time.Hour),
+	}
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", p.ProxyPostRouteHandler, p.RequestPrepareMiddleware())
+
+	send := func(cookie *http.Cookie) *http.Cookie {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
+		req.Host = "mainnet-aura.metaplex.com"
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		for _, issued := range rec.Result().Cookies() {
+			if issued.Name == providerAffinityCookie {
+				return issued
+			}
+		}
+		return nil
+	}
+	cookieProvider := func(cookie *http.Cookie) string {
+		provider, err := base64.RawURLEncoding.DecodeString(strings.Split(cookie.Value, ".")[0])
+		require.NoError(t, err)
+		return string(provider)
+	}
+
+	// issued with the provider which served the request
+	cookie := send(nil)
+	require.NotNil(t, cookie)
+	provider := cookieProvider(cookie)
+	require.Equal(t, 1, hits[provider])
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteNoneMode, cookie.SameSite)
+
+	// honored by the next requests, which don't reissue it
+	clear(hits)
+	for range 10 {
+		assert.Nil(t, send(cookie))
+	}
+	assert.Equal(t, map[string]int{provider: 10}, hits)
+
+	// a cookie edited to another provider is ignored and replaced
+	other := map[string]string{"provider1": "provider2", "provider2": "provider1"}[provider]
+	parts := strings.Split(cookie.Value, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(other))
+	tampered := &http.Cookie{Name: providerAffinityCookie, Value: strings.Join(parts, ".")}
+	for range 10 {
+		reissued := send(tampered)
+		require.NotNil(t, reissued, "a tampered cookie isn't honored")
+		assert.NotEqual(t, tampered.Value, reissued.Value)
+	}
+
+	// the request falls back to the other provider after the preferred one fails, and the cookie follows it
+	failing[provider] = true
+	reissued := send(cookie)
+	require.NotNil(t, reissued)
+	assert.Equal(t, other, cookieProvider(reissued))
+}
+
+func TestProviderAffinity_Provider(t *testing.T) {
+	a := newProviderAffinity("secret", time.Hour)
+	require.NotNil(t, a)
+	assert.Nil(t, newProviderAffinity("", time.Hour))
+
+	expiry := time.Now().Add(time.Hour).Unix()
+	newCookie := func(chainName, provider string, expiry int64, secret string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(provider)) + "." + strconv.FormatInt(expiry, 10) + "." +
+			newProviderAffinity(secret, time.Hour).sign(chainName, provider, expiry)
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "valid", value: newCookie("solana", "provider", expiry, "secret"), expected: "provider"},
+		{name: "other chain", value: newCookie("eclipse", "provider", expiry, "secret")},
+		{name: "other secret", value: newCookie("solana", "provider", expiry, "other")},
+		{name: "expired", value: newCookie("solana", "provider", time.Now().Add(-time.Minute).Unix(), "secret")},
+		{name: "malformed", value: "provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.AddCookie(&http.Cookie{Name: providerAffinityCookie, Value: tt.value})
+			cc := &echoUtil.CustomContext{Context: echo.New().NewContext(req, httptest.NewRecorder())}
+			cc.SetChainName("solana")
+
+			provider, ok := a.provider(cc)
+			assert.Equal(t, tt.expected != "", ok)
+			assert.Equal(t, tt.expected, provider)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,8 +18,11 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 
 	"aura-proxy/internal/pkg/collector"
+	"aura-proxy/internal/pkg/configtypes"
 	"aura-proxy/internal/pkg/log"
 	"aura-proxy/internal/pkg/metrics"
+	"aura-proxy/internal/pkg/transport"
+	"aura-proxy/internal/pkg/util"
 	echoUtil "aura-proxy/internal/pkg/util/echo"
 	"aura-proxy/internal/proxy/chains/solana"
 	"aura-proxy/internal/proxy/config"
@@ -30,15 +34,18 @@ const (
 	statusKey             = "status"
 	serviceKey            = "service"
 	serverShutdownTimeout = time.Second * 5
+	shutdownFlushTimeout  = time.Second * 5
 	collectorInterval     = 10 * time.Second
 )
 
 type IRequestCounter interface {
 	IncUserRequests(user *auraProto.UserWithTokens, creditsUsed int64, chain, token, requestType string, isMainnet bool)
+	Flush(ctx context.Context) error
 }
 
 type IStatCollector interface {
 	Add(s *auraProto.Stat)
+	Flush(ctx context.Context) error
 }
 
 type proxy struct {
@@ -49,15 +56,39 @@ type proxy struct {
 	router        *echo.Echo
 	metricsServer *echo.Echo
 
-	statsCollector IStatCollector
-	requestCounter IRequestCounter
-	serviceName    string
-
-	adapters map[string]Adapter // host
-	certData []byte
-
-	proxyPort   uint64
-	metricsPort uint64
+	statsCollector  IStatCollector
+	statsSampleRate float64
+	requestCounter  IRequestCounter
+	serviceName     string
+
+	adapters            map[string]Adapter // host
+	certData            []byte
+	concurrencyLimiter  *middlewares.ConcurrencyLimiter
+	requestTypeLimiters map[string]*middlewares.ConcurrencyLimiter
+	bodyLimits          *middlewares.TierBodyLimits
+	tokenConcurrency    *middlewares.TokenConcurrencyLimiter // nil if disabled
+	wsRateLimiter       *middlewares.WSRateLimiter
+	deniedMethods       *methodDenyList
+	getMethods          map[string]struct{} // served over GET
+	allowGPABatch       bool
+	configVersion       configVersion
+	adminToken          string
+	maskTargetURLs      bool
+	creditHeaders       bool
+	creditHeadersTiers  []string                  // all tiers if empty
+	providerPinTiers    []string                  // tiers allowed to pin a provider, disabled if empty
+	providerAffinity    *providerAffinity         // nil if affinity cookies are disabled
+	payloadStore        *middlewares.PayloadStore // nil if the debug capture is disabled
+	// API tokens which responses get the _aura extension field
+	debugExtensionTokens map[string]struct{}
+
+	responseCompression  bool
+	compressionMinLength int
+
+	proxyPort        uint64
+	metricsPort      uint64
+	metricsNamespace string // prefix of metric names, empty if not configured
+	requestTimeout   time.Duration
 
 	isMainnet bool
 }
@@ -86,35 +117,91 @@ func NewProxy(cfg config.Config) (p *proxy, err error) { //nolint:gocritic
 	if err != nil {
 		return nil, fmt.Errorf("auraAPI NewClient: %s", err)
 	}
-	statCollector, err := collector.NewCollector[*auraProto.Stat](ctx, collectorInterval, auraAPI)
+	statCollector, err := collector.NewCollector[*auraProto.Stat](ctx, collectorInterval, cfg.Proxy.StatsFlushWorkers, cfg.Proxy.StatsFlushQueueSize, auraAPI)
 	if err != nil {
 		return nil, fmt.Errorf("NewCollector: %s", err)
 	}
 
 	// load token to CustomContext. CustomContext must be inited before
-	tokenChecker, err := middlewares.NewTokenChecker(ctx, auraAPI)
+	authenticator, err := middlewares.NewAuraAuthenticator(auraAPI)
+	if err != nil {
+		return nil, fmt.Errorf("NewAuraAuthenticator: %s", err)
+	}
+	tokenChecker, err := middlewares.NewTokenChecker(ctx, authenticator, cfg.Proxy.TokenRefreshJitter)
 	if err != nil {
 		return nil, fmt.Errorf("NewTokenChecker: %s", err)
 	}
 	wg := &sync.WaitGroup{}
-	requestCounter := NewRequestCounter(ctx, wg, auraAPI)
+	requestCounter := NewRequestCounter(ctx, wg, auraAPI, cfg.Proxy.RequestCounterMaxUsers)
 	return InitProxy(ctx, cancelFunc, cfg, wg, statCollector, requestCounter, tokenChecker)
 }
 
 func InitProxy(ctx context.Context, cancel context.CancelFunc, cfg config.Config, wg *sync.WaitGroup, statCollector IStatCollector, requestCounter IRequestCounter, tokenChecker ITokenChecker) (p *proxy, err error) {
+	metricsServer, err := initMetricsServer(cfg.Proxy.MetricsNamespace, cfg.Proxy.WriteTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("initMetricsServer: %s", err)
+	}
 	p = &proxy{
-		proxyPort:      cfg.Proxy.Port,
-		metricsPort:    cfg.Proxy.MetricsPort,
-		metricsServer:  initMetricsServer(),
-		waitGroup:      wg,
-		ctx:            ctx,
-		ctxCancel:      cancel,
-		statsCollector: statCollector,
-		serviceName:    fmt.Sprintf("%s-%s", cfg.Service.Name, cfg.Service.Level),
-		requestCounter: requestCounter,
-		adapters:       make(map[string]Adapter),
-		isMainnet:      cfg.Proxy.IsMainnet,
+		proxyPort:            cfg.Proxy.Port,
+		metricsPort:          cfg.Proxy.MetricsPort,
+		metricsServer:        metricsServer,
+		metricsNamespace:     cfg.Proxy.MetricsNamespace,
+		requestTimeout:       cfg.Proxy.RequestTimeout,
+		waitGroup:            wg,
+		ctx:                  ctx,
+		ctxCancel:            cancel,
+		statsCollector:       statCollector,
+		statsSampleRate:      cfg.Proxy.StatsSampleRate,
+		serviceName:          fmt.Sprintf("%s-%s", cfg.Service.Name, cfg.Service.Level),
+		requestCounter:       requestCounter,
+		adapters:             make(map[string]Adapter),
+		isMainnet:            cfg.Proxy.IsMainnet,
+		deniedMethods:        newMethodDenyList(cfg.Proxy.DeniedMethods),
+		getMethods:           make(map[string]struct{}, len(cfg.Proxy.GetMethods)),
+		allowGPABatch:        cfg.Proxy.AllowGPABatchRequests,
+		adminToken:           cfg.Proxy.AdminToken,
+		maskTargetURLs:       cfg.Proxy.DebugMaskTargetURLs,
+		creditHeaders:        cfg.Proxy.CreditHeaders,
+		creditHeadersTiers:   util.Map(cfg.Proxy.CreditHeadersTiers, strings.ToLower),
+		providerPinTiers:     util.Map(cfg.Proxy.ProviderPinTiers, strings.ToLower),
+		providerAffinity:     newProviderAffinity(cfg.Proxy.ProviderAffinitySecret, cfg.Proxy.ProviderAffinityTTL),
+		responseCompression:  cfg.Proxy.ResponseCompression,
+		compressionMinLength: int(cfg.Proxy.ResponseCompressionMinLength), //nolint:gosec
+		wsRateLimiter:        middlewares.NewWSRateLimiter(cfg.Proxy.WSMaxConnections, cfg.Proxy.WSSubscriptionMaxConnections),
+	}
+	for _, method := range cfg.Proxy.GetMethods {
+		p.getMethods[method] = struct{}{}
 	}
+	if len(cfg.Proxy.DebugResponseExtensionTokens) != 0 {
+		p.debugExtensionTokens = make(map[string]struct{}, len(cfg.Proxy.DebugResponseExtensionTokens))
+		for _, token := range cfg.Proxy.DebugResponseExtensionTokens {
+			p.debugExtensionTokens[token] = struct{}{}
+		}
+	}
+	transport.SetExposeUpstreamRateLimit(cfg.Proxy.UpstreamRateLimitHeader)
+	echoUtil.SetDefaultReqCost(int64(cfg.Proxy.DefaultRequestCost)) //nolint:gosec
+	if cfg.Proxy.UpstreamUserAgent != "" {
+		transport.SetUserAgent(cfg.Proxy.UpstreamUserAgent)
+	} else {
+		transport.SetUserAgent(transport.DefaultUserAgent(p.serviceName))
+	}
+	if cfg.Proxy.AdminToken != "" && (cfg.Proxy.DebugCaptureSampleRate > 0 || len(cfg.Proxy.DebugCaptureTokens) != 0) {
+		p.payloadStore = middlewares.NewPayloadStore(middlewares.PayloadCaptureConfig{
+			SampleRate:   cfg.Proxy.DebugCaptureSampleRate,
+			Tokens:       cfg.Proxy.DebugCaptureTokens,
+			MaxBodySize:  int(cfg.Proxy.DebugCaptureMaxBodySize),  //nolint:gosec
+			MaxPerSecond: int(cfg.Proxy.DebugCaptureMaxPerSecond), //nolint:gosec
+			Capacity:     int(cfg.Proxy.DebugCaptureCapacity),     //nolint:gosec
+			RedactFields: cfg.Proxy.DebugCaptureRedactFields,
+		})
+	}
+	if cfg.Proxy.MaxConcurrentRequests > 0 {
+		p.concurrencyLimiter = middlewares.NewConcurrencyLimiter(cfg.Proxy.MaxConcurrentRequests, cfg.Proxy.RequestQueueSize, cfg.Proxy.RequestQueueTimeout)
+	}
+	p.bodyLimits = middlewares.NewTierBodyLimits(cfg.Proxy.RequestBodyLimit, cfg.Proxy.TierRequestBodyLimits)
+	transport.SetMaxDecompressedBodySize(p.bodyLimits.ServerLimit())
+	p.tokenConcurrency = middlewares.NewTokenConcurrencyLimiter(cfg.Proxy.TokenMaxConcurrentRequests, cfg.Proxy.TierTokenMaxConcurrentRequests)
+	p.requestTypeLimiters = middlewares.NewRequestTypeLimiters(cfg.Proxy.RequestTypeMaxConcurrentRequests, cfg.Proxy.RequestQueueSize, cfg.Proxy.RequestQueueTimeout)
 	if cfg.Proxy.CertFile != "" {
 		p.certData, err = os.ReadFile(cfg.Proxy.CertFile)
 		if err != nil {
@@ -126,45 +213,84 @@ func InitProxy(ctx context.Context, cancel context.CancelFunc, cfg config.Config
 	if err != nil {
 		return nil, fmt.Errorf("initAdapters: %s", err)
 	}
+	err = p.configVersion.update(&cfg.Proxy.Solana, &cfg.Proxy.Eclipse, cfg.Proxy.SolanaChains)
+	if err != nil {
+		return nil, fmt.Errorf("configVersion: %s", err)
+	}
 	p.initProxyServer()
 
 	p.initProxyHandlers(tokenChecker)
+	p.initAdminHandlers()
 	return p, nil
 }
 
 func (p *proxy) initAdapters(cfg *config.Config) error { //nolint:gocritic
 	// Conditionally initialize SolanaAdapter.
-	if len(cfg.Proxy.Solana.BasicRouteNodes) > 0 || len(cfg.Proxy.Solana.WSHostNodes) > 0 || len(cfg.Proxy.Solana.DasAPINodes) > 0 || len(cfg.Proxy.Solana.Providers) > 0 {
+	if hasNodes(&cfg.Proxy.Solana) || len(cfg.Proxy.Solana.WSHostNodes) > 0 {
 		// Create a method router
 		methodRouter, err := solana.NewMethodBasedRouter(&cfg.Proxy.Solana)
 		if err != nil {
 			return fmt.Errorf("creating method router: %w", err)
 		}
-		solanaAdapter, err := solana.NewSolanaAdapter(methodRouter, cfg.Proxy.IsMainnet)
+		solanaAdapter, err := solana.NewSolanaAdapter(p.ctx, methodRouter, &cfg.Proxy)
 		if err != nil {
 			return fmt.Errorf("NewSolanaAdapter: %s", err)
 		}
-		for _, n := range solanaAdapter.GetHostNames() {
-			p.adapters[n] = solanaAdapter
+		if err = p.addAdapter(solanaAdapter); err != nil {
+			return err
 		}
 	}
 
 	// Conditionally initialize EclipseAdapter.
-	if len(cfg.Proxy.Eclipse.DasAPINodes) > 0 || len(cfg.Proxy.Eclipse.BasicRouteNodes) > 0 || len(cfg.Proxy.Eclipse.Providers) > 0 {
+	if hasNodes(&cfg.Proxy.Eclipse) {
 		// Create a method router
 		methodRouter, err := solana.NewMethodBasedRouter(&cfg.Proxy.Eclipse)
 		if err != nil {
 			return fmt.Errorf("creating method router: %w", err)
 		}
-		eclipseAdapter, err := solana.NewEclipseAdapter(methodRouter, cfg.Proxy.IsMainnet)
+		eclipseAdapter, err := solana.NewEclipseAdapter(p.ctx, methodRouter, &cfg.Proxy)
 		if err != nil {
 			return fmt.Errorf("NewEclipseAdapter: %s", err)
 		}
-		for _, n := range eclipseAdapter.GetHostNames() {
-			p.adapters[n] = eclipseAdapter
+		if err = p.addAdapter(eclipseAdapter); err != nil {
+			return err
+		}
+	}
+
+	for chainName, chainCfg := range cfg.Proxy.SolanaChains {
+		methodRouter, err := solana.NewMethodBasedRouter(&chainCfg.SolanaConfig)
+		if err != nil {
+			return fmt.Errorf("chain %s: creating method router: %w", chainName, err)
+		}
+		isMainnet := cfg.Proxy.IsMainnet
+		if chainCfg.IsMainnet != nil {
+			isMainnet = *chainCfg.IsMainnet
+		}
+		chainAdapter, err := solana.NewChainAdapter(p.ctx, methodRouter, &cfg.Proxy, chainName, chainCfg.HostNames, isMainnet)
+		if err != nil {
+			return fmt.Errorf("chain %s: NewChainAdapter: %s", chainName, err)
+		}
+		if err = p.addAdapter(chainAdapter); err != nil {
+			return err
 		}
 	}
 
+	return p.checkSelfReference(cfg)
+}
+
+func hasNodes(cfg *configtypes.SolanaConfig) bool {
+	return len(cfg.DasAPINodes) > 0 || len(cfg.BasicRouteNodes) > 0 || len(cfg.GPANodes) > 0 || len(cfg.Providers) > 0
+}
+
+// addAdapter registers the adapter for its hosts. A host can be served by a single chain only
+func (p *proxy) addAdapter(adapter *solana.Adapter) error {
+	for _, n := range adapter.GetHostNames() {
+		if existing, ok := p.adapters[n]; ok {
+			return fmt.Errorf("host %s of chain %s is already served by chain %s", n, adapter.GetName(), existing.GetName())
+		}
+		p.adapters[n] = adapter
+	}
+
 	return nil
 }
 
@@ -172,21 +298,33 @@ func (p *proxy) initProxyServer() {
 	s := echo.New()
 	echoUtil.SetupServer(s, true)
 
-	echoUtil.InitBaseMiddlewares(s, middlewares.CORSWithConfig(middlewares.CORSConfig{
+	bodyLimit := uint64(echoUtil.DefaultBodyLimit)
+	if p.bodyLimits != nil {
+		bodyLimit = p.bodyLimits.ServerLimit()
+	}
+	echoUtil.InitBaseMiddlewaresWithBodyLimit(s, middlewares.CORSWithConfig(middlewares.CORSConfig{
 		// forked cors middleware
 		AllowOrigins: []string{"*"},
-	}))
+		// affinity cookies are sent by browsers with credentialed requests only
+		AllowCredentials: p.providerAffinity != nil,
+	}), bodyLimit)
 
 	// temp. Profile middleware
 	pprof.Register(s, "/pprof/d877cb77-e163-4542-9401-017dea48be76")
 
-	s.Use(echoprometheus.NewMiddleware("aura"))
+	s.Use(echoprometheus.NewMiddlewareWithConfig(echoprometheus.MiddlewareConfig{Namespace: p.metricsNamespace, Subsystem: metrics.HTTPSubsystem}))
 	p.router = s
 }
 
-func initMetricsServer() *echo.Echo {
+// initMetricsServer creates the metrics server. Proxy metrics are exposed under the namespace, if set
+func initMetricsServer(namespace string, writeTimeout time.Duration) (*echo.Echo, error) {
+	gatherer, err := metrics.NewGatherer(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("NewGatherer: %s", err)
+	}
+
 	s := echo.New()
-	echoUtil.SetupServer(s, false)
+	echoUtil.SetupServerWithWriteTimeout(s, writeTimeout)
 	s.HideBanner = true
 
 	s.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
@@ -204,10 +342,10 @@ func initMetricsServer() *echo.Echo {
 			return nil
 		},
 	}))
-	s.GET("/metrics", echoprometheus.NewHandler())
+	s.GET("/metrics", echoprometheus.NewHandlerWithConfig(echoprometheus.HandlerConfig{Gatherer: gatherer}))
 	metrics.InitStartTime()
 
-	return s
+	return s, nil
 }
 
 func (p *proxy) Run() (err error) {
@@ -246,6 +384,16 @@ func (p *proxy) Stop() error {
 	if err != nil {
 		log.Logger.Proxy.Errorf("router.Shutdown: %s", err)
 	}
+
+	// the router is stopped, send buffered stats and usage before the background loops are cancelled
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer flushCancel()
+	if err = p.statsCollector.Flush(flushCtx); err != nil {
+		log.Logger.Proxy.Errorf("statsCollector.Flush: %s", err)
+	}
+	if err = p.requestCounter.Flush(flushCtx); err != nil {
+		log.Logger.Proxy.Errorf("requestCounter.Flush: %s", err)
+	}
 	p.ctxCancel()
 
 	return nil
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aura-proxy/internal/pkg/configtypes"
+	echoUtil "aura-proxy/internal/pkg/util/echo"
+	"aura-proxy/internal/proxy/config"
+)
+
+type flushRecorder struct {
+	proxyCtx    context.Context
+	flushed     bool
+	ctxCanceled bool
+	hasDeadline bool
+}
+
+func (f *flushRecorder) Flush(ctx context.Context) error {
+	f.flushed = true
+	f.ctxCanceled = f.proxyCtx.Err() != nil
+	_, f.hasDeadline = ctx.Deadline()
+
+	return nil
+}
+
+type testFlushCollector struct{ flushRecorder }
+
+func (*testFlushCollector) Add(*auraProto.Stat) {}
+
+type testFlushCounter struct{ flushRecorder }
+
+func (*testFlushCounter) IncUserRequests(*auraProto.UserWithTokens, int64, string, string, string, bool) {
+}
+
+func TestProxy_StopFlushes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	collector, counter := &testFlushCollector{flushRecorder{proxyCtx: ctx}}, &testFlushCounter{flushRecorder{proxyCtx: ctx}}
+	p := &proxy{
+		ctx:            ctx,
+		ctxCancel:      cancel,
+		router:         echo.New(),
+		metricsServer:  echo.New(),
+		statsCollector: collector,
+		requestCounter: counter,
+	}
+
+	require.NoError(t, p.Stop())
+
+	for name, f := range map[string]*flushRecorder{"collector": &collector.flushRecorder, "counter": &counter.flushRecorder} {
+		assert.True(t, f.flushed, name)
+		assert.False(t, f.ctxCanceled, "%s is flushed before the background loops are cancelled", name)
+		assert.True(t, f.hasDeadline, name)
+	}
+	assert.Error(t, ctx.Err())
+}
+
+func TestInitAdapters_SolanaChains(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+	}))
+	defer upstream.Close()
+
+	isMainnet := false
+	chainCfg := configtypes.SolanaChainConfig{
+		SolanaConfig: configtypes.SolanaConfig{
+			HostNames: []string{"sonic.test"},
+			Providers: []configtypes.ProviderConfig{
+				{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: upstream.URL, HandleOther: true}}},
+			},
+		},
+		IsMainnet: &isMainnet,
+	}
+	cfg := &config.Config{Proxy: configtypes.ProxyConfig{
+		IsMainnet:    true,
+		SolanaChains: configtypes.SolanaChains{"sonic": chainCfg},
+	}}
+
+	p := &proxy{
+		adapters:       make(map[string]Adapter),
+		deniedMethods:  newMethodDenyList(nil),
+		requestCounter: &testFlushCounter{},
+	}
+	require.NoError(t, p.initAdapters(cfg))
+	require.Contains(t, p.adapters, "sonic.test")
+	assert.Equal(t, "sonic", p.adapters["sonic.test"].GetName())
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", p.ProxyPostRouteHandler, p.RequestPrepareMiddleware())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
+	req.Host = "sonic.test"
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":42}`, rec.Body.String())
+
+	// a host can't be served by two chains
+	cfg.Proxy.SolanaChains["sonic-copy"] = chainCfg
+	p.adapters = make(map[string]Adapter)
+	assert.ErrorContains(t, p.initAdapters(cfg), "host sonic.test")
+}
+
+func TestInitAdapters_SolanaHostNames(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":42}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Proxy: configtypes.ProxyConfig{
+		IsMainnet: true,
+		Solana: configtypes.SolanaConfig{
+			HostNames: []string{"aura-mainnet.metaplex.com", "aura-staging.example.com"},
+			Providers: []configtypes.ProviderConfig{
+				{Name: "provider", Endpoints: []configtypes.EndpointConfig{{URL: upstream.URL, HandleOther: true}}},
+			},
+		},
+	}}
+	p := &proxy{
+		adapters:       make(map[string]Adapter),
+		deniedMethods:  newMethodDenyList(nil),
+		requestCounter: &testFlushCounter{},
+	}
+	require.NoError(t, p.initAdapters(cfg))
+	assert.Len(t, p.adapters, 2, "configured hosts replace the built-in ones")
+
+	e := echo.New()
+	echoUtil.InitBaseMiddlewares(e, nil)
+	e.POST("/", p.ProxyPostRouteHandler, p.RequestPrepareMiddleware())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
+	req.Host = "aura-staging.example.com"
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":42}`, rec.Body.String())
+
+	// a configured host of another chain is rejected by the config validation
+	cfg.Proxy.Port = 2011
+	cfg.Proxy.SolanaChains = configtypes.SolanaChains{"sonic": {SolanaConfig: configtypes.SolanaConfig{
+		HostNames: []string{"aura-staging.example.com"},
+		Providers: cfg.Proxy.Solana.Providers,
+	}}}
+	assert.ErrorIs(t, cfg.Proxy.Validate(nil), configtypes.ErrDuplicateHostName)
+}
+
+func TestInitAdapters_SelfReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint configtypes.EndpointConfig
+		wantErr  bool
+	}{
+		{name: "own host name", endpoint: configtypes.EndpointConfig{URL: "https://mainnet-aura.metaplex.com/", HandleOther: true}, wantErr: true},
+		{name: "own listen address", endpoint: configtypes.EndpointConfig{URL: "http://127.0.0.1:2011", HandleOther: true}, wantErr: true},
+		{name: "own host header", endpoint: configtypes.EndpointConfig{URL: "https://10.0.0.1", HostHeader: "mainnet-aura.metaplex.com", HandleOther: true}, wantErr: true},
+		{name: "local node on another port", endpoint: configtypes.EndpointConfig{URL: "http://localhost:8899", HandleOther: true}},
+		{name: "remote node", endpoint: configtypes.EndpointConfig{URL: "https://node.example.com", HandleOther: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Proxy: configtypes.ProxyConfig{
+				Port:      2011,
+				IsMainnet: true,
+				Solana: configtypes.SolanaConfig{
+					Providers: []configtypes.ProviderConfig{{Name: "provider", Endpoints: []configtypes.EndpointConfig{tt.endpoint}}},
+				},
+			}}
+			p := &proxy{adapters: make(map[string]Adapter)}
+
+			err := p.initAdapters(cfg)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errSelfReferencingUpstream)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
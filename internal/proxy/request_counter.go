@@ -2,30 +2,60 @@ package proxy
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
 
 	"aura-proxy/internal/pkg/log"
+	"aura-proxy/internal/pkg/metrics"
 	"aura-proxy/internal/pkg/util"
 )
 
-const flushInterval = time.Second * 30
+const (
+	flushInterval = time.Second * 30
 
-type RequestCounter struct {
-	wg       *sync.WaitGroup
-	auraAPI  auraProto.AuraClient
-	counters map[string]map[string]map[string]map[string]*auraProto.RequestsWithUsage
-	mx       sync.Mutex
-}
+	flushMaxAttempts    = 10
+	flushInitialBackoff = 100 * time.Millisecond
+	flushMaxBackoff     = 5 * time.Second // all attempts fit into flushInterval
+
+	// a failed forced flush isn't retried before the cooldown, doubled after each failure up to flushInterval
+	forceFlushCooldown = flushMaxBackoff
+)
+
+type (
+	userCounters = map[string]map[string]map[string]map[string]*auraProto.RequestsWithUsage // user/chain/requestType/token/reqCount
+
+	// RequestCounter accumulates user usage and reports it to aura-api by interval.
+	// Memory is bounded by the number of users: reaching maxUsers forces a flush, the oldest users
+	// are evicted over 2*maxUsers while it's running and over maxUsers after it failed, until its cooldown ends
+	RequestCounter struct {
+		wg       *sync.WaitGroup
+		auraAPI  auraProto.AuraClient
+		counters userCounters
+		order    []string // users in the order of their first request since the last flush
+		mx       sync.Mutex
+
+		maxUsers        int
+		forceFlushing   atomic.Bool
+		forceFlushAfter time.Time     // forced flushes wait for the cooldown after a failure, guarded by mx
+		cooldown        time.Duration // of the last failed forced flush, reset by a successful flush
+		initialBackoff  time.Duration // doubled after each failed flush attempt up to flushMaxBackoff
+	}
+)
 
-func NewRequestCounter(ctx context.Context, wg *sync.WaitGroup, auraAPI auraProto.AuraClient) (r *RequestCounter) {
+func NewRequestCounter(ctx context.Context, wg *sync.WaitGroup, auraAPI auraProto.AuraClient, maxUsers uint64) (r *RequestCounter) {
 	r = &RequestCounter{
 		wg:       wg,
-		counters: make(map[string]map[string]map[string]map[string]*auraProto.RequestsWithUsage), // providerID/chain/requestType/token/reqCount
+		counters: make(userCounters),
 		mx:       sync.Mutex{},
 		auraAPI:  auraAPI,
+
+		maxUsers:       int(maxUsers), //nolint:gosec
+		initialBackoff: flushInitialBackoff,
 	}
 
 	// err not emitted
@@ -52,8 +82,72 @@ func (r *RequestCounter) IncUserRequests(user *auraProto.UserWithTokens, credits
 	}
 
 	r.mx.Lock()
+	isNewUser := r.counters[userID] == nil
+	coolingDown := time.Now().Before(r.forceFlushAfter)
+	if isNewUser && r.maxUsers > 0 {
+		switch {
+		case len(r.counters) >= 2*r.maxUsers:
+			r.evictOldest(2*r.maxUsers - 1) // forced flush is too slow
+		case coolingDown && len(r.counters) >= r.maxUsers:
+			r.evictOldest(r.maxUsers - 1) // forced flush failed, the backend isn't hammered until the cooldown ends
+		}
+	}
+	counter := r.getCounter(userID, chain, requestType, token, isMainnet)
+	counter.Reqs++
+	counter.Usage += creditsUsed
+	isFull := r.maxUsers > 0 && len(r.counters) >= r.maxUsers && !coolingDown
+	r.mx.Unlock()
+
+	if isNewUser && isFull {
+		r.forceFlush()
+	}
+}
+
+// forceFlush flushes counters in background, unless a forced flush is already running
+func (r *RequestCounter) forceFlush() {
+	if !r.forceFlushing.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer r.forceFlushing.Store(false)
+		if err := r.flush(); err != nil {
+			r.mx.Lock()
+			r.cooldown = min(max(2*r.cooldown, forceFlushCooldown), flushInterval)
+			r.forceFlushAfter = time.Now().Add(r.cooldown)
+			r.mx.Unlock()
+			log.Logger.Proxy.Errorf("RequestCounter: forced flush: %s", err)
+		}
+	}()
+}
+
+// evictOldest drops the oldest users until maxLen users left. Must be called under the lock
+func (r *RequestCounter) evictOldest(maxLen int) {
+	var droppedReqs int64
+	for len(r.counters) > maxLen && len(r.order) != 0 {
+		userID := r.order[0]
+		r.order = r.order[1:]
+		for _, requestTypes := range r.counters[userID] {
+			for _, tokens := range requestTypes {
+				for _, count := range tokens {
+					droppedReqs += count.GetReqs()
+				}
+			}
+		}
+		delete(r.counters, userID)
+	}
+
+	if droppedReqs != 0 {
+		metrics.AddDroppedUserRequests(droppedReqs)
+		log.Logger.Proxy.Errorf("RequestCounter: dropped %d requests of the oldest users over the %d users limit", droppedReqs, maxLen)
+	}
+}
+
+// getCounter returns the counter, creating it if needed. Must be called under the lock
+func (r *RequestCounter) getCounter(userID, chain, requestType, token string, isMainnet bool) *auraProto.RequestsWithUsage {
 	if r.counters[userID] == nil {
 		r.counters[userID] = make(map[string]map[string]map[string]*auraProto.RequestsWithUsage)
+		r.order = append(r.order, userID)
 	}
 	if r.counters[userID][chain] == nil {
 		r.counters[userID][chain] = make(map[string]map[string]*auraProto.RequestsWithUsage)
@@ -66,15 +160,24 @@ func (r *RequestCounter) IncUserRequests(user *auraProto.UserWithTokens, credits
 			IsMainnet: isMainnet,
 		}
 	}
-	r.counters[userID][chain][requestType][token].Reqs++
-	r.counters[userID][chain][requestType][token].Usage += creditsUsed
-	r.mx.Unlock()
+
+	return r.counters[userID][chain][requestType][token]
+}
+
+// Flush sends counters with ctx deadline, it's called on shutdown
+func (r *RequestCounter) Flush(ctx context.Context) error {
+	return r.flushWithContext(ctx)
 }
 
-func (r *RequestCounter) flush() (err error) {
+func (r *RequestCounter) flush() error {
+	// context background used for prevent query cancellation
+	return r.flushWithContext(context.Background())
+}
+
+func (r *RequestCounter) flushWithContext(ctx context.Context) (err error) {
 	r.mx.Lock()
-	counters := r.counters
-	r.counters = make(map[string]map[string]map[string]map[string]*auraProto.RequestsWithUsage)
+	counters, order := r.counters, r.order
+	r.counters, r.order = make(userCounters), nil
 	r.mx.Unlock()
 
 	if len(counters) == 0 {
@@ -83,22 +186,68 @@ func (r *RequestCounter) flush() (err error) {
 
 	timeNow := time.Now()
 	protoStruct := mapCountersToProto(counters)
-	for i := 0; i < 10; i++ {
-		// context background used for prevent query cancellation
-		_, err = r.auraAPI.IncreaseUserRequests(context.Background(), protoStruct)
+	backoff := r.initialBackoff
+	for i := 0; i < flushMaxAttempts; i++ {
+		if i != 0 {
+			select {
+			case <-ctx.Done():
+				r.merge(counters, order)
+				return fmt.Errorf("%s (last error: %s)", ctx.Err(), err)
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, flushMaxBackoff)
+		}
+
+		_, err = r.auraAPI.IncreaseUserRequests(ctx, protoStruct)
 		if err != nil {
 			log.Logger.Proxy.Errorf("RequestCounter.flush (attempt %d): IncreaseUserRequests: %s", i, err)
 			continue
 		}
 
+		r.mx.Lock()
+		r.cooldown, r.forceFlushAfter = 0, time.Time{}
+		r.mx.Unlock()
+
 		log.Logger.Proxy.Debugf("RequestCounter flushed %d items. Elapsed time %s", len(counters), time.Since(timeNow))
 		return nil
 	}
 
+	// keep usage for the next flush
+	r.merge(counters, order)
+
 	return err
 }
 
-func mapCountersToProto(counters map[string]map[string]map[string]map[string]*auraProto.RequestsWithUsage) *auraProto.IncreaseUserRequestsReq {
+// merge adds counters of a failed flush back. They are older than the current ones, so they are evicted first over maxUsers
+func (r *RequestCounter) merge(counters userCounters, order []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	current := r.order
+	for _, userID := range order {
+		for chain, requestTypes := range counters[userID] {
+			for requestType, tokens := range requestTypes {
+				for token, count := range tokens {
+					counter := r.getCounter(userID, chain, requestType, token, count.IsMainnet)
+					counter.Reqs += count.Reqs
+					counter.Usage += count.Usage
+				}
+			}
+		}
+	}
+	r.order = slices.Clone(order)
+	for _, userID := range current {
+		if counters[userID] == nil {
+			r.order = append(r.order, userID)
+		}
+	}
+
+	if r.maxUsers > 0 {
+		r.evictOldest(r.maxUsers)
+	}
+}
+
+func mapCountersToProto(counters userCounters) *auraProto.IncreaseUserRequestsReq {
 	protoReq := &auraProto.IncreaseUserRequestsReq{
 		Reqs: make(map[string]*auraProto.UserRequestsByChain),
 	}
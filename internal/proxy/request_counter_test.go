@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	auraProto "github.com/adm-metaex/aura-api/pkg/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// flakyAuraClient fails IncreaseUserRequests failures times, then records the requests
+type flakyAuraClient struct {
+	auraProto.AuraClient
+	mx       sync.Mutex
+	failures int
+	calls    []time.Time
+	reqs     []*auraProto.IncreaseUserRequestsReq
+}
+
+func (f *flakyAuraClient) IncreaseUserRequests(_ context.Context, in *auraProto.IncreaseUserRequestsReq, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.calls = append(f.calls, time.Now())
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("unavailable")
+	}
+	f.reqs = append(f.reqs, in)
+
+	return &emptypb.Empty{}, nil
+}
+
+func newTestRequestCounter(client auraProto.AuraClient) *RequestCounter {
+	return &RequestCounter{
+		auraAPI:        client,
+		counters:       make(userCounters),
+		initialBackoff: time.Millisecond,
+	}
+}
+
+func sentUsage(reqs []*auraProto.IncreaseUserRequestsReq, user string) (count, usage int64) {
+	for _, req := range reqs {
+		c := req.GetReqs()[user].GetReqs()["solana"].GetReqs()["http"].GetReqs()["token"]
+		count += c.GetReqs()
+		usage += c.GetUsage()
+	}
+
+	return count, usage
+}
+
+func TestRequestCounter_FlushTransientFailure(t *testing.T) {
+	client := &flakyAuraClient{failures: 3}
+	r := newTestRequestCounter(client)
+	user := &auraProto.UserWithTokens{User: "user1"}
+	r.IncUserRequests(user, 5, "solana", "token", "http", true)
+	r.IncUserRequests(user, 7, "solana", "token", "http", true)
+
+	require.NoError(t, r.flush())
+
+	require.Len(t, client.calls, 4)
+	for i := 1; i < len(client.calls); i++ { // exponential backoff between attempts
+		assert.GreaterOrEqual(t, client.calls[i].Sub(client.calls[i-1]), r.initialBackoff<<(i-1), "attempt %d", i)
+	}
+	count, usage := sentUsage(client.reqs, "user1")
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(12), usage)
+	assert.Empty(t, r.counters)
+}
+
+func TestRequestCounter_FlushPersistentFailure(t *testing.T) {
+	client := &flakyAuraClient{failures: flushMaxAttempts}
+	r := newTestRequestCounter(client)
+	user := &auraProto.UserWithTokens{User: "user1"}
+	r.IncUserRequests(user, 5, "solana", "token", "http", true)
+
+	require.Error(t, r.flush())
+	assert.Len(t, client.calls, flushMaxAttempts)
+
+	// counters are kept and merged with new ones
+	r.IncUserRequests(user, 7, "solana", "token", "http", true)
+	require.NoError(t, r.flush())
+
+	count, usage := sentUsage(client.reqs, "user1")
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(12), usage)
+	assert.True(t, client.reqs[0].GetReqs()["user1"].GetReqs()["solana"].GetReqs()["http"].GetReqs()["token"].GetIsMainnet())
+	assert.Empty(t, r.counters)
+}
+
+func TestRequestCounter_Flush(t *testing.T) {
+	client := &flakyAuraClient{}
+	r := newTestRequestCounter(client)
+	r.IncUserRequests(&auraProto.UserWithTokens{User: "user1"}, 5, "solana", "token", "http", true)
+
+	require.NoError(t, r.Flush(context.Background()))
+	count, usage := sentUsage(client.reqs, "user1")
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, int64(5), usage)
+	assert.Empty(t, r.counters)
+}
+
+func TestRequestCounter_FlushDeadline(t *testing.T) {
+	client := &flakyAuraClient{failures: flushMaxAttempts}
+	r := newTestRequestCounter(client)
+	r.initialBackoff = time.Hour
+	r.IncUserRequests(&auraProto.UserWithTokens{User: "user1"}, 5, "solana", "token", "http", true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.Flush(ctx)
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+
+	// the backoff is interrupted by the deadline and counters are kept
+	assert.Len(t, client.calls, 1)
+	assert.Contains(t, r.counters, "user1")
+}
+
+func droppedUserRequests(t *testing.T) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == "dropped_user_requests_total" && len(f.GetMetric()) != 0 {
+			return f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
+
+func incUsers(r *RequestCounter, from, to int) {
+	for i := from; i < to; i++ {
+		r.IncUserRequests(&auraProto.UserWithTokens{User: fmt.Sprintf("user%d", i)}, 1, "solana", "token", "http", true)
+	}
+}
+
+func TestRequestCounter_ForcedFlush(t *testing.T) {
+	client := &flakyAuraClient{}
+	r := newTestRequestCounter(client)
+	r.maxUsers = 3
+
+	incUsers(r, 0, 2)
+	time.Sleep(10 * time.Millisecond)
+	client.mx.Lock()
+	assert.Empty(t, client.calls, "flushed below the limit")
+	client.mx.Unlock()
+
+	incUsers(r, 2, 3)
+	require.Eventually(t, func() bool {
+		client.mx.Lock()
+		defer client.mx.Unlock()
+		return len(client.reqs) == 1
+	}, time.Second, time.Millisecond)
+	client.mx.Lock()
+	assert.Len(t, client.reqs[0].GetReqs(), 3)
+	client.mx.Unlock()
+
+	r.mx.Lock()
+	assert.Empty(t, r.counters)
+	assert.Empty(t, r.order)
+	r.mx.Unlock()
+}
+
+func TestRequestCounter_ForcedFlushCooldown(t *testing.T) {
+	client := &flakyAuraClient{failures: flushMaxAttempts}
+	r := newTestRequestCounter(client)
+	r.initialBackoff = time.Microsecond
+	r.maxUsers = 2
+	before := droppedUserRequests(t)
+
+	incUsers(r, 0, 2)
+	require.Eventually(t, func() bool {
+		r.mx.Lock()
+		defer r.mx.Unlock()
+		return !r.forceFlushAfter.IsZero()
+	}, time.Second, time.Millisecond, "forced flush failed")
+	assert.Equal(t, forceFlushCooldown, r.cooldown)
+
+	// new users evict the oldest ones instead of forcing a flush during the cooldown
+	incUsers(r, 2, 4)
+	time.Sleep(10 * time.Millisecond)
+	client.mx.Lock()
+	assert.Len(t, client.calls, flushMaxAttempts)
+	client.mx.Unlock()
+	r.mx.Lock()
+	assert.Equal(t, []string{"user2", "user3"}, r.order)
+	r.mx.Unlock()
+	assert.InDelta(t, 2, droppedUserRequests(t)-before, 0)
+
+	// the cooldown is over, the flush succeeds and resets it
+	r.mx.Lock()
+	r.forceFlushAfter = time.Now()
+	r.mx.Unlock()
+	incUsers(r, 4, 5)
+	require.Eventually(t, func() bool {
+		client.mx.Lock()
+		defer client.mx.Unlock()
+		return len(client.reqs) == 1
+	}, time.Second, time.Millisecond)
+	client.mx.Lock()
+	assert.Len(t, client.reqs[0].GetReqs(), 3)
+	client.mx.Unlock()
+	r.mx.Lock()
+	assert.Zero(t, r.cooldown)
+	r.mx.Unlock()
+}
+
+func TestRequestCounter_EvictOldest(t *testing.T) {
+	r := newTestRequestCounter(&flakyAuraClient{})
+	r.maxUsers = 2
+	r.forceFlushing.Store(true) // forced flush is stuck
+	before := droppedUserRequests(t)
+
+	incUsers(r, 0, 4)
+	r.IncUserRequests(&auraProto.UserWithTokens{User: "user0"}, 1, "solana", "token", "http", true)
+	assert.Len(t, r.counters, 4)
+
+	incUsers(r, 4, 5)
+	assert.Len(t, r.counters, 4)
+	assert.NotContains(t, r.counters, "user0")
+	assert.Contains(t, r.counters, "user4")
+	assert.Equal(t, []string{"user1", "user2", "user3", "user4"}, r.order)
+	assert.InDelta(t, 2, droppedUserRequests(t)-before, 0)
+}
+
+func TestRequestCounter_MergeLimit(t *testing.T) {
+	r := newTestRequestCounter(&flakyAuraClient{})
+	r.maxUsers = 3
+	r.forceFlushing.Store(true)
+	incUsers(r, 0, 2)
+	before := droppedUserRequests(t)
+
+	failed := userCounters{
+		"user0":    {"solana": {"http": {"token": {Reqs: 1, Usage: 1}}}}, // known user is merged
+		"oldUser1": {"solana": {"http": {"token": {Reqs: 1, Usage: 1}}}},
+		"oldUser2": {"solana": {"http": {"token": {Reqs: 2, Usage: 1}}}},
+	}
+	r.merge(failed, []string{"oldUser2", "user0", "oldUser1"})
+
+	// failed users are older, so they are evicted first
+	assert.Len(t, r.counters, 3)
+	assert.Equal(t, []string{"user0", "oldUser1", "user1"}, r.order)
+	assert.Equal(t, int64(2), r.counters["user0"]["solana"]["http"]["token"].GetReqs())
+	assert.NotContains(t, r.counters, "oldUser2")
+	assert.InDelta(t, 2, droppedUserRequests(t)-before, 0)
+}